@@ -0,0 +1,502 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package admin provides an http.Handler for inspecting and changing a live HashRing, so an
+operator can add, remove or drain a node in a running process without a redeploy:
+
+	GET    /nodes               members with their state, in ring order
+	POST   /nodes               add a node, body {"id": "cache-4", "addr": "10.0.0.4:11211"}
+	DELETE /nodes/{id}          remove a node
+	PUT    /nodes/{id}/state    set a node's state, body {"state": "draining"}
+	GET    /lookup?key=...      owner of a key
+	GET    /ranges?replicas=N   ranges of the ring with up to N owners each
+	GET    /stats               epoch, content hash, node counts and keyspace shares
+	GET    /events              live dashboard state as Server-Sent Events
+	GET    /dashboard/          single-page dashboard drawing the ring from /events
+
+Every response carries the ring epoch in the ETag header. Mutations are epoch-checked: a
+request with an If-Match header is only applied if the ring is still at that epoch and
+gets 412 Precondition Failed otherwise, so two operators cannot overwrite each other's
+change; If-Match: * applies the change at whatever epoch the ring is at. Reads that keep
+seeing the ring change get 503 Service Unavailable. Errors are JSON objects of the form {"error": "...", "epoch": N}.
+*/
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// readAttempts is how often a snapshot is read again when the ring changes while it is read
+const readAttempts = 5
+
+// Global error variables which has all error types to return
+var (
+	ErrBadRequest   = errors.New("Bad request")
+	ErrRingChanging = errors.New("Ring kept changing while being read")
+)
+
+/*
+Node is the CacheNode the handler creates for POST /nodes unless SetNodeFactory says
+otherwise.
+*/
+type Node struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+// Address makes the node's address show up in GET /nodes
+func (n *Node) Address() string {
+	return n.Addr
+}
+
+/*
+Addresser is implemented by nodes that know their network address. GET /nodes reports
+the address of every node implementing it.
+*/
+type Addresser interface {
+	Address() string
+}
+
+type handlerConfig struct {
+	NodeFactory     func(id, addr string) hashring.CacheNode
+	Metrics         *hashring.PrometheusMetrics
+	RefreshInterval time.Duration
+}
+
+// HandlerConfigFn is a function type that modifies the handlerConfig
+type HandlerConfigFn func(*handlerConfig)
+
+/*
+SetNodeFactory returns a HandlerConfigFn that sets how the CacheNode for POST /nodes is
+built from the id and address in the request. The default builds *Node. Use it when the
+rest of the process expects its own node type, such as *cachenode.Node.
+*/
+func SetNodeFactory(factory func(id, addr string) hashring.CacheNode) HandlerConfigFn {
+	return func(config *handlerConfig) {
+		config.NodeFactory = factory
+	}
+}
+
+/*
+Handler serves the admin API for one ring. Mount it under a prefix with
+http.StripPrefix, and behind authentication: it can remove every node. Fields:
+  - ring: Ring being inspected and changed
+  - config: Node factory and dashboard settings
+  - mux: Routes of the API
+  - hub: Source of the dashboard's live updates
+*/
+type Handler struct {
+	ring   *hashring.HashRing
+	config handlerConfig
+	mux    *http.ServeMux
+	hub    *hub
+}
+
+// HandlerInit creates a Handler serving ring
+func HandlerInit(ring *hashring.HashRing, opts ...HandlerConfigFn) *Handler {
+	config := &handlerConfig{
+		NodeFactory: func(id, addr string) hashring.CacheNode {
+			return &Node{ID: id, Addr: addr}
+		},
+		RefreshInterval: time.Second,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	h := &Handler{
+		ring:   ring,
+		config: *config,
+		mux:    http.NewServeMux(),
+		hub:    newHub(ring, config.Metrics, config.RefreshInterval),
+	}
+	h.mux.HandleFunc("GET /nodes", h.listNodes)
+	h.mux.HandleFunc("POST /nodes", h.addNode)
+	h.mux.HandleFunc("DELETE /nodes/{id}", h.removeNode)
+	h.mux.HandleFunc("PUT /nodes/{id}/state", h.setState)
+	h.mux.HandleFunc("GET /lookup", h.lookup)
+	h.mux.HandleFunc("GET /ranges", h.ranges)
+	h.mux.HandleFunc("GET /stats", h.stats)
+	h.mux.HandleFunc("GET /events", h.events)
+	h.mux.HandleFunc("GET /dashboard", dashboardRedirect)
+	h.mux.Handle("GET /dashboard/", h.dashboard())
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// NodeInfo describes one member in the responses of the API
+type NodeInfo struct {
+	ID    string             `json:"id"`
+	Addr  string             `json:"addr,omitempty"`
+	Zone  string             `json:"zone,omitempty"`
+	State hashring.NodeState `json:"state"`
+}
+
+func nodeInfo(node hashring.CacheNode, state hashring.NodeState) NodeInfo {
+	info := NodeInfo{ID: node.GetIdentifier(), State: state}
+	if a, ok := node.(Addresser); ok {
+		info.Addr = a.Address()
+	}
+	if z, ok := node.(hashring.ZonedNode); ok {
+		info.Zone = z.GetZone()
+	}
+	return info
+}
+
+// NodesResponse is the body of GET /nodes
+type NodesResponse struct {
+	Epoch uint64     `json:"epoch"`
+	Nodes []NodeInfo `json:"nodes"`
+}
+
+func (h *Handler) listNodes(w http.ResponseWriter, r *http.Request) {
+	members, epoch := h.ring.Members()
+	response := NodesResponse{Epoch: epoch, Nodes: make([]NodeInfo, 0, len(members))}
+	for _, member := range members {
+		response.Nodes = append(response.Nodes, nodeInfo(member.Node, member.State))
+	}
+	h.writeJSON(w, http.StatusOK, epoch, response)
+}
+
+// MutationResponse is the body of a successful POST, DELETE or PUT
+type MutationResponse struct {
+	Epoch uint64   `json:"epoch"`
+	Node  NodeInfo `json:"node"`
+}
+
+// addRequest is the body of POST /nodes
+type addRequest struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+}
+
+func (h *Handler) addNode(w http.ResponseWriter, r *http.Request) {
+	var body addRequest
+	if err := decode(r, &body); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	if body.ID == "" {
+		h.writeError(w, fmt.Errorf("%w: id is required", ErrBadRequest))
+		return
+	}
+
+	node := h.config.NodeFactory(body.ID, body.Addr)
+	epoch, err := h.apply(r, func(expected uint64) (uint64, error) {
+		return h.ring.CompareAndApply(expected, []hashring.Change{{Op: hashring.MembershipAdd, Node: node}})
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusCreated, epoch, MutationResponse{Epoch: epoch, Node: nodeInfo(node, hashring.StateActive)})
+}
+
+func (h *Handler) removeNode(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	node, state, err := h.member(id)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+
+	epoch, err := h.apply(r, func(expected uint64) (uint64, error) {
+		return h.ring.CompareAndApply(expected, []hashring.Change{{Op: hashring.MembershipRemove, Node: node}})
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, epoch, MutationResponse{Epoch: epoch, Node: nodeInfo(node, state)})
+}
+
+// stateRequest is the body of PUT /nodes/{id}/state
+type stateRequest struct {
+	State hashring.NodeState `json:"state"`
+}
+
+func (h *Handler) setState(w http.ResponseWriter, r *http.Request) {
+	var body stateRequest
+	if err := decode(r, &body); err != nil {
+		h.writeError(w, err)
+		return
+	}
+	id := r.PathValue("id")
+
+	epoch, err := h.apply(r, func(expected uint64) (uint64, error) {
+		return h.ring.CompareAndSetNodeState(expected, id, body.State)
+	})
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	node, state, err := h.member(id)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, epoch, MutationResponse{Epoch: epoch, Node: nodeInfo(node, state)})
+}
+
+/*
+apply runs an epoch-checked change. With an If-Match header the change must apply at that
+epoch; without one, or with If-Match: * which matches any epoch, it is applied at the epoch
+read just before, so it still cannot race with another change between the read and the
+write.
+*/
+func (h *Handler) apply(r *http.Request, change func(expected uint64) (uint64, error)) (uint64, error) {
+	// Record the change for the dashboard's event list even when no dashboard is open
+	defer h.hub.observe()
+
+	match := strings.TrimSpace(r.Header.Get("If-Match"))
+	if match == "" || match == "*" {
+		return change(h.ring.Epoch())
+	}
+	expected, err := strconv.ParseUint(strings.Trim(match, `"`), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: If-Match must be a ring epoch, got %q", ErrBadRequest, match)
+	}
+	return change(expected)
+}
+
+// member returns the node with the given identifier and its state
+func (h *Handler) member(id string) (hashring.CacheNode, hashring.NodeState, error) {
+	members, _ := h.ring.Members()
+	for _, member := range members {
+		if member.Node.GetIdentifier() == id {
+			return member.Node, member.State, nil
+		}
+	}
+	return nil, "", fmt.Errorf("%w: %s", hashring.ErrNodeNotFound, id)
+}
+
+// LookupResponse is the body of GET /lookup
+type LookupResponse struct {
+	Key     string   `json:"key"`
+	KeyHash uint64   `json:"key_hash"`
+	Node    NodeInfo `json:"node"`
+	Epoch   uint64   `json:"epoch"`
+}
+
+func (h *Handler) lookup(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	if key == "" {
+		h.writeError(w, fmt.Errorf("%w: key is required", ErrBadRequest))
+		return
+	}
+	result, err := h.ring.Lookup(key)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	keyHash, err := h.ring.KeyHash(key)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	state, _ := h.ring.NodeState(result.Node.GetIdentifier())
+	h.writeJSON(w, http.StatusOK, result.Epoch, LookupResponse{
+		Key:     key,
+		KeyHash: keyHash,
+		Node:    nodeInfo(result.Node, state),
+		Epoch:   result.Epoch,
+	})
+}
+
+// RangeInfo describes one range in GET /ranges
+type RangeInfo struct {
+	Start uint64   `json:"start"`
+	End   uint64   `json:"end"`
+	Share float64  `json:"share"`
+	Nodes []string `json:"nodes"`
+}
+
+// RangesResponse is the body of GET /ranges
+type RangesResponse struct {
+	Epoch  uint64      `json:"epoch"`
+	Ranges []RangeInfo `json:"ranges"`
+}
+
+func (h *Handler) ranges(w http.ResponseWriter, r *http.Request) {
+	replicas := 1
+	if value := r.URL.Query().Get("replicas"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			h.writeError(w, fmt.Errorf("%w: replicas must be a positive number, got %q", ErrBadRequest, value))
+			return
+		}
+		replicas = n
+	}
+
+	ranges, epoch, err := snapshotRanges(h.ring, replicas)
+	if err != nil && !errors.Is(err, hashring.ErrNoConnectedNodes) {
+		h.writeError(w, err)
+		return
+	}
+	response := RangesResponse{Epoch: epoch, Ranges: make([]RangeInfo, 0, len(ranges))}
+	for _, rg := range ranges {
+		info := RangeInfo{Start: rg.Start, End: rg.End, Share: share(rg), Nodes: make([]string, 0, len(rg.Nodes))}
+		for _, node := range rg.Nodes {
+			info.Nodes = append(info.Nodes, node.GetIdentifier())
+		}
+		response.Ranges = append(response.Ranges, info)
+	}
+	h.writeJSON(w, http.StatusOK, epoch, response)
+}
+
+// StatsResponse is the body of GET /stats
+type StatsResponse struct {
+	Epoch         uint64                     `json:"epoch"`
+	ContentHash   string                     `json:"content_hash"`
+	Nodes         int                        `json:"nodes"`
+	States        map[hashring.NodeState]int `json:"states"`
+	KeyspaceShare map[string]float64         `json:"keyspace_share"`
+}
+
+func (h *Handler) stats(w http.ResponseWriter, r *http.Request) {
+	stats, err := Stats(h.ring)
+	if err != nil {
+		h.writeError(w, err)
+		return
+	}
+	h.writeJSON(w, http.StatusOK, stats.Epoch, stats)
+}
+
+/*
+Stats computes the body of GET /stats for ring. Members, ranges and content hash are read
+again whenever the ring changed while they were being read, so they describe one epoch.
+Returns ErrRingChanging if the ring changed during every one of readAttempts reads.
+*/
+func Stats(ring *hashring.HashRing) (StatsResponse, error) {
+	for attempt := 0; attempt < readAttempts; attempt++ {
+		members, epoch := ring.Members()
+		ranges, rangesEpoch, err := snapshotRanges(ring, 1)
+		if err != nil && !errors.Is(err, hashring.ErrNoConnectedNodes) {
+			return StatsResponse{}, err
+		}
+		contentHash := ring.ContentHash()
+		if rangesEpoch != epoch || ring.Epoch() != epoch {
+			continue
+		}
+
+		stats := StatsResponse{
+			Epoch:         epoch,
+			ContentHash:   fmt.Sprintf("%016x", contentHash),
+			Nodes:         len(members),
+			States:        make(map[hashring.NodeState]int),
+			KeyspaceShare: make(map[string]float64, len(members)),
+		}
+		for _, member := range members {
+			stats.States[member.State]++
+		}
+		for _, rg := range ranges {
+			stats.KeyspaceShare[rg.Owner().GetIdentifier()] += share(rg)
+		}
+		return stats, nil
+	}
+	return StatsResponse{}, ErrRingChanging
+}
+
+/*
+snapshotRanges reads the ranges of ring together with the epoch they belong to, or fails
+with ErrRingChanging if the ring changed during every one of readAttempts reads.
+*/
+func snapshotRanges(ring *hashring.HashRing, replicas int) ([]hashring.Range, uint64, error) {
+	for attempt := 0; attempt < readAttempts; attempt++ {
+		before := ring.Epoch()
+		ranges, err := ring.Ranges(replicas)
+		if ring.Epoch() == before {
+			return ranges, before, err
+		}
+	}
+	return nil, ring.Epoch(), ErrRingChanging
+}
+
+// share returns the fraction of the hash space covered by a range
+func share(rg hashring.Range) float64 {
+	if rg.Start == rg.End {
+		return 1
+	}
+	return float64(rg.End-rg.Start) / math.Exp2(64)
+}
+
+// decode reads a JSON request body into v, rejecting unknown fields
+func decode(r *http.Request, v any) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, 1<<20))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return fmt.Errorf("%w: %v", ErrBadRequest, err)
+	}
+	return nil
+}
+
+// ErrorResponse is the body of every error
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Epoch uint64 `json:"epoch"`
+}
+
+// statusOf maps the errors of the ring to HTTP status codes
+func statusOf(err error) int {
+	switch {
+	case errors.Is(err, ErrBadRequest), errors.Is(err, hashring.ErrUnknownNodeState):
+		return http.StatusBadRequest
+	case errors.Is(err, hashring.ErrNodeNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, hashring.ErrNodeExits):
+		return http.StatusConflict
+	case errors.Is(err, hashring.ErrEpochMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, hashring.ErrNoConnectedNodes), errors.Is(err, ErrRingChanging):
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
+
+func (h *Handler) writeError(w http.ResponseWriter, err error) {
+	epoch := h.ring.Epoch()
+	h.writeJSON(w, statusOf(err), epoch, ErrorResponse{Error: err.Error(), Epoch: epoch})
+}
+
+func (h *Handler) writeJSON(w http.ResponseWriter, status int, epoch uint64, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatUint(epoch, 10)))
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,213 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// do sends a request to h and decodes the JSON response into out when it is not nil
+func do(t *testing.T, h http.Handler, method, target, body string, header map[string]string, out any) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	recorder := httptest.NewRecorder()
+	h.ServeHTTP(recorder, req)
+	if out != nil {
+		if err := json.Unmarshal(recorder.Body.Bytes(), out); err != nil {
+			t.Fatalf("%s %s: invalid JSON %q: %v", method, target, recorder.Body.String(), err)
+		}
+	}
+	return recorder
+}
+
+// adminRing returns a ring with three admin nodes and a handler serving it
+func adminRing(t *testing.T) (*hashring.HashRing, *Handler) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	for i := 0; i < 3; i++ {
+		ring.AddNode(&Node{ID: fmt.Sprintf("node-%d.local", i), Addr: fmt.Sprintf("10.0.0.%d:11211", i)})
+	}
+	return ring, HandlerInit(ring)
+}
+
+/*
+TestHandler tests the admin API. It verifies the read endpoints against the ring, that
+nodes can be added, drained and removed, that If-Match makes mutations conditional on the
+epoch unless it is *, that errors map to JSON bodies with the right status codes, and that
+reads of a ring that keeps changing give up with 503 instead of retrying forever.
+*/
+func TestHandler(t *testing.T) {
+	t.Run("read endpoints", func(t *testing.T) {
+		ring, h := adminRing(t)
+
+		var nodes NodesResponse
+		recorder := do(t, h, "GET", "/nodes", "", nil, &nodes)
+		if recorder.Code != http.StatusOK || len(nodes.Nodes) != 3 || nodes.Epoch != ring.Epoch() {
+			t.Fatalf("Unexpected /nodes response %d %+v", recorder.Code, nodes)
+		}
+		if nodes.Nodes[0].Addr == "" || nodes.Nodes[0].State != hashring.StateActive {
+			t.Errorf("Expected address and state in %+v", nodes.Nodes[0])
+		}
+		if etag := recorder.Header().Get("ETag"); etag != fmt.Sprintf("%q", fmt.Sprint(ring.Epoch())) {
+			t.Errorf("Expected the epoch as ETag, got %s", etag)
+		}
+
+		var lookup LookupResponse
+		do(t, h, "GET", "/lookup?key=user:42", "", nil, &lookup)
+		owner, _ := ring.GetNode("user:42")
+		if lookup.Node.ID != owner.GetIdentifier() {
+			t.Errorf("Expected user:42 on %s, got %s", owner.GetIdentifier(), lookup.Node.ID)
+		}
+
+		var ranges RangesResponse
+		do(t, h, "GET", "/ranges?replicas=2", "", nil, &ranges)
+		if len(ranges.Ranges) != 3 || len(ranges.Ranges[0].Nodes) != 2 {
+			t.Errorf("Expected 3 ranges with 2 owners, got %+v", ranges)
+		}
+
+		var stats StatsResponse
+		do(t, h, "GET", "/stats", "", nil, &stats)
+		total := 0.0
+		for _, share := range stats.KeyspaceShare {
+			total += share
+		}
+		if stats.Nodes != 3 || stats.States[hashring.StateActive] != 3 || math.Abs(total-1) > 1e-9 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+		if stats.ContentHash != fmt.Sprintf("%016x", ring.ContentHash()) {
+			t.Errorf("Expected content hash %016x, got %s", ring.ContentHash(), stats.ContentHash)
+		}
+	})
+
+	t.Run("add, drain and remove a node", func(t *testing.T) {
+		ring, h := adminRing(t)
+
+		var added MutationResponse
+		recorder := do(t, h, "POST", "/nodes", `{"id": "node-9.local", "addr": "10.0.0.9:11211"}`, nil, &added)
+		if recorder.Code != http.StatusCreated || added.Epoch != ring.Epoch() {
+			t.Fatalf("Unexpected add response %d %+v", recorder.Code, added)
+		}
+
+		var drained MutationResponse
+		do(t, h, "PUT", "/nodes/node-9.local/state", `{"state": "draining"}`, nil, &drained)
+		if state, _ := ring.NodeState("node-9.local"); state != hashring.StateDraining || drained.Node.State != state {
+			t.Errorf("Expected node-9.local to be draining, got %s", state)
+		}
+
+		recorder = do(t, h, "DELETE", "/nodes/node-9.local", "", nil, nil)
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+		if _, err := ring.NodeState("node-9.local"); err == nil {
+			t.Error("Expected node-9.local to be gone")
+		}
+	})
+
+	t.Run("if-match makes mutations conditional", func(t *testing.T) {
+		ring, h := adminRing(t)
+		stale := fmt.Sprint(ring.Epoch())
+		ring.SetNodeState("node-0.local", hashring.StateDown)
+
+		var failed ErrorResponse
+		recorder := do(t, h, "POST", "/nodes", `{"id": "node-9.local"}`, map[string]string{"If-Match": stale}, &failed)
+		if recorder.Code != http.StatusPreconditionFailed || failed.Epoch != ring.Epoch() {
+			t.Fatalf("Expected 412 with the current epoch, got %d %+v", recorder.Code, failed)
+		}
+		if _, err := ring.NodeState("node-9.local"); err == nil {
+			t.Fatal("Expected the stale add not to be applied")
+		}
+
+		current := fmt.Sprintf("%q", fmt.Sprint(ring.Epoch()))
+		if recorder := do(t, h, "PUT", "/nodes/node-0.local/state", `{"state": "active"}`, map[string]string{"If-Match": current}, nil); recorder.Code != http.StatusOK {
+			t.Errorf("Expected a current If-Match to succeed, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+
+		ring.SetNodeState("node-1.local", hashring.StateDown)
+		if recorder := do(t, h, "PUT", "/nodes/node-1.local/state", `{"state": "active"}`, map[string]string{"If-Match": "*"}, nil); recorder.Code != http.StatusOK {
+			t.Errorf("Expected If-Match: * to apply at any epoch, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	})
+
+	t.Run("errors map to status codes", func(t *testing.T) {
+		_, h := adminRing(t)
+		tests := []struct {
+			method, target, body string
+			header               map[string]string
+			want                 int
+		}{
+			{"POST", "/nodes", `{"id": "node-0.local"}`, nil, http.StatusConflict},
+			{"DELETE", "/nodes/node-7.local", "", nil, http.StatusNotFound},
+			{"PUT", "/nodes/node-7.local/state", `{"state": "down"}`, nil, http.StatusNotFound},
+			{"PUT", "/nodes/node-0.local/state", `{"state": "sleeping"}`, nil, http.StatusBadRequest},
+			{"POST", "/nodes", `{"addr": "10.0.0.9:11211"}`, nil, http.StatusBadRequest},
+			{"POST", "/nodes", `{"id": "x", "weight": 2}`, nil, http.StatusBadRequest},
+			{"POST", "/nodes", `{"id": "x"}`, map[string]string{"If-Match": "latest"}, http.StatusBadRequest},
+			{"GET", "/lookup", "", nil, http.StatusBadRequest},
+			{"GET", "/ranges?replicas=0", "", nil, http.StatusBadRequest},
+		}
+		for _, tt := range tests {
+			var body ErrorResponse
+			recorder := do(t, h, tt.method, tt.target, tt.body, tt.header, &body)
+			if recorder.Code != tt.want || body.Error == "" {
+				t.Errorf("%s %s: expected %d with an error body, got %d %q", tt.method, tt.target, tt.want, recorder.Code, recorder.Body.String())
+			}
+		}
+
+		empty := HandlerInit(hashring.HashRingInit())
+		if recorder := do(t, empty, "GET", "/lookup?key=k", "", nil, nil); recorder.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 on an empty ring, got %d", recorder.Code)
+		}
+		var ranges RangesResponse
+		if recorder := do(t, empty, "GET", "/ranges", "", nil, &ranges); recorder.Code != http.StatusOK || len(ranges.Ranges) != 0 {
+			t.Errorf("Expected no ranges on an empty ring, got %d %+v", recorder.Code, ranges)
+		}
+	})
+
+	t.Run("reads give up on a ring that keeps changing", func(t *testing.T) {
+		ring, h := adminRing(t)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for i := 0; ; i++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				state := hashring.StateActive
+				if i%2 == 0 {
+					state = hashring.StateDraining
+				}
+				ring.SetNodeState("node-0.local", state)
+			}
+		}()
+
+		// Every read returns, either with a consistent snapshot or with ErrRingChanging
+		for i := 0; i < 200; i++ {
+			if _, err := Stats(ring); err != nil && !errors.Is(err, ErrRingChanging) {
+				t.Fatalf("Expected Stats to succeed or fail with ErrRingChanging, got %v", err)
+			}
+			recorder := do(t, h, "GET", "/ranges", "", nil, nil)
+			if recorder.Code != http.StatusOK && recorder.Code != http.StatusServiceUnavailable {
+				t.Fatalf("Expected 200 or 503, got %d: %s", recorder.Code, recorder.Body.String())
+			}
+		}
+		close(stop)
+		<-done
+
+		if status := statusOf(ErrRingChanging); status != http.StatusServiceUnavailable {
+			t.Errorf("Expected ErrRingChanging to map to 503, got %d", status)
+		}
+	})
+}
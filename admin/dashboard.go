@@ -0,0 +1,366 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package admin
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// maxEvents is the number of recent membership events kept for the dashboard
+const maxEvents = 50
+
+//go:embed dashboard
+var dashboardFiles embed.FS
+
+/*
+Event is a membership change shown in the dashboard's event list. Events are found by
+comparing the ring's members between observations, which happen after every change made
+through the admin API and on every refresh while a dashboard is open; changes made
+elsewhere are stamped with the time they were first observed. Kind is "add", "remove" or
+"state", State is the node's state after the change.
+*/
+type Event struct {
+	Time  time.Time          `json:"time"`
+	Kind  string             `json:"kind"`
+	Node  string             `json:"node"`
+	State hashring.NodeState `json:"state"`
+	Epoch uint64             `json:"epoch"`
+}
+
+// DashboardNode is one node in a DashboardState
+type DashboardNode struct {
+	NodeInfo
+	Share         float64 `json:"share"`
+	LookupsPerSec float64 `json:"lookups_per_sec"`
+}
+
+// DashboardArc is one range in a DashboardState, with its ends as fractions of the ring
+type DashboardArc struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Owner string  `json:"owner"`
+}
+
+/*
+DashboardState is the payload of every "state" event sent on /events. Fields:
+  - Epoch, ContentHash: Identify the ring state the payload describes
+  - Nodes: Members in ring order with their keyspace share and lookup rate
+  - Arcs: Ranges of the ring for drawing it, positions are fractions of the hash space
+  - Events: Recent membership events, newest first
+  - LookupsPerSec: Lookups per second over all nodes
+  - HasMetrics: Whether lookup rates are available, see SetMetrics
+*/
+type DashboardState struct {
+	Epoch         uint64          `json:"epoch"`
+	ContentHash   string          `json:"content_hash"`
+	Nodes         []DashboardNode `json:"nodes"`
+	Arcs          []DashboardArc  `json:"arcs"`
+	Events        []Event         `json:"events"`
+	LookupsPerSec float64         `json:"lookups_per_sec"`
+	HasMetrics    bool            `json:"has_metrics"`
+}
+
+/*
+SetMetrics returns a HandlerConfigFn that gives the dashboard the exporter attached to the
+ring with hashring.SetMetricsHook, so it can show lookup rates. Without it rates are left
+out.
+*/
+func SetMetrics(metrics *hashring.PrometheusMetrics) HandlerConfigFn {
+	return func(config *handlerConfig) {
+		config.Metrics = metrics
+	}
+}
+
+// SetRefreshInterval returns a HandlerConfigFn that sets how often /events sends a new state, 1s by default
+func SetRefreshInterval(interval time.Duration) HandlerConfigFn {
+	return func(config *handlerConfig) {
+		config.RefreshInterval = interval
+	}
+}
+
+/*
+hub computes dashboard states and fans them out to the /events streams. It only polls the
+ring while at least one stream is open. Fields:
+  - mu: Mutex guarding every field below
+  - ring, metrics, interval: What to observe and how often
+  - subscribers: Channels of the open streams, each holds at most the newest payload
+  - members: States of the members at the last observation, keyed by identifier
+  - events: Recent membership events, oldest first
+  - lookups, lookupsAt: Lookup counts at the last refresh and when they were read
+  - stop: Closed to end the polling goroutine, nil when it is not running
+*/
+type hub struct {
+	mu          sync.Mutex
+	ring        *hashring.HashRing
+	metrics     *hashring.PrometheusMetrics
+	interval    time.Duration
+	subscribers map[chan []byte]struct{}
+	members     map[string]hashring.NodeState
+	events      []Event
+	lookups     map[string]uint64
+	lookupsAt   time.Time
+	stop        chan struct{}
+}
+
+func newHub(ring *hashring.HashRing, metrics *hashring.PrometheusMetrics, interval time.Duration) *hub {
+	hb := &hub{ring: ring, metrics: metrics, interval: interval, subscribers: make(map[chan []byte]struct{})}
+	// The first observation is the baseline, later ones report what changed since
+	members, _ := ring.Members()
+	hb.members = make(map[string]hashring.NodeState, len(members))
+	for _, member := range members {
+		hb.members[member.Node.GetIdentifier()] = member.State
+	}
+	if metrics != nil {
+		hb.lookups, hb.lookupsAt = metrics.Lookups(), time.Now()
+	}
+	return hb
+}
+
+// observe records an event for every member added, removed or changed since the last call
+func (hb *hub) observe() {
+	members, epoch := hb.ring.Members()
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	now := time.Now()
+	current := make(map[string]hashring.NodeState, len(members))
+	for _, member := range members {
+		id := member.Node.GetIdentifier()
+		current[id] = member.State
+		previous, ok := hb.members[id]
+		switch {
+		case !ok:
+			hb.record(Event{Time: now, Kind: "add", Node: id, State: member.State, Epoch: epoch})
+		case previous != member.State:
+			hb.record(Event{Time: now, Kind: "state", Node: id, State: member.State, Epoch: epoch})
+		}
+	}
+	for _, id := range sortedIDs(hb.members) {
+		if _, ok := current[id]; !ok {
+			hb.record(Event{Time: now, Kind: "remove", Node: id, State: hb.members[id], Epoch: epoch})
+		}
+	}
+	hb.members = current
+}
+
+// record appends an event and drops the oldest beyond maxEvents, callers must hold hb.mu
+func (hb *hub) record(event Event) {
+	hb.events = append(hb.events, event)
+	if len(hb.events) > maxEvents {
+		hb.events = hb.events[len(hb.events)-maxEvents:]
+	}
+}
+
+// state observes the ring and builds the payload, advancing the lookup rate window
+func (hb *hub) state() (DashboardState, error) {
+	hb.observe()
+	stats, err := Stats(hb.ring)
+	if err != nil {
+		return DashboardState{}, err
+	}
+	ranges, _, err := snapshotRanges(hb.ring, 1)
+	if err != nil && !errors.Is(err, hashring.ErrNoConnectedNodes) {
+		return DashboardState{}, err
+	}
+	members, _ := hb.ring.Members()
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	// Rates are the lookups counted since the previous state divided by the time it took
+	rates := make(map[string]float64)
+	state := DashboardState{
+		Epoch:       stats.Epoch,
+		ContentHash: stats.ContentHash,
+		Nodes:       make([]DashboardNode, 0, len(members)),
+		Arcs:        make([]DashboardArc, 0, len(ranges)),
+		Events:      make([]Event, 0, len(hb.events)),
+		HasMetrics:  hb.metrics != nil,
+	}
+	if hb.metrics != nil {
+		lookups, now := hb.metrics.Lookups(), time.Now()
+		if elapsed := now.Sub(hb.lookupsAt).Seconds(); elapsed > 0 {
+			for id, count := range lookups {
+				rate := float64(count-hb.lookups[id]) / elapsed
+				rates[id] = rate
+				state.LookupsPerSec += rate
+			}
+		}
+		hb.lookups, hb.lookupsAt = lookups, now
+	}
+
+	for _, member := range members {
+		id := member.Node.GetIdentifier()
+		state.Nodes = append(state.Nodes, DashboardNode{
+			NodeInfo:      nodeInfo(member.Node, member.State),
+			Share:         stats.KeyspaceShare[id],
+			LookupsPerSec: rates[id],
+		})
+	}
+	for _, rg := range ranges {
+		state.Arcs = append(state.Arcs, DashboardArc{
+			Start: float64(rg.Start) / math.Exp2(64),
+			End:   float64(rg.End) / math.Exp2(64),
+			Owner: rg.Owner().GetIdentifier(),
+		})
+	}
+	for i := len(hb.events) - 1; i >= 0; i-- {
+		state.Events = append(state.Events, hb.events[i])
+	}
+	return state, nil
+}
+
+// subscribe registers a stream and starts polling if it is the first one
+func (hb *hub) subscribe() chan []byte {
+	ch := make(chan []byte, 1)
+
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	hb.subscribers[ch] = struct{}{}
+	if hb.stop == nil {
+		hb.stop = make(chan struct{})
+		go hb.run(hb.stop)
+	}
+	return ch
+}
+
+// unsubscribe removes a stream and stops polling once no stream is left
+func (hb *hub) unsubscribe(ch chan []byte) {
+	hb.mu.Lock()
+	defer hb.mu.Unlock()
+
+	delete(hb.subscribers, ch)
+	if len(hb.subscribers) == 0 && hb.stop != nil {
+		close(hb.stop)
+		hb.stop = nil
+	}
+}
+
+// run sends a fresh state to every subscriber each interval until stop is closed
+func (hb *hub) run(stop chan struct{}) {
+	ticker := time.NewTicker(hb.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		state, err := hb.state()
+		if err != nil {
+			continue
+		}
+		payload, err := json.Marshal(state)
+		if err != nil {
+			continue
+		}
+
+		hb.mu.Lock()
+		for ch := range hb.subscribers {
+			// A slow stream gets the newest state instead of a backlog
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- payload
+		}
+		hb.mu.Unlock()
+	}
+}
+
+/*
+events streams DashboardState payloads as Server-Sent Events named "state": one right
+away unless the ring cannot be read, and one every refresh interval until the client goes
+away.
+*/
+func (h *Handler) events(w http.ResponseWriter, r *http.Request) {
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	ch := h.hub.subscribe()
+	defer h.hub.unsubscribe(ch)
+
+	// A ring too busy to read right away gets its first state at the next refresh
+	var payload []byte
+	if state, err := h.hub.state(); err == nil {
+		payload, _ = json.Marshal(state)
+	}
+	for {
+		if payload != nil {
+			if _, err := w.Write([]byte("event: state\ndata: ")); err != nil {
+				return
+			}
+			w.Write(payload)
+			w.Write([]byte("\n\n"))
+			if err := rc.Flush(); err != nil {
+				return
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case payload = <-ch:
+		}
+	}
+}
+
+// dashboard serves the embedded single-page dashboard
+func (h *Handler) dashboard() http.Handler {
+	files, err := fs.Sub(dashboardFiles, "dashboard")
+	if err != nil {
+		panic(err)
+	}
+	return http.StripPrefix("/dashboard", http.FileServerFS(files))
+}
+
+/*
+dashboardRedirect sends /dashboard to /dashboard/. The Location is relative on purpose:
+http.Redirect would make it absolute from the request path, which has lost the prefix the
+handler is mounted under.
+*/
+func dashboardRedirect(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Location", "dashboard/")
+	w.WriteHeader(http.StatusMovedPermanently)
+}
+
+// sortedIDs returns the keys of m in sorted order
+func sortedIDs[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
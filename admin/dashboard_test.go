@@ -0,0 +1,136 @@
+package admin
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// readState reads the next "state" event from an SSE stream
+func readState(t *testing.T, reader *bufio.Reader) DashboardState {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("Reading the event stream failed: %v", err)
+		}
+		if data, ok := strings.CutPrefix(line, "data: "); ok {
+			var state DashboardState
+			if err := json.Unmarshal([]byte(data), &state); err != nil {
+				t.Fatalf("Invalid state payload %q: %v", data, err)
+			}
+			return state
+		}
+	}
+}
+
+/*
+TestDashboard tests the dashboard. It verifies that the embedded page is served under the
+handler's mount point, that /events streams the ring with its shares and lookup rates and
+follows membership changes, that changes made through the API are recorded while no
+dashboard is open, and that polling stops when the last stream closes.
+*/
+func TestDashboard(t *testing.T) {
+	t.Run("serves the embedded page", func(t *testing.T) {
+		_, h := adminRing(t)
+		mux := http.NewServeMux()
+		mux.Handle("/admin/", http.StripPrefix("/admin", h))
+
+		recorder := httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/dashboard", nil))
+		if recorder.Code != http.StatusMovedPermanently || recorder.Header().Get("Location") != "dashboard/" {
+			t.Errorf("Expected a relative redirect to dashboard/, got %d %q", recorder.Code, recorder.Header().Get("Location"))
+		}
+
+		recorder = httptest.NewRecorder()
+		mux.ServeHTTP(recorder, httptest.NewRequest("GET", "/admin/dashboard/", nil))
+		if recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `new EventSource("../events")`) {
+			t.Errorf("Expected the dashboard page, got %d", recorder.Code)
+		}
+		if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+			t.Errorf("Expected text/html, got %q", ct)
+		}
+	})
+
+	t.Run("streams live state", func(t *testing.T) {
+		metrics := hashring.NewPrometheusMetrics()
+		ring := hashring.HashRingInit(hashring.SetMetricsHook(metrics))
+		ring.AddNode(&Node{ID: "node-0.local", Addr: "10.0.0.0:11211"})
+		ring.AddNode(&Node{ID: "node-1.local", Addr: "10.0.0.1:11211"})
+		h := HandlerInit(ring, SetMetrics(metrics), SetRefreshInterval(10*time.Millisecond))
+
+		// Changes through the API are recorded before any dashboard is open
+		do(t, h, "POST", "/nodes", `{"id": "node-9.local"}`, nil, nil)
+
+		server := httptest.NewServer(h)
+		defer server.Close()
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", server.URL+"/events", nil)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Connecting to /events failed: %v", err)
+		}
+		defer resp.Body.Close()
+		if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+			t.Fatalf("Expected text/event-stream, got %q", ct)
+		}
+		reader := bufio.NewReader(resp.Body)
+
+		state := readState(t, reader)
+		if len(state.Nodes) != 3 || len(state.Arcs) != 3 || !state.HasMetrics {
+			t.Fatalf("Unexpected first state %+v", state)
+		}
+		if len(state.Events) != 1 || state.Events[0].Kind != "add" || state.Events[0].Node != "node-9.local" {
+			t.Errorf("Expected the API add to be recorded, got %+v", state.Events)
+		}
+		total := 0.0
+		for _, node := range state.Nodes {
+			total += node.Share
+		}
+		if total < 0.999 || total > 1.001 {
+			t.Errorf("Expected shares to add up to 1, got %v", total)
+		}
+
+		// A change made directly on the ring and some traffic show up in later states
+		ring.SetNodeState("node-1.local", hashring.StateDraining)
+		for i := 0; i < 100; i++ {
+			ring.GetNode("user:42")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			state = readState(t, reader)
+			if state.Epoch == ring.Epoch() && state.LookupsPerSec > 0 {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected a state at epoch %d with lookups, last got %+v", ring.Epoch(), state)
+			}
+		}
+		if state.Events[0].Kind != "state" || state.Events[0].State != hashring.StateDraining {
+			t.Errorf("Expected the drain as newest event, got %+v", state.Events[0])
+		}
+
+		cancel()
+		deadline = time.Now().Add(5 * time.Second)
+		for {
+			h.hub.mu.Lock()
+			stopped := h.hub.stop == nil
+			h.hub.mu.Unlock()
+			if stopped {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatal("Expected polling to stop after the last stream closed")
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	})
+}
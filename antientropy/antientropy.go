@@ -0,0 +1,276 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package antientropy reconciles replicas that drifted apart, for example after a node
+failure, based on the ranges of a HashRing. Every replica builds a Merkle tree per ring
+range it holds from a Source listing its keys and versions; comparing the trees of the
+replicas of a range finds the keys they disagree on while only descending into subtrees
+that differ, and a Repair is produced for each such key, naming the replica with the
+newest version and the replicas that need it. Trees of other replicas are read through
+Remote, so replicas in different processes only exchange the hashes and leaves that differ.
+*/
+package antientropy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+const (
+	// DefaultDepth is the tree depth used when none is given, 2^10 leaves per range
+	DefaultDepth = 10
+	// MaxDepth is the deepest tree allowed, 2^16 leaves per range
+	MaxDepth = 16
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrNoSource     = errors.New("No source for replica")
+	ErrInvalidDepth = errors.New("Tree depth out of range")
+	ErrInvalidIndex = errors.New("Tree node index out of range")
+	ErrNotReplica   = errors.New("Node is not a replica of the range")
+)
+
+/*
+Source lists the keys a replica holds with their versions. Implementations must call fn
+once per key; a deleted key should be reported with the version of its tombstone so that
+deletes are repaired like writes.
+*/
+type Source interface {
+	Scan(fn func(key string, version uint64)) error
+}
+
+/*
+BuildTrees builds a tree of the given depth for every range of ring in which nodeID is
+one of the first replicas owners, from the keys in source. Keys that fall in ranges the
+node does not hold are ignored. The trees are returned in ring order. Returns
+ErrInvalidDepth unless depth is between 0 and MaxDepth.
+*/
+func BuildTrees(ring *hashring.HashRing, nodeID string, replicas, depth int, source Source) ([]*Tree, error) {
+	ranges, err := ring.Ranges(replicas)
+	if err != nil {
+		return nil, err
+	}
+	var trees []*Tree
+	for _, r := range ranges {
+		if slices.ContainsFunc(r.Nodes, func(node hashring.CacheNode) bool { return node.GetIdentifier() == nodeID }) {
+			tree, err := newTree(r, depth)
+			if err != nil {
+				return nil, err
+			}
+			trees = append(trees, tree)
+		}
+	}
+
+	var scanErr error
+	err = source.Scan(func(key string, version uint64) {
+		if scanErr != nil {
+			return
+		}
+		keyHash, err := ring.KeyHash(key)
+		if err != nil {
+			scanErr = err
+			return
+		}
+		for _, tree := range trees {
+			if tree.Range.Contains(keyHash) {
+				tree.add(key, keyHash, version)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	if scanErr != nil {
+		return nil, scanErr
+	}
+	for _, tree := range trees {
+		tree.build()
+	}
+	return trees, nil
+}
+
+/*
+Repair is one key to copy between replicas: the replica From holds the newest Version, and
+every replica in To holds an older version or none.
+*/
+type Repair struct {
+	Range   hashring.Range
+	Key     string
+	Version uint64
+	From    string
+	To      []string
+}
+
+/*
+Plan compares the replicas of every range of ring and returns the repairs that bring them
+back in sync, sorted by key. sources maps every node identifier to the Source of its keys.
+The owner's tree of each range is diffed against the tree of every other replica, which
+finds every key the replicas disagree on, and for each such key the newest version wins.
+Plan needs the Source of every replica in one process; replicas that only reach each
+other over the network plan each range with PlanRange instead.
+*/
+func Plan(ring *hashring.HashRing, replicas, depth int, sources map[string]Source) ([]Repair, error) {
+	if depth < 0 || depth > MaxDepth {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDepth, depth)
+	}
+
+	// Build every node's trees once, keyed by range end
+	trees := make(map[string]map[uint64]*Tree, len(sources))
+	for id, source := range sources {
+		built, err := BuildTrees(ring, id, replicas, depth, source)
+		if err != nil {
+			return nil, fmt.Errorf("building trees of %s: %w", id, err)
+		}
+		trees[id] = make(map[uint64]*Tree, len(built))
+		for _, tree := range built {
+			trees[id][tree.Range.End] = tree
+		}
+	}
+
+	ranges, err := ring.Ranges(replicas)
+	if err != nil {
+		return nil, err
+	}
+	var repairs []Repair
+	for _, r := range ranges {
+		var owner *Tree
+		rangeTrees := make([]Remote, len(r.Nodes))
+		for i, node := range r.Nodes {
+			nodeTrees, ok := trees[node.GetIdentifier()]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNoSource, node.GetIdentifier())
+			}
+			if i == 0 {
+				owner = nodeTrees[r.End]
+			}
+			rangeTrees[i] = nodeTrees[r.End]
+		}
+		rangeRepairs, err := planRange(r, 0, owner, rangeTrees)
+		if err != nil {
+			return nil, err
+		}
+		repairs = append(repairs, rangeRepairs...)
+	}
+	slices.SortFunc(repairs, func(a, b Repair) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return repairs, nil
+}
+
+/*
+PlanRange returns the repairs that bring the replicas of one range back in sync, sorted by
+key, as seen from the replica nodeID. local is that replica's tree of the range from
+BuildTrees, and peers maps every other replica of local.Range to its tree of the same
+range and depth, typically fetched over the network. Only differing subtrees are read from
+the peers. Returns ErrNotReplica if nodeID does not hold the range and ErrNoSource if a
+replica has no peer.
+*/
+func PlanRange(local *Tree, nodeID string, peers map[string]Remote) ([]Repair, error) {
+	r := local.Range
+	self := slices.IndexFunc(r.Nodes, func(node hashring.CacheNode) bool { return node.GetIdentifier() == nodeID })
+	if self < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrNotReplica, nodeID)
+	}
+	remotes := make([]Remote, len(r.Nodes))
+	for i, node := range r.Nodes {
+		if i == self {
+			continue
+		}
+		peer, ok := peers[node.GetIdentifier()]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNoSource, node.GetIdentifier())
+		}
+		remotes[i] = peer
+	}
+	repairs, err := planRange(r, self, local, remotes)
+	if err != nil {
+		return nil, err
+	}
+	slices.SortFunc(repairs, func(a, b Repair) int {
+		return strings.Compare(a.Key, b.Key)
+	})
+	return repairs, nil
+}
+
+/*
+planRange finds the repairs for one range, local is the tree of the replica at index self
+of r.Nodes and remotes holds the trees of the replicas, the entry at self is not used.
+*/
+func planRange(r hashring.Range, self int, local *Tree, remotes []Remote) ([]Repair, error) {
+	// Diffs against the local tree give its version and the version of every replica that disagrees with it
+	type held struct {
+		version uint64
+		ok      bool
+	}
+	owner := make(map[string]held)
+	others := make([]map[string]held, len(remotes))
+	for i, remote := range remotes {
+		if i == self {
+			continue
+		}
+		diffs, err := Diff(local, remote)
+		if err != nil {
+			return nil, fmt.Errorf("comparing with %s: %w", r.Nodes[i].GetIdentifier(), err)
+		}
+		others[i] = make(map[string]held)
+		for _, diff := range diffs {
+			owner[diff.Key] = held{diff.A, !diff.MissingA}
+			others[i][diff.Key] = held{diff.B, !diff.MissingB}
+		}
+	}
+
+	var repairs []Repair
+	for key, own := range owner {
+		versions := make([]held, len(remotes))
+		for i := range remotes {
+			if i == self {
+				versions[i] = own
+			} else if v, ok := others[i][key]; ok {
+				versions[i] = v
+			} else {
+				versions[i] = own
+			}
+		}
+
+		// The newest version wins, every replica behind it gets a copy
+		repair := Repair{Range: r, Key: key}
+		for i, v := range versions {
+			if v.ok && (repair.From == "" || v.version > repair.Version) {
+				repair.Version, repair.From = v.version, r.Nodes[i].GetIdentifier()
+			}
+		}
+		for i, v := range versions {
+			if !v.ok || v.version < repair.Version {
+				repair.To = append(repair.To, r.Nodes[i].GetIdentifier())
+			}
+		}
+		repairs = append(repairs, repair)
+	}
+	return repairs, nil
+}
@@ -0,0 +1,234 @@
+package antientropy
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+testNode is a test implementation of the CacheNode interface for building rings whose
+ranges the trees cover.
+*/
+type testNode struct {
+	id string
+}
+
+func (n *testNode) GetIdentifier() string {
+	return n.id
+}
+
+/*
+nodeIDs name the nodes of a cluster, in order. Each node holds a single position on the
+ring, and these names keep every node's share above a tenth so that each one holds keys
+whose replicas can drift.
+*/
+var nodeIDs = []string{"alder", "birch", "cypress", "sycamore", "banyan"}
+
+/*
+cluster returns a ring of count nodes and a MemorySource per node, with keys written to
+every replica of their range as a healthy cluster would.
+*/
+func cluster(t *testing.T, count, replicas, keys int) (*hashring.HashRing, map[string]*MemorySource) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	sources := make(map[string]*MemorySource)
+	for i := 0; i < count; i++ {
+		id := nodeIDs[i]
+		ring.AddNode(&testNode{id: id})
+		sources[id] = MemorySourceInit()
+	}
+	for i := 0; i < keys; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owners, _ := ring.GetNodes(key, replicas)
+		for _, owner := range owners {
+			sources[owner.GetIdentifier()].Set(key, 1)
+		}
+	}
+	return ring, sources
+}
+
+// asSources returns sources as the Source interface Plan takes
+func asSources(sources map[string]*MemorySource) map[string]Source {
+	out := make(map[string]Source, len(sources))
+	for id, source := range sources {
+		out[id] = source
+	}
+	return out
+}
+
+/*
+TestPlan tests repair planning across the replicas of every range. It verifies that
+replicas in sync need no repairs, that missed writes, stale versions and lost keys are
+each repaired from the replica with the newest version to exactly the replicas behind,
+that a replica without a source is reported and that tree depths are bounded.
+*/
+func TestPlan(t *testing.T) {
+	t.Run("replicas in sync", func(t *testing.T) {
+		ring, sources := cluster(t, 5, 3, 500)
+		repairs, err := Plan(ring, 3, DefaultDepth, asSources(sources))
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(repairs) != 0 {
+			t.Errorf("Expected no repairs, got %v", repairs)
+		}
+	})
+
+	t.Run("drifted replicas", func(t *testing.T) {
+		ring, sources := cluster(t, 5, 3, 500)
+
+		// key-1: one replica missed a newer write; key-2: one replica lost the key
+		owners1, _ := ring.GetNodes("key-1", 3)
+		sources[owners1[1].GetIdentifier()].Set("key-1", 5)
+		owners2, _ := ring.GetNodes("key-2", 3)
+		sources[owners2[0].GetIdentifier()].Delete("key-2")
+
+		repairs, err := Plan(ring, 3, DefaultDepth, asSources(sources))
+		if err != nil {
+			t.Fatalf("Plan failed: %v", err)
+		}
+		if len(repairs) != 2 {
+			t.Fatalf("Expected 2 repairs, got %+v", repairs)
+		}
+
+		r1 := repairs[0]
+		if r1.Key != "key-1" || r1.Version != 5 || r1.From != owners1[1].GetIdentifier() {
+			t.Errorf("Unexpected repair %+v", r1)
+		}
+		want1 := []string{owners1[0].GetIdentifier(), owners1[2].GetIdentifier()}
+		if !slices.Equal(r1.To, want1) {
+			t.Errorf("Expected key-1 to go to %v, got %v", want1, r1.To)
+		}
+
+		r2 := repairs[1]
+		if r2.Key != "key-2" || r2.Version != 1 || !slices.Equal(r2.To, []string{owners2[0].GetIdentifier()}) {
+			t.Errorf("Unexpected repair %+v", r2)
+		}
+
+		// Applying the repairs brings the replicas back in sync
+		for _, repair := range repairs {
+			for _, id := range repair.To {
+				sources[id].Set(repair.Key, repair.Version)
+			}
+		}
+		if repairs, _ := Plan(ring, 3, DefaultDepth, asSources(sources)); len(repairs) != 0 {
+			t.Errorf("Expected no repairs after applying them, got %v", repairs)
+		}
+	})
+
+	t.Run("keys outside held ranges are ignored", func(t *testing.T) {
+		ring, sources := cluster(t, 5, 1, 200)
+		owners, _ := ring.GetNodes("key-1", 5)
+		stray := sources[owners[4].GetIdentifier()]
+		held := 0
+		stray.Scan(func(string, uint64) { held++ })
+		stray.Set("key-1", 9)
+
+		trees, err := BuildTrees(ring, owners[4].GetIdentifier(), 1, 4, stray)
+		if err != nil {
+			t.Fatalf("BuildTrees failed: %v", err)
+		}
+		total := 0
+		for _, tree := range trees {
+			total += tree.Len()
+		}
+		if total != held {
+			t.Errorf("Expected %d keys in the trees, got %d", held, total)
+		}
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		ring, sources := cluster(t, 3, 2, 10)
+		for id := range sources {
+			delete(sources, id)
+			break
+		}
+		if _, err := Plan(ring, 2, DefaultDepth, asSources(sources)); !errors.Is(err, ErrNoSource) {
+			t.Errorf("Expected ErrNoSource, got %v", err)
+		}
+	})
+
+	t.Run("invalid depth", func(t *testing.T) {
+		ring, sources := cluster(t, 3, 2, 10)
+		for _, depth := range []int{-1, MaxDepth + 1, 64} {
+			if _, err := Plan(ring, 2, depth, asSources(sources)); !errors.Is(err, ErrInvalidDepth) {
+				t.Errorf("Depth %d: expected ErrInvalidDepth, got %v", depth, err)
+			}
+			for id, source := range sources {
+				if _, err := BuildTrees(ring, id, 2, depth, source); !errors.Is(err, ErrInvalidDepth) {
+					t.Errorf("Depth %d: expected BuildTrees to fail with ErrInvalidDepth, got %v", depth, err)
+				}
+			}
+		}
+	})
+}
+
+/*
+TestPlanRange tests planning one range from a replica's own tree and the trees of its
+peers. It verifies that every replica of a range plans the same repairs as Plan does, and
+that a node outside the range and a replica without a peer are reported.
+*/
+func TestPlanRange(t *testing.T) {
+	ring, sources := cluster(t, 5, 3, 500)
+	owners, _ := ring.GetNodes("key-1", 3)
+	sources[owners[1].GetIdentifier()].Set("key-1", 5)
+	sources[owners[2].GetIdentifier()].Delete("key-1")
+
+	want, err := Plan(ring, 3, DefaultDepth, asSources(sources))
+	if err != nil || len(want) != 1 {
+		t.Fatalf("Expected one repair from Plan, got %+v, %v", want, err)
+	}
+
+	// Every replica builds its own trees; the peers' trees stand in for remote replicas
+	trees := make(map[string]*Tree)
+	for _, owner := range owners {
+		id := owner.GetIdentifier()
+		built, err := BuildTrees(ring, id, 3, DefaultDepth, sources[id])
+		if err != nil {
+			t.Fatalf("BuildTrees failed: %v", err)
+		}
+		for _, tree := range built {
+			if tree.Range.End == want[0].Range.End {
+				trees[id] = tree
+			}
+		}
+	}
+
+	t.Run("every replica plans the same repairs", func(t *testing.T) {
+		for id, local := range trees {
+			peers := make(map[string]Remote)
+			for peer, tree := range trees {
+				if peer != id {
+					peers[peer] = tree
+				}
+			}
+			repairs, err := PlanRange(local, id, peers)
+			if err != nil {
+				t.Fatalf("PlanRange on %s failed: %v", id, err)
+			}
+			if len(repairs) != 1 || repairs[0].Key != want[0].Key || repairs[0].From != want[0].From ||
+				!slices.Equal(repairs[0].To, want[0].To) {
+				t.Errorf("PlanRange on %s: expected %+v, got %+v", id, want, repairs)
+			}
+		}
+	})
+
+	t.Run("node outside the range", func(t *testing.T) {
+		local := trees[owners[0].GetIdentifier()]
+		if _, err := PlanRange(local, "elsewhere", nil); !errors.Is(err, ErrNotReplica) {
+			t.Errorf("Expected ErrNotReplica, got %v", err)
+		}
+	})
+
+	t.Run("missing peer", func(t *testing.T) {
+		id := owners[0].GetIdentifier()
+		peers := map[string]Remote{owners[1].GetIdentifier(): trees[owners[1].GetIdentifier()]}
+		if _, err := PlanRange(trees[id], id, peers); !errors.Is(err, ErrNoSource) {
+			t.Errorf("Expected ErrNoSource, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,72 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package antientropy
+
+import "sync"
+
+/*
+MemorySource is an in-memory Source for tests and examples, mapping keys to versions.
+Fields:
+  - mu: Mutex guarding versions
+  - versions: Version of every key held
+*/
+type MemorySource struct {
+	mu       sync.Mutex
+	versions map[string]uint64
+}
+
+// MemorySourceInit creates an empty MemorySource
+func MemorySourceInit() *MemorySource {
+	return &MemorySource{versions: make(map[string]uint64)}
+}
+
+// Set records key at version
+func (s *MemorySource) Set(key string, version uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[key] = version
+}
+
+// Delete forgets key
+func (s *MemorySource) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.versions, key)
+}
+
+// Version returns the version of key, if held
+func (s *MemorySource) Version(key string) (uint64, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	version, ok := s.versions[key]
+	return version, ok
+}
+
+func (s *MemorySource) Scan(fn func(key string, version uint64)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, version := range s.versions {
+		fn(key, version)
+	}
+	return nil
+}
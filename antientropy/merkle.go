@@ -0,0 +1,276 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package antientropy
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"maps"
+	"slices"
+	"strings"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+Tree is a Merkle tree over the keys one replica holds in one ring range. Keys are spread
+over 2^depth leaves by their ring position; a leaf's hash combines the hashes of its
+key/version pairs independently of their order, and every inner node hashes its two
+children. Two replicas holding the same keys at the same versions have the same root, and
+when they differ only the subtrees whose hashes differ have to be looked at. Fields:
+  - Range: Ring range the tree covers
+  - depth: Number of levels below the root
+  - hashes: Node hashes in heap order, the root first and the leaves last
+  - leaves: Key versions of every leaf, nil until the leaf gets its first key
+*/
+type Tree struct {
+	Range  hashring.Range
+	depth  int
+	hashes []uint64
+	leaves []map[string]uint64
+}
+
+/*
+newTree creates an empty tree for r with 2^depth leaves, or fails with ErrInvalidDepth
+unless depth is between 0 and MaxDepth.
+*/
+func newTree(r hashring.Range, depth int) (*Tree, error) {
+	if depth < 0 || depth > MaxDepth {
+		return nil, fmt.Errorf("%w: %d", ErrInvalidDepth, depth)
+	}
+	return &Tree{
+		Range:  r,
+		depth:  depth,
+		hashes: make([]uint64, 1<<(depth+1)-1),
+		leaves: make([]map[string]uint64, 1<<depth),
+	}, nil
+}
+
+// add records key at version, keyHash is the key's ring position
+func (t *Tree) add(key string, keyHash, version uint64) {
+	i := keyHash % uint64(len(t.leaves))
+	if t.leaves[i] == nil {
+		t.leaves[i] = make(map[string]uint64)
+	}
+	t.leaves[i][key] = version
+}
+
+// build computes every hash of the tree once all keys are added
+func (t *Tree) build() {
+	first := len(t.hashes) - len(t.leaves)
+	for i, leaf := range t.leaves {
+		// Summing entry hashes makes the leaf hash independent of map iteration order
+		var sum uint64
+		for key, version := range leaf {
+			sum += entryHash(key, version)
+		}
+		t.hashes[first+i] = sum
+	}
+	var buf [16]byte
+	for i := first - 1; i >= 0; i-- {
+		binary.BigEndian.PutUint64(buf[0:8], t.hashes[2*i+1])
+		binary.BigEndian.PutUint64(buf[8:16], t.hashes[2*i+2])
+		h := fnv.New64a()
+		h.Write(buf[:])
+		t.hashes[i] = h.Sum64()
+	}
+}
+
+// Root returns the hash of the whole tree
+func (t *Tree) Root() uint64 {
+	return t.hashes[0]
+}
+
+// Depth returns the number of levels below the root
+func (t *Tree) Depth() int {
+	return t.depth
+}
+
+// Len returns the number of keys in the tree
+func (t *Tree) Len() int {
+	n := 0
+	for _, leaf := range t.leaves {
+		n += len(leaf)
+	}
+	return n
+}
+
+/*
+Hash returns the hash of the node at index in heap order: the root is 0, the children of
+node i are 2i+1 and 2i+2, and the leaves are the last 2^depth nodes. Returns
+ErrInvalidIndex if the tree has no such node.
+*/
+func (t *Tree) Hash(index int) (uint64, error) {
+	if index < 0 || index >= len(t.hashes) {
+		return 0, fmt.Errorf("%w: node %d", ErrInvalidIndex, index)
+	}
+	return t.hashes[index], nil
+}
+
+/*
+Leaf returns a copy of the key versions of the leaf at index, counted from 0 among the
+leaves. Returns ErrInvalidIndex if the tree has no such leaf.
+*/
+func (t *Tree) Leaf(index int) (map[string]uint64, error) {
+	if index < 0 || index >= len(t.leaves) {
+		return nil, fmt.Errorf("%w: leaf %d", ErrInvalidIndex, index)
+	}
+	return maps.Clone(t.leaves[index]), nil
+}
+
+// Hashes returns the hashes of the nodes at indices, see Hash
+func (t *Tree) Hashes(indices []int) ([]uint64, error) {
+	hashes := make([]uint64, len(indices))
+	for i, index := range indices {
+		hash, err := t.Hash(index)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
+// Leaves returns the key versions of the leaves at indices, see Leaf
+func (t *Tree) Leaves(indices []int) ([]map[string]uint64, error) {
+	leaves := make([]map[string]uint64, len(indices))
+	for i, index := range indices {
+		leaf, err := t.Leaf(index)
+		if err != nil {
+			return nil, err
+		}
+		leaves[i] = leaf
+	}
+	return leaves, nil
+}
+
+/*
+Remote is the tree of another replica, read through whatever transport connects the
+replicas. A *Tree is a Remote, so a replica can serve its trees by answering these calls
+from the tree it built for the range. Requests are batched per tree level, so a Diff
+takes at most depth+2 round trips.
+*/
+type Remote interface {
+	Hashes(indices []int) ([]uint64, error)
+	Leaves(indices []int) ([]map[string]uint64, error)
+}
+
+/*
+KeyDiff is a key on which two trees disagree. A and B are its versions in the first and
+second tree, MissingA and MissingB report that the respective tree does not hold the key.
+*/
+type KeyDiff struct {
+	Key      string
+	A, B     uint64
+	MissingA bool
+	MissingB bool
+}
+
+/*
+Diff returns the keys on which the local tree a and the tree b of another replica
+disagree, sorted by key. It walks the trees one level at a time, fetching from b only the
+hashes of the children of nodes that differed, and only the leaves whose hashes differ, so
+replicas that mostly agree are compared cheaply. Both trees must cover the same range
+with the same depth.
+*/
+func Diff(a *Tree, b Remote) ([]KeyDiff, error) {
+	first := len(a.hashes) - len(a.leaves)
+	level := []int{0}
+	for len(level) > 0 {
+		hashes, err := b.Hashes(level)
+		if err != nil {
+			return nil, err
+		}
+		if len(hashes) != len(level) {
+			return nil, fmt.Errorf("%w: %d hashes for %d nodes", ErrInvalidIndex, len(hashes), len(level))
+		}
+		var differ []int
+		for i, index := range level {
+			if a.hashes[index] != hashes[i] {
+				differ = append(differ, index)
+			}
+		}
+		if len(differ) == 0 || differ[0] >= first {
+			level = differ
+			break
+		}
+		level = level[:0]
+		for _, index := range differ {
+			level = append(level, 2*index+1, 2*index+2)
+		}
+	}
+	if len(level) == 0 {
+		return nil, nil
+	}
+
+	// level now holds the differing leaves in heap order
+	indices := make([]int, len(level))
+	for i, index := range level {
+		indices[i] = index - first
+	}
+	leaves, err := b.Leaves(indices)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaves) != len(indices) {
+		return nil, fmt.Errorf("%w: %d leaves for %d requested", ErrInvalidIndex, len(leaves), len(indices))
+	}
+	var diffs []KeyDiff
+	for i, index := range indices {
+		diffs = append(diffs, diffLeaf(a.leaves[index], leaves[i])...)
+	}
+	slices.SortFunc(diffs, func(x, y KeyDiff) int {
+		return strings.Compare(x.Key, y.Key)
+	})
+	return diffs, nil
+}
+
+// diffLeaf compares the key versions of two leaves
+func diffLeaf(a, b map[string]uint64) []KeyDiff {
+	var diffs []KeyDiff
+	for key, va := range a {
+		vb, ok := b[key]
+		if !ok {
+			diffs = append(diffs, KeyDiff{Key: key, A: va, MissingB: true})
+		} else if va != vb {
+			diffs = append(diffs, KeyDiff{Key: key, A: va, B: vb})
+		}
+	}
+	for key, vb := range b {
+		if _, ok := a[key]; !ok {
+			diffs = append(diffs, KeyDiff{Key: key, B: vb, MissingA: true})
+		}
+	}
+	return diffs
+}
+
+// entryHash hashes one key/version pair
+func entryHash(key string, version uint64) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	var buf [9]byte
+	binary.BigEndian.PutUint64(buf[1:], version)
+	h.Write(buf[:])
+	return h.Sum64()
+}
@@ -0,0 +1,176 @@
+package antientropy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// buildTree builds a tree covering the whole ring from the given key versions
+func buildTree(t *testing.T, versions map[string]uint64) *Tree {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	ring.AddNode(&testNode{id: "node"})
+	source := MemorySourceInit()
+	for key, version := range versions {
+		source.Set(key, version)
+	}
+	trees, err := BuildTrees(ring, "node", 1, 4, source)
+	if err != nil || len(trees) != 1 {
+		t.Fatalf("BuildTrees failed: %v", err)
+	}
+	return trees[0]
+}
+
+/*
+TestDiff tests Merkle tree comparison. It verifies that identical contents give the same
+root regardless of insertion order, and that Diff reports exactly the keys that are
+missing on either side or held at different versions.
+*/
+func TestDiff(t *testing.T) {
+	base := make(map[string]uint64)
+	for i := 0; i < 200; i++ {
+		base[fmt.Sprintf("key-%d", i)] = uint64(i)
+	}
+
+	a, b := buildTree(t, base), buildTree(t, base)
+	if a.Root() != b.Root() || a.Len() != 200 {
+		t.Fatalf("Expected identical trees, roots %d and %d", a.Root(), b.Root())
+	}
+	if diffs, err := Diff(a, b); err != nil || len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %v, %v", diffs, err)
+	}
+
+	changed := make(map[string]uint64)
+	for key, version := range base {
+		changed[key] = version
+	}
+	delete(changed, "key-1")
+	changed["key-2"] = 99
+	changed["key-new"] = 7
+	c := buildTree(t, changed)
+	if a.Root() == c.Root() {
+		t.Fatal("Expected different roots")
+	}
+
+	want := []KeyDiff{
+		{Key: "key-1", A: 1, MissingB: true},
+		{Key: "key-2", A: 2, B: 99},
+		{Key: "key-new", B: 7, MissingA: true},
+	}
+	diffs, err := Diff(a, c)
+	if err != nil || len(diffs) != len(want) {
+		t.Fatalf("Expected %v, got %v, %v", want, diffs, err)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("Expected %+v, got %+v", want[i], diffs[i])
+		}
+	}
+}
+
+// countingRemote serves a tree as a Remote and counts the hashes and leaves read from it
+type countingRemote struct {
+	tree   *Tree
+	hashes int
+	leaves int
+	calls  int
+}
+
+func (r *countingRemote) Hashes(indices []int) ([]uint64, error) {
+	r.calls++
+	r.hashes += len(indices)
+	return r.tree.Hashes(indices)
+}
+
+func (r *countingRemote) Leaves(indices []int) ([]map[string]uint64, error) {
+	r.calls++
+	r.leaves += len(indices)
+	return r.tree.Leaves(indices)
+}
+
+/*
+TestDiffRemote tests comparing against a tree read through Remote. It verifies that only
+the hashes below differing nodes and only the differing leaves are fetched, in one call
+per level, that node hashes and leaves are exported by index with bounds checks, and that
+errors from the remote are returned.
+*/
+func TestDiffRemote(t *testing.T) {
+	base := make(map[string]uint64)
+	for i := 0; i < 200; i++ {
+		base[fmt.Sprintf("key-%d", i)] = uint64(i)
+	}
+	a := buildTree(t, base)
+
+	t.Run("only differing subtrees are fetched", func(t *testing.T) {
+		changed := make(map[string]uint64)
+		for key, version := range base {
+			changed[key] = version
+		}
+		changed["key-2"] = 99
+		remote := &countingRemote{tree: buildTree(t, changed)}
+
+		diffs, err := Diff(a, remote)
+		if err != nil {
+			t.Fatalf("Diff failed: %v", err)
+		}
+		if len(diffs) != 1 || diffs[0] != (KeyDiff{Key: "key-2", A: 2, B: 99}) {
+			t.Errorf("Expected only key-2 to differ, got %+v", diffs)
+		}
+		// One path from the root to a leaf: the root, then two children per level
+		if want := 1 + 2*a.Depth(); remote.hashes != want {
+			t.Errorf("Expected %d hashes fetched, got %d", want, remote.hashes)
+		}
+		if remote.leaves != 1 || remote.calls != a.Depth()+2 {
+			t.Errorf("Expected 1 leaf in %d calls, got %d in %d", a.Depth()+2, remote.leaves, remote.calls)
+		}
+	})
+
+	t.Run("equal trees only fetch the root", func(t *testing.T) {
+		remote := &countingRemote{tree: buildTree(t, base)}
+		if diffs, err := Diff(a, remote); err != nil || len(diffs) != 0 {
+			t.Errorf("Expected no differences, got %v, %v", diffs, err)
+		}
+		if remote.hashes != 1 || remote.leaves != 0 {
+			t.Errorf("Expected only the root fetched, got %d hashes and %d leaves", remote.hashes, remote.leaves)
+		}
+	})
+
+	t.Run("hashes and leaves by index", func(t *testing.T) {
+		if root, err := a.Hash(0); err != nil || root != a.Root() {
+			t.Errorf("Expected the root at index 0, got %d, %v", root, err)
+		}
+		total := 0
+		for i := 0; i < 1<<a.Depth(); i++ {
+			leaf, err := a.Leaf(i)
+			if err != nil {
+				t.Fatalf("Leaf %d failed: %v", i, err)
+			}
+			total += len(leaf)
+		}
+		if total != a.Len() {
+			t.Errorf("Expected %d keys over the leaves, got %d", a.Len(), total)
+		}
+		for _, index := range []int{-1, 1<<(a.Depth()+1) - 1} {
+			if _, err := a.Hash(index); !errors.Is(err, ErrInvalidIndex) {
+				t.Errorf("Hash %d: expected ErrInvalidIndex, got %v", index, err)
+			}
+		}
+		if _, err := a.Leaf(1 << a.Depth()); !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("Expected ErrInvalidIndex, got %v", err)
+		}
+	})
+
+	t.Run("remote errors are returned", func(t *testing.T) {
+		shallow, err := newTree(a.Range, a.Depth()-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		shallow.build()
+		if _, err := Diff(a, shallow); !errors.Is(err, ErrInvalidIndex) {
+			t.Errorf("Expected ErrInvalidIndex comparing different depths, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,220 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package cachenode is a small distributed in-memory cache built on the hash ring: a Cache
+with TTLs, LRU eviction and a byte limit, a Server exposing it over HTTP, and a Client
+that routes every key to its owner on a HashRing and fails over to the next node
+clockwise. It is meant as a batteries-included example cluster for trying out routing,
+failover and rebalancing locally, not as a production cache.
+*/
+package cachenode
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrNotFound      = errors.New("Key not found")
+	ErrValueTooLarge = errors.New("Value is larger than the cache")
+	ErrNotPeer       = errors.New("Node is not a Peer")
+)
+
+/*
+entry is one cached value. Fields:
+  - key: Key the value is stored under
+  - value: Cached bytes
+  - expires: Time after which the entry is gone, zero for no expiry
+*/
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// size is what the entry counts against the byte limit
+func (e *entry) size() int64 {
+	return int64(len(e.key) + len(e.value))
+}
+
+// Stats are the counters of a Cache
+type Stats struct {
+	Items     int   `json:"items"`
+	Bytes     int64 `json:"bytes"`
+	MaxBytes  int64 `json:"max_bytes"`
+	Hits      int64 `json:"hits"`
+	Misses    int64 `json:"misses"`
+	Evictions int64 `json:"evictions"`
+	Expired   int64 `json:"expired"`
+}
+
+type cacheConfig struct {
+	MaxBytes int64
+	Clock    func() time.Time
+}
+
+// CacheConfigFn is a function type that modifies the cacheConfig
+type CacheConfigFn func(*cacheConfig)
+
+// SetMaxBytes returns a CacheConfigFn that sets the byte limit of keys plus values, 64 MiB by default
+func SetMaxBytes(limit int64) CacheConfigFn {
+	return func(config *cacheConfig) {
+		config.MaxBytes = limit
+	}
+}
+
+// SetClock returns a CacheConfigFn that replaces time.Now, so tests can expire entries
+func SetClock(clock func() time.Time) CacheConfigFn {
+	return func(config *cacheConfig) {
+		config.Clock = clock
+	}
+}
+
+/*
+Cache is an LRU cache bounded by the total size of its keys and values, with optional
+per-entry TTLs. Expired entries are dropped when they are next touched or when eviction
+reaches them. Fields:
+  - mu: Mutex guarding everything below, reads move entries so they need it too
+  - config: Byte limit and clock
+  - order: Entries from most to least recently used
+  - items: Elements of order keyed by entry key
+  - stats: Counters, Items and Bytes are kept current
+*/
+type Cache struct {
+	mu     sync.Mutex
+	config cacheConfig
+	order  *list.List
+	items  map[string]*list.Element
+	stats  Stats
+}
+
+// CacheInit creates an empty Cache
+func CacheInit(opts ...CacheConfigFn) *Cache {
+	config := &cacheConfig{
+		MaxBytes: 64 << 20,
+		Clock:    time.Now,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Cache{
+		config: *config,
+		order:  list.New(),
+		items:  make(map[string]*list.Element),
+		stats:  Stats{MaxBytes: config.MaxBytes},
+	}
+}
+
+// Get returns the value stored under key and marks it as recently used
+func (c *Cache) Get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.stats.Misses++
+		return nil, false
+	}
+	e := elem.Value.(*entry)
+	if c.expired(e) {
+		c.remove(elem)
+		c.stats.Expired++
+		c.stats.Misses++
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	c.stats.Hits++
+	return e.value, true
+}
+
+/*
+Set stores value under key, replacing any previous value, with a TTL of ttl or no expiry
+when ttl is zero. Least recently used entries are evicted until the cache fits its byte
+limit again. Returns ErrValueTooLarge when the entry alone exceeds the limit.
+*/
+func (c *Cache) Set(key string, value []byte, ttl time.Duration) error {
+	e := &entry{key: key, value: value}
+	if ttl > 0 {
+		e.expires = c.config.Clock().Add(ttl)
+	}
+	if e.size() > c.config.MaxBytes {
+		return fmt.Errorf("%w: %d bytes, limit is %d", ErrValueTooLarge, e.size(), c.config.MaxBytes)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.items[key]; ok {
+		c.remove(elem)
+	}
+	c.items[key] = c.order.PushFront(e)
+	c.stats.Items++
+	c.stats.Bytes += e.size()
+
+	for c.stats.Bytes > c.config.MaxBytes {
+		oldest := c.order.Back()
+		if c.expired(oldest.Value.(*entry)) {
+			c.stats.Expired++
+		} else {
+			c.stats.Evictions++
+		}
+		c.remove(oldest)
+	}
+	return nil
+}
+
+// Delete removes key and reports whether it was there
+func (c *Cache) Delete(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.items[key]
+	if !ok {
+		return false
+	}
+	expired := c.expired(elem.Value.(*entry))
+	c.remove(elem)
+	return !expired
+}
+
+// Stats returns the current counters
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// expired reports whether e is past its TTL, callers must hold c.mu
+func (c *Cache) expired(e *entry) bool {
+	return !e.expires.IsZero() && !c.config.Clock().Before(e.expires)
+}
+
+// remove drops elem from the cache, callers must hold c.mu
+func (c *Cache) remove(elem *list.Element) {
+	e := c.order.Remove(elem).(*entry)
+	delete(c.items, e.key)
+	c.stats.Items--
+	c.stats.Bytes -= e.size()
+}
@@ -0,0 +1,85 @@
+package cachenode
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+/*
+TestCache tests the LRU cache. It verifies that the least recently used entries are
+evicted to stay within the byte limit, that reads refresh recency, that entries expire
+after their TTL, that replacing a value updates the byte count, and that values larger
+than the cache are rejected.
+*/
+func TestCache(t *testing.T) {
+	t.Run("evicts least recently used", func(t *testing.T) {
+		// Every entry is 2 bytes of key and 8 of value, so 3 fit
+		c := CacheInit(SetMaxBytes(30))
+		for i := 0; i < 3; i++ {
+			c.Set(fmt.Sprintf("k%d", i), []byte("12345678"), 0)
+		}
+		c.Get("k0")
+		c.Set("k3", []byte("12345678"), 0)
+
+		if _, ok := c.Get("k1"); ok {
+			t.Error("Expected k1 to be evicted")
+		}
+		for _, key := range []string{"k0", "k2", "k3"} {
+			if _, ok := c.Get(key); !ok {
+				t.Errorf("Expected %s to be kept", key)
+			}
+		}
+		if stats := c.Stats(); stats.Bytes != 30 || stats.Items != 3 || stats.Evictions != 1 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("expires entries", func(t *testing.T) {
+		now := time.Now()
+		c := CacheInit(SetClock(func() time.Time { return now }))
+		c.Set("session", []byte("abc"), time.Minute)
+		c.Set("forever", []byte("abc"), 0)
+
+		now = now.Add(59 * time.Second)
+		if _, ok := c.Get("session"); !ok {
+			t.Error("Expected session to be alive before its TTL")
+		}
+		now = now.Add(time.Second)
+		if _, ok := c.Get("session"); ok {
+			t.Error("Expected session to expire after its TTL")
+		}
+		if _, ok := c.Get("forever"); !ok {
+			t.Error("Expected an entry without TTL to stay")
+		}
+		if stats := c.Stats(); stats.Expired != 1 || stats.Items != 1 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+	})
+
+	t.Run("replace and delete", func(t *testing.T) {
+		c := CacheInit()
+		c.Set("key", []byte("short"), 0)
+		c.Set("key", []byte("much longer value"), 0)
+		if value, _ := c.Get("key"); string(value) != "much longer value" {
+			t.Errorf("Expected the new value, got %q", value)
+		}
+		if stats := c.Stats(); stats.Bytes != int64(len("key")+len("much longer value")) || stats.Items != 1 {
+			t.Errorf("Unexpected stats %+v", stats)
+		}
+		if !c.Delete("key") || c.Delete("key") {
+			t.Error("Expected Delete to report whether the key was there")
+		}
+		if stats := c.Stats(); stats.Bytes != 0 || stats.Items != 0 {
+			t.Errorf("Expected an empty cache, got %+v", stats)
+		}
+	})
+
+	t.Run("rejects oversized values", func(t *testing.T) {
+		c := CacheInit(SetMaxBytes(10))
+		if err := c.Set("key", []byte("12345678"), 0); !errors.Is(err, ErrValueTooLarge) {
+			t.Errorf("Expected ErrValueTooLarge, got %v", err)
+		}
+	})
+}
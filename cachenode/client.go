@@ -0,0 +1,196 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cachenode
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+Peer is a ring node the Client can talk to. Nodes added to the ring used by a Client must
+implement it, Node is a ready-made implementation.
+*/
+type Peer interface {
+	hashring.CacheNode
+	BaseURL() string
+}
+
+// Node is a simple Peer identified by ID and served at URL, for example "http://10.0.0.1:8080"
+type Node struct {
+	ID  string
+	URL string
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+func (n *Node) BaseURL() string {
+	return n.URL
+}
+
+type clientConfig struct {
+	HTTPClient  *http.Client
+	MaxAttempts int
+}
+
+// ClientConfigFn is a function type that modifies the clientConfig
+type ClientConfigFn func(*clientConfig)
+
+// SetHTTPClient returns a ClientConfigFn that sets the HTTP client used to reach nodes
+func SetHTTPClient(client *http.Client) ClientConfigFn {
+	return func(config *clientConfig) {
+		config.HTTPClient = client
+	}
+}
+
+/*
+SetMaxAttempts returns a ClientConfigFn that sets how many distinct nodes a request tries,
+walking clockwise from the owner, when a node cannot be reached. The default of 2 tries
+the owner and its successor; 1 disables failover, and values below 1 are treated as 1.
+*/
+func SetMaxAttempts(attempts int) ClientConfigFn {
+	return func(config *clientConfig) {
+		config.MaxAttempts = max(attempts, 1)
+	}
+}
+
+/*
+Client talks to a cluster of Servers, sending every key to its owner on the ring. When the
+owner cannot be reached the request goes to the next node clockwise, which is where the
+key lands once the owner is removed from the ring. Fields:
+  - config: HTTP client and failover settings
+  - ring: Ring the owner of a key is looked up on
+*/
+type Client struct {
+	config clientConfig
+	ring   *hashring.HashRing
+}
+
+// ClientInit creates a Client routing through ring
+func ClientInit(ring *hashring.HashRing, opts ...ClientConfigFn) *Client {
+	config := &clientConfig{
+		HTTPClient:  &http.Client{Timeout: 5 * time.Second},
+		MaxAttempts: 2,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Client{config: *config, ring: ring}
+}
+
+// Get returns the value stored under key, or ErrNotFound
+func (c *Client) Get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := c.do(ctx, http.MethodGet, key, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return io.ReadAll(resp.Body)
+}
+
+// Set stores value under key with the given TTL, zero for no expiry
+func (c *Client) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	query := ""
+	if ttl > 0 {
+		query = "ttl=" + ttl.String()
+	}
+	resp, err := c.do(ctx, http.MethodPut, key, query, value)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// Delete removes key, or returns ErrNotFound
+func (c *Client) Delete(ctx context.Context, key string) error {
+	resp, err := c.do(ctx, http.MethodDelete, key, "", nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+/*
+do sends one request for key to its owner, failing over to the successors on network
+errors. A 404 becomes ErrNotFound and other non-2xx answers become errors, neither is
+retried since the node did answer.
+*/
+func (c *Client) do(ctx context.Context, method, key, query string, body []byte) (*http.Response, error) {
+	nodes, err := c.ring.GetNodes(key, c.config.MaxAttempts)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, node := range nodes {
+		peer, ok := node.(Peer)
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrNotPeer, node.GetIdentifier())
+		}
+		target := peer.BaseURL() + "/keys/" + url.PathEscape(key)
+		if query != "" {
+			target += "?" + query
+		}
+		req, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.config.HTTPClient.Do(req)
+		if err != nil {
+			var netErr *net.OpError
+			if ctx.Err() != nil || !errors.As(err, &netErr) {
+				return nil, err
+			}
+			errs = append(errs, fmt.Errorf("%s: %w", node.GetIdentifier(), err))
+			continue
+		}
+		switch {
+		case resp.StatusCode == http.StatusNotFound:
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: %s", ErrNotFound, key)
+		case resp.StatusCode >= 300:
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			resp.Body.Close()
+			return nil, fmt.Errorf("%s answered %s: %s", node.GetIdentifier(), resp.Status, bytes.TrimSpace(msg))
+		}
+		return resp, nil
+	}
+	if len(errs) == 0 {
+		return nil, fmt.Errorf("%w: no node tried for key %s", hashring.ErrNoConnectedNodes, key)
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,125 @@
+package cachenode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// testCluster starts count servers and returns them with a ring of their nodes
+func testCluster(t *testing.T, count int) ([]*Server, []*httptest.Server, *hashring.HashRing) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	servers := make([]*Server, count)
+	listeners := make([]*httptest.Server, count)
+	for i := range servers {
+		id := fmt.Sprintf("node-%d.local", i)
+		servers[i] = ServerInit(id, CacheInit())
+		listeners[i] = httptest.NewServer(servers[i])
+		t.Cleanup(listeners[i].Close)
+		ring.AddNode(&Node{ID: id, URL: listeners[i].URL})
+	}
+	return servers, listeners, ring
+}
+
+// holders returns the identifiers of the servers that hold key
+func holders(servers []*Server, key string) []string {
+	var ids []string
+	for _, s := range servers {
+		if _, ok := s.Cache().Get(key); ok {
+			ids = append(ids, s.ID)
+		}
+	}
+	return ids
+}
+
+/*
+TestClient tests routing through the ring. It verifies that every key is stored on its
+owner only and read back from it, that deletes and misses report ErrNotFound, and that a
+request fails over to the next node clockwise when the owner is unreachable, and that
+max attempts below one still reach the owner.
+*/
+func TestClient(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("keys are stored on their owner", func(t *testing.T) {
+		servers, _, ring := testCluster(t, 3)
+		client := ClientInit(ring)
+		for i := 0; i < 50; i++ {
+			key := fmt.Sprintf("user:%d/profile", i)
+			if err := client.Set(ctx, key, []byte(key), time.Minute); err != nil {
+				t.Fatalf("Set failed: %v", err)
+			}
+			owner, _ := ring.GetNode(key)
+			if got := holders(servers, key); len(got) != 1 || got[0] != owner.GetIdentifier() {
+				t.Fatalf("Expected %s only on %s, found on %v", key, owner.GetIdentifier(), got)
+			}
+			value, err := client.Get(ctx, key)
+			if err != nil || string(value) != key {
+				t.Fatalf("Expected %q, got %q, %v", key, value, err)
+			}
+		}
+	})
+
+	t.Run("delete and miss", func(t *testing.T) {
+		_, _, ring := testCluster(t, 3)
+		client := ClientInit(ring)
+		client.Set(ctx, "key", []byte("value"), 0)
+		if err := client.Delete(ctx, "key"); err != nil {
+			t.Fatalf("Delete failed: %v", err)
+		}
+		if _, err := client.Get(ctx, "key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound from Get, got %v", err)
+		}
+		if err := client.Delete(ctx, "key"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound from Delete, got %v", err)
+		}
+	})
+
+	t.Run("fails over when the owner is down", func(t *testing.T) {
+		servers, listeners, ring := testCluster(t, 3)
+		client := ClientInit(ring)
+		owners, _ := ring.GetNodes("user:42", 2)
+		for i, s := range servers {
+			if s.ID == owners[0].GetIdentifier() {
+				listeners[i].Close()
+			}
+		}
+
+		if err := client.Set(ctx, "user:42", []byte("alice"), 0); err != nil {
+			t.Fatalf("Expected Set to fail over, got %v", err)
+		}
+		if got := holders(servers, "user:42"); len(got) != 1 || got[0] != owners[1].GetIdentifier() {
+			t.Errorf("Expected the successor %s to hold the key, found on %v", owners[1].GetIdentifier(), got)
+		}
+
+		noFailover := ClientInit(ring, SetMaxAttempts(1))
+		if _, err := noFailover.Get(ctx, "user:42"); err == nil {
+			t.Error("Expected Get without failover to fail")
+		}
+	})
+	t.Run("max attempts below one still reach the owner", func(t *testing.T) {
+		servers, _, ring := testCluster(t, 3)
+		owner, _ := ring.GetNode("user:42")
+		for _, attempts := range []int{0, -1} {
+			client := ClientInit(ring, SetMaxAttempts(attempts))
+			if err := client.Set(ctx, "user:42", []byte("alice"), 0); err != nil {
+				t.Fatalf("Attempts %d: Set failed: %v", attempts, err)
+			}
+			if got := holders(servers, "user:42"); len(got) != 1 || got[0] != owner.GetIdentifier() {
+				t.Errorf("Attempts %d: expected the key on %s, found on %v", attempts, owner.GetIdentifier(), got)
+			}
+			if value, err := client.Get(ctx, "user:42"); err != nil || string(value) != "alice" {
+				t.Errorf("Attempts %d: expected \"alice\", got %q, %v", attempts, value, err)
+			}
+			if err := client.Delete(ctx, "user:42"); err != nil {
+				t.Errorf("Attempts %d: Delete failed: %v", attempts, err)
+			}
+		}
+	})
+}
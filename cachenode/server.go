@@ -0,0 +1,121 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package cachenode
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+/*
+Server exposes a Cache over HTTP:
+
+	GET    /keys/{key}           200 with the value, 404 when missing
+	PUT    /keys/{key}?ttl=30s   204 once stored, 413 when the value exceeds the cache
+	DELETE /keys/{key}           204 when deleted, 404 when missing
+	GET    /stats                200 with the cache Stats as JSON
+
+Keys may contain slashes. Fields:
+  - ID: Identifier of the node, reported in the X-Cache-Node response header
+  - cache: Cache being served
+  - mux: Routes of the API
+*/
+type Server struct {
+	ID    string
+	cache *Cache
+	mux   *http.ServeMux
+}
+
+// ServerInit creates a Server named id serving cache
+func ServerInit(id string, cache *Cache) *Server {
+	s := &Server{ID: id, cache: cache, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /keys/{key...}", s.get)
+	s.mux.HandleFunc("PUT /keys/{key...}", s.set)
+	s.mux.HandleFunc("DELETE /keys/{key...}", s.delete)
+	s.mux.HandleFunc("GET /stats", s.statsHandler)
+	return s
+}
+
+// Cache returns the cache the server serves
+func (s *Server) Cache() *Cache {
+	return s.cache
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Cache-Node", s.ID)
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	value, ok := s.cache.Get(r.PathValue("key"))
+	if !ok {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Write(value)
+}
+
+func (s *Server) set(w http.ResponseWriter, r *http.Request) {
+	var ttl time.Duration
+	if raw := r.URL.Query().Get("ttl"); raw != "" {
+		var err error
+		if ttl, err = time.ParseDuration(raw); err != nil || ttl < 0 {
+			http.Error(w, "invalid ttl", http.StatusBadRequest)
+			return
+		}
+	}
+
+	// Reading more than the cache can hold is pointless, the value would be rejected anyway
+	value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.cache.config.MaxBytes+1))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, ErrValueTooLarge.Error(), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := s.cache.Set(r.PathValue("key"), value, ttl); err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	if !s.cache.Delete(r.PathValue("key")) {
+		http.Error(w, ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.cache.Stats())
+}
@@ -0,0 +1,68 @@
+package cachenode
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+/*
+TestServer tests the HTTP API. It verifies the get/set/delete round trip including keys
+with slashes, TTL parsing, the 413 for values larger than the cache and the stats endpoint.
+*/
+func TestServer(t *testing.T) {
+	server := httptest.NewServer(ServerInit("node-1", CacheInit(SetMaxBytes(64))))
+	defer server.Close()
+
+	request := func(method, path, body string) *http.Response {
+		t.Helper()
+		req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		return resp
+	}
+
+	if resp := request(http.MethodPut, "/keys/users/42", "alice"); resp.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected 204 from PUT, got %d", resp.StatusCode)
+	}
+	resp := request(http.MethodGet, "/keys/users/42", "")
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || string(body) != "alice" {
+		t.Errorf("Expected 200 alice, got %d %q", resp.StatusCode, body)
+	}
+	if node := resp.Header.Get("X-Cache-Node"); node != "node-1" {
+		t.Errorf("Expected X-Cache-Node node-1, got %q", node)
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		body         string
+		want         int
+	}{
+		{"delete", http.MethodDelete, "/keys/users/42", "", http.StatusNoContent},
+		{"get deleted", http.MethodGet, "/keys/users/42", "", http.StatusNotFound},
+		{"delete missing", http.MethodDelete, "/keys/users/42", "", http.StatusNotFound},
+		{"valid ttl", http.MethodPut, "/keys/session?ttl=30s", "x", http.StatusNoContent},
+		{"invalid ttl", http.MethodPut, "/keys/session?ttl=soon", "x", http.StatusBadRequest},
+		{"too large", http.MethodPut, "/keys/big", strings.Repeat("x", 100), http.StatusRequestEntityTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if resp := request(tt.method, tt.path, tt.body); resp.StatusCode != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, resp.StatusCode)
+			}
+		})
+	}
+
+	var stats Stats
+	json.NewDecoder(request(http.MethodGet, "/stats", "").Body).Decode(&stats)
+	if stats.Items != 1 || stats.MaxBytes != 64 || stats.Hits != 1 {
+		t.Errorf("Unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Command ringsim replays a membership scenario against a key population and prints, for
+every step, the fraction of keys moved, the peak per-node load and the imbalance. Passing
+several vnode counts runs the scenario once per count, to compare them side by side:
+
+	go run ./cmd/ringsim -scenario expand.json -synthetic 100000 -vnodes 1,10,100,1000
+
+See the simulate package for the scenario format.
+*/
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+	"github.com/atharvamhaske/chash/simulate"
+)
+
+func main() {
+	scenarioPath := flag.String("scenario", "", "JSON scenario to replay (required)")
+	keysPath := flag.String("keys", "", "file with one key per line, instead of synthetic keys")
+	synthetic := flag.Int("synthetic", 100000, "number of synthetic keys when -keys is not set")
+	keySeed := flag.Uint64("key-seed", 1, "seed of the synthetic keys")
+	vnodes := flag.String("vnodes", "1,10,100", "comma-separated points per unit of weight to compare")
+	hashName := flag.String("hash", "fnv1a", "hash function: fnv1a, fnv1 or siphash")
+	sipSeed := flag.String("siphash-seed", "", "hex seed of siphash, random when empty")
+	asJSON := flag.Bool("json", false, "print the reports as JSON")
+	flag.Parse()
+
+	if *scenarioPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	file, err := os.Open(*scenarioPath)
+	if err != nil {
+		log.Fatalf("Failed to open scenario: %v", err)
+	}
+	scenario, err := simulate.LoadScenario(file)
+	file.Close()
+	if err != nil {
+		log.Fatalf("Failed to load scenario: %v", err)
+	}
+
+	keys := simulate.SyntheticKeys(*synthetic, *keySeed)
+	if *keysPath != "" {
+		file, err := os.Open(*keysPath)
+		if err != nil {
+			log.Fatalf("Failed to open keys: %v", err)
+		}
+		keys, err = simulate.ReadKeys(file)
+		file.Close()
+		if err != nil {
+			log.Fatalf("Failed to read keys: %v", err)
+		}
+	}
+
+	hashFn, err := hashFunction(*hashName, *sipSeed)
+	if err != nil {
+		log.Fatal(err)
+	}
+	counts, err := parseCounts(*vnodes)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var reports []simulate.Report
+	for _, count := range counts {
+		report, err := simulate.Run(scenario, keys, simulate.SetVNodes(count), simulate.SetHashFunction(hashFn))
+		if err != nil {
+			log.Fatalf("Simulation with %d vnodes failed: %v", count, err)
+		}
+		reports = append(reports, report)
+	}
+
+	if *asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(reports); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	for i, report := range reports {
+		if i > 0 {
+			fmt.Println()
+		}
+		printReport(report)
+	}
+}
+
+// hashFunction resolves the -hash flag, with a random seed for siphash unless one is given
+func hashFunction(name, seedText string) (func() hash.Hash64, error) {
+	switch name {
+	case "fnv1a":
+		return fnv.New64a, nil
+	case "fnv1":
+		return fnv.New64, nil
+	case "siphash":
+		seed, err := hashring.NewSeed()
+		if seedText != "" {
+			seed, err = hashring.ParseSeed(seedText)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return hashring.SipHash(seed), nil
+	default:
+		return nil, fmt.Errorf("unknown hash %q", name)
+	}
+}
+
+// parseCounts parses the comma-separated -vnodes flag
+func parseCounts(text string) ([]int, error) {
+	var counts []int
+	for _, field := range strings.Split(text, ",") {
+		count, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil || count < 1 {
+			return nil, fmt.Errorf("invalid vnode count %q", field)
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+func printReport(report simulate.Report) {
+	fmt.Printf("vnodes=%d keys=%d\n", report.VNodes, report.Keys)
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "step\tnodes\tpoints\tmoved\tpeak load\tpeak node\timbalance\t")
+	for _, step := range report.Steps {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.2f%%\t%d\t%s\t%.3f\t\n",
+			step.Name, step.Nodes, step.Points, step.Moved*100, step.PeakLoad, step.PeakNode, step.Imbalance)
+	}
+	w.Flush()
+}
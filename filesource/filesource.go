@@ -0,0 +1,364 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package filesource keeps a HashRing in sync with a node list kept in a JSON file, so that
+membership can be distributed through config management. A Watcher polls the file, and
+when its content changes it validates the new list, computes the minimal set of nodes to
+add and remove, and applies them to the ring in one atomic CompareAndApply. A file that
+does not parse or fails validation is rejected and the ring keeps its previous membership.
+
+The file looks like this, weight defaults to 1 and zone is optional:
+
+	{
+	  "nodes": [
+	    {"id": "cache-1", "addr": "10.0.0.1:11211", "weight": 2, "zone": "us-east-1a"},
+	    {"id": "cache-2", "addr": "10.0.0.2:11211", "zone": "us-east-1b"}
+	  ]
+	}
+*/
+package filesource
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// applyAttempts is how often an update is retried when the ring changes under it
+const applyAttempts = 5
+
+// Global error variables which has all error types to return
+var (
+	ErrInvalidNodeList = errors.New("Invalid node list")
+	ErrWatcherClosed   = errors.New("Watcher is closed")
+	ErrInvalidInterval = errors.New("Poll interval must be positive")
+)
+
+/*
+Node is one entry of the node list and the CacheNode the Watcher adds to the ring, so
+lookups return *Node values carrying the address, weight and zone of the owner. The ring
+places every node once, the weight is carried for consumers that use it.
+*/
+type Node struct {
+	ID     string `json:"id"`
+	Addr   string `json:"addr"`
+	Weight int    `json:"weight,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+// GetZone makes Node a hashring.ZonedNode, so lookups can prefer a zone
+func (n *Node) GetZone() string {
+	return n.Zone
+}
+
+// nodeList is the document stored in the file
+type nodeList struct {
+	Nodes []Node `json:"nodes"`
+}
+
+type watcherConfig struct {
+	PollInterval time.Duration
+	OnReload     func(added, removed []Node, err error)
+}
+
+// WatcherConfigFn is a function type that modifies the watcherConfig
+type WatcherConfigFn func(*watcherConfig)
+
+// SetPollInterval returns a WatcherConfigFn that sets how often the file is checked, positive and 5s by default
+func SetPollInterval(interval time.Duration) WatcherConfigFn {
+	return func(config *watcherConfig) {
+		config.PollInterval = interval
+	}
+}
+
+/*
+SetOnReload returns a WatcherConfigFn that registers a callback invoked every time the
+file changed: with the nodes added and removed on success (a node whose address, weight
+or zone changed is in both), or with the error that got the new content rejected. It runs
+on the polling goroutine and must not block.
+*/
+func SetOnReload(fn func(added, removed []Node, err error)) WatcherConfigFn {
+	return func(config *watcherConfig) {
+		config.OnReload = fn
+	}
+}
+
+/*
+Watcher keeps a HashRing in sync with a node list file. Fields:
+  - mu: Mutex serializing reloads
+  - config: Poll interval and reload callback
+  - path: Path of the node list file
+  - ring: Ring the nodes are applied to
+  - current: Nodes from the file that are on the ring, keyed by identifier
+  - content: Content of the file last applied or rejected as invalid
+  - closed: Set by Close
+  - stop: Closed by Close to end the polling goroutine
+  - done: Closed when the polling goroutine has exited
+*/
+type Watcher struct {
+	mu      sync.Mutex
+	config  watcherConfig
+	path    string
+	ring    *hashring.HashRing
+	current map[string]*Node
+	content []byte
+	closed  bool
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+/*
+WatcherInit loads the node list at path into ring and starts polling the file for changes.
+The initial load must succeed, an unreadable or invalid file fails WatcherInit, and a poll
+interval that is not positive fails with ErrInvalidInterval. The ring may hold other nodes
+as well, the Watcher only ever adds and removes the ones listed in the file.
+*/
+func WatcherInit(path string, ring *hashring.HashRing, opts ...WatcherConfigFn) (*Watcher, error) {
+	config := &watcherConfig{
+		PollInterval: 5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.PollInterval <= 0 {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidInterval, config.PollInterval)
+	}
+
+	w := &Watcher{
+		config:  *config,
+		path:    path,
+		ring:    ring,
+		current: make(map[string]*Node),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err := w.apply(content); err != nil {
+		return nil, err
+	}
+	go w.poll()
+	return w, nil
+}
+
+// poll reloads the file every poll interval until Close
+func (w *Watcher) poll() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Reload()
+		}
+	}
+}
+
+/*
+Reload reads the file now instead of waiting for the next poll and applies it if its
+content changed since it was last applied. Returns the error that got the content
+rejected, in which case the ring keeps its previous membership. Content that fails
+validation is not retried until the file changes again; content the ring refused, for
+example because it kept changing under the Watcher, is retried on the next poll.
+*/
+func (w *Watcher) Reload() error {
+	content, err := os.ReadFile(w.path)
+	if err != nil {
+		w.notify(nil, nil, err)
+		return err
+	}
+
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWatcherClosed
+	}
+	if bytes.Equal(content, w.content) {
+		w.mu.Unlock()
+		return nil
+	}
+	added, removed, err := w.apply(content)
+	w.mu.Unlock()
+
+	w.notify(added, removed, err)
+	return err
+}
+
+func (w *Watcher) notify(added, removed []Node, err error) {
+	if w.config.OnReload != nil {
+		w.config.OnReload(added, removed, err)
+	}
+}
+
+/*
+apply validates content and applies the difference to the currently applied nodes to the
+ring, callers must hold w.mu. The changes go through CompareAndApply, so either all of
+them are applied or none is; when another writer changes the ring in between, the batch
+is retried against the new epoch.
+*/
+func (w *Watcher) apply(content []byte) ([]Node, []Node, error) {
+	nodes, err := parse(content)
+	if err != nil {
+		w.content = content
+		return nil, nil, err
+	}
+
+	changes, added, removed := w.delta(nodes)
+	if len(changes) > 0 {
+		for attempt := 1; ; attempt++ {
+			_, err = w.ring.CompareAndApply(w.ring.Epoch(), changes)
+			if !errors.Is(err, hashring.ErrEpochMismatch) || attempt == applyAttempts {
+				break
+			}
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	next := make(map[string]*Node, len(nodes))
+	for i := range nodes {
+		if node, ok := w.current[nodes[i].ID]; ok && *node == nodes[i] {
+			next[node.ID] = node
+		} else {
+			next[nodes[i].ID] = &nodes[i]
+		}
+	}
+	w.current = next
+	w.content = content
+	return added, removed, nil
+}
+
+/*
+delta returns the ring changes that turn the applied nodes into nodes: removals of nodes
+that are gone or changed, then additions of nodes that are new or changed. Unchanged nodes
+are left alone so their keys do not move.
+*/
+func (w *Watcher) delta(nodes []Node) ([]hashring.Change, []Node, []Node) {
+	wanted := make(map[string]*Node, len(nodes))
+	for i := range nodes {
+		wanted[nodes[i].ID] = &nodes[i]
+	}
+
+	var changes []hashring.Change
+	var added, removed []Node
+	for _, id := range sortedIDs(w.current) {
+		node := w.current[id]
+		if want, ok := wanted[id]; !ok || *want != *node {
+			changes = append(changes, hashring.Change{Op: hashring.MembershipRemove, Node: node})
+			removed = append(removed, *node)
+		}
+	}
+	for i := range nodes {
+		if node, ok := w.current[nodes[i].ID]; !ok || *node != nodes[i] {
+			changes = append(changes, hashring.Change{Op: hashring.MembershipAdd, Node: &nodes[i]})
+			added = append(added, nodes[i])
+		}
+	}
+	return changes, added, removed
+}
+
+/*
+parse decodes and validates a node list: every node needs a unique non-empty identifier
+and an address, and the weight must not be negative. A missing weight defaults to 1.
+*/
+func parse(content []byte) ([]Node, error) {
+	var list nodeList
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&list); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidNodeList, err)
+	}
+
+	seen := make(map[string]bool, len(list.Nodes))
+	for i := range list.Nodes {
+		node := &list.Nodes[i]
+		node.ID = strings.TrimSpace(node.ID)
+		switch {
+		case node.ID == "":
+			return nil, fmt.Errorf("%w: node %d has no id", ErrInvalidNodeList, i)
+		case seen[node.ID]:
+			return nil, fmt.Errorf("%w: duplicate node %s", ErrInvalidNodeList, node.ID)
+		case node.Addr == "":
+			return nil, fmt.Errorf("%w: node %s has no addr", ErrInvalidNodeList, node.ID)
+		case node.Weight < 0:
+			return nil, fmt.Errorf("%w: node %s has negative weight %d", ErrInvalidNodeList, node.ID, node.Weight)
+		}
+		if node.Weight == 0 {
+			node.Weight = 1
+		}
+		seen[node.ID] = true
+	}
+	return list.Nodes, nil
+}
+
+// Nodes returns the nodes from the file that are currently on the ring, sorted by identifier
+func (w *Watcher) Nodes() []Node {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	nodes := make([]Node, 0, len(w.current))
+	for _, id := range sortedIDs(w.current) {
+		nodes = append(nodes, *w.current[id])
+	}
+	return nodes
+}
+
+// Close stops polling. The ring keeps the nodes that were applied.
+func (w *Watcher) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return ErrWatcherClosed
+	}
+	w.closed = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return nil
+}
+
+func sortedIDs(nodes map[string]*Node) []string {
+	ids := make([]string, 0, len(nodes))
+	for id := range nodes {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
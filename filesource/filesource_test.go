@@ -0,0 +1,235 @@
+package filesource
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// writeList writes content to the node list file at path
+func writeList(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write node list: %v", err)
+	}
+}
+
+// ringNodes returns the sorted identifiers of every node on ring
+func ringNodes(ring *hashring.HashRing) []string {
+	nodes, err := ring.GetNodes("", 1<<20)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, node := range nodes {
+		ids = append(ids, node.GetIdentifier())
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+const threeNodes = `{"nodes": [
+	{"id": "cache-1", "addr": "10.0.0.1:11211", "weight": 2, "zone": "a"},
+	{"id": "cache-2", "addr": "10.0.0.2:11211", "zone": "b"},
+	{"id": "cache-3", "addr": "10.0.0.3:11211", "zone": "a"}
+]}`
+
+/*
+TestWatcher tests the file-based membership source. It verifies the initial load, that a
+changed file is applied as the minimal delta in one epoch, that nodes the file does not
+own are left alone, that invalid files are rejected while the ring keeps its nodes, that
+content the ring refused is retried, that poll intervals must be positive, and that
+polling picks up changes on its own.
+*/
+func TestWatcher(t *testing.T) {
+	t.Run("initial load", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		ring := hashring.HashRingInit()
+		w, err := WatcherInit(path, ring, SetPollInterval(time.Hour))
+		if err != nil {
+			t.Fatalf("WatcherInit failed: %v", err)
+		}
+		defer w.Close()
+
+		if got := ringNodes(ring); !slices.Equal(got, []string{"cache-1", "cache-2", "cache-3"}) {
+			t.Errorf("Unexpected ring nodes %v", got)
+		}
+		node, _ := ring.GetNode("user:42")
+		if n := node.(*Node); n.Addr == "" || n.Zone == "" || n.Weight == 0 {
+			t.Errorf("Expected the node to carry its attributes, got %+v", n)
+		}
+		if nodes := w.Nodes(); nodes[1].Weight != 1 {
+			t.Errorf("Expected a missing weight to default to 1, got %d", nodes[1].Weight)
+		}
+	})
+
+	t.Run("changes are applied as a minimal delta", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		ring := hashring.HashRingInit()
+		ring.AddNode(&Node{ID: "unmanaged", Addr: "10.0.0.9:11211"})
+
+		var added, removed []Node
+		w, err := WatcherInit(path, ring, SetPollInterval(time.Hour), SetOnReload(func(a, r []Node, err error) {
+			added, removed = a, r
+		}))
+		if err != nil {
+			t.Fatalf("WatcherInit failed: %v", err)
+		}
+		defer w.Close()
+
+		// cache-1 is dropped, cache-3 moves, cache-2 is untouched and cache-4 is new
+		epoch := ring.Epoch()
+		writeList(t, path, `{"nodes": [
+			{"id": "cache-2", "addr": "10.0.0.2:11211", "zone": "b"},
+			{"id": "cache-3", "addr": "10.0.1.3:11211", "zone": "a"},
+			{"id": "cache-4", "addr": "10.0.0.4:11211", "zone": "b"}
+		]}`)
+		if err := w.Reload(); err != nil {
+			t.Fatalf("Reload failed: %v", err)
+		}
+
+		if got := ringNodes(ring); !slices.Equal(got, []string{"cache-2", "cache-3", "cache-4", "unmanaged"}) {
+			t.Errorf("Unexpected ring nodes %v", got)
+		}
+		if ring.Epoch() != epoch+1 {
+			t.Errorf("Expected the delta to be applied in one epoch, went from %d to %d", epoch, ring.Epoch())
+		}
+		if len(added) != 2 || len(removed) != 2 {
+			t.Errorf("Expected 2 added and 2 removed, got %v and %v", added, removed)
+		}
+		for _, node := range append(added, removed...) {
+			if node.ID == "cache-2" {
+				t.Error("Expected the unchanged node to be left alone")
+			}
+		}
+
+		// The moved node is on the ring with its new address
+		nodes, _ := ring.GetNodes("", 10)
+		for _, node := range nodes {
+			if n, ok := node.(*Node); ok && n.ID == "cache-3" && n.Addr != "10.0.1.3:11211" {
+				t.Errorf("Expected cache-3 at its new address, got %s", n.Addr)
+			}
+		}
+
+		// Unchanged content is a no-op
+		epoch = ring.Epoch()
+		w.Reload()
+		if ring.Epoch() != epoch {
+			t.Error("Expected reloading unchanged content not to touch the ring")
+		}
+	})
+
+	t.Run("invalid files keep the old ring", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		ring := hashring.HashRingInit()
+		w, err := WatcherInit(path, ring, SetPollInterval(time.Hour))
+		if err != nil {
+			t.Fatalf("WatcherInit failed: %v", err)
+		}
+		defer w.Close()
+		before := ring.ContentHash()
+
+		tests := []struct {
+			name    string
+			content string
+		}{
+			{"truncated json", `{"nodes": [{"id": "cache-1"`},
+			{"missing id", `{"nodes": [{"addr": "10.0.0.1:11211"}]}`},
+			{"missing addr", `{"nodes": [{"id": "cache-1"}]}`},
+			{"duplicate id", `{"nodes": [{"id": "a", "addr": "x:1"}, {"id": "a", "addr": "y:1"}]}`},
+			{"negative weight", `{"nodes": [{"id": "a", "addr": "x:1", "weight": -1}]}`},
+			{"unknown field", `{"nodes": [{"id": "a", "addr": "x:1", "wieght": 3}]}`},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				writeList(t, path, tt.content)
+				if err := w.Reload(); !errors.Is(err, ErrInvalidNodeList) {
+					t.Errorf("Expected ErrInvalidNodeList, got %v", err)
+				}
+				if ring.ContentHash() != before || len(w.Nodes()) != 3 {
+					t.Error("Expected the ring to keep its nodes")
+				}
+			})
+		}
+
+		if _, err := WatcherInit(path, hashring.HashRingInit()); !errors.Is(err, ErrInvalidNodeList) {
+			t.Errorf("Expected WatcherInit to reject an invalid file, got %v", err)
+		}
+	})
+
+	t.Run("content refused by the ring is retried", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		ring := hashring.HashRingInit()
+		w, err := WatcherInit(path, ring, SetPollInterval(time.Hour))
+		if err != nil {
+			t.Fatalf("WatcherInit failed: %v", err)
+		}
+		defer w.Close()
+
+		// Another writer holds cache-4, so the ring refuses the batch adding it
+		other := &Node{ID: "cache-4", Addr: "10.0.0.9:11211"}
+		ring.AddNode(other)
+		writeList(t, path, `{"nodes": [{"id": "cache-1", "addr": "10.0.0.1:11211"}, {"id": "cache-4", "addr": "10.0.0.4:11211"}]}`)
+		if err := w.Reload(); !errors.Is(err, hashring.ErrNodeExits) {
+			t.Fatalf("Expected ErrNodeExits, got %v", err)
+		}
+
+		// The file is unchanged, but the content was never applied so it is tried again
+		ring.RemoveNode(other)
+		if err := w.Reload(); err != nil {
+			t.Fatalf("Expected the retry to succeed, got %v", err)
+		}
+		if got := ringNodes(ring); !slices.Equal(got, []string{"cache-1", "cache-4"}) {
+			t.Errorf("Unexpected ring nodes %v", got)
+		}
+	})
+
+	t.Run("poll interval must be positive", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		for _, interval := range []time.Duration{0, -time.Second} {
+			if _, err := WatcherInit(path, hashring.HashRingInit(), SetPollInterval(interval)); !errors.Is(err, ErrInvalidInterval) {
+				t.Errorf("Interval %v: expected ErrInvalidInterval, got %v", interval, err)
+			}
+		}
+	})
+
+	t.Run("polling picks up changes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nodes.json")
+		writeList(t, path, threeNodes)
+		ring := hashring.HashRingInit()
+
+		reloaded := make(chan struct{}, 1)
+		w, err := WatcherInit(path, ring, SetPollInterval(10*time.Millisecond), SetOnReload(func(_, _ []Node, err error) {
+			if err == nil {
+				select {
+				case reloaded <- struct{}{}:
+				default:
+				}
+			}
+		}))
+		if err != nil {
+			t.Fatalf("WatcherInit failed: %v", err)
+		}
+		defer w.Close()
+
+		writeList(t, path, `{"nodes": [{"id": "cache-1", "addr": "10.0.0.1:11211"}]}`)
+		select {
+		case <-reloaded:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Polling did not pick up the change")
+		}
+		if got := ringNodes(ring); !slices.Equal(got, []string{"cache-1"}) {
+			t.Errorf("Unexpected ring nodes %v", got)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// ErrShortOutput is returned by GetNodesForKeysInto when out cannot hold a node per key
+var ErrShortOutput = errors.New("Output slice is shorter than the key slice")
+
+/*
+GetNodesForKeys maps many keys to their owners in one pass and returns the keys grouped
+by owning node, which is the shape a client wants for issuing one multi-get per node.
+All keys are resolved against the same snapshot of the ring under a single read lock, so
+a concurrent AddNode or RemoveNode cannot split the batch across two placements. The only
+allocations are the result map and one key slice per distinct node. On an empty ring the
+result is empty; use GetNodesForKeysInto when the reason a batch failed matters.
+*/
+func (ring *HashRing) GetNodesForKeys(keys []string) map[CacheNode][]string {
+	start := time.Now()
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	if len(ring.sortedKeyOfNodes) == 0 {
+		return map[CacheNode][]string{}
+	}
+
+	result := make(map[CacheNode][]string, len(ring.sortedKeyOfNodes))
+	if err := ring.lookupBatchLocked(keys, start, func(i int, node CacheNode) {
+		result[node] = append(result[node], keys[i])
+	}); err != nil {
+		return map[CacheNode][]string{}
+	}
+	return result
+}
+
+/*
+GetNodesForKeysInto is the allocation-free form of GetNodesForKeys: it writes the owner of
+keys[i] into out[i] instead of building a map. The hasher and the scratch buffer used to
+hash the keys are created once per call and reused for every key, so the cost of a batch
+does not grow in allocations with its size. A KeyExtractor that allocates still does so
+per key. Returns ErrShortOutput if out is shorter than keys and ErrNoConnectedNodes if the
+ring is empty; on error out is left in an unspecified state.
+*/
+func (ring *HashRing) GetNodesForKeysInto(keys []string, out []CacheNode) error {
+	if len(out) < len(keys) {
+		return fmt.Errorf("%w: %d keys, %d slots", ErrShortOutput, len(keys), len(out))
+	}
+
+	start := time.Now()
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	if len(ring.sortedKeyOfNodes) == 0 {
+		return ErrNoConnectedNodes
+	}
+	return ring.lookupBatchLocked(keys, start, func(i int, node CacheNode) {
+		out[i] = node
+	})
+}
+
+/*
+lookupBatchLocked hashes every key with one reused hasher, finds its owner and hands it to
+emit together with the index of the key. Callers must hold ring.mu and have checked that
+the ring is not empty.
+*/
+func (ring *HashRing) lookupBatchLocked(keys []string, start time.Time, emit func(int, CacheNode)) error {
+	h := ring.config.HashFunction()
+	var buf []byte
+	for i, key := range keys {
+		buf = append(buf[:0], ring.extractKey(key)...)
+		h.Reset()
+		if _, err := h.Write(buf); err != nil {
+			return fmt.Errorf("%w: %s", ErrInHashingKey, key)
+		}
+
+		index, _ := ring.binarySearch(int64(h.Sum64()))
+		value, ok := ring.nodes.Load(uint64(ring.sortedKeyOfNodes[index]))
+		if !ok {
+			return fmt.Errorf("%w: no node found for key %s", ErrNodeNotFound, key)
+		}
+		node := value.(CacheNode)
+		if ring.config.Metrics != nil {
+			ring.config.Metrics.ObserveLookup(node.GetIdentifier(), time.Since(start))
+		}
+		emit(i, node)
+	}
+
+	if ring.logEnabled(slog.LevelDebug) {
+		ring.config.Logger.LogAttrs(context.Background(), slog.LevelDebug, "mapped key batch",
+			slog.String("op", "lookup_batch"),
+			slog.Int("keys", len(keys)),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+/*
+TestBatchLookup tests GetNodesForKeys and GetNodesForKeysInto. It verifies that every key
+lands on the node GetNode picks for it, that the grouped form holds each key exactly once,
+that the index form does not allocate per key, and that an empty ring and a short output
+slice are reported.
+*/
+func TestBatchLookup(t *testing.T) {
+	ring := HashRingInit()
+	for i := 0; i < 8; i++ {
+		ring.AddNode(&mockNode{identifier: fmt.Sprintf("node-%d.local", i)})
+	}
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user:%d", i)
+	}
+
+	t.Run("index form agrees with GetNode", func(t *testing.T) {
+		out := make([]CacheNode, len(keys))
+		if err := ring.GetNodesForKeysInto(keys, out); err != nil {
+			t.Fatalf("GetNodesForKeysInto failed: %v", err)
+		}
+		for i, key := range keys {
+			want, _ := ring.GetNode(key)
+			if out[i] != want {
+				t.Fatalf("Key %s: batch picked %s, GetNode picked %s", key, out[i].GetIdentifier(), want.GetIdentifier())
+			}
+		}
+	})
+
+	t.Run("grouped form holds every key once under its owner", func(t *testing.T) {
+		groups := ring.GetNodesForKeys(keys)
+		total := 0
+		for node, group := range groups {
+			for _, key := range group {
+				want, _ := ring.GetNode(key)
+				if node != want {
+					t.Errorf("Key %s grouped under %s, GetNode picked %s", key, node.GetIdentifier(), want.GetIdentifier())
+				}
+			}
+			total += len(group)
+		}
+		if total != len(keys) {
+			t.Errorf("Expected %d grouped keys, got %d", len(keys), total)
+		}
+	})
+
+	t.Run("index form does not allocate per key", func(t *testing.T) {
+		out := make([]CacheNode, len(keys))
+		allocs := testing.AllocsPerRun(100, func() {
+			ring.GetNodesForKeysInto(keys, out)
+		})
+		// The hasher and the scratch buffer are created once per batch
+		if allocs > 3 {
+			t.Errorf("Expected a constant number of allocations per batch, got %.0f for %d keys", allocs, len(keys))
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		empty := HashRingInit()
+		if err := empty.GetNodesForKeysInto(keys, make([]CacheNode, len(keys))); err != ErrNoConnectedNodes {
+			t.Errorf("Expected ErrNoConnectedNodes, got %v", err)
+		}
+		if groups := empty.GetNodesForKeys(keys); len(groups) != 0 {
+			t.Errorf("Expected no groups on an empty ring, got %d", len(groups))
+		}
+		if err := ring.GetNodesForKeysInto(keys, make([]CacheNode, 1)); !errors.Is(err, ErrShortOutput) {
+			t.Errorf("Expected ErrShortOutput, got %v", err)
+		}
+	})
+}
@@ -2,6 +2,8 @@ package hashring
 
 import (
 	"hash/fnv"
+	"math/rand"
+	"strconv"
 	"testing"
 )
 
@@ -46,6 +48,27 @@ func BenchmarkGetNode(b *testing.B) {
 	}
 }
 
+func BenchmarkGetNodesForKeys(b *testing.B) {
+	ring := HashRingInit()
+	// Add 100 nodes
+	for i := 0; i < 100; i++ {
+		node := &benchmarkNode{identifier: "node" + string(rune(i))}
+		ring.AddNode(node)
+	}
+
+	// A 500-key multiget resolved in one call
+	keys := make([]string, 500)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+	out := make([]CacheNode, len(keys))
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ring.GetNodesForKeysInto(keys, out)
+	}
+}
+
 func BenchmarkRemoveNode(b *testing.B) {
 	ring := HashRingInit()
 	nodes := make([]*benchmarkNode, b.N+10)
@@ -99,6 +122,28 @@ func BenchmarkBinarySearch(b *testing.B) {
 	}
 }
 
+func BenchmarkLookupTable(b *testing.B) {
+	// 100k positions, the size where binary search dominates the lookup cost
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]int64, 1<<16)
+	for i := range hashes {
+		hashes[i] = int64(rng.Uint64())
+	}
+
+	for _, bits := range []int{0, 18} {
+		ring := syntheticRing(100000, rng, SetLookupTableBits(bits))
+		name := "binary search"
+		if bits > 0 {
+			name = "table"
+		}
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				ring.binarySearch(hashes[i&(len(hashes)-1)])
+			}
+		})
+	}
+}
+
 func BenchmarkHashRingWithCustomHash(b *testing.B) {
 	ring := HashRingInit(SetHashFunction(fnv.New64))
 	for i := 0; i < 100; i++ {
@@ -114,3 +159,33 @@ func BenchmarkHashRingWithCustomHash(b *testing.B) {
 		ring.GetNode(key)
 	}
 }
+
+func BenchmarkLookupTableClustered(b *testing.B) {
+	// Sequential IDs hash close together under FNV-1a and crowd a few buckets
+	rng := rand.New(rand.NewSource(1))
+	hashes := make([]int64, 1<<16)
+	for i := range hashes {
+		hashes[i] = int64(rng.Uint64())
+	}
+	ring := HashRingInit(SetLookupTableBits(10))
+	for i := 0; i < 10000; i++ {
+		ring.AddNode(&benchmarkNode{identifier: "node-" + strconv.Itoa(i)})
+	}
+	b.Run("node IDs", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			ring.binarySearch(hashes[i&(len(hashes)-1)])
+		}
+	})
+
+	// Worst case: every position in the bucket of the keys looked up
+	packed := HashRingInit(SetLookupTableBits(10))
+	for i := 0; i < 100000; i++ {
+		packed.sortedKeyOfNodes = append(packed.sortedKeyOfNodes, int64(i))
+	}
+	packed.rebuildLookupTableLocked()
+	b.Run("one bucket", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			packed.binarySearch(hashes[i&(len(hashes)-1)] & 0xffff)
+		}
+	})
+}
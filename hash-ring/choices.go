@@ -0,0 +1,126 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+LoadReporter tells a two-choices lookup how loaded a node currently is, in any unit as
+long as lower means less loaded: in-flight requests, queue depth, a smoothed latency.
+It is called without the ring's lock held, once per candidate, and must not block.
+*/
+type LoadReporter interface {
+	Load(node CacheNode) float64
+}
+
+// LoadReporterFunc adapts a plain function to a LoadReporter
+type LoadReporterFunc func(node CacheNode) float64
+
+func (f LoadReporterFunc) Load(node CacheNode) float64 {
+	return f(node)
+}
+
+/*
+TwoChoices returns a LookupOption that applies the power of two choices: the lookup picks
+the owner of the key as usual and the next eligible node clockwise, the key's first
+replica, and returns whichever loads reports as less loaded, the owner on a tie. Every key
+stays on one of two nodes, so this suits stateless backends and replicated data readable
+from either replica, while transient hot spots spill over to the neighbour. The second
+candidate passes the same exclusions and states as the owner, and when a preferred zone
+holds the owner it must be in that zone too. The owner passed over is reported to the
+LookupTracer as skipped.
+*/
+func TwoChoices(loads LoadReporter) LookupOption {
+	return func(o *lookupOptions) {
+		o.loads = loads
+		o.rehash = false
+	}
+}
+
+/*
+TwoChoicesByHash is TwoChoices with the second candidate taken from an alternate hash of
+the key instead of the next node clockwise. With the next replica, a hot node always
+spills onto the same successor; the alternate hash spreads its keys over the whole ring,
+which balances stateless backends better. Keys owned by the same node still share their
+owner, so use TwoChoices when the second candidate has to hold a replica of the data.
+*/
+func TwoChoicesByHash(loads LoadReporter) LookupOption {
+	return func(o *lookupOptions) {
+		o.loads = loads
+		o.rehash = true
+	}
+}
+
+// alternateSalt is fed to the hash function before the key to place its alternate position
+var alternateSalt = []byte("\xffalternate\x00")
+
+// alternateHash returns the alternate position of an already extracted key
+func (ring *HashRing) alternateHash(key string) (uint64, error) {
+	h := ring.config.HashFunction()
+	if _, err := h.Write(alternateSalt); err != nil {
+		return 0, err
+	}
+	if _, err := h.Write([]byte(key)); err != nil {
+		return 0, err
+	}
+	return h.Sum64(), nil
+}
+
+/*
+InFlight is a LoadReporter counting the requests in flight to every node, the usual load
+signal for two-choices routing in a proxy: Acquire the picked node before forwarding and
+call the returned release once the response is done. The zero value is ready to use and
+safe for concurrent use.
+*/
+type InFlight struct {
+	counts sync.Map // node identifier -> *atomic.Int64
+}
+
+// Acquire counts one more request in flight to node and returns the function that ends it
+func (f *InFlight) Acquire(node CacheNode) (release func()) {
+	count := f.counter(node.GetIdentifier())
+	count.Add(1)
+	var once sync.Once
+	return func() {
+		once.Do(func() { count.Add(-1) })
+	}
+}
+
+// Load returns the number of requests in flight to node
+func (f *InFlight) Load(node CacheNode) float64 {
+	if count, ok := f.counts.Load(node.GetIdentifier()); ok {
+		return float64(count.(*atomic.Int64).Load())
+	}
+	return 0
+}
+
+func (f *InFlight) counter(id string) *atomic.Int64 {
+	if count, ok := f.counts.Load(id); ok {
+		return count.(*atomic.Int64)
+	}
+	count, _ := f.counts.LoadOrStore(id, new(atomic.Int64))
+	return count.(*atomic.Int64)
+}
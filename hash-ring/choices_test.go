@@ -0,0 +1,126 @@
+package hashring
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// fixedLoads is a LoadReporter with a preset load per node identifier
+type fixedLoads map[string]float64
+
+func (f fixedLoads) Load(node CacheNode) float64 {
+	return f[node.GetIdentifier()]
+}
+
+/*
+TestTwoChoices tests power-of-two-choices lookups. It verifies that the owner wins unless
+its replica or alternate candidate is strictly less loaded, that the second candidate
+honours exclusions and the preferred zone, that the tracer sees the owner passed over,
+and that routing through InFlight spreads a hot owner's requests over two nodes.
+*/
+func TestTwoChoices(t *testing.T) {
+	tracer := &recordingTracer{}
+	ring := HashRingInit(SetLookupTracer(tracer))
+	zones := []string{"a", "b", "a", "b", "c"}
+	for i, zone := range zones {
+		ring.AddNode(&zonedNode{mockNode: mockNode{identifier: fmt.Sprintf("node-%d.local", i)}, zone: zone})
+	}
+	ctx := context.Background()
+	key := "user:42"
+	order, _ := ring.GetNodes(key, len(zones))
+	owner, replica := order[0].GetIdentifier(), order[1].GetIdentifier()
+
+	t.Run("owner wins ties", func(t *testing.T) {
+		node, err := ring.GetNodeContext(ctx, key, TwoChoices(fixedLoads{}))
+		if err != nil {
+			t.Fatalf("GetNodeContext failed: %v", err)
+		}
+		if node.GetIdentifier() != owner {
+			t.Errorf("Expected the owner %s on equal loads, got %s", owner, node.GetIdentifier())
+		}
+	})
+
+	t.Run("less loaded replica wins", func(t *testing.T) {
+		node, _ := ring.GetNodeContext(ctx, key, TwoChoices(fixedLoads{owner: 5, replica: 1}))
+		if node.GetIdentifier() != replica {
+			t.Errorf("Expected the replica %s, got %s", replica, node.GetIdentifier())
+		}
+		skipped := tracer.skipped[len(tracer.skipped)-1]
+		if len(skipped) != 1 || skipped[0] != owner {
+			t.Errorf("Expected the tracer to see %s skipped, got %v", owner, skipped)
+		}
+
+		// Other nodes are never considered, however idle
+		loads := fixedLoads{owner: 5, replica: 5}
+		for _, n := range order[2:] {
+			loads[n.GetIdentifier()] = 0
+		}
+		if node, _ := ring.GetNodeContext(ctx, key, TwoChoices(loads)); node.GetIdentifier() != owner {
+			t.Errorf("Expected the owner %s, got %s", owner, node.GetIdentifier())
+		}
+	})
+
+	t.Run("second candidate honours exclusions", func(t *testing.T) {
+		loads := fixedLoads{owner: 5, replica: 0, order[2].GetIdentifier(): 1}
+		node, _ := ring.GetNodeContext(ctx, key, TwoChoices(loads), ExcludeNodes(replica))
+		if node.GetIdentifier() != order[2].GetIdentifier() {
+			t.Errorf("Expected %s with the replica excluded, got %s", order[2].GetIdentifier(), node.GetIdentifier())
+		}
+	})
+
+	t.Run("preferred zone is kept", func(t *testing.T) {
+		zone := order[0].(ZonedNode).GetZone()
+		loads := fixedLoads{owner: 100}
+		node, _ := ring.GetNodeContext(ctx, key, TwoChoices(loads), PreferZone(zone))
+		if z := node.(ZonedNode).GetZone(); z != zone {
+			t.Errorf("Expected a node in zone %s, got %s in %s", zone, node.GetIdentifier(), z)
+		}
+	})
+
+	t.Run("alternate hash spreads a hot owner", func(t *testing.T) {
+		// Every key owned by the hot node falls back to the idle alternate
+		hot := owner
+		loads := fixedLoads{hot: 100}
+		second := make(map[string]int)
+		for i := 0; i < 2000; i++ {
+			k := fmt.Sprintf("%d:user", i)
+			if primary, _ := ring.GetNode(k); primary.GetIdentifier() != hot {
+				continue
+			}
+			node, err := ring.GetNodeContext(ctx, k, TwoChoicesByHash(loads))
+			if err != nil {
+				t.Fatalf("GetNodeContext failed: %v", err)
+			}
+			second[node.GetIdentifier()]++
+		}
+		if second[hot] != 0 {
+			t.Errorf("Expected no key to stay on the hot node, got %d", second[hot])
+		}
+		if len(second) < 2 {
+			t.Errorf("Expected the alternate hash to spread the hot node's keys, got %v", second)
+		}
+	})
+
+	t.Run("in flight counts balance a hot key", func(t *testing.T) {
+		inflight := &InFlight{}
+		counts := make(map[string]int)
+		var releases []func()
+		for i := 0; i < 10; i++ {
+			node, _ := ring.GetNodeContext(ctx, key, TwoChoices(inflight))
+			counts[node.GetIdentifier()]++
+			releases = append(releases, inflight.Acquire(node))
+		}
+		if counts[owner] != 5 || counts[replica] != 5 {
+			t.Errorf("Expected 10 concurrent requests split evenly over %s and %s, got %v", owner, replica, counts)
+		}
+
+		for _, release := range releases {
+			release()
+			release()
+		}
+		if load := inflight.Load(order[0]); load != 0 {
+			t.Errorf("Expected no requests in flight after release, got %v", load)
+		}
+	})
+}
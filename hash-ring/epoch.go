@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
+)
+
+// ErrEpochMismatch is returned by CompareAndApply when the ring moved on since the caller read it
+var ErrEpochMismatch = errors.New("Ring epoch does not match")
+
+/*
+Change is one membership edit applied by CompareAndApply. Op is MembershipAdd or
+MembershipRemove and Node is the node to add or remove.
+*/
+type Change struct {
+	Op   MembershipOp
+	Node CacheNode
+}
+
+/*
+LookupResult is the answer to a Lookup. Epoch is the ring epoch the node was picked
+against, so a caller holding on to the result can later compare it with Epoch() to find
+out whether the routing decision may be stale.
+*/
+type LookupResult struct {
+	Node  CacheNode
+	Epoch uint64
+}
+
+/*
+Epoch returns the current epoch of the ring. It starts at zero and grows by one with
+every successful AddNode, RemoveNode, SetNodeState and non-empty CompareAndApply, so it
+is a cheap way to tell whether the membership changed since it was last read.
+*/
+func (ring *HashRing) Epoch() uint64 {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+	return ring.epoch
+}
+
+/*
+ContentHash returns a hash of the ring's placement: the position and identifier of every
+node, in ring order. Two rings that place nodes identically have the same content hash
+no matter how they got there or which epoch they are at, which makes it possible to check
+that rings in different processes agree. It always uses FNV-1a regardless of the ring's
+hash function, so content hashes only match between rings that place nodes identically;
+rings with different hash functions or seeds place nodes differently and will not match.
+Node states are not part of the hash, draining a node leaves it unchanged.
+*/
+func (ring *HashRing) ContentHash() uint64 {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	h := fnv.New64a()
+	var position [8]byte
+	for _, nodeHash := range ring.sortedKeyOfNodes {
+		value, ok := ring.nodes.Load(uint64(nodeHash))
+		if !ok {
+			continue
+		}
+		binary.BigEndian.PutUint64(position[:], uint64(nodeHash))
+		h.Write(position[:])
+		h.Write([]byte(value.(CacheNode).GetIdentifier()))
+		// Separator so that identifiers cannot run into the next position
+		h.Write([]byte{0})
+	}
+	return h.Sum64()
+}
+
+/*
+Lookup works like GetNode but also returns the epoch the answer was computed against.
+The node and the epoch are read under the same lock, so the node is guaranteed to be the
+owner of the key at that epoch.
+*/
+func (ring *HashRing) Lookup(key string) (LookupResult, error) {
+	start := time.Now()
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	node, err := ring.getNodeLocked(key, start)
+	if err != nil {
+		return LookupResult{}, err
+	}
+	return LookupResult{Node: node, Epoch: ring.epoch}, nil
+}
+
+/*
+CompareAndApply applies a batch of membership changes atomically, but only if the ring is
+still at expectedEpoch. Concurrent controllers read Epoch, compute their edits and call
+CompareAndApply; the loser gets ErrEpochMismatch instead of silently overwriting the
+winner's edits, and can re-read and retry. Either every change is applied or none is: the
+batch is validated first, so adding an existing node or removing a missing one (taking the
+earlier changes of the batch into account) fails with ErrNodeExits or ErrNodeNotFound and
+leaves the ring untouched. A successful batch moves the ring to the next epoch, which is
+returned. An empty batch changes nothing, so the current epoch is returned unchanged.
+*/
+func (ring *HashRing) CompareAndApply(expectedEpoch uint64, changes []Change) (uint64, error) {
+	start := time.Now()
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if ring.epoch != expectedEpoch {
+		return ring.epoch, fmt.Errorf("%w: expected %d, ring is at %d", ErrEpochMismatch, expectedEpoch, ring.epoch)
+	}
+	if len(changes) == 0 {
+		return ring.epoch, nil
+	}
+
+	// Validate the whole batch against the ring overlaid with the earlier changes of the batch
+	present := make(map[uint64]bool, len(changes))
+	hashes := make([]uint64, len(changes))
+	for i, change := range changes {
+		hashVal, err := ring.generateHash(change.Node.GetIdentifier())
+		if err != nil {
+			return ring.epoch, fmt.Errorf("%w: node %s", ErrInHashingKey, change.Node.GetIdentifier())
+		}
+		hashes[i] = hashVal
+		exists, seen := present[hashVal]
+		if !seen {
+			_, exists = ring.nodes.Load(hashVal)
+		}
+
+		switch change.Op {
+		case MembershipAdd:
+			if exists {
+				return ring.epoch, fmt.Errorf("%w: node %s", ErrNodeExits, change.Node.GetIdentifier())
+			}
+			present[hashVal] = true
+		case MembershipRemove:
+			if !exists {
+				return ring.epoch, fmt.Errorf("%w: %s", ErrNodeNotFound, change.Node.GetIdentifier())
+			}
+			present[hashVal] = false
+		default:
+			return ring.epoch, fmt.Errorf("unknown membership op %q for node %s", change.Op, change.Node.GetIdentifier())
+		}
+	}
+
+	// The batch is valid, so none of these can fail
+	if len(present) == len(changes) {
+		ring.applyBatchLocked(changes, hashes, start)
+	} else {
+		// A node is touched twice, apply the changes one by one so their order is kept
+		for _, change := range changes {
+			if change.Op == MembershipAdd {
+				ring.addNodeLocked(change.Node, start)
+			} else {
+				ring.removeNodeLocked(change.Node, start)
+			}
+		}
+	}
+	ring.epoch++
+	return ring.epoch, nil
+}
+
+/*
+applyBatchLocked applies a validated batch in which every change touches a different node,
+so the order of the changes does not matter. Instead of re-sorting the ring for every node
+as addNodeLocked does, it drops all removed positions in one pass, sorts once after all
+adds and rebuilds the lookup table once, which makes loading a ring with many points one
+CompareAndApply away. The metrics hook sees every change and the ring state once, after
+the whole batch. Callers must hold ring.mu for writing.
+*/
+func (ring *HashRing) applyBatchLocked(changes []Change, hashes []uint64, start time.Time) {
+	removed := make(map[int64]struct{})
+	for i, change := range changes {
+		if change.Op == MembershipRemove {
+			ring.nodes.Delete(hashes[i])
+			delete(ring.states, hashes[i])
+			removed[int64(hashes[i])] = struct{}{}
+		}
+	}
+	if len(removed) > 0 {
+		ring.sortedKeyOfNodes = slices.DeleteFunc(ring.sortedKeyOfNodes, func(nodeHash int64) bool {
+			_, ok := removed[nodeHash]
+			return ok
+		})
+	}
+	for i, change := range changes {
+		if change.Op == MembershipAdd {
+			ring.nodes.Store(hashes[i], change.Node)
+			ring.sortedKeyOfNodes = append(ring.sortedKeyOfNodes, int64(hashes[i]))
+		}
+	}
+	slices.Sort(ring.sortedKeyOfNodes)
+	ring.rebuildLookupTableLocked()
+
+	for i, change := range changes {
+		ring.logMembership(change.Op, change.Node, hashes[i], start)
+		if ring.config.Metrics != nil {
+			ring.config.Metrics.ObserveMembershipChange(change.Op, change.Node.GetIdentifier())
+		}
+	}
+	if ring.config.Metrics != nil {
+		ring.config.Metrics.ObserveRingState(ring.ringState())
+	}
+}
@@ -0,0 +1,279 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+)
+
+/*
+TestEpochs tests ring epochs and content hashes. It verifies that every successful change
+moves the epoch forward while failed ones do not, that CompareAndApply refuses a stale
+epoch and applies a batch all-or-nothing, that only one of many concurrent controllers
+racing on the same epoch wins, and that rings with the same placement share a content hash.
+*/
+func TestEpochs(t *testing.T) {
+	t.Run("successful changes advance the epoch", func(t *testing.T) {
+		ring := HashRingInit()
+		if ring.Epoch() != 0 {
+			t.Fatalf("Expected a new ring at epoch 0, got %d", ring.Epoch())
+		}
+
+		node := &mockNode{identifier: "node1"}
+		if err := ring.AddNode(node); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		if ring.Epoch() != 1 {
+			t.Errorf("Expected epoch 1 after AddNode, got %d", ring.Epoch())
+		}
+
+		// A failed add must leave the epoch alone
+		if err := ring.AddNode(node); err == nil {
+			t.Fatal("Expected adding a duplicate node to fail")
+		}
+		if ring.Epoch() != 1 {
+			t.Errorf("Expected epoch 1 after a failed AddNode, got %d", ring.Epoch())
+		}
+
+		if err := ring.RemoveNode(node); err != nil {
+			t.Fatalf("RemoveNode failed: %v", err)
+		}
+		if ring.Epoch() != 2 {
+			t.Errorf("Expected epoch 2 after RemoveNode, got %d", ring.Epoch())
+		}
+	})
+
+	t.Run("lookup reports the epoch it was computed against", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		ring.AddNode(&mockNode{identifier: "node2"})
+
+		result, err := ring.Lookup("user:42")
+		if err != nil {
+			t.Fatalf("Lookup failed: %v", err)
+		}
+		node, _ := ring.GetNode("user:42")
+		if result.Node.GetIdentifier() != node.GetIdentifier() {
+			t.Errorf("Lookup returned %s, GetNode returned %s", result.Node.GetIdentifier(), node.GetIdentifier())
+		}
+		if result.Epoch != 2 {
+			t.Errorf("Expected epoch 2, got %d", result.Epoch)
+		}
+
+		// After a change the result is recognisably stale
+		ring.AddNode(&mockNode{identifier: "node3"})
+		if result.Epoch == ring.Epoch() {
+			t.Error("Expected the ring epoch to move past the lookup epoch")
+		}
+
+		if _, err := HashRingInit().Lookup("user:42"); err != ErrNoConnectedNodes {
+			t.Errorf("Expected ErrNoConnectedNodes on an empty ring, got %v", err)
+		}
+	})
+
+	t.Run("compare and apply", func(t *testing.T) {
+		ring := HashRingInit()
+		epoch, err := ring.CompareAndApply(0, []Change{
+			{Op: MembershipAdd, Node: &mockNode{identifier: "node1"}},
+			{Op: MembershipAdd, Node: &mockNode{identifier: "node2"}},
+		})
+		if err != nil {
+			t.Fatalf("CompareAndApply failed: %v", err)
+		}
+		if epoch != 1 || ring.Epoch() != 1 {
+			t.Errorf("Expected a batch to advance the epoch once, got %d", epoch)
+		}
+		if got := len(ringIdentifiers(ring)); got != 2 {
+			t.Errorf("Expected 2 nodes, got %d", got)
+		}
+
+		// A stale epoch is refused and changes nothing
+		if _, err := ring.CompareAndApply(0, []Change{{Op: MembershipAdd, Node: &mockNode{identifier: "node3"}}}); !errors.Is(err, ErrEpochMismatch) {
+			t.Errorf("Expected ErrEpochMismatch, got %v", err)
+		}
+		if got := len(ringIdentifiers(ring)); got != 2 {
+			t.Errorf("Expected a refused batch to leave 2 nodes, got %d", got)
+		}
+
+		// An empty batch is a no-op and keeps the epoch
+		if epoch, err := ring.CompareAndApply(1, nil); err != nil || epoch != 1 || ring.Epoch() != 1 {
+			t.Errorf("Expected an empty batch to keep epoch 1, got %d, %v", epoch, err)
+		}
+		if _, err := ring.CompareAndApply(0, nil); !errors.Is(err, ErrEpochMismatch) {
+			t.Errorf("Expected an empty batch at a stale epoch to get ErrEpochMismatch, got %v", err)
+		}
+	})
+
+	t.Run("invalid batch is not applied at all", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		before := ring.ContentHash()
+
+		tests := []struct {
+			name    string
+			changes []Change
+			want    error
+		}{
+			{"add existing node", []Change{
+				{Op: MembershipAdd, Node: &mockNode{identifier: "node2"}},
+				{Op: MembershipAdd, Node: &mockNode{identifier: "node1"}},
+			}, ErrNodeExits},
+			{"remove missing node", []Change{
+				{Op: MembershipRemove, Node: &mockNode{identifier: "node1"}},
+				{Op: MembershipRemove, Node: &mockNode{identifier: "node9"}},
+			}, ErrNodeNotFound},
+			{"remove twice", []Change{
+				{Op: MembershipRemove, Node: &mockNode{identifier: "node1"}},
+				{Op: MembershipRemove, Node: &mockNode{identifier: "node1"}},
+			}, ErrNodeNotFound},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if _, err := ring.CompareAndApply(ring.Epoch(), tt.changes); !errors.Is(err, tt.want) {
+					t.Errorf("Expected %v, got %v", tt.want, err)
+				}
+				if ring.ContentHash() != before || ring.Epoch() != 1 {
+					t.Error("Expected a failed batch to leave the ring untouched")
+				}
+			})
+		}
+
+		// Removing and re-adding within one batch is valid
+		if _, err := ring.CompareAndApply(1, []Change{
+			{Op: MembershipRemove, Node: &mockNode{identifier: "node1"}},
+			{Op: MembershipAdd, Node: &mockNode{identifier: "node1"}},
+		}); err != nil {
+			t.Errorf("Expected remove then add to succeed, got %v", err)
+		}
+	})
+
+	t.Run("only one concurrent controller wins an epoch", func(t *testing.T) {
+		ring := HashRingInit()
+		epoch := ring.Epoch()
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		wins := 0
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				node := &mockNode{identifier: "controller-" + string(rune('a'+i))}
+				if _, err := ring.CompareAndApply(epoch, []Change{{Op: MembershipAdd, Node: node}}); err == nil {
+					mu.Lock()
+					wins++
+					mu.Unlock()
+				} else if !errors.Is(err, ErrEpochMismatch) {
+					t.Errorf("Unexpected error: %v", err)
+				}
+			}(i)
+		}
+		wg.Wait()
+
+		if wins != 1 {
+			t.Errorf("Expected exactly one winner, got %d", wins)
+		}
+		if got := len(ringIdentifiers(ring)); got != 1 {
+			t.Errorf("Expected 1 node, got %d", got)
+		}
+	})
+
+	t.Run("content hash depends on placement only", func(t *testing.T) {
+		a, b := HashRingInit(), HashRingInit()
+		if a.ContentHash() != b.ContentHash() {
+			t.Error("Expected empty rings to have the same content hash")
+		}
+
+		// Same nodes added in a different order, with an extra add and remove on one side
+		a.AddNode(&mockNode{identifier: "node1"})
+		a.AddNode(&mockNode{identifier: "node2"})
+		b.AddNode(&mockNode{identifier: "node3"})
+		b.AddNode(&mockNode{identifier: "node2"})
+		b.AddNode(&mockNode{identifier: "node1"})
+		b.RemoveNode(&mockNode{identifier: "node3"})
+
+		if a.Epoch() == b.Epoch() {
+			t.Fatal("Expected the rings to be at different epochs")
+		}
+		if a.ContentHash() != b.ContentHash() {
+			t.Error("Expected rings with the same nodes to have the same content hash")
+		}
+
+		// Node states are not part of the placement
+		b.SetNodeState("node1", StateDraining)
+		if a.ContentHash() != b.ContentHash() {
+			t.Error("Expected a node state change to keep the content hash")
+		}
+
+		b.AddNode(&mockNode{identifier: "node3"})
+		if a.ContentHash() == b.ContentHash() {
+			t.Error("Expected rings with different nodes to have different content hashes")
+		}
+	})
+
+	t.Run("large batch places nodes like one by one", func(t *testing.T) {
+		batched, single := HashRingInit(), HashRingInit()
+		var changes []Change
+		for i := 0; i < 500; i++ {
+			node := &mockNode{identifier: fmt.Sprintf("%d-node", i)}
+			changes = append(changes, Change{Op: MembershipAdd, Node: node})
+			single.AddNode(node)
+		}
+		if _, err := batched.CompareAndApply(0, changes); err != nil {
+			t.Fatalf("CompareAndApply failed: %v", err)
+		}
+
+		// Swap half of the nodes for new ones in one batch
+		changes = changes[:0]
+		for i := 0; i < 250; i++ {
+			old, fresh := &mockNode{identifier: fmt.Sprintf("%d-node", i)}, &mockNode{identifier: fmt.Sprintf("%d-fresh", i)}
+			changes = append(changes, Change{Op: MembershipRemove, Node: old}, Change{Op: MembershipAdd, Node: fresh})
+			single.RemoveNode(old)
+			single.AddNode(fresh)
+		}
+		if _, err := batched.CompareAndApply(1, changes); err != nil {
+			t.Fatalf("CompareAndApply failed: %v", err)
+		}
+		if batched.ContentHash() != single.ContentHash() {
+			t.Error("Expected a batch to place the nodes like adding them one by one")
+		}
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("%d:key", i)
+			a, _ := batched.GetNode(key)
+			b, _ := single.GetNode(key)
+			if a.GetIdentifier() != b.GetIdentifier() {
+				t.Fatalf("Key %s: batched ring picked %s, one by one picked %s", key, a.GetIdentifier(), b.GetIdentifier())
+			}
+		}
+	})
+
+	t.Run("batch touching a node twice keeps its order", func(t *testing.T) {
+		ring := HashRingInit()
+		node := &mockNode{identifier: "node1"}
+		_, err := ring.CompareAndApply(0, []Change{
+			{Op: MembershipAdd, Node: node},
+			{Op: MembershipRemove, Node: node},
+			{Op: MembershipAdd, Node: &mockNode{identifier: "node2"}},
+		})
+		if err != nil {
+			t.Fatalf("CompareAndApply failed: %v", err)
+		}
+		if ids := ringIdentifiers(ring); len(ids) != 1 || ids[0] != "node2" {
+			t.Errorf("Expected only node2 on the ring, got %v", ids)
+		}
+	})
+}
+
+// ringIdentifiers returns the identifier of every node on ring
+func ringIdentifiers(ring *HashRing) []string {
+	nodes, err := ring.GetNodes("", 1<<20)
+	if err != nil {
+		return nil
+	}
+	ids := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		ids = append(ids, node.GetIdentifier())
+	}
+	return ids
+}
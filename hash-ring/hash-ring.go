@@ -23,14 +23,17 @@ SOFTWARE.
 package hashring
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hash"
 	"hash/fnv"
-	"log"
+	"log/slog"
+	"os"
 	"slices"
 	"sort"
 	"sync"
+	"time"
 )
 
 // Global error variables which has all error types to return
@@ -39,6 +42,7 @@ var (
 	ErrNodeExits        = errors.New("Node already exists")
 	ErrNodeNotFound     = errors.New("Node not found")
 	ErrInHashingKey     = errors.New("Error in Hashing Key")
+	ErrInvalidCount     = errors.New("Count must be at least 1")
 )
 
 // GetIdentifier gives each CacheNode its own identity
@@ -49,6 +53,12 @@ type CacheNode interface {
 type hashRingConfig struct {
 	HashFunction func() hash.Hash64
 	EnableLogs   bool
+	Logger       *slog.Logger
+	RedactKeys   bool
+	Metrics      MetricsHook
+	KeyExtractor KeyExtractor
+	Tracer       LookupTracer
+	TableBits    int
 }
 
 /*
@@ -75,7 +85,8 @@ func SetHashFunction(f func() hash.Hash64) HashRingConfigFn {
 EnableVerboseLogs returns a HashRingConfigFn that enables or disables verbose
 logging for HashRing operations. When enabled, the HashRing will log operations
 like adding nodes, removing nodes, and key-to-node mappings. This is useful for
-debugging and monitoring the hash ring behavior.
+debugging and monitoring the hash ring behavior. Unless a logger is supplied with
+SetLogger, verbose logs are written as text to stderr at Debug level.
 */
 func EnableVerboseLogs(enabled bool) HashRingConfigFn {
 	return func(config *hashRingConfig) {
@@ -83,6 +94,79 @@ func EnableVerboseLogs(enabled bool) HashRingConfigFn {
 	}
 }
 
+/*
+SetLogger returns a HashRingConfigFn that sends HashRing logs to the given *slog.Logger.
+Records carry typed attributes (op, node, hash, key, duration). Lookups are logged at
+Debug level and membership changes at Info level, so the handler's level decides how
+much is emitted. Setting a logger turns logging on without EnableVerboseLogs.
+*/
+func SetLogger(logger *slog.Logger) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.Logger = logger
+	}
+}
+
+/*
+RedactLoggedKeys returns a HashRingConfigFn that keeps lookup keys out of the logs.
+When enabled, the key attribute of lookup records is replaced with "[REDACTED]" while
+the key's hash is still logged, which is enough to correlate lookups without storing
+user data such as emails or account ids in the log pipeline.
+*/
+func RedactLoggedKeys(enabled bool) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.RedactKeys = enabled
+	}
+}
+
+/*
+SetMetricsHook returns a HashRingConfigFn that attaches a MetricsHook to the HashRing.
+The hook is notified about every successful lookup, every membership change and the
+resulting ring state, which makes it possible to export production metrics without
+turning on verbose logs. Passing nil disables metrics, which is also the default.
+*/
+func SetMetricsHook(hook MetricsHook) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.Metrics = hook
+	}
+}
+
+/*
+SetKeyExtractor returns a HashRingConfigFn that sets the KeyExtractor applied to every
+lookup key before it is hashed. All keys that extract to the same value land on the same
+node, which keeps the keys of one tenant or entity together without callers having to
+pre-process keys. Node identifiers are never passed through the extractor.
+*/
+func SetKeyExtractor(extractor KeyExtractor) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.KeyExtractor = extractor
+	}
+}
+
+/*
+SetLookupTracer returns a HashRingConfigFn that attaches a LookupTracer, which is told
+about every GetNodeContext call together with its context, so that it can record the
+lookup on the span the caller carries in that context.
+*/
+func SetLookupTracer(tracer LookupTracer) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.Tracer = tracer
+	}
+}
+
+/*
+SetLookupTableBits returns a HashRingConfigFn that sets the size of the lookup table to
+2^bits buckets. The table maps every bucket of the hash space straight to its first node
+position, so lookups scan a bucket instead of binary searching the whole ring. By default
+the table is sized to about two buckets per position, up to 2^20; a fixed size trades
+memory for scan length, and 0 turns the table off so every lookup uses binary search.
+Values above 24 are capped at 24.
+*/
+func SetLookupTableBits(bits int) HashRingConfigFn {
+	return func(config *hashRingConfig) {
+		config.TableBits = min(max(bits, 0), 24)
+	}
+}
+
 /*
 HashRing represents a consistent hash ring data structure that maps keys to nodes
 in a distributed system. It maintains a sorted list of node hash values and uses
@@ -93,12 +177,18 @@ mapping. Fields:
   - config: Configuration settings including hash function and logging preferences
   - nodes: Thread-safe map storing nodes keyed by their hash values
   - sortedKeyOfNodes: Sorted slice of node hash values used for efficient binary search lookups
+  - epoch: Number of membership changes applied so far, identifies the current ring state
+  - states: States of the nodes that are not StateActive, keyed by node hash
+  - table: Bucketed index over sortedKeyOfNodes, rebuilt on every membership change
 */
 type HashRing struct {
 	mu               sync.RWMutex
 	config           hashRingConfig
 	nodes            sync.Map
 	sortedKeyOfNodes []int64
+	epoch            uint64
+	states           map[uint64]NodeState
+	table            *lookupTable
 }
 
 /*
@@ -112,10 +202,16 @@ func HashRingInit(opts ...HashRingConfigFn) *HashRing {
 	config := &hashRingConfig{
 		HashFunction: fnv.New64a,
 		EnableLogs:   false,
+		TableBits:    autoLookupTableBits,
 	}
 	for _, opt := range opts {
 		opt(config)
 	}
+
+	// Verbose logs without an explicit logger fall back to a Debug level text logger on stderr
+	if config.Logger == nil && config.EnableLogs {
+		config.Logger = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	}
 	return &HashRing{
 		config:           *config,
 		sortedKeyOfNodes: make([]int64, 0),
@@ -128,12 +224,23 @@ identifier and stores the node at that hash position. The node's hash is also ad
 to the sortedKeyOfNodes slice which is then sorted to maintain the ring structure.
 If a node with the same hash already exists, it returns ErrNodeExits. This method
 is thread-safe and can be used to dynamically add nodes to the hash ring (for example,
-adding a new database shard to a distributed system).
+adding a new database shard to a distributed system). A successful add moves the ring
+to the next epoch.
 */
 func (ring *HashRing) AddNode(node CacheNode) error {
+	start := time.Now()
 	ring.mu.Lock()
 	defer ring.mu.Unlock()
 
+	if err := ring.addNodeLocked(node, start); err != nil {
+		return err
+	}
+	ring.epoch++
+	return nil
+}
+
+// addNodeLocked does the work of AddNode, callers must hold ring.mu for writing
+func (ring *HashRing) addNodeLocked(node CacheNode, start time.Time) error {
 	// We find out hashVal of a node which we gonna add here
 	hashVal, err := ring.generateHash(node.GetIdentifier())
 	if err != nil {
@@ -151,9 +258,12 @@ func (ring *HashRing) AddNode(node CacheNode) error {
 
 	// Now sort this slice of sortedKeyOfNodes
 	slices.Sort(ring.sortedKeyOfNodes)
+	ring.rebuildLookupTableLocked()
 
-	if ring.config.EnableLogs {
-		log.Printf("[HashRing] says Added Node: %s (hash: %d)", node.GetIdentifier(), hashVal)
+	ring.logMembership(MembershipAdd, node, hashVal, start)
+	if ring.config.Metrics != nil {
+		ring.config.Metrics.ObserveMembershipChange(MembershipAdd, node.GetIdentifier())
+		ring.config.Metrics.ObserveRingState(ring.ringState())
 	}
 	return nil
 }
@@ -168,11 +278,17 @@ which database shard to query for a given data key). Returns the node and nil er
 success, or nil and an error if no nodes are available or if the key cannot be hashed.
 */
 func (ring *HashRing) GetNode(key string) (CacheNode, error) {
-	ring.mu.Lock()
-	defer ring.mu.Unlock()
+	start := time.Now()
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
 
+	return ring.getNodeLocked(key, start)
+}
+
+// getNodeLocked does the work of GetNode, callers must hold ring.mu
+func (ring *HashRing) getNodeLocked(key string, start time.Time) (CacheNode, error) {
 	// We find out hashVal of a key which we gonna lookup here
-	hashVal, err := ring.generateHash(key)
+	hashVal, err := ring.generateHash(ring.extractKey(key))
 	if err != nil {
 		return nil, fmt.Errorf("%w: %s", ErrInHashingKey, key)
 	}
@@ -189,8 +305,18 @@ func (ring *HashRing) GetNode(key string) (CacheNode, error) {
 	// Load the node from HashRing using the node hash and return it
 	// Convert int64 back to uint64 to match the key type used when storing
 	if node, ok := ring.nodes.Load(uint64(nodeHash)); ok {
-		if ring.config.EnableLogs {
-			log.Printf("[HashRing] Key '%s' (hash: %d) mapped to node (hash: %d)", key, hashVal, nodeHash)
+		if ring.logEnabled(slog.LevelDebug) {
+			ring.config.Logger.LogAttrs(context.Background(), slog.LevelDebug, "mapped key to node",
+				slog.String("op", "lookup"),
+				slog.String("key", ring.loggedKey(key)),
+				slog.Uint64("key_hash", hashVal),
+				slog.String("node", node.(CacheNode).GetIdentifier()),
+				slog.Uint64("hash", uint64(nodeHash)),
+				slog.Duration("duration", time.Since(start)),
+			)
+		}
+		if ring.config.Metrics != nil {
+			ring.config.Metrics.ObserveLookup(node.(CacheNode).GetIdentifier(), time.Since(start))
 		}
 		return node.(CacheNode), nil
 	}
@@ -198,18 +324,58 @@ func (ring *HashRing) GetNode(key string) (CacheNode, error) {
 	return nil, fmt.Errorf("%w: no node found for key %s", ErrNodeNotFound, key)
 }
 
+/*
+GetNodes returns up to count distinct nodes for a given key, in the clockwise order in
+which they follow the key on the ring. The first node is the same one GetNode returns,
+the following ones are its successors, which makes GetNodes useful for failover (try the
+next owner when the first one is unreachable) and for picking replica owners. If count is
+larger than the number of nodes, every node is returned. Returns ErrInvalidCount if count
+is below 1 and ErrNoConnectedNodes if the ring is empty.
+*/
+func (ring *HashRing) GetNodes(key string, count int) ([]CacheNode, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("%w: got %d", ErrInvalidCount, count)
+	}
+
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	hashVal, err := ring.generateHash(ring.extractKey(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInHashingKey, key)
+	}
+
+	index, err := ring.binarySearch(int64(hashVal))
+	if err != nil {
+		return nil, err
+	}
+
+	// Walk clockwise from the owner, skipping points of nodes we already picked
+	return ring.successorsLocked(index, count), nil
+}
+
 /*
 RemoveNode removes an existing node from the HashRing. It computes the hash value of
 the node's identifier, removes the node from the nodes map, and removes its hash from
 the sortedKeyOfNodes slice. If the node does not exist, it returns ErrNodeNotFound.
 This method is thread-safe and can be used to dynamically remove nodes from the hash
 ring (for example, removing a database shard that is being decommissioned from a
-distributed system).
+distributed system). A successful removal moves the ring to the next epoch.
 */
 func (ring *HashRing) RemoveNode(node CacheNode) error {
+	start := time.Now()
 	ring.mu.Lock()
 	defer ring.mu.Unlock()
 
+	if err := ring.removeNodeLocked(node, start); err != nil {
+		return err
+	}
+	ring.epoch++
+	return nil
+}
+
+// removeNodeLocked does the work of RemoveNode, callers must hold ring.mu for writing
+func (ring *HashRing) removeNodeLocked(node CacheNode, start time.Time) error {
 	// We find out hashVal of a node which we gonna remove here
 	hashVal, err := ring.generateHash(node.GetIdentifier())
 	if err != nil {
@@ -221,6 +387,8 @@ func (ring *HashRing) RemoveNode(node CacheNode) error {
 		return fmt.Errorf("%w: %s", ErrNodeNotFound, node.GetIdentifier())
 	}
 
+	delete(ring.states, hashVal)
+
 	// Find the index of this node hash in sortedKeyOfNodes using binary search
 	index, err := ring.binarySearch(int64(hashVal))
 	if err != nil {
@@ -229,20 +397,41 @@ func (ring *HashRing) RemoveNode(node CacheNode) error {
 
 	// Remove the node hash from sortedKeyOfNodes slice by slicing around the index
 	ring.sortedKeyOfNodes = append(ring.sortedKeyOfNodes[:index], ring.sortedKeyOfNodes[index+1:]...)
+	ring.rebuildLookupTableLocked()
 
-	if ring.config.EnableLogs {
-		log.Printf("[HashRing] Removed node: %s (hash: %d)", node.GetIdentifier(), hashVal)
+	ring.logMembership(MembershipRemove, node, hashVal, start)
+	if ring.config.Metrics != nil {
+		ring.config.Metrics.ObserveMembershipChange(MembershipRemove, node.GetIdentifier())
+		ring.config.Metrics.ObserveRingState(ring.ringState())
 	}
 	return nil
 }
 
+// logMembership logs a node being added or removed at Info level
+func (ring *HashRing) logMembership(op MembershipOp, node CacheNode, hashVal uint64, start time.Time) {
+	if !ring.logEnabled(slog.LevelInfo) {
+		return
+	}
+	msg := "added node"
+	if op == MembershipRemove {
+		msg = "removed node"
+	}
+	ring.config.Logger.LogAttrs(context.Background(), slog.LevelInfo, msg,
+		slog.String("op", string(op)),
+		slog.String("node", node.GetIdentifier()),
+		slog.Uint64("hash", hashVal),
+		slog.Duration("duration", time.Since(start)),
+	)
+}
+
 /*
 binarySearch performs a binary search on the sortedKeyOfNodes slice to find the index
 of the first node hash that is greater than or equal to the given key hash. This implements
 the consistent hashing algorithm where keys are mapped to the first node whose hash is
 greater than or equal to the key's hash. If no such node exists (meaning the key hash is
 larger than all node hashes), it wraps around and returns index 0, implementing the ring
-behavior. Returns the index of the target node and nil error on success, or -1 and
+behavior. When the ring has a lookup table the same index is read from the key's bucket
+instead. Returns the index of the target node and nil error on success, or -1 and
 ErrNoConnectedNodes if the ring is empty.
 */
 func (ring *HashRing) binarySearch(key int64) (int, error) {
@@ -250,6 +439,11 @@ func (ring *HashRing) binarySearch(key int64) (int, error) {
 		return -1, ErrNoConnectedNodes
 	}
 
+	// The lookup table answers without searching the whole slice
+	if ring.table != nil {
+		return ring.table.search(ring.sortedKeyOfNodes, key), nil
+	}
+
 	//“Find the first index wherenodeHash ≥ requestHash” pick first servernodeHash which is greater than or equal to our hashedVal of entry we are adding
 	index := sort.Search(len(ring.sortedKeyOfNodes), func(i int) bool {
 		return ring.sortedKeyOfNodes[i] >= key //here key is what we pass as parameter in BS (key is a int return by generateHash to lookup which node is best)
@@ -262,6 +456,27 @@ func (ring *HashRing) binarySearch(key int64) (int, error) {
 	return index, nil
 }
 
+// extractKey applies the configured KeyExtractor to a lookup key, if there is one
+func (ring *HashRing) extractKey(key string) string {
+	if ring.config.KeyExtractor == nil {
+		return key
+	}
+	return ring.config.KeyExtractor(key)
+}
+
+// logEnabled reports whether a logger is configured and accepts records at the given level
+func (ring *HashRing) logEnabled(level slog.Level) bool {
+	return ring.config.Logger != nil && ring.config.Logger.Enabled(context.Background(), level)
+}
+
+// loggedKey returns the key as it should appear in logs, honoring RedactLoggedKeys
+func (ring *HashRing) loggedKey(key string) string {
+	if ring.config.RedactKeys {
+		return "[REDACTED]"
+	}
+	return key
+}
+
 /*
 generateHash converts a string key to a uint64 hash value using the configured hash
 function from the HashRing's configuration. It creates a new hash instance, writes the
@@ -1,8 +1,12 @@
 package hashring
 
 import (
+	"bytes"
+	"encoding/json"
 	"errors"
 	"hash/fnv"
+	"log/slog"
+	"strings"
 	"sync"
 	"testing"
 )
@@ -318,6 +322,80 @@ func TestGetNode(t *testing.T) {
 	})
 }
 
+/*
+TestGetNodes tests the GetNodes method to ensure it returns distinct nodes in clockwise
+order starting from the node GetNode picks for the same key. It verifies that the count is
+capped at the number of nodes, that a count below 1 is refused and that an empty ring
+returns ErrNoConnectedNodes.
+*/
+func TestGetNodes(t *testing.T) {
+	t.Run("first node matches GetNode and nodes are distinct", func(t *testing.T) {
+		ring := HashRingInit()
+		for _, id := range []string{"node1", "node2", "node3", "node4"} {
+			if err := ring.AddNode(&mockNode{identifier: id}); err != nil {
+				t.Fatalf("Failed to add %s: %v", id, err)
+			}
+		}
+
+		for _, key := range []string{"key1", "key2", "user:123", "order:101"} {
+			owner, err := ring.GetNode(key)
+			if err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+			nodes, err := ring.GetNodes(key, 3)
+			if err != nil {
+				t.Fatalf("GetNodes failed: %v", err)
+			}
+			if len(nodes) != 3 {
+				t.Fatalf("Expected 3 nodes, got %d", len(nodes))
+			}
+			// The first node must be the owner and no node may repeat
+			if nodes[0].GetIdentifier() != owner.GetIdentifier() {
+				t.Errorf("Expected first node %s, got %s", owner.GetIdentifier(), nodes[0].GetIdentifier())
+			}
+			seen := map[string]bool{}
+			for _, node := range nodes {
+				if seen[node.GetIdentifier()] {
+					t.Errorf("Node %s returned twice", node.GetIdentifier())
+				}
+				seen[node.GetIdentifier()] = true
+			}
+		}
+	})
+
+	t.Run("count larger than ring returns every node", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		ring.AddNode(&mockNode{identifier: "node2"})
+
+		nodes, err := ring.GetNodes("key", 5)
+		if err != nil {
+			t.Fatalf("GetNodes failed: %v", err)
+		}
+		if len(nodes) != 2 {
+			t.Errorf("Expected 2 nodes, got %d", len(nodes))
+		}
+	})
+
+	t.Run("empty ring", func(t *testing.T) {
+		ring := HashRingInit()
+		if _, err := ring.GetNodes("key", 2); !errors.Is(err, ErrNoConnectedNodes) {
+			t.Errorf("Expected ErrNoConnectedNodes, got %v", err)
+		}
+	})
+
+	t.Run("count below one", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		for _, count := range []int{0, -1} {
+			nodes, err := ring.GetNodes("key", count)
+			if !errors.Is(err, ErrInvalidCount) || nodes != nil {
+				t.Errorf("Count %d: expected ErrInvalidCount, got %v, %v", count, nodes, err)
+			}
+		}
+	})
+}
+
 /*
 TestRemoveNode tests the RemoveNode method to ensure nodes can be properly removed from the HashRing.
 It verifies successful node removal, error handling for non-existent nodes, removal from empty ring,
@@ -562,3 +640,111 @@ func TestConcurrentOperations(t *testing.T) {
 		wg.Wait()
 	})
 }
+
+/*
+TestStructuredLogging tests that HashRing writes typed slog records to the logger given
+with SetLogger. It verifies that membership changes are logged at Info and lookups at
+Debug, that the handler level filters records, and that RedactLoggedKeys keeps the raw
+key out of the log output.
+*/
+func TestStructuredLogging(t *testing.T) {
+	// decodeRecords splits JSON handler output into one map per record
+	decodeRecords := func(t *testing.T, buf *bytes.Buffer) []map[string]any {
+		var records []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			if line == "" {
+				continue
+			}
+			var record map[string]any
+			if err := json.Unmarshal([]byte(line), &record); err != nil {
+				t.Fatalf("Failed to decode log record %q: %v", line, err)
+			}
+			records = append(records, record)
+		}
+		return records
+	}
+
+	t.Run("logs membership changes and lookups with attributes", func(t *testing.T) {
+		// Initialize HashRing with a JSON logger that accepts Debug records
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		ring := HashRingInit(SetLogger(logger))
+		node := &mockNode{identifier: "node1"}
+
+		if err := ring.AddNode(node); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		if _, err := ring.GetNode("user:42"); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		if err := ring.RemoveNode(node); err != nil {
+			t.Fatalf("RemoveNode failed: %v", err)
+		}
+
+		records := decodeRecords(t, &buf)
+		if len(records) != 3 {
+			t.Fatalf("Expected 3 log records, got %d", len(records))
+		}
+
+		// Verify level, operation and typed attributes of every record
+		expected := []struct{ level, op string }{{"INFO", "add"}, {"DEBUG", "lookup"}, {"INFO", "remove"}}
+		for i, want := range expected {
+			if records[i]["level"] != want.level || records[i]["op"] != want.op {
+				t.Errorf("Record %d: expected %s/%s, got %v/%v", i, want.level, want.op, records[i]["level"], records[i]["op"])
+			}
+			if records[i]["node"] != "node1" {
+				t.Errorf("Record %d: expected node1, got %v", i, records[i]["node"])
+			}
+			if _, ok := records[i]["hash"].(float64); !ok {
+				t.Errorf("Record %d: expected numeric hash attribute, got %v", i, records[i]["hash"])
+			}
+			if _, ok := records[i]["duration"]; !ok {
+				t.Errorf("Record %d: expected duration attribute", i)
+			}
+		}
+		if records[1]["key"] != "user:42" {
+			t.Errorf("Expected key user:42, got %v", records[1]["key"])
+		}
+	})
+
+	t.Run("handler level filters lookups", func(t *testing.T) {
+		// Info level logger should only see membership changes
+		var buf bytes.Buffer
+		ring := HashRingInit(SetLogger(slog.New(slog.NewJSONHandler(&buf, nil))))
+		if err := ring.AddNode(&mockNode{identifier: "node1"}); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		if _, err := ring.GetNode("key"); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+
+		records := decodeRecords(t, &buf)
+		if len(records) != 1 || records[0]["op"] != "add" {
+			t.Errorf("Expected only the add record, got %v", records)
+		}
+	})
+
+	t.Run("redacts keys", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+		ring := HashRingInit(SetLogger(logger), RedactLoggedKeys(true))
+		if err := ring.AddNode(&mockNode{identifier: "node1"}); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		if _, err := ring.GetNode("alice@example.com"); err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+
+		// The raw key must not appear anywhere, but its hash should still be logged
+		if strings.Contains(buf.String(), "alice@example.com") {
+			t.Errorf("Raw key leaked into logs: %s", buf.String())
+		}
+		records := decodeRecords(t, &buf)
+		if records[1]["key"] != "[REDACTED]" {
+			t.Errorf("Expected redacted key, got %v", records[1]["key"])
+		}
+		if _, ok := records[1]["key_hash"].(float64); !ok {
+			t.Errorf("Expected key_hash attribute, got %v", records[1]["key_hash"])
+		}
+	})
+}
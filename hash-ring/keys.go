@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import "strings"
+
+/*
+KeyExtractor maps a lookup key to the part of it that decides placement. It is set with
+SetKeyExtractor and applied by GetNode and GetNodes before hashing, so every key with the
+same extracted value is owned by the same node. Extractors must be deterministic.
+*/
+type KeyExtractor func(key string) string
+
+/*
+HashTag is a KeyExtractor following the Redis Cluster hashtag rule: if the key contains a
+'{' followed later by a '}' with at least one character in between, only the characters
+between the first '{' and the first '}' after it are used. So "{user:1000}.following" and
+"{user:1000}.followers" land on the same node, while keys like "{}.x" or "foo{" are used
+whole.
+*/
+func HashTag(key string) string {
+	start := strings.IndexByte(key, '{')
+	if start < 0 {
+		return key
+	}
+	end := strings.IndexByte(key[start+1:], '}')
+	if end <= 0 {
+		return key
+	}
+	return key[start+1 : start+1+end]
+}
+
+/*
+PrefixBefore returns a KeyExtractor that keeps the part of the key before the first
+occurrence of delimiter, so PrefixBefore(":") maps "user:123" and "user:456" to "user".
+Keys that do not contain the delimiter are used whole.
+*/
+func PrefixBefore(delimiter string) KeyExtractor {
+	return func(key string) string {
+		if prefix, _, found := strings.Cut(key, delimiter); found {
+			return prefix
+		}
+		return key
+	}
+}
+
+/*
+CaseFold is a KeyExtractor that lower-cases the key, so keys that only differ in case
+such as "User@Example.com" and "user@example.com" land on the same node.
+*/
+func CaseFold(key string) string {
+	return strings.ToLower(key)
+}
+
+/*
+ChainExtractors returns a KeyExtractor that applies the given extractors in order, each
+one receiving the output of the previous one. For example
+ChainExtractors(HashTag, CaseFold) case-folds the hashtag of a key.
+*/
+func ChainExtractors(extractors ...KeyExtractor) KeyExtractor {
+	return func(key string) string {
+		for _, extractor := range extractors {
+			key = extractor(key)
+		}
+		return key
+	}
+}
@@ -0,0 +1,67 @@
+package hashring
+
+import "testing"
+
+/*
+TestKeyExtractors tests the built-in KeyExtractor implementations on their own and checks
+that a ring configured with SetKeyExtractor sends every key with the same extracted value
+to the same node, for both GetNode and GetNodes.
+*/
+func TestKeyExtractors(t *testing.T) {
+	t.Run("built-in extractors", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			extractor KeyExtractor
+			key, want string
+		}{
+			{"hashtag", HashTag, "{user:1000}.following", "user:1000"},
+			{"first hashtag wins", HashTag, "foo{bar}{zap}", "bar"},
+			{"empty hashtag uses whole key", HashTag, "foo{}{bar}", "foo{}{bar}"},
+			{"unclosed hashtag uses whole key", HashTag, "foo{bar", "foo{bar"},
+			{"prefix", PrefixBefore(":"), "user:123", "user"},
+			{"prefix without delimiter", PrefixBefore(":"), "user", "user"},
+			{"case fold", CaseFold, "User@Example.COM", "user@example.com"},
+			{"chain", ChainExtractors(HashTag, CaseFold), "{Tenant-A}:order:1", "tenant-a"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				if got := tt.extractor(tt.key); got != tt.want {
+					t.Errorf("Expected %q, got %q", tt.want, got)
+				}
+			})
+		}
+	})
+
+	t.Run("keys with the same extracted value share a node", func(t *testing.T) {
+		// Initialize HashRing that places keys by their prefix
+		ring := HashRingInit(SetKeyExtractor(PrefixBefore(":")))
+		for _, id := range []string{"node1", "node2", "node3", "node4"} {
+			if err := ring.AddNode(&mockNode{identifier: id}); err != nil {
+				t.Fatalf("Failed to add %s: %v", id, err)
+			}
+		}
+
+		// Every key of the tenant must map where the bare tenant name maps
+		tenantNode, err := ring.GetNode("tenant42")
+		if err != nil {
+			t.Fatalf("GetNode failed: %v", err)
+		}
+		for _, key := range []string{"tenant42:user:1", "tenant42:order:99", "tenant42:cart"} {
+			node, err := ring.GetNode(key)
+			if err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+			if node.GetIdentifier() != tenantNode.GetIdentifier() {
+				t.Errorf("Key %s mapped to %s, expected %s", key, node.GetIdentifier(), tenantNode.GetIdentifier())
+			}
+
+			nodes, err := ring.GetNodes(key, 2)
+			if err != nil {
+				t.Fatalf("GetNodes failed: %v", err)
+			}
+			if nodes[0].GetIdentifier() != tenantNode.GetIdentifier() {
+				t.Errorf("GetNodes for %s started at %s, expected %s", key, nodes[0].GetIdentifier(), tenantNode.GetIdentifier())
+			}
+		}
+	})
+}
@@ -0,0 +1,265 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+)
+
+// ErrNoEligibleNodes is returned by GetNodeContext when every node was ruled out by the lookup options
+var ErrNoEligibleNodes = errors.New("No node satisfies the lookup options")
+
+/*
+ZonedNode is implemented by nodes that know the zone (availability zone, rack, data
+center) they run in. PreferZone uses it; nodes that do not implement it are in no zone.
+*/
+type ZonedNode interface {
+	CacheNode
+	GetZone() string
+}
+
+/*
+LookupTracer is told about every GetNodeContext call. ctx is the caller's context, so a
+tracer can pull the current span out of it and record the lookup on it; the ring itself
+has no tracing dependency. node is nil when the lookup failed, skipped lists the nodes
+passed over because of the lookup options. It is called synchronously and must not block.
+*/
+type LookupTracer interface {
+	TraceLookup(ctx context.Context, key string, node CacheNode, skipped []string, err error, duration time.Duration)
+}
+
+/*
+lookupOptions are the constraints of one GetNodeContext call. Fields:
+  - exclude: Identifiers of nodes that must not be returned
+  - zone: Zone preferred over the others, empty for no preference
+  - states: States the returned node must be in, empty for any state
+  - loads: Load of the candidates of a two-choices lookup, nil for a single choice
+  - rehash: Take the second candidate from the alternate hash instead of the next node
+*/
+type lookupOptions struct {
+	exclude map[string]struct{}
+	zone    string
+	states  []NodeState
+	loads   LoadReporter
+	rehash  bool
+}
+
+// LookupOption is a per-call constraint passed to GetNodeContext
+type LookupOption func(*lookupOptions)
+
+/*
+ExcludeNodes returns a LookupOption that rules out the nodes with the given identifiers,
+so a request retrying after a failed upstream can ask for the next owner excluding it.
+Passing it several times excludes the union.
+*/
+func ExcludeNodes(ids ...string) LookupOption {
+	return func(o *lookupOptions) {
+		if o.exclude == nil {
+			o.exclude = make(map[string]struct{}, len(ids))
+		}
+		for _, id := range ids {
+			o.exclude[id] = struct{}{}
+		}
+	}
+}
+
+/*
+PreferZone returns a LookupOption that picks the first eligible node clockwise that is in
+zone, and only falls back to the first eligible node in another zone when there is none.
+*/
+func PreferZone(zone string) LookupOption {
+	return func(o *lookupOptions) {
+		o.zone = zone
+	}
+}
+
+/*
+RequireState returns a LookupOption that only accepts nodes in one of the given states,
+for example RequireState(StateActive) to skip draining and down nodes.
+*/
+func RequireState(states ...NodeState) LookupOption {
+	return func(o *lookupOptions) {
+		o.states = append(o.states, states...)
+	}
+}
+
+/*
+GetNodeContext is GetNode with per-call constraints. It walks clockwise from the key and
+returns the first node that is not excluded and is in a required state, preferring nodes
+in the preferred zone. Without options it returns the same node as GetNode, with
+TwoChoices or TwoChoicesByHash the less loaded of two such candidates. Returns
+ErrNoEligibleNodes when every node is ruled out, and the context's error when ctx is done
+before the lookup completes. Logs are written with ctx, so slog handlers can attach trace
+identifiers carried in it, and the LookupTracer, if any, is called with it.
+*/
+func (ring *HashRing) GetNodeContext(ctx context.Context, key string, opts ...LookupOption) (CacheNode, error) {
+	start := time.Now()
+	var options lookupOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	node, second, skipped, err := ring.lookup(ctx, key, &options)
+	if second != nil && options.loads.Load(second) < options.loads.Load(node) {
+		skipped = append(skipped, node.GetIdentifier())
+		node = second
+	}
+	if ring.config.Tracer != nil {
+		ring.config.Tracer.TraceLookup(ctx, key, node, skipped, err, time.Since(start))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if ring.logEnabled(slog.LevelDebug) {
+		ring.config.Logger.LogAttrs(ctx, slog.LevelDebug, "mapped key to node",
+			slog.String("op", "lookup"),
+			slog.String("key", ring.loggedKey(key)),
+			slog.String("node", node.GetIdentifier()),
+			slog.Int("skipped", len(skipped)),
+			slog.Duration("duration", time.Since(start)),
+		)
+	}
+	if ring.config.Metrics != nil {
+		ring.config.Metrics.ObserveLookup(node.GetIdentifier(), time.Since(start))
+	}
+	return node, nil
+}
+
+/*
+lookup does the work of GetNodeContext under the read lock. It returns the node picked by
+the options and, for a two-choices lookup, the second candidate, nil when there is none.
+Loads are compared by the caller, after the lock is released.
+*/
+func (ring *HashRing) lookup(ctx context.Context, key string, options *lookupOptions) (CacheNode, CacheNode, []string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, nil, err
+	}
+
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	extracted := ring.extractKey(key)
+	hashVal, err := ring.generateHash(extracted)
+	if err != nil {
+		return nil, nil, nil, fmt.Errorf("%w: %s", ErrInHashingKey, key)
+	}
+	index, err := ring.binarySearch(int64(hashVal))
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	node, skipped := ring.pickLocked(index, options, "")
+	if node == nil {
+		return nil, nil, skipped, fmt.Errorf("%w: %s", ErrNoEligibleNodes, key)
+	}
+	if options.loads == nil {
+		return node, nil, skipped, nil
+	}
+
+	if options.rehash {
+		altHash, err := ring.alternateHash(extracted)
+		if err != nil {
+			return nil, nil, nil, fmt.Errorf("%w: %s", ErrInHashingKey, key)
+		}
+		if index, err = ring.binarySearch(int64(altHash)); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	second, _ := ring.pickLocked(index, options, node.GetIdentifier())
+	// Never trade a node in the preferred zone for one outside it
+	if second != nil && options.zone != "" && inZone(node, options.zone) && !inZone(second, options.zone) {
+		second = nil
+	}
+	return node, second, skipped, nil
+}
+
+/*
+pickLocked walks clockwise from the position at index and returns the first node that
+passes the options, preferring nodes in the preferred zone, and the nodes skipped on the
+way. The node identified by avoid is passed over without being reported as skipped. Returns
+a nil node when every node is ruled out, callers must hold ring.mu.
+*/
+func (ring *HashRing) pickLocked(index int, options *lookupOptions, avoid string) (CacheNode, []string) {
+	var fallback CacheNode
+	var skipped []string
+	seen := make(map[string]struct{})
+	for i := 0; i < len(ring.sortedKeyOfNodes); i++ {
+		nodeHash := ring.sortedKeyOfNodes[(index+i)%len(ring.sortedKeyOfNodes)]
+		value, ok := ring.nodes.Load(uint64(nodeHash))
+		if !ok {
+			continue
+		}
+		node := value.(CacheNode)
+		id := node.GetIdentifier()
+		if _, dup := seen[id]; dup || id == avoid {
+			continue
+		}
+		seen[id] = struct{}{}
+
+		if !options.eligible(id, ring.stateLocked(uint64(nodeHash))) {
+			skipped = append(skipped, id)
+			continue
+		}
+		if options.zone == "" || inZone(node, options.zone) {
+			return node, skipped
+		}
+		if fallback == nil {
+			fallback = node
+		}
+		skipped = append(skipped, id)
+	}
+
+	// Nothing in the preferred zone, settle for the first eligible node in another one
+	if fallback != nil {
+		skipped = slices.DeleteFunc(skipped, func(id string) bool { return id == fallback.GetIdentifier() })
+	}
+	return fallback, skipped
+}
+
+// inZone reports whether node is a ZonedNode in zone
+func inZone(node CacheNode, zone string) bool {
+	zoned, ok := node.(ZonedNode)
+	return ok && zoned.GetZone() == zone
+}
+
+// eligible reports whether a node with the given identifier and state passes the options
+func (o *lookupOptions) eligible(id string, state NodeState) bool {
+	if _, excluded := o.exclude[id]; excluded {
+		return false
+	}
+	if len(o.states) == 0 {
+		return true
+	}
+	for _, s := range o.states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package hashring
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// zonedNode is a mockNode that also knows its zone
+type zonedNode struct {
+	mockNode
+	zone string
+}
+
+func (z *zonedNode) GetZone() string {
+	return z.zone
+}
+
+// recordingTracer remembers the context value and outcome of every lookup
+type recordingTracer struct {
+	spans   []any
+	nodes   []CacheNode
+	skipped [][]string
+}
+
+type spanKey struct{}
+
+func (r *recordingTracer) TraceLookup(ctx context.Context, key string, node CacheNode, skipped []string, err error, duration time.Duration) {
+	r.spans = append(r.spans, ctx.Value(spanKey{}))
+	r.nodes = append(r.nodes, node)
+	r.skipped = append(r.skipped, skipped)
+}
+
+/*
+TestGetNodeContext tests lookups with per-call options. It verifies that without options
+it agrees with GetNode, that excluded nodes and nodes in other states are skipped in ring
+order, that a preferred zone wins when one of its nodes is eligible, that impossible
+constraints and done contexts are reported, and that the tracer sees the caller's context.
+*/
+func TestGetNodeContext(t *testing.T) {
+	tracer := &recordingTracer{}
+	ring := HashRingInit(SetLookupTracer(tracer))
+	zones := []string{"a", "b", "a", "b", "c"}
+	for i, zone := range zones {
+		ring.AddNode(&zonedNode{mockNode: mockNode{identifier: fmt.Sprintf("node-%d.local", i)}, zone: zone})
+	}
+	ctx := context.Background()
+	key := "user:42"
+	order, _ := ring.GetNodes(key, len(zones))
+
+	t.Run("no options", func(t *testing.T) {
+		node, err := ring.GetNodeContext(ctx, key)
+		if err != nil {
+			t.Fatalf("GetNodeContext failed: %v", err)
+		}
+		if owner, _ := ring.GetNode(key); node != owner {
+			t.Errorf("Expected %s, got %s", owner.GetIdentifier(), node.GetIdentifier())
+		}
+	})
+
+	t.Run("exclude", func(t *testing.T) {
+		node, _ := ring.GetNodeContext(ctx, key, ExcludeNodes(order[0].GetIdentifier()))
+		if node != order[1] {
+			t.Errorf("Expected the next owner %s, got %s", order[1].GetIdentifier(), node.GetIdentifier())
+		}
+		node, _ = ring.GetNodeContext(ctx, key, ExcludeNodes(order[0].GetIdentifier()), ExcludeNodes(order[1].GetIdentifier()))
+		if node != order[2] {
+			t.Errorf("Expected %s with both excluded, got %s", order[2].GetIdentifier(), node.GetIdentifier())
+		}
+	})
+
+	t.Run("require state", func(t *testing.T) {
+		ring.SetNodeState(order[0].GetIdentifier(), StateDraining)
+		defer ring.SetNodeState(order[0].GetIdentifier(), StateActive)
+
+		if node, _ := ring.GetNodeContext(ctx, key, RequireState(StateActive)); node != order[1] {
+			t.Errorf("Expected to skip the draining owner, got %s", node.GetIdentifier())
+		}
+		if node, _ := ring.GetNodeContext(ctx, key, RequireState(StateActive, StateDraining)); node != order[0] {
+			t.Errorf("Expected the draining owner to be accepted, got %s", node.GetIdentifier())
+		}
+	})
+
+	t.Run("prefer zone", func(t *testing.T) {
+		want := order[0].(ZonedNode).GetZone()
+		for _, node := range order {
+			if zone := node.(ZonedNode).GetZone(); zone != want {
+				want = zone
+				break
+			}
+		}
+		node, _ := ring.GetNodeContext(ctx, key, PreferZone(want))
+		if node.(ZonedNode).GetZone() != want {
+			t.Errorf("Expected a node in zone %s, got %s", want, node.(ZonedNode).GetZone())
+		}
+
+		// An unknown zone falls back to the owner
+		if node, _ := ring.GetNodeContext(ctx, key, PreferZone("nowhere")); node != order[0] {
+			t.Errorf("Expected the owner without a node in the zone, got %s", node.GetIdentifier())
+		}
+	})
+
+	t.Run("no eligible node", func(t *testing.T) {
+		var all []string
+		for _, node := range order {
+			all = append(all, node.GetIdentifier())
+		}
+		if _, err := ring.GetNodeContext(ctx, key, ExcludeNodes(all...)); !errors.Is(err, ErrNoEligibleNodes) {
+			t.Errorf("Expected ErrNoEligibleNodes, got %v", err)
+		}
+	})
+
+	t.Run("done context", func(t *testing.T) {
+		done, cancel := context.WithCancel(ctx)
+		cancel()
+		if _, err := ring.GetNodeContext(done, key); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+	})
+
+	t.Run("tracer sees the context", func(t *testing.T) {
+		tracer.spans, tracer.nodes, tracer.skipped = nil, nil, nil
+		spanCtx := context.WithValue(ctx, spanKey{}, "span-1")
+		ring.GetNodeContext(spanCtx, key, ExcludeNodes(order[0].GetIdentifier()))
+
+		if len(tracer.spans) != 1 || tracer.spans[0] != "span-1" {
+			t.Fatalf("Expected the tracer to see span-1, got %v", tracer.spans)
+		}
+		if tracer.nodes[0] != order[1] || len(tracer.skipped[0]) != 1 || tracer.skipped[0][0] != order[0].GetIdentifier() {
+			t.Errorf("Unexpected trace %v skipping %v", tracer.nodes[0], tracer.skipped[0])
+		}
+	})
+}
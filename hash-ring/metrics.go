@@ -0,0 +1,369 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MembershipOp names the kind of membership change reported to a MetricsHook
+type MembershipOp string
+
+const (
+	MembershipAdd    MembershipOp = "add"
+	MembershipRemove MembershipOp = "remove"
+)
+
+/*
+RingState is a point-in-time summary of the HashRing handed to MetricsHook after every
+membership change. Fields:
+  - Nodes: Number of distinct nodes stored in the ring
+  - VirtualNodes: Number of points placed on the ring (one per node today)
+  - KeyspaceShare: Fraction of the 64-bit hash space owned by each node identifier
+*/
+type RingState struct {
+	Nodes         int
+	VirtualNodes  int
+	KeyspaceShare map[string]float64
+}
+
+/*
+MetricsHook receives observations from a HashRing. It is attached with SetMetricsHook
+and is called synchronously while the ring holds its lock, so implementations should
+only record the values and return quickly. ObserveLookup is called for every successful
+GetNode, ObserveMembershipChange for every successful AddNode or RemoveNode, followed by
+ObserveRingState with the state of the ring after that change.
+*/
+type MetricsHook interface {
+	ObserveLookup(nodeID string, duration time.Duration)
+	ObserveMembershipChange(op MembershipOp, nodeID string)
+	ObserveRingState(state RingState)
+}
+
+/*
+ringState builds a RingState from the current sortedKeyOfNodes slice. Every node owns the
+arc between its predecessor's hash (exclusive) and its own hash (inclusive), because
+binarySearch maps a key to the first node hash greater than or equal to the key's hash.
+Arc lengths are computed with uint64 arithmetic so that the wrap-around arc is measured
+correctly. Callers must hold ring.mu.
+*/
+func (ring *HashRing) ringState() RingState {
+	state := RingState{
+		VirtualNodes:  len(ring.sortedKeyOfNodes),
+		KeyspaceShare: make(map[string]float64),
+	}
+
+	for i, nodeHash := range ring.sortedKeyOfNodes {
+		value, ok := ring.nodes.Load(uint64(nodeHash))
+		if !ok {
+			continue
+		}
+		id := value.(CacheNode).GetIdentifier()
+
+		// A single point owns the whole ring, otherwise measure the distance from the previous point
+		share := 1.0
+		if len(ring.sortedKeyOfNodes) > 1 {
+			prev := ring.sortedKeyOfNodes[(i-1+len(ring.sortedKeyOfNodes))%len(ring.sortedKeyOfNodes)]
+			share = float64(uint64(nodeHash)-uint64(prev)) / math.Exp2(64)
+		}
+		if _, seen := state.KeyspaceShare[id]; !seen {
+			state.Nodes++
+		}
+		state.KeyspaceShare[id] += share
+	}
+	return state
+}
+
+// DefaultLookupBuckets are the upper bounds (in seconds) of the lookup latency histogram
+var DefaultLookupBuckets = []float64{
+	0.000001, 0.0000025, 0.000005, 0.00001, 0.000025, 0.00005,
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01,
+}
+
+/*
+PrometheusMetrics is a MetricsHook that keeps its own counters, histogram and gauges and
+writes them in the Prometheus text exposition format. It only depends on the standard
+library, and it implements http.Handler so it can be mounted directly on a /metrics route.
+Fields:
+  - mu: Mutex guarding every metric below
+  - buckets: Sorted upper bounds of the lookup latency histogram in seconds
+  - lookups: Successful lookups counted per node identifier
+  - bucketCounts: Non-cumulative number of lookups that fell into each bucket (+Inf last)
+  - lookupSum: Sum of all lookup durations in seconds
+  - lookupCount: Total number of observed lookups
+  - changes: Membership changes counted per operation
+  - state: Ring state reported after the latest membership change
+*/
+type PrometheusMetrics struct {
+	mu           sync.Mutex
+	buckets      []float64
+	lookups      map[string]uint64
+	bucketCounts []uint64
+	lookupSum    float64
+	lookupCount  uint64
+	changes      map[MembershipOp]uint64
+	state        RingState
+}
+
+/*
+NewPrometheusMetrics creates a PrometheusMetrics exporter. The optional buckets are the
+upper bounds of the lookup latency histogram in seconds; when none are given
+DefaultLookupBuckets is used. Pass the returned value to SetMetricsHook.
+*/
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+	if len(buckets) == 0 {
+		buckets = DefaultLookupBuckets
+	}
+	buckets = slices.Clone(buckets)
+	slices.Sort(buckets)
+	return &PrometheusMetrics{
+		buckets:      buckets,
+		lookups:      make(map[string]uint64),
+		bucketCounts: make([]uint64, len(buckets)+1),
+		changes:      make(map[MembershipOp]uint64),
+		state:        RingState{KeyspaceShare: make(map[string]float64)},
+	}
+}
+
+// ObserveLookup counts the lookup against its node and records its latency
+func (m *PrometheusMetrics) ObserveLookup(nodeID string, duration time.Duration) {
+	seconds := duration.Seconds()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.lookups[nodeID]++
+	// Bucket counts are stored non-cumulatively and summed up when written out
+	index, _ := slices.BinarySearch(m.buckets, seconds)
+	m.bucketCounts[index]++
+	m.lookupSum += seconds
+	m.lookupCount++
+}
+
+// ObserveMembershipChange counts an add or remove of a node
+func (m *PrometheusMetrics) ObserveMembershipChange(op MembershipOp, nodeID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.changes[op]++
+}
+
+// ObserveRingState replaces the gauges with the state reported by the ring
+func (m *PrometheusMetrics) ObserveRingState(state RingState) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.state = state
+}
+
+// Lookups returns a copy of the number of successful lookups counted per node identifier
+func (m *PrometheusMetrics) Lookups() map[string]uint64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return maps.Clone(m.lookups)
+}
+
+/*
+WriteTo writes every metric in the Prometheus text exposition format (version 0.0.4).
+Series with labels are written in sorted label order so the output is stable between
+scrapes. Returns the number of bytes written and the first write error, if any.
+*/
+func (m *PrometheusMetrics) WriteTo(w io.Writer) (int64, error) {
+	return writeMetrics(w, "", map[string]*PrometheusMetrics{"": m})
+}
+
+/*
+WriteLabeledMetrics writes the metrics of several rings as one exposition, with every
+series of metrics[value] carrying the label labelName="value". This is how a process that
+runs many rings exposes them on a single /metrics route without the series colliding,
+each metric family is written once with the series of all rings under it.
+*/
+func WriteLabeledMetrics(w io.Writer, labelName string, metrics map[string]*PrometheusMetrics) (int64, error) {
+	return writeMetrics(w, labelName, metrics)
+}
+
+// metricsSnapshot is a copy of the metrics of one PrometheusMetrics, taken for writing them out
+type metricsSnapshot struct {
+	buckets      []float64
+	lookups      map[string]uint64
+	bucketCounts []uint64
+	lookupSum    float64
+	lookupCount  uint64
+	changes      map[MembershipOp]uint64
+	state        RingState
+}
+
+// snapshot copies the metrics under m.mu, so they can be written out without holding it
+func (m *PrometheusMetrics) snapshot() *metricsSnapshot {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state := m.state
+	state.KeyspaceShare = maps.Clone(m.state.KeyspaceShare)
+	return &metricsSnapshot{
+		buckets:      m.buckets,
+		lookups:      maps.Clone(m.lookups),
+		bucketCounts: slices.Clone(m.bucketCounts),
+		lookupSum:    m.lookupSum,
+		lookupCount:  m.lookupCount,
+		changes:      maps.Clone(m.changes),
+		state:        state,
+	}
+}
+
+/*
+writeMetrics writes every metric family for all sets, labelName is empty for a single
+unlabeled set. Each exporter is copied under its own lock, one at a time, and the copies
+are written out without holding any lock, so a slow reader never blocks lookups and two
+writers can never wait on each other.
+*/
+func writeMetrics(w io.Writer, labelName string, exporters map[string]*PrometheusMetrics) (int64, error) {
+	values := sortedKeys(exporters)
+	// Copy every exporter once, the same one may be passed under several labels
+	copies := make(map[*PrometheusMetrics]*metricsSnapshot, len(exporters))
+	metrics := make(map[string]*metricsSnapshot, len(exporters))
+	for _, value := range values {
+		m := exporters[value]
+		if _, ok := copies[m]; !ok {
+			copies[m] = m.snapshot()
+		}
+		metrics[value] = copies[m]
+	}
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	// labels renders the set's label followed by extra, in the {..} form or empty
+	labels := func(value string, extra ...string) string {
+		if labelName != "" {
+			extra = append([]string{fmt.Sprintf("%s=\"%s\"", labelName, escapeLabelValue(value))}, extra...)
+		}
+		if len(extra) == 0 {
+			return ""
+		}
+		return "{" + strings.Join(extra, ",") + "}"
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_lookups_total Number of successful key lookups per node.")
+	fmt.Fprintln(cw, "# TYPE chash_lookups_total counter")
+	for _, value := range values {
+		m := metrics[value]
+		for _, id := range sortedKeys(m.lookups) {
+			fmt.Fprintf(cw, "chash_lookups_total%s %d\n", labels(value, fmt.Sprintf("node=\"%s\"", escapeLabelValue(id))), m.lookups[id])
+		}
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_lookup_duration_seconds Latency of successful key lookups.")
+	fmt.Fprintln(cw, "# TYPE chash_lookup_duration_seconds histogram")
+	for _, value := range values {
+		m := metrics[value]
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += m.bucketCounts[i]
+			fmt.Fprintf(cw, "chash_lookup_duration_seconds_bucket%s %d\n", labels(value, fmt.Sprintf("le=\"%s\"", formatFloat(bound))), cumulative)
+		}
+		fmt.Fprintf(cw, "chash_lookup_duration_seconds_bucket%s %d\n", labels(value, "le=\"+Inf\""), m.lookupCount)
+		fmt.Fprintf(cw, "chash_lookup_duration_seconds_sum%s %s\n", labels(value), formatFloat(m.lookupSum))
+		fmt.Fprintf(cw, "chash_lookup_duration_seconds_count%s %d\n", labels(value), m.lookupCount)
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_membership_changes_total Number of nodes added to or removed from the ring.")
+	fmt.Fprintln(cw, "# TYPE chash_membership_changes_total counter")
+	for _, value := range values {
+		for _, op := range []MembershipOp{MembershipAdd, MembershipRemove} {
+			fmt.Fprintf(cw, "chash_membership_changes_total%s %d\n", labels(value, fmt.Sprintf("op=\"%s\"", op)), metrics[value].changes[op])
+		}
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_nodes Number of nodes in the ring.")
+	fmt.Fprintln(cw, "# TYPE chash_nodes gauge")
+	for _, value := range values {
+		fmt.Fprintf(cw, "chash_nodes%s %d\n", labels(value), metrics[value].state.Nodes)
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_vnodes Number of points placed on the ring.")
+	fmt.Fprintln(cw, "# TYPE chash_vnodes gauge")
+	for _, value := range values {
+		fmt.Fprintf(cw, "chash_vnodes%s %d\n", labels(value), metrics[value].state.VirtualNodes)
+	}
+
+	fmt.Fprintln(cw, "# HELP chash_keyspace_share Fraction of the hash space owned by each node.")
+	fmt.Fprintln(cw, "# TYPE chash_keyspace_share gauge")
+	for _, value := range values {
+		m := metrics[value]
+		for _, id := range sortedKeys(m.state.KeyspaceShare) {
+			fmt.Fprintf(cw, "chash_keyspace_share%s %s\n", labels(value, fmt.Sprintf("node=\"%s\"", escapeLabelValue(id))), formatFloat(m.state.KeyspaceShare[id]))
+		}
+	}
+
+	if cw.err == nil {
+		cw.err = cw.w.Flush()
+	}
+	return cw.n, cw.err
+}
+
+// ServeHTTP writes the metrics so PrometheusMetrics can be mounted as a scrape endpoint
+func (m *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	m.WriteTo(w)
+}
+
+// countingWriter remembers the bytes written and the first error so WriteTo can report them
+type countingWriter struct {
+	w   *bufio.Writer
+	n   int64
+	err error
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	c.err = err
+	return n, err
+}
+
+// escapeLabelValue escapes backslashes, double quotes and newlines as the exposition format requires
+func escapeLabelValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func sortedKeys[K ~string, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
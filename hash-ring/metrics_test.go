@@ -0,0 +1,211 @@
+package hashring
+
+import (
+	"io"
+	"math"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// blockingWriter signals started on the first write and blocks every write until release is closed
+type blockingWriter struct {
+	once    sync.Once
+	started chan struct{}
+	release chan struct{}
+}
+
+func (b *blockingWriter) Write(p []byte) (int, error) {
+	b.once.Do(func() { close(b.started) })
+	<-b.release
+	return len(p), nil
+}
+
+/*
+TestPrometheusMetrics tests that a PrometheusMetrics exporter attached with SetMetricsHook
+receives lookups and membership changes from the HashRing and writes them in the Prometheus
+text exposition format, including the node count and keyspace share gauges, and that
+writing holds no lock a lookup or another writer could wait on.
+*/
+func TestPrometheusMetrics(t *testing.T) {
+	t.Run("records lookups and membership changes", func(t *testing.T) {
+		// Initialize HashRing with the exporter attached
+		metrics := NewPrometheusMetrics()
+		ring := HashRingInit(SetMetricsHook(metrics))
+		node1 := &mockNode{identifier: "node1"}
+		node2 := &mockNode{identifier: "node2"}
+
+		if err := ring.AddNode(node1); err != nil {
+			t.Fatalf("Failed to add node1: %v", err)
+		}
+		if err := ring.AddNode(node2); err != nil {
+			t.Fatalf("Failed to add node2: %v", err)
+		}
+		if err := ring.RemoveNode(node2); err != nil {
+			t.Fatalf("Failed to remove node2: %v", err)
+		}
+
+		// With a single node left every lookup lands on node1
+		for i := 0; i < 3; i++ {
+			if _, err := ring.GetNode("key"); err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+		}
+
+		if lookups := metrics.Lookups(); lookups["node1"] != 3 {
+			t.Errorf("Expected 3 lookups on node1, got %v", lookups)
+		}
+
+		var out strings.Builder
+		if _, err := metrics.WriteTo(&out); err != nil {
+			t.Fatalf("WriteTo failed: %v", err)
+		}
+
+		// Verify every expected series is present with its value
+		expected := []string{
+			`chash_lookups_total{node="node1"} 3`,
+			`chash_lookup_duration_seconds_bucket{le="+Inf"} 3`,
+			`chash_lookup_duration_seconds_count 3`,
+			`chash_membership_changes_total{op="add"} 2`,
+			`chash_membership_changes_total{op="remove"} 1`,
+			`chash_nodes 1`,
+			`chash_vnodes 1`,
+			`chash_keyspace_share{node="node1"} 1`,
+			`# TYPE chash_lookup_duration_seconds histogram`,
+		}
+		for _, line := range expected {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+			}
+		}
+	})
+
+	t.Run("keyspace shares add up to one", func(t *testing.T) {
+		metrics := NewPrometheusMetrics()
+		ring := HashRingInit(SetMetricsHook(metrics))
+		for _, id := range []string{"node1", "node2", "node3", "node4"} {
+			if err := ring.AddNode(&mockNode{identifier: id}); err != nil {
+				t.Fatalf("Failed to add %s: %v", id, err)
+			}
+		}
+
+		// Sum up every node's share of the hash space
+		total := 0.0
+		for _, share := range metrics.state.KeyspaceShare {
+			total += share
+		}
+		if math.Abs(total-1) > 1e-9 {
+			t.Errorf("Expected shares to add up to 1, got %v", total)
+		}
+		if metrics.state.Nodes != 4 {
+			t.Errorf("Expected 4 nodes, got %d", metrics.state.Nodes)
+		}
+	})
+
+	t.Run("histogram buckets are cumulative", func(t *testing.T) {
+		// Use explicit buckets so each observation lands in a known bucket
+		metrics := NewPrometheusMetrics(0.001, 0.01)
+		metrics.ObserveLookup("node1", 500*time.Microsecond)
+		metrics.ObserveLookup("node1", 5*time.Millisecond)
+		metrics.ObserveLookup("node1", time.Second)
+
+		var out strings.Builder
+		metrics.WriteTo(&out)
+		for _, line := range []string{
+			`chash_lookup_duration_seconds_bucket{le="0.001"} 1`,
+			`chash_lookup_duration_seconds_bucket{le="0.01"} 2`,
+			`chash_lookup_duration_seconds_bucket{le="+Inf"} 3`,
+		} {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+			}
+		}
+	})
+
+	t.Run("escapes label values and serves over http", func(t *testing.T) {
+		metrics := NewPrometheusMetrics()
+		metrics.ObserveLookup("node\"1\\", time.Microsecond)
+
+		// Scrape the exporter as Prometheus would
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+		if ct := recorder.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+			t.Errorf("Unexpected content type %q", ct)
+		}
+		if !strings.Contains(recorder.Body.String(), `chash_lookups_total{node="node\"1\\"} 1`) {
+			t.Errorf("Label value was not escaped:\n%s", recorder.Body.String())
+		}
+	})
+
+	t.Run("labeled metrics of several rings", func(t *testing.T) {
+		users, orders := NewPrometheusMetrics(), NewPrometheusMetrics()
+		users.ObserveLookup("node1", time.Microsecond)
+		orders.ObserveLookup("node2", time.Microsecond)
+		orders.ObserveLookup("node2", time.Microsecond)
+
+		var out strings.Builder
+		if _, err := WriteLabeledMetrics(&out, "ring", map[string]*PrometheusMetrics{"users": users, "orders": orders}); err != nil {
+			t.Fatalf("WriteLabeledMetrics failed: %v", err)
+		}
+		for _, line := range []string{
+			`chash_lookups_total{ring="users",node="node1"} 1`,
+			`chash_lookups_total{ring="orders",node="node2"} 2`,
+			`chash_lookup_duration_seconds_count{ring="orders"} 2`,
+			`chash_nodes{ring="users"} 0`,
+		} {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+			}
+		}
+		if got := strings.Count(out.String(), "# TYPE chash_nodes gauge"); got != 1 {
+			t.Errorf("Expected each metric family once, got %d TYPE lines for chash_nodes", got)
+		}
+	})
+	t.Run("slow reader does not block lookups", func(t *testing.T) {
+		metrics := NewPrometheusMetrics()
+		w := &blockingWriter{started: make(chan struct{}), release: make(chan struct{})}
+		defer close(w.release)
+		go metrics.WriteTo(w)
+		<-w.started
+
+		observed := make(chan struct{})
+		go func() {
+			metrics.ObserveLookup("node1", time.Microsecond)
+			close(observed)
+		}()
+		select {
+		case <-observed:
+		case <-time.After(2 * time.Second):
+			t.Fatal("ObserveLookup blocked behind a slow metrics reader")
+		}
+	})
+
+	t.Run("writers with swapped labels do not deadlock", func(t *testing.T) {
+		a, b := NewPrometheusMetrics(), NewPrometheusMetrics()
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			var wg sync.WaitGroup
+			for i := 0; i < 200; i++ {
+				wg.Add(2)
+				go func() {
+					defer wg.Done()
+					WriteLabeledMetrics(io.Discard, "ring", map[string]*PrometheusMetrics{"x": a, "y": b})
+				}()
+				go func() {
+					defer wg.Done()
+					WriteLabeledMetrics(io.Discard, "ring", map[string]*PrometheusMetrics{"x": b, "y": a})
+				}()
+			}
+			wg.Wait()
+		}()
+		select {
+		case <-done:
+		case <-time.After(10 * time.Second):
+			t.Fatal("Concurrent labeled writes deadlocked")
+		}
+	})
+}
@@ -0,0 +1,206 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+/*
+Range is an arc of the ring: the hashes after Start up to and including End, the position
+of the node that owns them. Ranges follow the ring's order, so the last one wraps around
+from the largest position to the smallest. A ring with a single position has one Range
+with Start equal to End, covering every hash. Nodes are the owners of the range, the
+owner first and its successors after it, as GetNodes would return them for any key in it.
+*/
+type Range struct {
+	Start uint64
+	End   uint64
+	Nodes []CacheNode
+}
+
+// Contains reports whether a key hash, as returned by KeyHash, falls in the range
+func (r Range) Contains(hash uint64) bool {
+	return arcContains(r.Start, r.End, hash)
+}
+
+// arcContains reports whether hash falls after start up to and including end
+func arcContains(startHash, endHash, hash uint64) bool {
+	// Positions are ordered as signed integers, the same way binarySearch compares them
+	start, end, h := int64(startHash), int64(endHash), int64(hash)
+	switch {
+	case start == end:
+		return true
+	case start < end:
+		return start < h && h <= end
+	default:
+		return h > start || h <= end
+	}
+}
+
+// Owner returns the node that owns the range
+func (r Range) Owner() CacheNode {
+	return r.Nodes[0]
+}
+
+/*
+Ranges returns every range of the ring in ring order, each with up to replicas distinct
+owners. The ranges partition the hash space, so every key hash falls in exactly one of
+them. Returns ErrInvalidCount if replicas is below 1, since every range needs an owner,
+and ErrNoConnectedNodes if the ring is empty.
+*/
+func (ring *HashRing) Ranges(replicas int) ([]Range, error) {
+	if replicas < 1 {
+		return nil, fmt.Errorf("%w: got %d replicas", ErrInvalidCount, replicas)
+	}
+
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	if len(ring.sortedKeyOfNodes) == 0 {
+		return nil, ErrNoConnectedNodes
+	}
+	ranges := make([]Range, 0, len(ring.sortedKeyOfNodes))
+	for i, nodeHash := range ring.sortedKeyOfNodes {
+		prev := ring.sortedKeyOfNodes[(i-1+len(ring.sortedKeyOfNodes))%len(ring.sortedKeyOfNodes)]
+		ranges = append(ranges, Range{
+			Start: uint64(prev),
+			End:   uint64(nodeHash),
+			Nodes: ring.successorsLocked(i, replicas),
+		})
+	}
+	return ranges, nil
+}
+
+/*
+successorsLocked returns up to count distinct nodes walking clockwise from the position at
+index, callers must hold ring.mu.
+*/
+func (ring *HashRing) successorsLocked(index, count int) []CacheNode {
+	nodes := make([]CacheNode, 0, min(count, len(ring.sortedKeyOfNodes)))
+	seen := make(map[string]struct{}, cap(nodes))
+	for i := 0; i < len(ring.sortedKeyOfNodes) && len(nodes) < count; i++ {
+		nodeHash := ring.sortedKeyOfNodes[(index+i)%len(ring.sortedKeyOfNodes)]
+		value, ok := ring.nodes.Load(uint64(nodeHash))
+		if !ok {
+			continue
+		}
+		node := value.(CacheNode)
+		if _, dup := seen[node.GetIdentifier()]; dup {
+			continue
+		}
+		seen[node.GetIdentifier()] = struct{}{}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+/*
+KeyHash returns the position of key on the ring, after the key extractor is applied, so
+that callers can tell which Range a key belongs to.
+*/
+func (ring *HashRing) KeyHash(key string) (uint64, error) {
+	hashVal, err := ring.generateHash(ring.extractKey(key))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %s", ErrInHashingKey, key)
+	}
+	return hashVal, nil
+}
+
+/*
+Move is an arc of the hash space whose owner changed between two versions of a ring:
+keys hashing after Start up to and including End were owned by From and are now owned by
+To. Start equal to End covers the whole ring.
+*/
+type Move struct {
+	Start uint64
+	End   uint64
+	From  CacheNode
+	To    CacheNode
+}
+
+// Contains reports whether a key hash, as returned by KeyHash, falls in the moved arc
+func (m Move) Contains(hash uint64) bool {
+	return arcContains(m.Start, m.End, hash)
+}
+
+/*
+DiffRanges compares the ranges of a ring before and after a membership change, as
+returned by Ranges, and returns the arcs whose owner changed, in ring order. Adding a node
+yields the arcs it takes over from its successors, removing one yields the arcs its
+successors take over from it, and unchanged arcs are left out. Returns nil when either
+side is empty, since there is nothing to move from or to.
+*/
+func DiffRanges(before, after []Range) []Move {
+	if len(before) == 0 || len(after) == 0 {
+		return nil
+	}
+
+	// Every range boundary of either ring starts a new elementary arc
+	var points []int64
+	for _, r := range before {
+		points = append(points, int64(r.End))
+	}
+	for _, r := range after {
+		points = append(points, int64(r.End))
+	}
+	slices.Sort(points)
+	points = slices.Compact(points)
+
+	var moves []Move
+	for i, point := range points {
+		prev := points[(i-1+len(points))%len(points)]
+		from, to := ownerAt(before, point), ownerAt(after, point)
+		if from.GetIdentifier() == to.GetIdentifier() {
+			continue
+		}
+		// Merge with the previous arc when it moved between the same nodes
+		if n := len(moves); n > 0 && moves[n-1].End == uint64(prev) &&
+			moves[n-1].From.GetIdentifier() == from.GetIdentifier() && moves[n-1].To.GetIdentifier() == to.GetIdentifier() {
+			moves[n-1].End = uint64(point)
+			continue
+		}
+		moves = append(moves, Move{Start: uint64(prev), End: uint64(point), From: from, To: to})
+	}
+
+	// The last arc may continue into the first one across the wrap-around
+	if n := len(moves); n > 1 && moves[n-1].End == moves[0].Start &&
+		moves[n-1].From.GetIdentifier() == moves[0].From.GetIdentifier() && moves[n-1].To.GetIdentifier() == moves[0].To.GetIdentifier() {
+		moves[0].Start = moves[n-1].Start
+		moves = moves[:n-1]
+	}
+	return moves
+}
+
+// ownerAt returns the owner of the range of ranges that contains hash
+func ownerAt(ranges []Range, hash int64) CacheNode {
+	i := sort.Search(len(ranges), func(i int) bool {
+		return int64(ranges[i].End) >= hash
+	})
+	if i == len(ranges) {
+		i = 0
+	}
+	return ranges[i].Owner()
+}
@@ -0,0 +1,181 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+/*
+TestRanges tests the ring's ranges. It verifies that every key hash falls in exactly one
+range, that the range agrees with GetNode and GetNodes about the key's owners, and that a
+single node owns one range covering the whole ring.
+*/
+func TestRanges(t *testing.T) {
+	t.Run("ranges partition the ring", func(t *testing.T) {
+		ring := HashRingInit()
+		for i := 0; i < 6; i++ {
+			ring.AddNode(&mockNode{identifier: fmt.Sprintf("node-%d.local", i)})
+		}
+		ranges, err := ring.Ranges(2)
+		if err != nil {
+			t.Fatalf("Ranges failed: %v", err)
+		}
+		if len(ranges) != 6 {
+			t.Fatalf("Expected 6 ranges, got %d", len(ranges))
+		}
+
+		for i := 0; i < 1000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			hash, err := ring.KeyHash(key)
+			if err != nil {
+				t.Fatalf("KeyHash failed: %v", err)
+			}
+			var matches []Range
+			for _, r := range ranges {
+				if r.Contains(hash) {
+					matches = append(matches, r)
+				}
+			}
+			if len(matches) != 1 {
+				t.Fatalf("Key %s falls in %d ranges", key, len(matches))
+			}
+
+			owners, _ := ring.GetNodes(key, 2)
+			got := matches[0].Nodes
+			if len(got) != 2 || got[0] != owners[0] || got[1] != owners[1] {
+				t.Fatalf("Range owners of %s disagree with GetNodes", key)
+			}
+		}
+	})
+
+	t.Run("single node owns the whole ring", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		ranges, _ := ring.Ranges(3)
+		if len(ranges) != 1 || len(ranges[0].Nodes) != 1 {
+			t.Fatalf("Expected one range with one owner, got %+v", ranges)
+		}
+		for _, hash := range []uint64{0, 1 << 63, ^uint64(0)} {
+			if !ranges[0].Contains(hash) {
+				t.Errorf("Expected the range to contain %d", hash)
+			}
+		}
+	})
+
+	t.Run("empty ring", func(t *testing.T) {
+		if _, err := HashRingInit().Ranges(1); err != ErrNoConnectedNodes {
+			t.Errorf("Expected ErrNoConnectedNodes, got %v", err)
+		}
+	})
+
+	t.Run("replicas below one", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		for _, replicas := range []int{0, -1} {
+			if _, err := ring.Ranges(replicas); !errors.Is(err, ErrInvalidCount) {
+				t.Errorf("Replicas %d: expected ErrInvalidCount, got %v", replicas, err)
+			}
+		}
+	})
+}
+
+/*
+TestDiffRanges tests the range diff of a membership change. It verifies, for a join and a
+leave, that a key falls in a move exactly when its owner changed, with the move naming
+the old and the new owner, and that a join only moves keys to the new node.
+*/
+func TestDiffRanges(t *testing.T) {
+	ring := HashRingInit()
+	for i := 0; i < 5; i++ {
+		ring.AddNode(&mockNode{identifier: fmt.Sprintf("node-%d.local", i)})
+	}
+
+	// check verifies the moves against GetNode for the rings before and after
+	check := func(t *testing.T, moves []Move, ownerBefore map[string]string) {
+		t.Helper()
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			hash, _ := ring.KeyHash(key)
+			after, _ := ring.GetNode(key)
+
+			var matches []Move
+			for _, m := range moves {
+				if m.Contains(hash) {
+					matches = append(matches, m)
+				}
+			}
+			moved := ownerBefore[key] != after.GetIdentifier()
+			if moved != (len(matches) == 1) || len(matches) > 1 {
+				t.Fatalf("Key %s moved=%v but falls in %d moves", key, moved, len(matches))
+			}
+			if moved && (matches[0].From.GetIdentifier() != ownerBefore[key] || matches[0].To.GetIdentifier() != after.GetIdentifier()) {
+				t.Fatalf("Move of %s is %s -> %s, expected %s -> %s", key, matches[0].From.GetIdentifier(),
+					matches[0].To.GetIdentifier(), ownerBefore[key], after.GetIdentifier())
+			}
+		}
+	}
+
+	owners := func() map[string]string {
+		out := make(map[string]string)
+		for i := 0; i < 2000; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			node, _ := ring.GetNode(key)
+			out[key] = node.GetIdentifier()
+		}
+		return out
+	}
+
+	t.Run("join", func(t *testing.T) {
+		ownerBefore := owners()
+		before, _ := ring.Ranges(1)
+		ring.AddNode(&mockNode{identifier: "node-new.local"})
+		after, _ := ring.Ranges(1)
+
+		moves := DiffRanges(before, after)
+		if len(moves) == 0 {
+			t.Fatal("Expected the new node to take over some range")
+		}
+		for _, m := range moves {
+			if m.To.GetIdentifier() != "node-new.local" {
+				t.Errorf("Expected every move to go to the new node, got %s", m.To.GetIdentifier())
+			}
+		}
+		check(t, moves, ownerBefore)
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		ownerBefore := owners()
+		before, _ := ring.Ranges(1)
+		ring.RemoveNode(&mockNode{identifier: "node-2.local"})
+		after, _ := ring.Ranges(1)
+
+		moves := DiffRanges(before, after)
+		for _, m := range moves {
+			if m.From.GetIdentifier() != "node-2.local" {
+				t.Errorf("Expected every move to come from the removed node, got %s", m.From.GetIdentifier())
+			}
+		}
+		check(t, moves, ownerBefore)
+	})
+
+	t.Run("second node takes one arc", func(t *testing.T) {
+		single := HashRingInit()
+		single.AddNode(&mockNode{identifier: "node1"})
+		before, _ := single.Ranges(1)
+		single.AddNode(&mockNode{identifier: "node2"})
+		after, _ := single.Ranges(1)
+
+		moves := DiffRanges(before, after)
+		if len(moves) != 1 || moves[0].From.GetIdentifier() != "node1" || moves[0].To.GetIdentifier() != "node2" {
+			t.Errorf("Expected a single arc from node1 to node2, got %+v", moves)
+		}
+	})
+
+	t.Run("unchanged ring", func(t *testing.T) {
+		ranges, _ := ring.Ranges(1)
+		if moves := DiffRanges(ranges, ranges); len(moves) != 0 {
+			t.Errorf("Expected no moves, got %v", moves)
+		}
+	})
+}
@@ -0,0 +1,312 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrRotationInProgress = errors.New("Seed rotation already in progress")
+	ErrNoRotation         = errors.New("No seed rotation in progress")
+	ErrSeedMismatch       = errors.New("Seed does not match the ring")
+)
+
+/*
+RotatingRing is a secure-mode ring whose seed can be replaced without a flag day. Changing
+the seed moves almost every key, so a rotation runs through a transition period in which
+two rings with the same members exist side by side: the current one keyed with the old
+seed and the next one keyed with the new seed. During the transition GetNode already
+answers from the next ring, so new writes land where they will stay, and
+GetNodeCandidates also returns the old owner, so reads can fall back to it while data
+moves. CompleteRotation then drops the old ring. Fields:
+  - mu: Read-write mutex guarding the rings and seeds
+  - opts: Options every ring is created with, before its seed is applied
+  - current: Ring keyed with seed
+  - next: Ring keyed with nextSeed, nil when no rotation is running
+  - seed: Seed of current
+  - nextSeed: Seed of next
+*/
+type RotatingRing struct {
+	mu       sync.RWMutex
+	opts     []HashRingConfigFn
+	current  *HashRing
+	next     *HashRing
+	seed     Seed
+	nextSeed Seed
+}
+
+/*
+RotatingRingInit creates a RotatingRing keyed with seed. opts configure both the current
+ring and every ring created for a rotation, a hash function set in them is replaced by the
+keyed SipHash. Returns ErrInvalidSeed for a zero seed.
+*/
+func RotatingRingInit(seed Seed, opts ...HashRingConfigFn) (*RotatingRing, error) {
+	if seed.IsZero() {
+		return nil, fmt.Errorf("%w: seed is all zeros", ErrInvalidSeed)
+	}
+	rr := &RotatingRing{opts: opts, seed: seed}
+	rr.current = rr.newRing(seed)
+	return rr, nil
+}
+
+// newRing creates an empty ring with the configured options keyed with seed
+func (rr *RotatingRing) newRing(seed Seed) *HashRing {
+	return HashRingInit(append(append([]HashRingConfigFn{}, rr.opts...), SetSecureHash(seed))...)
+}
+
+/*
+AddNode adds node to the current ring and, during a rotation, to the next ring as well.
+If the next ring refuses the node, the add is undone on the current ring so that both
+rings keep the same members.
+*/
+func (rr *RotatingRing) AddNode(node CacheNode) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if err := rr.current.AddNode(node); err != nil {
+		return err
+	}
+	if rr.next != nil {
+		if err := rr.next.AddNode(node); err != nil {
+			rr.current.RemoveNode(node)
+			return err
+		}
+	}
+	return nil
+}
+
+// RemoveNode removes node from the current ring and, during a rotation, from the next ring
+func (rr *RotatingRing) RemoveNode(node CacheNode) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if err := rr.current.RemoveNode(node); err != nil {
+		return err
+	}
+	if rr.next != nil {
+		if err := rr.next.RemoveNode(node); err != nil {
+			rr.current.AddNode(node)
+			return err
+		}
+	}
+	return nil
+}
+
+// SetNodeState sets the state of a node on every ring
+func (rr *RotatingRing) SetNodeState(id string, state NodeState) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if err := rr.current.SetNodeState(id, state); err != nil {
+		return err
+	}
+	if rr.next != nil {
+		return rr.next.SetNodeState(id, state)
+	}
+	return nil
+}
+
+/*
+GetNode returns the owner of key under the newest seed: the next ring's owner during a
+rotation and the current ring's owner otherwise. Writes should go to this node.
+*/
+func (rr *RotatingRing) GetNode(key string) (CacheNode, error) {
+	return rr.Ring().GetNode(key)
+}
+
+/*
+GetNodeCandidates returns the nodes that may hold key, in the order they should be read:
+the owner under the newest seed first and, during a rotation, the owner under the old seed
+second if it is a different node. Outside a rotation there is exactly one candidate.
+*/
+func (rr *RotatingRing) GetNodeCandidates(key string) ([]CacheNode, error) {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	if rr.next == nil {
+		node, err := rr.current.GetNode(key)
+		if err != nil {
+			return nil, err
+		}
+		return []CacheNode{node}, nil
+	}
+
+	owner, err := rr.next.GetNode(key)
+	if err != nil {
+		return nil, err
+	}
+	previous, err := rr.current.GetNode(key)
+	if err != nil {
+		return nil, err
+	}
+	if previous.GetIdentifier() == owner.GetIdentifier() {
+		return []CacheNode{owner}, nil
+	}
+	return []CacheNode{owner, previous}, nil
+}
+
+/*
+Ring returns the ring GetNode answers from, for the lookups RotatingRing does not wrap
+such as GetNodeContext or GetNodesForKeys. Membership must still be changed through the
+RotatingRing, changes made on the returned ring directly are not mirrored to the other.
+*/
+func (rr *RotatingRing) Ring() *HashRing {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+
+	if rr.next != nil {
+		return rr.next
+	}
+	return rr.current
+}
+
+// Rotating reports whether a rotation is in progress
+func (rr *RotatingRing) Rotating() bool {
+	rr.mu.RLock()
+	defer rr.mu.RUnlock()
+	return rr.next != nil
+}
+
+/*
+BeginRotation starts the transition to seed: it builds the next ring keyed with seed and
+fills it with the members and node states of the current ring. Returns
+ErrRotationInProgress if a rotation is already running and ErrInvalidSeed for a zero seed
+or the seed already in use.
+*/
+func (rr *RotatingRing) BeginRotation(seed Seed) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+	return rr.beginRotationLocked(seed)
+}
+
+// beginRotationLocked does the work of BeginRotation, callers must hold rr.mu for writing
+func (rr *RotatingRing) beginRotationLocked(seed Seed) error {
+	if rr.next != nil {
+		return fmt.Errorf("%w: rotating to %s", ErrRotationInProgress, rr.nextSeed.fingerprint())
+	}
+	if seed.IsZero() || seed == rr.seed {
+		return fmt.Errorf("%w: next seed must be non-zero and differ from the current one", ErrInvalidSeed)
+	}
+
+	next := rr.newRing(seed)
+	members, _ := rr.current.Members()
+	changes := make([]Change, 0, len(members))
+	for _, member := range members {
+		changes = append(changes, Change{Op: MembershipAdd, Node: member.Node})
+	}
+	if _, err := next.CompareAndApply(0, changes); err != nil {
+		return err
+	}
+	for _, member := range members {
+		if err := next.SetNodeState(member.Node.GetIdentifier(), member.State); err != nil {
+			return err
+		}
+	}
+
+	rr.next = next
+	rr.nextSeed = seed
+	return nil
+}
+
+/*
+CompleteRotation ends the transition: the next ring becomes the current ring and the old
+ring and seed are dropped. Only call it once the data has been moved to the new owners,
+since afterwards reads no longer fall back to them. Returns ErrNoRotation if no rotation
+is running.
+*/
+func (rr *RotatingRing) CompleteRotation() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.next == nil {
+		return ErrNoRotation
+	}
+	rr.current, rr.seed = rr.next, rr.nextSeed
+	rr.dropNextLocked()
+	return nil
+}
+
+// AbortRotation drops the next ring and keeps the current seed, or returns ErrNoRotation
+func (rr *RotatingRing) AbortRotation() error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if rr.next == nil {
+		return ErrNoRotation
+	}
+	rr.dropNextLocked()
+	return nil
+}
+
+// dropNextLocked forgets the next ring and its seed, callers must hold rr.mu for writing
+func (rr *RotatingRing) dropNextLocked() {
+	rr.next, rr.nextSeed = nil, Seed{}
+}
+
+/*
+ApplySeedConfig moves the ring towards config, which lets a rotation be driven entirely by
+rewriting the config file and reloading it:
+  - {current: A} with the ring on A and no rotation changes nothing
+  - {current: A, next: B} with the ring on A begins the rotation to B
+  - {current: B} while rotating from A to B completes the rotation
+  - {current: A} while rotating from A aborts the rotation
+
+Any other combination, such as a current seed the ring never had, returns ErrSeedMismatch
+and leaves the ring as it is.
+*/
+func (rr *RotatingRing) ApplySeedConfig(config SeedConfig) error {
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	rotating := rr.next != nil
+	switch {
+	case config.Current == rr.seed && config.Next == nil && !rotating:
+		return nil
+	case config.Current == rr.seed && config.Next != nil && !rotating:
+		return rr.beginRotationLocked(*config.Next)
+	case config.Current == rr.seed && config.Next != nil && *config.Next == rr.nextSeed:
+		return nil
+	case config.Current == rr.nextSeed && config.Next == nil && rotating:
+		rr.current, rr.seed = rr.next, rr.nextSeed
+		rr.dropNextLocked()
+		return nil
+	case config.Current == rr.seed && config.Next == nil && rotating:
+		rr.dropNextLocked()
+		return nil
+	}
+	return fmt.Errorf("%w: ring is on %s", ErrSeedMismatch, rr.seed.fingerprint())
+}
+
+/*
+fingerprint identifies a seed in errors and logs without revealing it: the first bytes of
+its SipHash of a fixed message, which cannot be turned back into the seed.
+*/
+func (s Seed) fingerprint() string {
+	h := SipHash(s)()
+	h.Write([]byte("chash seed fingerprint"))
+	return fmt.Sprintf("seed#%08x", h.Sum64()>>32)
+}
@@ -0,0 +1,161 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+/*
+TestRotatingRing tests seed rotation. It verifies that during a rotation writes go to the
+owner under the new seed while reads can still reach the old owner, that membership and
+node states stay mirrored on both rings, that completing or aborting settles on one seed,
+and that a rotation can be driven through ApplySeedConfig.
+*/
+func TestRotatingRing(t *testing.T) {
+	oldSeed, _ := ParseSeed("000102030405060708090a0b0c0d0e0f")
+	newSeed, _ := ParseSeed("f0e0d0c0b0a090807060504030201000")
+
+	newRing := func(t *testing.T) *RotatingRing {
+		rr, err := RotatingRingInit(oldSeed)
+		if err != nil {
+			t.Fatalf("RotatingRingInit failed: %v", err)
+		}
+		for i := 0; i < 6; i++ {
+			rr.AddNode(&mockNode{identifier: fmt.Sprintf("node-%d.local", i)})
+		}
+		return rr
+	}
+	reference := func(seed Seed, rr *RotatingRing) *HashRing {
+		ring := HashRingInit(SetSecureHash(seed))
+		members, _ := rr.current.Members()
+		for _, member := range members {
+			ring.AddNode(member.Node)
+		}
+		return ring
+	}
+
+	t.Run("rotation routes writes to the new owner and reads to both", func(t *testing.T) {
+		rr := newRing(t)
+		before, after := reference(oldSeed, rr), reference(newSeed, rr)
+
+		if err := rr.BeginRotation(newSeed); err != nil {
+			t.Fatalf("BeginRotation failed: %v", err)
+		}
+		if !rr.Rotating() {
+			t.Fatal("Expected a rotation in progress")
+		}
+
+		moved := 0
+		for i := 0; i < 200; i++ {
+			key := fmt.Sprintf("user:%d", i)
+			oldOwner, _ := before.GetNode(key)
+			newOwner, _ := after.GetNode(key)
+
+			if node, _ := rr.GetNode(key); node != newOwner {
+				t.Fatalf("Key %s: expected writes to go to %s, got %s", key, newOwner.GetIdentifier(), node.GetIdentifier())
+			}
+			candidates, err := rr.GetNodeCandidates(key)
+			if err != nil {
+				t.Fatalf("GetNodeCandidates failed: %v", err)
+			}
+			if candidates[0] != newOwner || candidates[len(candidates)-1] != oldOwner {
+				t.Fatalf("Key %s: expected candidates %s then %s", key, newOwner.GetIdentifier(), oldOwner.GetIdentifier())
+			}
+			if oldOwner != newOwner {
+				moved++
+			}
+		}
+		if moved == 0 {
+			t.Error("Expected a new seed to move keys")
+		}
+
+		if err := rr.CompleteRotation(); err != nil {
+			t.Fatalf("CompleteRotation failed: %v", err)
+		}
+		candidates, _ := rr.GetNodeCandidates("user:1")
+		want, _ := after.GetNode("user:1")
+		if rr.Rotating() || len(candidates) != 1 || candidates[0] != want {
+			t.Error("Expected a completed rotation to route by the new seed only")
+		}
+	})
+
+	t.Run("membership and states are mirrored during a rotation", func(t *testing.T) {
+		rr := newRing(t)
+		rr.SetNodeState("node-1.local", StateDraining)
+		rr.BeginRotation(newSeed)
+		if state, _ := rr.next.NodeState("node-1.local"); state != StateDraining {
+			t.Errorf("Expected the next ring to inherit the draining state, got %s", state)
+		}
+
+		extra := &mockNode{identifier: "extra.local"}
+		rr.AddNode(extra)
+		rr.RemoveNode(&mockNode{identifier: "node-2.local"})
+		rr.SetNodeState("node-3.local", StateDown)
+		if got, want := len(ringIdentifiers(rr.next)), len(ringIdentifiers(rr.current)); got != want || got != 6 {
+			t.Errorf("Expected both rings to have 6 nodes, got %d and %d", want, got)
+		}
+		if state, _ := rr.next.NodeState("node-3.local"); state != StateDown {
+			t.Errorf("Expected the state change to reach the next ring, got %s", state)
+		}
+
+		if err := rr.AddNode(extra); !errors.Is(err, ErrNodeExits) {
+			t.Errorf("Expected ErrNodeExits, got %v", err)
+		}
+		if err := rr.AbortRotation(); err != nil {
+			t.Fatalf("AbortRotation failed: %v", err)
+		}
+		if rr.Rotating() || rr.seed != oldSeed {
+			t.Error("Expected an aborted rotation to keep the old seed")
+		}
+	})
+
+	t.Run("invalid transitions", func(t *testing.T) {
+		if _, err := RotatingRingInit(Seed{}); !errors.Is(err, ErrInvalidSeed) {
+			t.Errorf("Expected ErrInvalidSeed for a zero seed, got %v", err)
+		}
+		rr := newRing(t)
+		if err := rr.CompleteRotation(); err != ErrNoRotation {
+			t.Errorf("Expected ErrNoRotation, got %v", err)
+		}
+		if err := rr.BeginRotation(oldSeed); !errors.Is(err, ErrInvalidSeed) {
+			t.Errorf("Expected ErrInvalidSeed for the current seed, got %v", err)
+		}
+		rr.BeginRotation(newSeed)
+		if err := rr.BeginRotation(newSeed); !errors.Is(err, ErrRotationInProgress) {
+			t.Errorf("Expected ErrRotationInProgress, got %v", err)
+		}
+	})
+
+	t.Run("rotation driven by config", func(t *testing.T) {
+		rr := newRing(t)
+		steps := []struct {
+			config   SeedConfig
+			rotating bool
+			seed     Seed
+		}{
+			{SeedConfig{Current: oldSeed}, false, oldSeed},
+			{SeedConfig{Current: oldSeed, Next: &newSeed}, true, oldSeed},
+			{SeedConfig{Current: oldSeed, Next: &newSeed}, true, oldSeed},
+			{SeedConfig{Current: newSeed}, false, newSeed},
+		}
+		for i, step := range steps {
+			if err := rr.ApplySeedConfig(step.config); err != nil {
+				t.Fatalf("Step %d: ApplySeedConfig failed: %v", i, err)
+			}
+			if rr.Rotating() != step.rotating || rr.seed != step.seed {
+				t.Fatalf("Step %d: expected rotating=%v on %s", i, step.rotating, step.seed)
+			}
+		}
+
+		// The ring is on the new seed now, a config still naming the old one is refused
+		err := rr.ApplySeedConfig(SeedConfig{Current: oldSeed})
+		if !errors.Is(err, ErrSeedMismatch) {
+			t.Errorf("Expected ErrSeedMismatch, got %v", err)
+		}
+		if err != nil && (strings.Contains(err.Error(), newSeed.String()) || strings.Contains(err.Error(), oldSeed.String())) {
+			t.Errorf("Expected errors not to reveal seeds, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrInvalidSeed is returned for a seed that is not 32 hex characters or is all zeros
+var ErrInvalidSeed = errors.New("Invalid hash seed")
+
+/*
+Seed is the 128-bit secret key of the SipHash used in secure mode. It is written and read
+as 32 hex characters, in text and in JSON, so it can be kept in a config file or a secret
+store. Anyone who knows the seed can compute placements and craft colliding keys again, so
+it must be kept as secret as any other credential.
+*/
+type Seed [16]byte
+
+// NewSeed returns a random seed read from crypto/rand
+func NewSeed() (Seed, error) {
+	var seed Seed
+	if _, err := rand.Read(seed[:]); err != nil {
+		return Seed{}, err
+	}
+	return seed, nil
+}
+
+// ParseSeed parses a seed from its 32 hex character form
+func ParseSeed(text string) (Seed, error) {
+	var seed Seed
+	if err := seed.UnmarshalText([]byte(text)); err != nil {
+		return Seed{}, err
+	}
+	return seed, nil
+}
+
+func (s Seed) String() string {
+	return hex.EncodeToString(s[:])
+}
+
+func (s Seed) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Seed) UnmarshalText(text []byte) error {
+	if hex.DecodedLen(len(text)) != len(s) {
+		return fmt.Errorf("%w: expected %d hex characters, got %d", ErrInvalidSeed, 2*len(s), len(text))
+	}
+	if _, err := hex.Decode(s[:], text); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidSeed, err)
+	}
+	return nil
+}
+
+// IsZero reports whether the seed is all zeros, which is never a secret
+func (s Seed) IsZero() bool {
+	return s == Seed{}
+}
+
+/*
+SetSecureHash returns a HashRingConfigFn that hashes keys and node identifiers with
+SipHash keyed by seed instead of the unkeyed FNV-1a default. Use it whenever lookup keys
+come from users: with FNV-1a an attacker can search offline for keys that all land on
+one node, with a secret seed they cannot. Rings must share the seed to agree on placement.
+*/
+func SetSecureHash(seed Seed) HashRingConfigFn {
+	return SetHashFunction(SipHash(seed))
+}
+
+/*
+SeedConfig is the seed section of a service config. Current is the seed placement uses
+today. Next is set while a rotation to a new seed is planned or running and left out
+otherwise. As JSON:
+
+	{"current": "000102030405060708090a0b0c0d0e0f", "next": "f0e0d0c0b0a090807060504030201000"}
+*/
+type SeedConfig struct {
+	Current Seed  `json:"current"`
+	Next    *Seed `json:"next,omitempty"`
+}
+
+// LoadSeedConfig reads a SeedConfig from the JSON file at path
+func LoadSeedConfig(path string) (SeedConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SeedConfig{}, err
+	}
+	var config SeedConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return SeedConfig{}, fmt.Errorf("seed config %s: %w", path, err)
+	}
+	if config.Current.IsZero() {
+		return SeedConfig{}, fmt.Errorf("%w: seed config %s has no current seed", ErrInvalidSeed, path)
+	}
+	if config.Next != nil && config.Next.IsZero() {
+		return SeedConfig{}, fmt.Errorf("%w: seed config %s has a zero next seed", ErrInvalidSeed, path)
+	}
+	return config, nil
+}
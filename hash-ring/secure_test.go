@@ -0,0 +1,102 @@
+package hashring
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+/*
+TestSecureHash tests secure mode. It verifies that keys crafted to pile onto one node of
+an FNV-1a ring spread out over a ring keyed with a secret seed, that seeds round-trip
+through their text form and reject malformed input, and that seed configs load from JSON.
+*/
+func TestSecureHash(t *testing.T) {
+	seed, err := NewSeed()
+	if err != nil {
+		t.Fatalf("NewSeed failed: %v", err)
+	}
+
+	t.Run("crafted keys spread over a keyed ring", func(t *testing.T) {
+		plain := HashRingInit()
+		secure := HashRingInit(SetSecureHash(seed))
+		for i := 0; i < 8; i++ {
+			node := &mockNode{identifier: fmt.Sprintf("node-%d.local", i)}
+			plain.AddNode(node)
+			secure.AddNode(node)
+		}
+
+		// An attacker who knows the hash function collects keys that all hit one node
+		target, _ := plain.GetNode("victim")
+		var crafted []string
+		for i := 0; len(crafted) < 400; i++ {
+			key := fmt.Sprintf("attack-%d", i)
+			if node, _ := plain.GetNode(key); node == target {
+				crafted = append(crafted, key)
+			}
+		}
+
+		counts := make(map[string]int)
+		for _, key := range crafted {
+			node, err := secure.GetNode(key)
+			if err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+			counts[node.GetIdentifier()]++
+		}
+		for id, count := range counts {
+			if count > len(crafted)*3/4 {
+				t.Errorf("Expected crafted keys to spread, %s got %d of %d", id, count, len(crafted))
+			}
+		}
+	})
+
+	t.Run("seed text form", func(t *testing.T) {
+		parsed, err := ParseSeed(seed.String())
+		if err != nil || parsed != seed {
+			t.Fatalf("Expected %s to round-trip, got %s, %v", seed, parsed, err)
+		}
+		for _, text := range []string{"", "abc", seed.String() + "00", "zz" + seed.String()[2:]} {
+			if _, err := ParseSeed(text); !errors.Is(err, ErrInvalidSeed) {
+				t.Errorf("Expected ErrInvalidSeed for %q, got %v", text, err)
+			}
+		}
+	})
+
+	t.Run("load seed config", func(t *testing.T) {
+		dir := t.TempDir()
+		write := func(name, content string) string {
+			path := filepath.Join(dir, name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			return path
+		}
+
+		next, _ := NewSeed()
+		config, err := LoadSeedConfig(write("rotating.json", fmt.Sprintf(`{"current": %q, "next": %q}`, seed, next)))
+		if err != nil {
+			t.Fatalf("LoadSeedConfig failed: %v", err)
+		}
+		if config.Current != seed || config.Next == nil || *config.Next != next {
+			t.Errorf("Unexpected config %+v", config)
+		}
+
+		config, err = LoadSeedConfig(write("steady.json", fmt.Sprintf(`{"current": %q}`, seed)))
+		if err != nil || config.Next != nil {
+			t.Errorf("Expected a config without next seed, got %+v, %v", config, err)
+		}
+
+		for name, content := range map[string]string{
+			"empty.json":    `{}`,
+			"short.json":    `{"current": "0102"}`,
+			"zeronext.json": fmt.Sprintf(`{"current": %q, "next": "00000000000000000000000000000000"}`, seed),
+		} {
+			if _, err := LoadSeedConfig(write(name, content)); !errors.Is(err, ErrInvalidSeed) {
+				t.Errorf("%s: expected ErrInvalidSeed, got %v", name, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,142 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"encoding/binary"
+	"hash"
+	"math/bits"
+)
+
+/*
+SipHash returns a hash constructor for SipHash-2-4 keyed with seed, for use with
+SetHashFunction or through SetSecureHash. SipHash is a keyed pseudo-random function: as
+long as the seed stays secret, nobody outside the process can tell where a key lands on
+the ring, so crafting many keys that all fall onto one node is no easier than guessing.
+*/
+func SipHash(seed Seed) func() hash.Hash64 {
+	k0 := binary.LittleEndian.Uint64(seed[:8])
+	k1 := binary.LittleEndian.Uint64(seed[8:])
+	return func() hash.Hash64 {
+		d := &sipHash{k0: k0, k1: k1}
+		d.Reset()
+		return d
+	}
+}
+
+/*
+sipHash is a streaming SipHash-2-4 state. Fields:
+  - k0, k1: The two halves of the 128-bit key
+  - v0, v1, v2, v3: Internal state after the compressed message blocks
+  - tail: Bytes written that do not yet fill a block, ntail of them are valid
+  - length: Total number of bytes written, its low byte is folded into the last block
+*/
+type sipHash struct {
+	k0, k1         uint64
+	v0, v1, v2, v3 uint64
+	tail           [8]byte
+	ntail          int
+	length         uint64
+}
+
+func (d *sipHash) Reset() {
+	d.v0 = d.k0 ^ 0x736f6d6570736575
+	d.v1 = d.k1 ^ 0x646f72616e646f6d
+	d.v2 = d.k0 ^ 0x6c7967656e657261
+	d.v3 = d.k1 ^ 0x7465646279746573
+	d.ntail = 0
+	d.length = 0
+}
+
+func (d *sipHash) Size() int { return 8 }
+
+func (d *sipHash) BlockSize() int { return 8 }
+
+func (d *sipHash) Write(p []byte) (int, error) {
+	n := len(p)
+	d.length += uint64(n)
+
+	// Complete a block started by an earlier Write
+	if d.ntail > 0 {
+		copied := copy(d.tail[d.ntail:], p)
+		d.ntail += copied
+		p = p[copied:]
+		if d.ntail < 8 {
+			return n, nil
+		}
+		d.block(binary.LittleEndian.Uint64(d.tail[:]))
+		d.ntail = 0
+	}
+
+	for len(p) >= 8 {
+		d.block(binary.LittleEndian.Uint64(p))
+		p = p[8:]
+	}
+	d.ntail = copy(d.tail[:], p)
+	return n, nil
+}
+
+func (d *sipHash) Sum(b []byte) []byte {
+	return binary.BigEndian.AppendUint64(b, d.Sum64())
+}
+
+// Sum64 finalizes a copy of the state, so more data can still be written afterwards
+func (d *sipHash) Sum64() uint64 {
+	last := d.length << 56
+	for i := d.ntail - 1; i >= 0; i-- {
+		last |= uint64(d.tail[i]) << (8 * uint(i))
+	}
+
+	s := *d
+	s.block(last)
+	s.v2 ^= 0xff
+	for i := 0; i < 4; i++ {
+		s.round()
+	}
+	return s.v0 ^ s.v1 ^ s.v2 ^ s.v3
+}
+
+// block compresses one 8-byte message word with two SipRounds
+func (d *sipHash) block(m uint64) {
+	d.v3 ^= m
+	d.round()
+	d.round()
+	d.v0 ^= m
+}
+
+func (d *sipHash) round() {
+	d.v0 += d.v1
+	d.v1 = bits.RotateLeft64(d.v1, 13)
+	d.v1 ^= d.v0
+	d.v0 = bits.RotateLeft64(d.v0, 32)
+	d.v2 += d.v3
+	d.v3 = bits.RotateLeft64(d.v3, 16)
+	d.v3 ^= d.v2
+	d.v0 += d.v3
+	d.v3 = bits.RotateLeft64(d.v3, 21)
+	d.v3 ^= d.v0
+	d.v2 += d.v1
+	d.v1 = bits.RotateLeft64(d.v1, 17)
+	d.v1 ^= d.v2
+	d.v2 = bits.RotateLeft64(d.v2, 32)
+}
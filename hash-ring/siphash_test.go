@@ -0,0 +1,79 @@
+package hashring
+
+import (
+	"fmt"
+	"testing"
+)
+
+/*
+TestSipHash tests the SipHash-2-4 implementation. It verifies the reference vectors from
+the SipHash paper, that writing a message in pieces gives the same hash as writing it at
+once, that Sum64 does not disturb the state and Reset restores it, and that a different
+seed gives a different hash.
+*/
+func TestSipHash(t *testing.T) {
+	var seed Seed
+	for i := range seed {
+		seed[i] = byte(i)
+	}
+	message := make([]byte, 64)
+	for i := range message {
+		message[i] = byte(i)
+	}
+
+	t.Run("reference vectors", func(t *testing.T) {
+		tests := []struct {
+			length int
+			want   uint64
+		}{
+			{0, 0x726fdb47dd0e0e31},
+			{1, 0x74f839c593dc67fd},
+			{8, 0x93f5f5799a932462},
+			{15, 0xa129ca6149be45e5},
+		}
+		for _, tt := range tests {
+			h := SipHash(seed)()
+			h.Write(message[:tt.length])
+			if got := h.Sum64(); got != tt.want {
+				t.Errorf("%d byte message: expected %#x, got %#x", tt.length, tt.want, got)
+			}
+		}
+	})
+
+	t.Run("streaming matches one shot", func(t *testing.T) {
+		for length := 0; length <= len(message); length++ {
+			whole := SipHash(seed)()
+			whole.Write(message[:length])
+
+			pieces := SipHash(seed)()
+			for i := 0; i < length; i += 3 {
+				pieces.Write(message[i:min(i+3, length)])
+				// Sum64 in the middle must not change the result
+				pieces.Sum64()
+			}
+			if whole.Sum64() != pieces.Sum64() {
+				t.Fatalf("%d byte message hashed differently in pieces", length)
+			}
+
+			pieces.Reset()
+			pieces.Write(message[:length])
+			if whole.Sum64() != pieces.Sum64() {
+				t.Fatalf("%d byte message hashed differently after Reset", length)
+			}
+		}
+	})
+
+	t.Run("seed changes the hash", func(t *testing.T) {
+		other := seed
+		other[0] ^= 1
+		a, b := SipHash(seed)(), SipHash(other)()
+		a.Write([]byte("user:42"))
+		b.Write([]byte("user:42"))
+		if a.Sum64() == b.Sum64() {
+			t.Error("Expected different seeds to give different hashes")
+		}
+		if got := fmt.Sprintf("%x", a.Sum(nil)); len(got) != 16 {
+			t.Errorf("Expected an 8 byte Sum, got %s", got)
+		}
+	})
+}
@@ -0,0 +1,170 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrUnknownNodeState is returned by SetNodeState for a state that is not one of the NodeState constants
+var ErrUnknownNodeState = errors.New("Unknown node state")
+
+/*
+NodeState is the operational state of a node on the ring. The state does not change
+placement: a draining or down node keeps its position and still owns its keys, so that
+marking a node down for a short outage does not move any keys. Callers that care, such
+as a coordinator looking for a healthy replica, read it with NodeState.
+*/
+type NodeState string
+
+const (
+	// StateActive is the state of every node when it is added
+	StateActive NodeState = "active"
+	// StateDraining marks a node that is being taken out of service
+	StateDraining NodeState = "draining"
+	// StateDown marks a node that is temporarily unavailable
+	StateDown NodeState = "down"
+)
+
+// Valid reports whether s is one of the NodeState constants
+func (s NodeState) Valid() bool {
+	switch s {
+	case StateActive, StateDraining, StateDown:
+		return true
+	}
+	return false
+}
+
+/*
+SetNodeState sets the state of the node with the given identifier. Returns
+ErrNodeNotFound if no such node is on the ring and ErrUnknownNodeState for an invalid
+state. Setting a different state moves the ring to the next epoch, setting the state the
+node already has is a no-op.
+*/
+func (ring *HashRing) SetNodeState(id string, state NodeState) error {
+	if !state.Valid() {
+		return fmt.Errorf("%w: %q", ErrUnknownNodeState, state)
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+	return ring.setNodeStateLocked(id, state)
+}
+
+/*
+CompareAndSetNodeState is SetNodeState for concurrent controllers: the state is only set
+if the ring is still at expectedEpoch, otherwise ErrEpochMismatch is returned and nothing
+changes. Returns the epoch of the ring afterwards, which is expectedEpoch again when the
+node already had the state.
+*/
+func (ring *HashRing) CompareAndSetNodeState(expectedEpoch uint64, id string, state NodeState) (uint64, error) {
+	if !state.Valid() {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownNodeState, state)
+	}
+
+	ring.mu.Lock()
+	defer ring.mu.Unlock()
+
+	if ring.epoch != expectedEpoch {
+		return ring.epoch, fmt.Errorf("%w: expected %d, ring is at %d", ErrEpochMismatch, expectedEpoch, ring.epoch)
+	}
+	err := ring.setNodeStateLocked(id, state)
+	return ring.epoch, err
+}
+
+// setNodeStateLocked does the work of SetNodeState, callers must hold ring.mu for writing
+func (ring *HashRing) setNodeStateLocked(id string, state NodeState) error {
+	hashVal, err := ring.generateHash(id)
+	if err != nil {
+		return fmt.Errorf("%w: node %s", ErrInHashingKey, id)
+	}
+	if _, ok := ring.nodes.Load(hashVal); !ok {
+		return fmt.Errorf("%w: %s", ErrNodeNotFound, id)
+	}
+	if ring.stateLocked(hashVal) == state {
+		return nil
+	}
+
+	// Only states other than active are stored, so a ring without drained nodes keeps no map
+	if state == StateActive {
+		delete(ring.states, hashVal)
+	} else {
+		if ring.states == nil {
+			ring.states = make(map[uint64]NodeState)
+		}
+		ring.states[hashVal] = state
+	}
+	ring.epoch++
+	return nil
+}
+
+/*
+NodeState returns the state of the node with the given identifier, or ErrNodeNotFound if
+no such node is on the ring.
+*/
+func (ring *HashRing) NodeState(id string) (NodeState, error) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	hashVal, err := ring.generateHash(id)
+	if err != nil {
+		return "", fmt.Errorf("%w: node %s", ErrInHashingKey, id)
+	}
+	if _, ok := ring.nodes.Load(hashVal); !ok {
+		return "", fmt.Errorf("%w: %s", ErrNodeNotFound, id)
+	}
+	return ring.stateLocked(hashVal), nil
+}
+
+// stateLocked returns the state of the node at hashVal, callers must hold ring.mu
+func (ring *HashRing) stateLocked(hashVal uint64) NodeState {
+	if state, ok := ring.states[hashVal]; ok {
+		return state
+	}
+	return StateActive
+}
+
+// Member is a node on the ring together with its state, as returned by Members
+type Member struct {
+	Node  CacheNode
+	State NodeState
+}
+
+/*
+Members returns every node on the ring in ring order with its state, and the epoch they
+were read at. Both are read under one lock, so the list is exactly the membership of that
+epoch, which a caller can pass to CompareAndApply to change it.
+*/
+func (ring *HashRing) Members() ([]Member, uint64) {
+	ring.mu.RLock()
+	defer ring.mu.RUnlock()
+
+	members := make([]Member, 0, len(ring.sortedKeyOfNodes))
+	for _, nodeHash := range ring.sortedKeyOfNodes {
+		if value, ok := ring.nodes.Load(uint64(nodeHash)); ok {
+			members = append(members, Member{Node: value.(CacheNode), State: ring.stateLocked(uint64(nodeHash))})
+		}
+	}
+	return members, ring.epoch
+}
@@ -0,0 +1,81 @@
+package hashring
+
+import (
+	"errors"
+	"testing"
+)
+
+/*
+TestNodeState tests node states. It verifies that new nodes are active, that a state change
+is visible, moves the epoch and leaves placement alone, that invalid states and unknown nodes
+are rejected, and that a removed and re-added node starts out active again.
+*/
+func TestNodeState(t *testing.T) {
+	ring := HashRingInit()
+	node1, node2 := &mockNode{identifier: "node1"}, &mockNode{identifier: "node2"}
+	ring.AddNode(node1)
+	ring.AddNode(node2)
+
+	if state, err := ring.NodeState("node1"); err != nil || state != StateActive {
+		t.Fatalf("Expected a new node to be active, got %q, %v", state, err)
+	}
+
+	before, epoch := ring.ContentHash(), ring.Epoch()
+	owner, _ := ring.GetNode("user:42")
+	if err := ring.SetNodeState("node1", StateDown); err != nil {
+		t.Fatalf("SetNodeState failed: %v", err)
+	}
+	if state, _ := ring.NodeState("node1"); state != StateDown {
+		t.Errorf("Expected node1 to be down, got %q", state)
+	}
+	if ring.Epoch() != epoch+1 {
+		t.Errorf("Expected a state change to advance the epoch")
+	}
+	if after, _ := ring.GetNode("user:42"); ring.ContentHash() != before || after != owner {
+		t.Error("Expected a state change to leave placement alone")
+	}
+
+	// Setting the same state again is a no-op
+	ring.SetNodeState("node1", StateDown)
+	if ring.Epoch() != epoch+1 {
+		t.Error("Expected setting the current state not to advance the epoch")
+	}
+
+	if err := ring.SetNodeState("node1", "sleeping"); !errors.Is(err, ErrUnknownNodeState) {
+		t.Errorf("Expected ErrUnknownNodeState, got %v", err)
+	}
+	if err := ring.SetNodeState("node9", StateDraining); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Expected ErrNodeNotFound for SetNodeState, got %v", err)
+	}
+	if _, err := ring.NodeState("node9"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Expected ErrNodeNotFound for NodeState, got %v", err)
+	}
+
+	ring.RemoveNode(node1)
+	ring.AddNode(node1)
+	if state, _ := ring.NodeState("node1"); state != StateActive {
+		t.Errorf("Expected a re-added node to be active, got %q", state)
+	}
+
+	t.Run("compare and set", func(t *testing.T) {
+		ring := HashRingInit()
+		ring.AddNode(&mockNode{identifier: "node1"})
+		epoch := ring.Epoch()
+
+		if _, err := ring.CompareAndSetNodeState(epoch-1, "node1", StateDraining); !errors.Is(err, ErrEpochMismatch) {
+			t.Errorf("Expected ErrEpochMismatch, got %v", err)
+		}
+		next, err := ring.CompareAndSetNodeState(epoch, "node1", StateDraining)
+		if err != nil || next != epoch+1 {
+			t.Fatalf("Expected epoch %d, got %d, %v", epoch+1, next, err)
+		}
+		if same, _ := ring.CompareAndSetNodeState(next, "node1", StateDraining); same != next {
+			t.Errorf("Expected setting the same state to keep epoch %d, got %d", next, same)
+		}
+
+		members, at := ring.Members()
+		if at != next || len(members) != 1 || members[0].State != StateDraining {
+			t.Errorf("Expected one draining member at epoch %d, got %+v at %d", next, members, at)
+		}
+	})
+}
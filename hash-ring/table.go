@@ -0,0 +1,105 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package hashring
+
+import (
+	"math/bits"
+	"sort"
+)
+
+const (
+	// maxLookupTableBits caps the automatically sized table at 2^20 buckets (4 MiB)
+	maxLookupTableBits = 20
+	// autoLookupTableBits is the TableBits value that sizes the table to the ring
+	autoLookupTableBits = -1
+)
+
+/*
+lookupTable is a precomputed index over sortedKeyOfNodes. The hash space is cut into
+2^k equal buckets by the top k bits of a hash (with the sign bit flipped, so that buckets
+follow the signed order of sortedKeyOfNodes) and starts[b] holds the index of the first
+node position at or after the start of bucket b. A lookup only binary searches the
+positions that fall into the key's own bucket, which with a table sized to the ring is zero
+to a couple of entries, instead of the whole slice. Node IDs that hash close together can
+still pile many positions into one bucket, which the search keeps logarithmic. Fields:
+  - shift: 64-k, turns a hash into its bucket number
+  - starts: 2^k+1 indexes into sortedKeyOfNodes, the last one is len(sortedKeyOfNodes)
+*/
+type lookupTable struct {
+	shift  uint
+	starts []uint32
+}
+
+/*
+rebuildLookupTableLocked recomputes the lookup table from sortedKeyOfNodes. It is called
+after every change to sortedKeyOfNodes and costs O(2^k + n). Callers must hold ring.mu for
+writing.
+*/
+func (ring *HashRing) rebuildLookupTableLocked() {
+	n := len(ring.sortedKeyOfNodes)
+	k := ring.config.TableBits
+	if k == autoLookupTableBits {
+		// Two buckets per position keeps the expected scan length below one entry
+		k = min(max(bits.Len(uint(2*n)), 1), maxLookupTableBits)
+	}
+	if k == 0 || n == 0 {
+		ring.table = nil
+		return
+	}
+
+	buckets := 1 << k
+	table := ring.table
+	if table == nil || len(table.starts) != buckets+1 {
+		table = &lookupTable{starts: make([]uint32, buckets+1)}
+	}
+	table.shift = uint(64 - k)
+
+	index := 0
+	for b := 0; b < buckets; b++ {
+		bucketStart := int64(uint64(b)<<table.shift ^ 1<<63)
+		for index < n && ring.sortedKeyOfNodes[index] < bucketStart {
+			index++
+		}
+		table.starts[b] = uint32(index)
+	}
+	table.starts[buckets] = uint32(n)
+	ring.table = table
+}
+
+/*
+search returns the index of the first position in sorted that is greater than or equal
+to key, wrapping around to 0, exactly like the sort.Search in binarySearch. sorted must be
+the slice the table was built from and must not be empty.
+*/
+func (table *lookupTable) search(sorted []int64, key int64) int {
+	bucket := (uint64(key) ^ 1<<63) >> table.shift
+	lo, hi := int(table.starts[bucket]), int(table.starts[bucket+1])
+	index := lo + sort.Search(hi-lo, func(i int) bool {
+		return sorted[lo+i] >= key
+	})
+	// When every position of the bucket is below key the answer is the first one after it, wrapping at the end
+	if index == len(sorted) {
+		return 0
+	}
+	return index
+}
@@ -0,0 +1,126 @@
+package hashring
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"sort"
+	"testing"
+)
+
+// searchSorted is the plain binary search the lookup table has to agree with
+func searchSorted(sorted []int64, key int64) int {
+	index := sort.Search(len(sorted), func(i int) bool { return sorted[i] >= key })
+	if index == len(sorted) {
+		return 0
+	}
+	return index
+}
+
+// syntheticRing returns a ring with n random positions and no nodes behind them
+func syntheticRing(n int, rng *rand.Rand, opts ...HashRingConfigFn) *HashRing {
+	ring := HashRingInit(opts...)
+	for i := 0; i < n; i++ {
+		ring.sortedKeyOfNodes = append(ring.sortedKeyOfNodes, int64(rng.Uint64()))
+	}
+	slices.Sort(ring.sortedKeyOfNodes)
+	ring.rebuildLookupTableLocked()
+	return ring
+}
+
+/*
+TestLookupTable tests the bucketed lookup table. It verifies that the table finds the
+same position as binary search for random and boundary keys across ring and table sizes
+and when every position falls into one bucket, that it follows nodes being added and removed, and that it can be turned off.
+*/
+func TestLookupTable(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	t.Run("agrees with binary search", func(t *testing.T) {
+		for _, n := range []int{1, 2, 3, 17, 1000} {
+			for _, bits := range []int{1, 4, 12} {
+				ring := syntheticRing(n, rng, SetLookupTableBits(bits))
+				keys := []int64{math.MinInt64, math.MaxInt64, -1, 0, 1}
+				for _, position := range ring.sortedKeyOfNodes {
+					keys = append(keys, position-1, position, position+1)
+				}
+				for i := 0; i < 2000; i++ {
+					keys = append(keys, int64(rng.Uint64()))
+				}
+				for _, key := range keys {
+					if got, want := ring.table.search(ring.sortedKeyOfNodes, key), searchSorted(ring.sortedKeyOfNodes, key); got != want {
+						t.Fatalf("n=%d bits=%d key=%d: table found %d, binary search %d", n, bits, key, got, want)
+					}
+				}
+			}
+		}
+	})
+
+	t.Run("crowded bucket", func(t *testing.T) {
+		ring := HashRingInit(SetLookupTableBits(8))
+		for i := 0; i < 5000; i++ {
+			ring.sortedKeyOfNodes = append(ring.sortedKeyOfNodes, int64(3*i))
+		}
+		ring.rebuildLookupTableLocked()
+		for key := int64(-5); key < 15005; key++ {
+			if got, want := ring.table.search(ring.sortedKeyOfNodes, key), searchSorted(ring.sortedKeyOfNodes, key); got != want {
+				t.Fatalf("key=%d: table found %d, binary search %d", key, got, want)
+			}
+		}
+	})
+
+	t.Run("is sized to the ring by default", func(t *testing.T) {
+		ring := syntheticRing(1000, rng)
+		if got := len(ring.table.starts) - 1; got != 2048 {
+			t.Errorf("Expected 2048 buckets for 1000 positions, got %d", got)
+		}
+		if got := HashRingInit(SetLookupTableBits(99)).config.TableBits; got != 24 {
+			t.Errorf("Expected the table to be capped at 2^24 buckets, got 2^%d", got)
+		}
+	})
+
+	t.Run("follows membership changes", func(t *testing.T) {
+		ring := HashRingInit()
+		plain := HashRingInit(SetLookupTableBits(0))
+		nodes := make([]CacheNode, 40)
+		for i := range nodes {
+			nodes[i] = &mockNode{identifier: fmt.Sprintf("node-%d.local", i)}
+			ring.AddNode(nodes[i])
+			plain.AddNode(nodes[i])
+		}
+		if plain.table != nil {
+			t.Fatal("Expected SetLookupTableBits(0) to disable the table")
+		}
+
+		check := func(stage string) {
+			for i := 0; i < 500; i++ {
+				key := fmt.Sprintf("user:%d", i)
+				got, _ := ring.GetNode(key)
+				want, _ := plain.GetNode(key)
+				if got != want {
+					t.Fatalf("%s: key %s mapped to %s with the table, %s without", stage, key, got.GetIdentifier(), want.GetIdentifier())
+				}
+			}
+		}
+		check("after adds")
+		for i := 0; i < len(nodes); i += 3 {
+			ring.RemoveNode(nodes[i])
+			plain.RemoveNode(nodes[i])
+		}
+		check("after removes")
+
+		changes := []Change{{Op: MembershipRemove, Node: nodes[1]}, {Op: MembershipAdd, Node: &mockNode{identifier: "extra.local"}}}
+		ring.CompareAndApply(ring.Epoch(), changes)
+		plain.CompareAndApply(plain.Epoch(), changes)
+		check("after compare and apply")
+
+		for _, node := range nodes {
+			ring.RemoveNode(node)
+		}
+		ring.RemoveNode(&mockNode{identifier: "extra.local"})
+		if ring.table != nil {
+			t.Error("Expected an empty ring to drop its table")
+		}
+	})
+}
@@ -0,0 +1,413 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package hierarchy routes keys through two levels of consistent hash rings: a top ring
+picks a group, such as a region or a cluster, and the group's own ring picks a node inside
+it. Each level has its own membership and weights, so adding or removing a node in one
+group only moves keys between nodes of that group and never moves keys of other groups,
+and reweighting groups only moves keys between groups.
+*/
+package hierarchy
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"sync"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrGroupExists   = errors.New("Group already exists")
+	ErrGroupNotFound = errors.New("Group not found")
+	ErrEmptyGroup    = errors.New("Group has no nodes")
+	ErrInvalidWeight = errors.New("Weight must be at least 1")
+)
+
+/*
+Placement is where a key is routed: the group picked by the top ring and the node picked
+by that group's ring.
+*/
+type Placement struct {
+	Group string
+	Node  hashring.CacheNode
+}
+
+type routerConfig struct {
+	HashFunction func() hash.Hash64
+	RingOptions  []hashring.HashRingConfigFn
+}
+
+// RouterConfigFn is a function type that modifies the routerConfig
+type RouterConfigFn func(*routerConfig)
+
+/*
+SetHashFunction returns a RouterConfigFn that sets the hash function of both levels,
+fnv.New64a by default. Pass hashring.SipHash(seed) for secure mode.
+*/
+func SetHashFunction(f func() hash.Hash64) RouterConfigFn {
+	return func(config *routerConfig) {
+		config.HashFunction = f
+	}
+}
+
+/*
+SetRingOptions returns a RouterConfigFn with options applied to the top ring and to every
+group ring, such as a logger, a metrics hook or a key extractor. A hash function set here
+is replaced by the router's own, see SetHashFunction.
+*/
+func SetRingOptions(opts ...hashring.HashRingConfigFn) RouterConfigFn {
+	return func(config *routerConfig) {
+		config.RingOptions = append(config.RingOptions, opts...)
+	}
+}
+
+/*
+point is one position of a weighted member on a ring. A member of weight w is placed as
+w points with the identifiers 0#id to w-1#id, so doubling the weight doubles its share of
+the ring and changing the weight only adds or removes the points at the end. Fields:
+  - id: Identifier of the point, hashed for its position
+  - name: Identifier of the member the point belongs to
+  - node: Node the point stands for, nil for group points
+*/
+type point struct {
+	id   string
+	name string
+	node hashring.CacheNode
+}
+
+func (p *point) GetIdentifier() string {
+	return p.id
+}
+
+/*
+group is one group of the router. Fields:
+  - weight: Number of points the group has on the top ring
+  - ring: Ring of the group's nodes, hashed with the group name as salt
+  - nodes: Nodes of the group keyed by identifier
+  - weights: Weight of every node keyed by identifier
+*/
+type group struct {
+	weight  int
+	ring    *hashring.HashRing
+	nodes   map[string]hashring.CacheNode
+	weights map[string]int
+}
+
+/*
+Router is a two-level consistent hash router. Fields:
+  - mu: Read-write mutex guarding the groups and the rings' membership
+  - config: Hash function and ring options
+  - top: Ring of group points
+  - groups: Groups keyed by name
+*/
+type Router struct {
+	mu     sync.RWMutex
+	config routerConfig
+	top    *hashring.HashRing
+	groups map[string]*group
+}
+
+/*
+RouterInit creates an empty Router. Add groups with AddGroup and nodes with AddNode
+before routing keys.
+*/
+func RouterInit(opts ...RouterConfigFn) *Router {
+	config := &routerConfig{HashFunction: fnv.New64a}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Router{
+		config: *config,
+		top:    config.newRing(config.HashFunction),
+		groups: make(map[string]*group),
+	}
+}
+
+// newRing creates a ring with the configured ring options hashing with f
+func (config *routerConfig) newRing(f func() hash.Hash64) *hashring.HashRing {
+	opts := append(slices.Clone(config.RingOptions), hashring.SetHashFunction(f))
+	return hashring.HashRingInit(opts...)
+}
+
+/*
+AddGroup adds an empty group with the given weight. Keys start routing to the group at
+once, so lookups for its share of keys fail with ErrEmptyGroup until it has a node; add
+at least one node right after the group. Returns ErrGroupExists for a known name and
+ErrInvalidWeight for a weight below 1.
+*/
+func (r *Router) AddGroup(name string, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: group %s has weight %d", ErrInvalidWeight, name, weight)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.groups[name]; ok {
+		return fmt.Errorf("%w: %s", ErrGroupExists, name)
+	}
+	if err := setPoints(r.top, name, nil, 0, weight); err != nil {
+		return err
+	}
+	r.groups[name] = &group{
+		weight:  weight,
+		ring:    r.config.newRing(salted(r.config.HashFunction, name)),
+		nodes:   make(map[string]hashring.CacheNode),
+		weights: make(map[string]int),
+	}
+	return nil
+}
+
+/*
+RemoveGroup removes a group together with its nodes. Its keys move to the other groups,
+keys of the other groups stay where they are.
+*/
+func (r *Router) RemoveGroup(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.groups[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	if err := setPoints(r.top, name, nil, g.weight, 0); err != nil {
+		return err
+	}
+	delete(r.groups, name)
+	return nil
+}
+
+// SetGroupWeight changes the weight of a group, which only moves keys to or from that group
+func (r *Router) SetGroupWeight(name string, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: group %s has weight %d", ErrInvalidWeight, name, weight)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.groups[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, name)
+	}
+	if err := setPoints(r.top, name, nil, g.weight, weight); err != nil {
+		return err
+	}
+	g.weight = weight
+	return nil
+}
+
+/*
+AddNode adds node to the named group with the given weight. Only keys of that group can
+move, to the new node. Returns ErrGroupNotFound for an unknown group and
+hashring.ErrNodeExits if the group already has a node with the same identifier.
+*/
+func (r *Router) AddNode(groupName string, node hashring.CacheNode, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: node %s has weight %d", ErrInvalidWeight, node.GetIdentifier(), weight)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, ok := r.groups[groupName]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+	id := node.GetIdentifier()
+	if _, ok := g.nodes[id]; ok {
+		return fmt.Errorf("%w: node %s in group %s", hashring.ErrNodeExits, id, groupName)
+	}
+	if err := setPoints(g.ring, id, node, 0, weight); err != nil {
+		return err
+	}
+	g.nodes[id] = node
+	g.weights[id] = weight
+	return nil
+}
+
+// RemoveNode removes the node with the given identifier from the named group
+func (r *Router) RemoveNode(groupName, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, node, err := r.nodeLocked(groupName, id)
+	if err != nil {
+		return err
+	}
+	if err := setPoints(g.ring, id, node, g.weights[id], 0); err != nil {
+		return err
+	}
+	delete(g.nodes, id)
+	delete(g.weights, id)
+	return nil
+}
+
+// SetNodeWeight changes the weight of a node, which only moves keys inside its group
+func (r *Router) SetNodeWeight(groupName, id string, weight int) error {
+	if weight < 1 {
+		return fmt.Errorf("%w: node %s has weight %d", ErrInvalidWeight, id, weight)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	g, node, err := r.nodeLocked(groupName, id)
+	if err != nil {
+		return err
+	}
+	if err := setPoints(g.ring, id, node, g.weights[id], weight); err != nil {
+		return err
+	}
+	g.weights[id] = weight
+	return nil
+}
+
+// nodeLocked finds a group and one of its nodes, callers must hold r.mu
+func (r *Router) nodeLocked(groupName, id string) (*group, hashring.CacheNode, error) {
+	g, ok := r.groups[groupName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+	node, ok := g.nodes[id]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %s in group %s", hashring.ErrNodeNotFound, id, groupName)
+	}
+	return g, node, nil
+}
+
+/*
+GetNode routes key: the top ring picks the group, the group's ring picks the node. Returns
+hashring.ErrNoConnectedNodes if there are no groups and ErrEmptyGroup if the key's group
+has no nodes yet.
+*/
+func (r *Router) GetNode(key string) (Placement, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groupPoint, err := r.top.GetNode(key)
+	if err != nil {
+		return Placement{}, err
+	}
+	name := groupPoint.(*point).name
+	g := r.groups[name]
+
+	nodePoint, err := g.ring.GetNode(key)
+	if errors.Is(err, hashring.ErrNoConnectedNodes) {
+		return Placement{}, fmt.Errorf("%w: %s", ErrEmptyGroup, name)
+	}
+	if err != nil {
+		return Placement{}, err
+	}
+	return Placement{Group: name, Node: nodePoint.(*point).node}, nil
+}
+
+// Groups returns the names of all groups in sorted order
+func (r *Router) Groups() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.groups))
+	for name := range r.groups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Nodes returns the nodes of the named group sorted by identifier
+func (r *Router) Nodes(groupName string) ([]hashring.CacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, ok := r.groups[groupName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrGroupNotFound, groupName)
+	}
+	nodes := make([]hashring.CacheNode, 0, len(g.nodes))
+	for _, node := range g.nodes {
+		nodes = append(nodes, node)
+	}
+	slices.SortFunc(nodes, func(a, b hashring.CacheNode) int {
+		return cmp.Compare(a.GetIdentifier(), b.GetIdentifier())
+	})
+	return nodes, nil
+}
+
+/*
+setPoints moves a member from weight from to weight to by adding or removing its last
+points, in one CompareAndApply so that the ring never shows a half-applied weight. The
+caller serializes all changes to the ring, so the epoch cannot move under it.
+*/
+func setPoints(ring *hashring.HashRing, name string, node hashring.CacheNode, from, to int) error {
+	var changes []hashring.Change
+	for i := to; i < from; i++ {
+		changes = append(changes, hashring.Change{Op: hashring.MembershipRemove, Node: newPoint(name, node, i)})
+	}
+	for i := from; i < to; i++ {
+		changes = append(changes, hashring.Change{Op: hashring.MembershipAdd, Node: newPoint(name, node, i)})
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err := ring.CompareAndApply(ring.Epoch(), changes)
+	return err
+}
+
+func newPoint(name string, node hashring.CacheNode, index int) *point {
+	// The index goes first, FNV-1a places identifiers that differ only at the end close together
+	return &point{id: strconv.Itoa(index) + "#" + name, name: name, node: node}
+}
+
+/*
+salted returns a hash constructor that feeds salt into f before the data. Every group ring
+hashes with its group name as salt: the keys routed to a group are exactly those whose
+unsalted hash falls into the group's arcs of the top ring, and hashing them unsalted again
+would crowd them onto the nodes owning those same arcs of the group ring.
+*/
+func salted(f func() hash.Hash64, salt string) func() hash.Hash64 {
+	prefix := append([]byte(salt), 0)
+	return func() hash.Hash64 {
+		h := &saltedHash{Hash64: f(), salt: prefix}
+		h.Hash64.Write(h.salt)
+		return h
+	}
+}
+
+// saltedHash is a hash.Hash64 that starts every message with salt, including after Reset
+type saltedHash struct {
+	hash.Hash64
+	salt []byte
+}
+
+func (h *saltedHash) Reset() {
+	h.Hash64.Reset()
+	h.Hash64.Write(h.salt)
+}
@@ -0,0 +1,206 @@
+package hierarchy
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+testNode is a test implementation of the CacheNode interface, used as the nodes inside
+the groups of a Router.
+*/
+type testNode struct {
+	id string
+}
+
+func (n *testNode) GetIdentifier() string {
+	return n.id
+}
+
+/*
+nodeID returns the identifier of the i-th node of a group. Every node has a single point
+per weight on its group's ring, so the names differ in more than a trailing digit, which
+FNV-1a would place right next to each other.
+*/
+func nodeID(group string, i int) string {
+	return group + "-" + []string{"alder", "birch", "cypress", "sycamore"}[i]
+}
+
+// routerWithGroups returns a router with the given groups of weight 1 and four nodes each
+func routerWithGroups(t *testing.T, groups ...string) *Router {
+	t.Helper()
+	r := RouterInit()
+	for _, name := range groups {
+		if err := r.AddGroup(name, 1); err != nil {
+			t.Fatalf("AddGroup failed: %v", err)
+		}
+		for i := 0; i < 4; i++ {
+			if err := r.AddNode(name, &testNode{id: nodeID(name, i)}, 1); err != nil {
+				t.Fatalf("AddNode failed: %v", err)
+			}
+		}
+	}
+	return r
+}
+
+// placements routes n keys and returns the placement of each
+func placements(t *testing.T, r *Router, n int) map[string]Placement {
+	t.Helper()
+	result := make(map[string]Placement, n)
+	for i := 0; i < n; i++ {
+		key := fmt.Sprintf("user:%d", i)
+		placement, err := r.GetNode(key)
+		if err != nil {
+			t.Fatalf("GetNode(%s) failed: %v", key, err)
+		}
+		result[key] = placement
+	}
+	return result
+}
+
+/*
+TestRouter tests two-level routing. It verifies that changing the nodes of one group
+never moves keys of another group, that reweighting or removing a group only moves keys
+to or from it, that weights shift load at both levels, that every node of a group gets
+keys, and that invalid calls are refused.
+*/
+func TestRouter(t *testing.T) {
+	t.Run("node changes stay inside their group", func(t *testing.T) {
+		r := routerWithGroups(t, "eu-west", "us-east", "ap-south")
+		before := placements(t, r, 5000)
+
+		added := &testNode{id: "us-east-rowan"}
+		if err := r.AddNode("us-east", added, 1); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		if err := r.RemoveNode("eu-west", nodeID("eu-west", 0)); err != nil {
+			t.Fatalf("RemoveNode failed: %v", err)
+		}
+
+		for key, after := range placements(t, r, 5000) {
+			old := before[key]
+			if after.Group != old.Group {
+				t.Fatalf("Key %s moved from group %s to %s", key, old.Group, after.Group)
+			}
+			if after.Node == old.Node {
+				continue
+			}
+			switch {
+			case old.Group == "us-east" && after.Node == added:
+			case old.Group == "eu-west" && old.Node.GetIdentifier() == nodeID("eu-west", 0):
+			default:
+				t.Fatalf("Key %s moved from %s to %s", key, old.Node.GetIdentifier(), after.Node.GetIdentifier())
+			}
+		}
+	})
+
+	t.Run("group changes only move keys of that group", func(t *testing.T) {
+		r := routerWithGroups(t, "eu-west", "us-east", "ap-south")
+		before := placements(t, r, 5000)
+
+		if err := r.SetGroupWeight("us-east", 3); err != nil {
+			t.Fatalf("SetGroupWeight failed: %v", err)
+		}
+		gained := 0
+		for key, after := range placements(t, r, 5000) {
+			old := before[key]
+			if after != old {
+				if after.Group != "us-east" {
+					t.Fatalf("Key %s moved from %s to %s when us-east grew", key, old.Group, after.Group)
+				}
+				gained++
+			}
+		}
+		if gained == 0 {
+			t.Error("Expected a heavier group to take over keys")
+		}
+
+		before = placements(t, r, 5000)
+		if err := r.RemoveGroup("ap-south"); err != nil {
+			t.Fatalf("RemoveGroup failed: %v", err)
+		}
+		for key, after := range placements(t, r, 5000) {
+			if old := before[key]; old.Group != "ap-south" && after != old {
+				t.Fatalf("Key %s moved from %s to %s when ap-south left", key, old.Group, after.Group)
+			}
+		}
+	})
+
+	t.Run("weights and balance", func(t *testing.T) {
+		r := RouterInit()
+		r.AddGroup("small", 1)
+		r.AddGroup("large", 8)
+		for _, name := range []string{"small", "large"} {
+			for i := 0; i < 4; i++ {
+				r.AddNode(name, &testNode{id: nodeID(name, i)}, 4)
+			}
+		}
+
+		groups := make(map[string]int)
+		nodes := make(map[string]int)
+		for _, placement := range placements(t, r, 20000) {
+			groups[placement.Group]++
+			nodes[placement.Node.GetIdentifier()]++
+		}
+		if groups["large"] <= groups["small"] {
+			t.Errorf("Expected the heavier group to get more keys, got %v", groups)
+		}
+		// Salting the group rings lets every node of a group take part
+		for name, count := range groups {
+			for i := 0; i < 4; i++ {
+				id := nodeID(name, i)
+				if nodes[id] == 0 {
+					t.Errorf("Expected %s to get some of the %d keys of %s", id, count, name)
+				}
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		r := RouterInit()
+		if _, err := r.GetNode("key"); err != hashring.ErrNoConnectedNodes {
+			t.Errorf("Expected ErrNoConnectedNodes, got %v", err)
+		}
+		r.AddGroup("eu-west", 1)
+		if _, err := r.GetNode("key"); !errors.Is(err, ErrEmptyGroup) {
+			t.Errorf("Expected ErrEmptyGroup, got %v", err)
+		}
+
+		node := &testNode{id: "node-1"}
+		tests := []struct {
+			name string
+			err  error
+			want error
+		}{
+			{"duplicate group", r.AddGroup("eu-west", 1), ErrGroupExists},
+			{"zero weight group", r.AddGroup("us-east", 0), ErrInvalidWeight},
+			{"unknown group", r.AddNode("us-east", node, 1), ErrGroupNotFound},
+			{"zero weight node", r.AddNode("eu-west", node, 0), ErrInvalidWeight},
+			{"first add", r.AddNode("eu-west", node, 2), nil},
+			{"duplicate node", r.AddNode("eu-west", node, 1), hashring.ErrNodeExits},
+			{"unknown node", r.RemoveNode("eu-west", "node-2"), hashring.ErrNodeNotFound},
+			{"reweight unknown group", r.SetGroupWeight("us-east", 2), ErrGroupNotFound},
+			{"remove unknown group", r.RemoveGroup("us-east"), ErrGroupNotFound},
+			{"reweight node", r.SetNodeWeight("eu-west", "node-1", 5), nil},
+		}
+		for _, tt := range tests {
+			if !errors.Is(tt.err, tt.want) {
+				t.Errorf("%s: expected %v, got %v", tt.name, tt.want, tt.err)
+			}
+		}
+
+		if groups := r.Groups(); len(groups) != 1 || groups[0] != "eu-west" {
+			t.Errorf("Expected [eu-west], got %v", groups)
+		}
+		nodes, _ := r.Nodes("eu-west")
+		if len(nodes) != 1 || nodes[0] != node {
+			t.Errorf("Expected [node-1], got %v", nodes)
+		}
+		if placement, err := r.GetNode("key"); err != nil || placement.Node != node {
+			t.Errorf("Expected key to route to node-1, got %v, %v", placement, err)
+		}
+	})
+}
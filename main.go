@@ -1,21 +1,27 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"net/http"
 	"strings"
+	"time"
 
+	"github.com/atharvamhaske/chash/cachenode"
 	hashring "github.com/atharvamhaske/chash/hash-ring"
 )
 
-// DemoNode implements the CacheNode interface for demonstration
-type DemoNode struct {
-	ID   string
-	Data map[string]string
-}
-
-func (n *DemoNode) GetIdentifier() string {
-	return n.ID
+// startNode serves a cache node on a random localhost port and returns its ring node
+func startNode(id string) *cachenode.Node {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		log.Fatalf("Failed to listen for %s: %v", id, err)
+	}
+	go http.Serve(listener, cachenode.ServerInit(id, cachenode.CacheInit()))
+	return &cachenode.Node{ID: id, URL: "http://" + listener.Addr().String()}
 }
 
 func main() {
@@ -27,10 +33,10 @@ func main() {
 	fmt.Println("Initialized hash ring")
 	fmt.Println()
 
-	// Create demo nodes (representing database shards or cache servers)
-	node1 := &DemoNode{ID: "node-1", Data: make(map[string]string)}
-	node2 := &DemoNode{ID: "node-2", Data: make(map[string]string)}
-	node3 := &DemoNode{ID: "node-3", Data: make(map[string]string)}
+	// Start demo cache nodes on localhost (representing database shards or cache servers)
+	node1 := startNode("node-1")
+	node2 := startNode("node-2")
+	node3 := startNode("node-3")
 
 	// Add nodes to the hash ring
 	fmt.Println("Adding nodes to hash ring...")
@@ -60,6 +66,10 @@ func main() {
 		"session:303",
 	}
 
+	// Store every key on its owner through a client that routes with the ring
+	client := cachenode.ClientInit(ring)
+	ctx := context.Background()
+
 	fmt.Println("Mapping keys to nodes:")
 	fmt.Println("----------------------")
 	for _, key := range testKeys {
@@ -68,6 +78,10 @@ func main() {
 			log.Printf("Error getting node for key %s: %v", key, err)
 			continue
 		}
+		if err := client.Set(ctx, key, []byte("value of "+key), time.Hour); err != nil {
+			log.Printf("Error storing key %s: %v", key, err)
+			continue
+		}
 		fmt.Printf("Key: %-15s -> Node: %s\n", key, node.GetIdentifier())
 	}
 
@@ -83,6 +97,7 @@ func main() {
 	fmt.Println("✓ Removed node-2")
 	fmt.Println()
 
+	// Keys that lived on node-2 now map to another node, which has never seen them
 	fmt.Println("Key mapping after node removal:")
 	fmt.Println("-------------------------------")
 	for _, key := range testKeys {
@@ -91,7 +106,11 @@ func main() {
 			log.Printf("Error getting node for key %s: %v", key, err)
 			continue
 		}
-		fmt.Printf("Key: %-15s -> Node: %s\n", key, node.GetIdentifier())
+		status := "hit"
+		if _, err := client.Get(ctx, key); errors.Is(err, cachenode.ErrNotFound) {
+			status = "miss (moved)"
+		}
+		fmt.Printf("Key: %-15s -> Node: %-7s %s\n", key, node.GetIdentifier(), status)
 	}
 
 	fmt.Println("\n" + strings.Repeat("=", 50))
@@ -99,7 +118,7 @@ func main() {
 	fmt.Println(strings.Repeat("=", 50) + "\n")
 
 	// Try to add a node with the same identifier
-	duplicateNode := &DemoNode{ID: "node-1", Data: make(map[string]string)}
+	duplicateNode := &cachenode.Node{ID: "node-1", URL: node1.URL}
 	if err := ring.AddNode(duplicateNode); err != nil {
 		fmt.Printf("✓ Correctly prevented duplicate node: %v\n", err)
 	}
@@ -0,0 +1,798 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package membership implements SWIM-style failure detection and gossip dissemination over
+UDP, and keeps a local HashRing in sync with the members it considers alive. Every process
+probes one member per protocol period, asks a few others to probe indirectly when the
+direct probe times out, and marks the member suspect and later dead if nobody could reach
+it. Membership updates are piggybacked on probe traffic and a periodic full-state sync with
+a random member lets processes converge even when packets are lost. Members that are alive
+or suspect are in the ring, dead and departed members are removed from it and forgotten
+once the dead retention has passed.
+*/
+package membership
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrJoinFailed = errors.New("No seed answered the join request")
+	ErrShutdown   = errors.New("Membership is shut down")
+)
+
+// State is the liveness of a member as seen by this process
+type State int
+
+const (
+	StateAlive State = iota
+	StateSuspect
+	StateDead
+)
+
+func (s State) String() string {
+	switch s {
+	case StateAlive:
+		return "alive"
+	case StateSuspect:
+		return "suspect"
+	case StateDead:
+		return "dead"
+	}
+	return fmt.Sprintf("State(%d)", int(s))
+}
+
+/*
+Member is the view of one process in the cluster. The incarnation number is only ever
+increased by the member itself, to refute suspicion about it; an update about a member
+overrides the current view when it has a higher incarnation, or the same incarnation and
+a more severe state.
+*/
+type Member struct {
+	ID          string `json:"id"`
+	Addr        string `json:"addr"`
+	State       State  `json:"state"`
+	Incarnation uint64 `json:"inc"`
+}
+
+/*
+Node is what the membership adds to the HashRing for every live member. Lookups on the
+ring return *Node values, so callers can use Addr to reach the owner of a key.
+*/
+type Node struct {
+	ID   string
+	Addr string
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+type membershipConfig struct {
+	BindAddr         string
+	Transport        Transport
+	ProbeInterval    time.Duration
+	ProbeTimeout     time.Duration
+	IndirectChecks   int
+	SuspicionTimeout time.Duration
+	SyncInterval     time.Duration
+	DeadRetention    time.Duration
+	RetransmitMult   int
+	OnChange         func(Member)
+	Logger           *slog.Logger
+}
+
+// MembershipConfigFn is a function type that modifies the membershipConfig
+type MembershipConfigFn func(*membershipConfig)
+
+// SetBindAddr returns a MembershipConfigFn that sets the UDP address to listen on
+func SetBindAddr(addr string) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.BindAddr = addr
+	}
+}
+
+/*
+SetTransport returns a MembershipConfigFn that replaces the UDP transport, for example
+with one that simulates packet loss in tests. The bind address is ignored when set.
+*/
+func SetTransport(transport Transport) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.Transport = transport
+	}
+}
+
+/*
+SetProbeInterval returns a MembershipConfigFn that sets the protocol period (one member is
+probed per period) and the timeout of the direct probe within it. The timeout must be
+shorter than the interval to leave room for indirect probes.
+*/
+func SetProbeInterval(interval, timeout time.Duration) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.ProbeInterval = interval
+		config.ProbeTimeout = timeout
+	}
+}
+
+// SetIndirectChecks returns a MembershipConfigFn that sets how many members probe indirectly
+func SetIndirectChecks(count int) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.IndirectChecks = count
+	}
+}
+
+/*
+SetSuspicionTimeout returns a MembershipConfigFn that sets how long a member stays suspect,
+giving it time to refute, before it is declared dead and removed from the ring.
+*/
+func SetSuspicionTimeout(timeout time.Duration) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.SuspicionTimeout = timeout
+	}
+}
+
+// SetSyncInterval returns a MembershipConfigFn that sets how often full state is exchanged
+func SetSyncInterval(interval time.Duration) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.SyncInterval = interval
+	}
+}
+
+/*
+SetDeadRetention returns a MembershipConfigFn that sets how long dead and departed members
+are remembered, 5 minutes by default. Until then a stale alive update about them is
+refused; afterwards they are forgotten, so a fleet with churn does not grow its state
+forever. It should comfortably exceed the time an update needs to reach every member.
+*/
+func SetDeadRetention(retention time.Duration) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.DeadRetention = retention
+	}
+}
+
+/*
+SetLogger returns a MembershipConfigFn that sets where failed sends are reported,
+slog.Default() by default and nil to drop them. Delivery stays best effort, a failed send
+is only logged.
+*/
+func SetLogger(logger *slog.Logger) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.Logger = logger
+	}
+}
+
+/*
+SetOnChange returns a MembershipConfigFn that registers a callback invoked with the new
+view of a member every time its state changes. It runs on the protocol goroutine and must
+not block.
+*/
+func SetOnChange(fn func(Member)) MembershipConfigFn {
+	return func(config *membershipConfig) {
+		config.OnChange = fn
+	}
+}
+
+// messageType identifies the kind of a protocol message
+type messageType string
+
+const (
+	msgPing    messageType = "ping"
+	msgAck     messageType = "ack"
+	msgPingReq messageType = "ping-req"
+	msgSync    messageType = "sync"
+	msgSyncAck messageType = "sync-ack"
+)
+
+/*
+message is the JSON payload of every datagram. Fields:
+  - Type: Kind of message
+  - Seq: Sequence number matching an ack to its ping
+  - Node: Identifier of the member a ping or ping-req is meant for
+  - Target: Address to probe on behalf of the sender of a ping-req
+  - Updates: Piggybacked membership updates, or a chunk of the full state for sync messages
+  - More: Set on every chunk of a sync or sync-ack but the last
+*/
+type message struct {
+	Type    messageType `json:"t"`
+	Seq     uint64      `json:"seq,omitempty"`
+	Node    string      `json:"node,omitempty"`
+	Target  string      `json:"target,omitempty"`
+	Updates []Member    `json:"u,omitempty"`
+	More    bool        `json:"more,omitempty"`
+}
+
+// broadcast is a queued update together with how many more times it should be piggybacked
+type broadcast struct {
+	member    Member
+	transmits int
+}
+
+const (
+	// maxPiggyback bounds the number of updates carried by one message
+	maxPiggyback = 16
+	// maxEnvelopeSize is room left in a sync datagram for everything but the members
+	maxEnvelopeSize = 256
+)
+
+/*
+Membership runs the SWIM protocol for the local member and applies membership changes to
+a HashRing. Fields:
+  - ring: Ring kept in sync with the live members, including the local one
+  - config: Protocol timings and transport
+  - transport: Transport used for all protocol traffic
+  - mu: Mutex guarding every field below
+  - self: View of the local member
+  - members: Views of every other member seen, dead ones included until they are reaped
+  - deadSince: When each dead member in members was recorded as dead
+  - broadcasts: Updates waiting to be piggybacked, keyed by member identifier
+  - acks: Channels closed when the ack for a sequence number arrives
+  - seq: Last sequence number used
+  - probeOrder: Shuffled member identifiers still to be probed in this round
+  - joined: Closed when the first sync-ack arrives after Join
+  - leaving: Whether Leave was called, suspicion about self is then not refuted
+  - done: Closed by Shutdown to stop the protocol goroutines
+  - wg: Tracks the protocol goroutines
+*/
+type Membership struct {
+	ring       *hashring.HashRing
+	config     membershipConfig
+	transport  Transport
+	mu         sync.Mutex
+	self       Member
+	members    map[string]*Member
+	deadSince  map[string]time.Time
+	broadcasts map[string]*broadcast
+	acks       map[uint64]chan struct{}
+	seq        uint64
+	probeOrder []string
+	joined     chan struct{}
+	leaving    bool
+	done       chan struct{}
+	wg         sync.WaitGroup
+}
+
+/*
+MembershipInit starts the local member id and adds it to ring. The member listens on the
+bind address (default "127.0.0.1:7946") unless a transport is given with SetTransport, and
+starts probing as soon as it knows other members; call Join with the address of any
+existing member to enter a cluster.
+*/
+func MembershipInit(id string, ring *hashring.HashRing, opts ...MembershipConfigFn) (*Membership, error) {
+	config := &membershipConfig{
+		BindAddr:         "127.0.0.1:7946",
+		ProbeInterval:    time.Second,
+		ProbeTimeout:     300 * time.Millisecond,
+		IndirectChecks:   3,
+		SuspicionTimeout: 5 * time.Second,
+		SyncInterval:     10 * time.Second,
+		DeadRetention:    5 * time.Minute,
+		RetransmitMult:   4,
+		Logger:           slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	transport := config.Transport
+	if transport == nil {
+		udp, err := UDPTransportInit(config.BindAddr)
+		if err != nil {
+			return nil, err
+		}
+		transport = udp
+	}
+
+	m := &Membership{
+		ring:       ring,
+		config:     *config,
+		transport:  transport,
+		self:       Member{ID: id, Addr: transport.Addr(), State: StateAlive},
+		members:    make(map[string]*Member),
+		deadSince:  make(map[string]time.Time),
+		broadcasts: make(map[string]*broadcast),
+		acks:       make(map[uint64]chan struct{}),
+		joined:     make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+	if err := ring.AddNode(&Node{ID: id, Addr: m.self.Addr}); err != nil && !errors.Is(err, hashring.ErrNodeExits) {
+		transport.Close()
+		return nil, err
+	}
+
+	m.wg.Add(3)
+	go m.receiveLoop()
+	go m.tickLoop(m.config.ProbeInterval, m.probe)
+	go m.tickLoop(m.config.SyncInterval, m.syncRandom)
+	return m, nil
+}
+
+// joinAttempts is how many probe intervals Join keeps asking the seeds before giving up
+const joinAttempts = 10
+
+/*
+Join contacts the given seed addresses and exchanges full state with them. The request is
+repeated every probe interval, since datagrams may be lost. It returns nil as soon as one
+seed answers, or ErrJoinFailed if none did after joinAttempts intervals.
+*/
+func (m *Membership) Join(seeds ...string) error {
+	for attempt := 0; attempt < joinAttempts; attempt++ {
+		for _, seed := range seeds {
+			m.sendState(seed, msgSync)
+		}
+		select {
+		case <-m.joined:
+			return nil
+		case <-m.done:
+			return ErrShutdown
+		case <-time.After(m.config.ProbeInterval):
+		}
+	}
+	return ErrJoinFailed
+}
+
+/*
+Leave announces that the local member is leaving by sending a dead update about itself
+to every member it knows, then shuts down. Members that miss the announcement detect the
+departure through failed probes instead.
+*/
+func (m *Membership) Leave() error {
+	m.mu.Lock()
+	m.leaving = true
+	m.self.Incarnation++
+	m.self.State = StateDead
+	update := m.self
+	var addrs []string
+	for _, member := range m.members {
+		if member.State != StateDead {
+			addrs = append(addrs, member.Addr)
+		}
+	}
+	m.mu.Unlock()
+
+	// Send the announcement a few times since datagrams may be lost
+	for i := 0; i < 3; i++ {
+		for _, addr := range addrs {
+			m.send(addr, message{Type: msgSync, Updates: []Member{update}})
+		}
+	}
+	return m.Shutdown()
+}
+
+// Shutdown stops the protocol without telling anyone and closes the transport
+func (m *Membership) Shutdown() error {
+	m.mu.Lock()
+	select {
+	case <-m.done:
+		m.mu.Unlock()
+		return nil
+	default:
+		close(m.done)
+	}
+	m.mu.Unlock()
+
+	err := m.transport.Close()
+	m.wg.Wait()
+	return err
+}
+
+// Members returns the views of every known member including the local one, dead ones included until reaped
+func (m *Membership) Members() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	members := []Member{m.self}
+	for _, member := range m.members {
+		members = append(members, *member)
+	}
+	return members
+}
+
+// LocalMember returns the view of the local member
+func (m *Membership) LocalMember() Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.self
+}
+
+// tickLoop calls fn every interval until Shutdown
+func (m *Membership) tickLoop(interval time.Duration, fn func()) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-m.done:
+			return
+		}
+	}
+}
+
+// receiveLoop decodes and handles incoming datagrams until the transport is closed
+func (m *Membership) receiveLoop() {
+	defer m.wg.Done()
+	for packet := range m.transport.Packets() {
+		var msg message
+		if err := json.Unmarshal(packet.Data, &msg); err != nil {
+			continue
+		}
+		m.handle(packet.From, msg)
+	}
+}
+
+// handle processes one message received from addr
+func (m *Membership) handle(from string, msg message) {
+	if msg.Type != msgSync && msg.Type != msgSyncAck {
+		for _, update := range msg.Updates {
+			m.apply(update)
+		}
+	}
+
+	switch msg.Type {
+	case msgPing:
+		// Pings meant for a previous process on this address are ignored
+		if msg.Node == "" || msg.Node == m.self.ID {
+			m.send(from, message{Type: msgAck, Seq: msg.Seq})
+		}
+
+	case msgAck:
+		m.mu.Lock()
+		if ch, ok := m.acks[msg.Seq]; ok {
+			close(ch)
+			delete(m.acks, msg.Seq)
+		}
+		m.mu.Unlock()
+
+	case msgPingReq:
+		// Probe the target on behalf of the sender and forward the ack under its sequence number
+		seq, ack := m.expectAck()
+		m.send(msg.Target, message{Type: msgPing, Seq: seq, Node: msg.Node})
+		m.wg.Add(1)
+		go func() {
+			defer m.wg.Done()
+			select {
+			case <-ack:
+				m.send(from, message{Type: msgAck, Seq: msg.Seq})
+			case <-time.After(m.config.ProbeInterval):
+				m.cancelAck(seq)
+			case <-m.done:
+			}
+		}()
+
+	case msgSync, msgSyncAck:
+		for _, update := range msg.Updates {
+			m.apply(update)
+		}
+		// Only the last chunk of a sync is answered, with the full local state
+		if msg.Type == msgSync && !msg.More {
+			m.sendState(from, msgSyncAck)
+		} else if msg.Type == msgSyncAck {
+			m.mu.Lock()
+			select {
+			case <-m.joined:
+			default:
+				close(m.joined)
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+/*
+probe runs one protocol period: ping the next member, and if it does not ack within the
+probe timeout ask IndirectChecks other members to ping it. If no ack arrived by the end of
+the period the member becomes suspect.
+*/
+func (m *Membership) probe() {
+	target, ok := m.nextProbeTarget()
+	if !ok {
+		return
+	}
+
+	seq, ack := m.expectAck()
+	m.send(target.Addr, message{Type: msgPing, Seq: seq, Node: target.ID})
+	select {
+	case <-ack:
+		return
+	case <-m.done:
+		return
+	case <-time.After(m.config.ProbeTimeout):
+	}
+
+	for _, helper := range m.randomMembers(m.config.IndirectChecks, target.ID) {
+		m.send(helper.Addr, message{Type: msgPingReq, Seq: seq, Node: target.ID, Target: target.Addr})
+	}
+	select {
+	case <-ack:
+		return
+	case <-m.done:
+		return
+	case <-time.After(m.config.ProbeInterval - m.config.ProbeTimeout):
+	}
+
+	m.cancelAck(seq)
+	m.apply(Member{ID: target.ID, Addr: target.Addr, State: StateSuspect, Incarnation: target.Incarnation})
+}
+
+// syncRandom reaps expired dead members and exchanges full state with one random live member
+func (m *Membership) syncRandom() {
+	m.reap(time.Now())
+	for _, member := range m.randomMembers(1, "") {
+		m.sendState(member.Addr, msgSync)
+	}
+}
+
+// reap forgets the members that have been dead for longer than the dead retention
+func (m *Membership) reap(now time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for id, since := range m.deadSince {
+		if now.Sub(since) < m.config.DeadRetention {
+			continue
+		}
+		delete(m.deadSince, id)
+		delete(m.members, id)
+		delete(m.broadcasts, id)
+	}
+}
+
+/*
+apply merges one membership update into the local view. Updates about the local member
+that claim it is suspect or dead are refuted by raising its incarnation and gossiping that
+it is alive. Accepted updates are re-gossiped and applied to the ring: alive and suspect
+members are in the ring, dead members are not.
+*/
+func (m *Membership) apply(update Member) {
+	m.mu.Lock()
+
+	if update.ID == m.self.ID {
+		if !m.leaving && update.Incarnation >= m.self.Incarnation && (update.State != StateAlive || update.Incarnation > m.self.Incarnation) {
+			m.self.Incarnation = update.Incarnation + 1
+			m.queue(m.self)
+		}
+		m.mu.Unlock()
+		return
+	}
+
+	current, known := m.members[update.ID]
+	if !overrides(update, current, known) {
+		m.mu.Unlock()
+		return
+	}
+
+	wasInRing := known && current.State != StateDead
+	previous := Member{}
+	if known {
+		previous = *current
+	}
+	m.members[update.ID] = &update
+	if update.State == StateDead {
+		m.deadSince[update.ID] = time.Now()
+	} else {
+		delete(m.deadSince, update.ID)
+	}
+	m.queue(update)
+
+	// Keep the ring in step with the new state
+	inRing := update.State != StateDead
+	switch {
+	case inRing && wasInRing && previous.Addr != update.Addr:
+		m.ring.RemoveNode(&Node{ID: update.ID})
+		m.ring.AddNode(&Node{ID: update.ID, Addr: update.Addr})
+	case inRing && !wasInRing:
+		m.ring.AddNode(&Node{ID: update.ID, Addr: update.Addr})
+	case !inRing && wasInRing:
+		m.ring.RemoveNode(&Node{ID: update.ID})
+	}
+
+	if update.State == StateSuspect {
+		m.startSuspicion(update)
+	}
+	m.mu.Unlock()
+
+	if m.config.OnChange != nil && (!known || previous.State != update.State || previous.Addr != update.Addr) {
+		m.config.OnChange(update)
+	}
+}
+
+/*
+overrides reports whether update should replace current. A higher incarnation always
+wins; at the same incarnation suspect overrides alive and dead overrides both. Suspicion
+about unknown members is ignored, while unknown dead members are recorded so that stale
+alive updates cannot bring them back.
+*/
+func overrides(update Member, current *Member, known bool) bool {
+	if !known {
+		return update.State != StateSuspect
+	}
+	if update.Incarnation != current.Incarnation {
+		return update.Incarnation > current.Incarnation
+	}
+	return update.State > current.State
+}
+
+// startSuspicion declares the member dead once the suspicion timeout passes without a refutation
+func (m *Membership) startSuspicion(suspect Member) {
+	time.AfterFunc(m.config.SuspicionTimeout, func() {
+		select {
+		case <-m.done:
+			return
+		default:
+		}
+		m.mu.Lock()
+		current, ok := m.members[suspect.ID]
+		stillSuspect := ok && current.State == StateSuspect && current.Incarnation == suspect.Incarnation
+		m.mu.Unlock()
+		if stillSuspect {
+			m.apply(Member{ID: suspect.ID, Addr: suspect.Addr, State: StateDead, Incarnation: suspect.Incarnation})
+		}
+	})
+}
+
+/*
+queue schedules an update to be piggybacked on the next messages. Each update is sent
+RetransmitMult * log(n+1) times, enough to reach every member with high probability, and
+replaces any older queued update about the same member. Callers must hold m.mu.
+*/
+func (m *Membership) queue(update Member) {
+	transmits := m.config.RetransmitMult * int(math.Ceil(math.Log10(float64(len(m.members)+2))))
+	m.broadcasts[update.ID] = &broadcast{member: update, transmits: max(transmits, 1)}
+}
+
+// piggyback takes up to maxPiggyback queued updates for one outgoing message
+func (m *Membership) piggyback() []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var updates []Member
+	for id, b := range m.broadcasts {
+		if len(updates) == maxPiggyback {
+			break
+		}
+		updates = append(updates, b.member)
+		if b.transmits--; b.transmits <= 0 {
+			delete(m.broadcasts, id)
+		}
+	}
+	return updates
+}
+
+/*
+sendState sends the full local view, including the local member, to addr as a sync or
+sync-ack. The view is split into chunks that each fit in one datagram, so the sync keeps
+working however many members there are; all chunks but the last carry More.
+*/
+func (m *Membership) sendState(addr string, typ messageType) {
+	chunks := chunkMembers(m.Members(), maxPacketSize-maxEnvelopeSize)
+	for i, chunk := range chunks {
+		m.send(addr, message{Type: typ, Updates: chunk, More: i < len(chunks)-1})
+	}
+}
+
+// chunkMembers splits members into chunks whose JSON encoding stays within size bytes
+func chunkMembers(members []Member, size int) [][]Member {
+	var chunks [][]Member
+	var chunk []Member
+	used := 0
+	for _, member := range members {
+		encoded, _ := json.Marshal(member)
+		// One byte for the comma separating the member from the previous one
+		if len(chunk) > 0 && used+len(encoded)+1 > size {
+			chunks = append(chunks, chunk)
+			chunk, used = nil, 0
+		}
+		chunk = append(chunk, member)
+		used += len(encoded) + 1
+	}
+	return append(chunks, chunk)
+}
+
+// send encodes msg, adds piggybacked updates to non-sync messages and writes it to addr
+func (m *Membership) send(addr string, msg message) {
+	if msg.Type != msgSync && msg.Type != msgSyncAck {
+		msg.Updates = m.piggyback()
+	}
+	data, err := json.Marshal(msg)
+	if err == nil {
+		err = m.transport.WriteTo(data, addr)
+	}
+	if err != nil && m.config.Logger != nil {
+		m.config.Logger.LogAttrs(context.Background(), slog.LevelWarn, "membership send failed",
+			slog.String("addr", addr),
+			slog.String("type", string(msg.Type)),
+			slog.Int("bytes", len(data)),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+// expectAck allocates a sequence number and a channel closed when its ack arrives
+func (m *Membership) expectAck() (uint64, chan struct{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.seq++
+	ch := make(chan struct{})
+	m.acks[m.seq] = ch
+	return m.seq, ch
+}
+
+func (m *Membership) cancelAck(seq uint64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.acks, seq)
+}
+
+/*
+nextProbeTarget returns the next live member to probe. Members are probed in a shuffled
+round-robin order, so every member is probed once per round and failures are detected in
+bounded time.
+*/
+func (m *Membership) nextProbeTarget() (Member, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for attempt := 0; attempt < 2; attempt++ {
+		for len(m.probeOrder) > 0 {
+			id := m.probeOrder[0]
+			m.probeOrder = m.probeOrder[1:]
+			if member, ok := m.members[id]; ok && member.State != StateDead {
+				return *member, true
+			}
+		}
+		// Start a new round over the current live members
+		for id, member := range m.members {
+			if member.State != StateDead {
+				m.probeOrder = append(m.probeOrder, id)
+			}
+		}
+		rand.Shuffle(len(m.probeOrder), func(i, j int) {
+			m.probeOrder[i], m.probeOrder[j] = m.probeOrder[j], m.probeOrder[i]
+		})
+	}
+	return Member{}, false
+}
+
+// randomMembers returns up to count random members that are alive, excluding the one with id exclude
+func (m *Membership) randomMembers(count int, exclude string) []Member {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var candidates []Member
+	for id, member := range m.members {
+		if id != exclude && member.State == StateAlive {
+			candidates = append(candidates, *member)
+		}
+	}
+	rand.Shuffle(len(candidates), func(i, j int) {
+		candidates[i], candidates[j] = candidates[j], candidates[i]
+	})
+	return candidates[:min(count, len(candidates))]
+}
@@ -0,0 +1,319 @@
+package membership
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+lossyTransport wraps a Transport and drops the given fraction of outgoing datagrams, to
+check that the protocol converges on a lossy network.
+*/
+type lossyTransport struct {
+	Transport
+	loss float64
+}
+
+func (l *lossyTransport) WriteTo(data []byte, addr string) error {
+	if rand.Float64() < l.loss {
+		return nil
+	}
+	return l.Transport.WriteTo(data, addr)
+}
+
+// failingTransport wraps a Transport and fails every outgoing datagram
+type failingTransport struct {
+	Transport
+}
+
+func (f *failingTransport) WriteTo(data []byte, addr string) error {
+	return errors.New("network unreachable")
+}
+
+// testMember is one running member together with its ring
+type testMember struct {
+	*Membership
+	ring *hashring.HashRing
+}
+
+/*
+startCluster starts count members on localhost ports with fast protocol timings, every
+member dropping loss of its outgoing packets, and joins them all through the first one.
+*/
+func startCluster(t *testing.T, count int, loss float64) []*testMember {
+	t.Helper()
+	members := make([]*testMember, count)
+	for i := range members {
+		udp, err := UDPTransportInit("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to bind transport: %v", err)
+		}
+		ring := hashring.HashRingInit()
+		m, err := MembershipInit(fmt.Sprintf("member-%d", i), ring,
+			SetTransport(&lossyTransport{Transport: udp, loss: loss}),
+			SetProbeInterval(50*time.Millisecond, 20*time.Millisecond),
+			SetSuspicionTimeout(400*time.Millisecond),
+			SetSyncInterval(200*time.Millisecond),
+		)
+		if err != nil {
+			t.Fatalf("MembershipInit failed: %v", err)
+		}
+		t.Cleanup(func() { m.Shutdown() })
+		members[i] = &testMember{Membership: m, ring: ring}
+	}
+
+	for _, m := range members[1:] {
+		if err := m.Join(members[0].LocalMember().Addr); err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+	}
+	return members
+}
+
+// ringIDs returns the sorted identifiers of every node that owns a key in ring
+func ringIDs(ring *hashring.HashRing) []string {
+	nodes, err := ring.GetNodes("", 1<<20)
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, node := range nodes {
+		ids = append(ids, node.GetIdentifier())
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// waitForRings waits until the ring of every member holds exactly the expected identifiers
+func waitForRings(t *testing.T, members []*testMember, expected []string, timeout time.Duration) {
+	t.Helper()
+	slices.Sort(expected)
+	deadline := time.Now().Add(timeout)
+	for {
+		converged := true
+		for _, m := range members {
+			if !slices.Equal(ringIDs(m.ring), expected) {
+				converged = false
+				break
+			}
+		}
+		if converged {
+			return
+		}
+		if time.Now().After(deadline) {
+			for _, m := range members {
+				t.Logf("%s ring: %v", m.LocalMember().ID, ringIDs(m.ring))
+			}
+			t.Fatalf("Rings did not converge on %v within %v", expected, timeout)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func ids(members []*testMember) []string {
+	var out []string
+	for _, m := range members {
+		out = append(out, m.LocalMember().ID)
+	}
+	return out
+}
+
+/*
+TestMembership tests the SWIM protocol with several members on localhost. It verifies that
+joining members converge on the same ring, that a crashed member is detected and removed
+from every ring, that a member leaving gracefully is removed, that all of this still
+works when a fraction of the packets are dropped, that a full state too large for one
+datagram still syncs, and that failed sends are logged.
+*/
+func TestMembership(t *testing.T) {
+	t.Run("members converge on the same ring", func(t *testing.T) {
+		members := startCluster(t, 5, 0)
+		waitForRings(t, members, ids(members), 3*time.Second)
+	})
+
+	t.Run("crashed member is removed from every ring", func(t *testing.T) {
+		members := startCluster(t, 4, 0)
+		waitForRings(t, members, ids(members), 3*time.Second)
+
+		// Shut down without announcing anything, the others have to detect it
+		members[3].Shutdown()
+		waitForRings(t, members[:3], ids(members[:3]), 5*time.Second)
+	})
+
+	t.Run("leaving member is removed from every ring", func(t *testing.T) {
+		members := startCluster(t, 4, 0)
+		waitForRings(t, members, ids(members), 3*time.Second)
+
+		if err := members[1].Leave(); err != nil {
+			t.Fatalf("Leave failed: %v", err)
+		}
+		remaining := []*testMember{members[0], members[2], members[3]}
+		waitForRings(t, remaining, ids(remaining), 3*time.Second)
+	})
+
+	t.Run("converges and detects failures with packet loss", func(t *testing.T) {
+		members := startCluster(t, 5, 0.2)
+		waitForRings(t, members, ids(members), 10*time.Second)
+
+		members[4].Shutdown()
+		waitForRings(t, members[:4], ids(members[:4]), 10*time.Second)
+	})
+
+	t.Run("join fails without a live seed", func(t *testing.T) {
+		udp, err := UDPTransportInit("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to bind transport: %v", err)
+		}
+		m, err := MembershipInit("lonely", hashring.HashRingInit(),
+			SetTransport(udp), SetProbeInterval(20*time.Millisecond, 10*time.Millisecond))
+		if err != nil {
+			t.Fatalf("MembershipInit failed: %v", err)
+		}
+		defer m.Shutdown()
+
+		if err := m.Join("127.0.0.1:1"); err != ErrJoinFailed {
+			t.Errorf("Expected ErrJoinFailed, got %v", err)
+		}
+	})
+
+	t.Run("state larger than a datagram still syncs", func(t *testing.T) {
+		members := startCluster(t, 2, 0)
+		waitForRings(t, members, ids(members), 3*time.Second)
+
+		// Enough departed members to push the full state well past one datagram
+		for i := 0; i < 2000; i++ {
+			members[0].apply(Member{ID: fmt.Sprintf("departed-member-%d.example.internal", i), Addr: "10.0.0.1:7946", State: StateDead})
+		}
+		udp, err := UDPTransportInit("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to bind transport: %v", err)
+		}
+		late, err := MembershipInit("late", hashring.HashRingInit(), SetTransport(udp),
+			SetProbeInterval(50*time.Millisecond, 20*time.Millisecond))
+		if err != nil {
+			t.Fatalf("MembershipInit failed: %v", err)
+		}
+		defer late.Shutdown()
+
+		if err := late.Join(members[0].LocalMember().Addr); err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		deadline := time.Now().Add(3 * time.Second)
+		for len(late.Members()) < 2003 && time.Now().Before(deadline) {
+			time.Sleep(20 * time.Millisecond)
+		}
+		if got := len(late.Members()); got != 2003 {
+			t.Errorf("Expected the full state of 2003 members, got %d", got)
+		}
+	})
+
+	t.Run("failed sends are logged", func(t *testing.T) {
+		udp, err := UDPTransportInit("127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to bind transport: %v", err)
+		}
+		var logs bytes.Buffer
+		m, err := MembershipInit("isolated", hashring.HashRingInit(), SetTransport(&failingTransport{Transport: udp}),
+			SetProbeInterval(20*time.Millisecond, 10*time.Millisecond), SetLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		if err != nil {
+			t.Fatalf("MembershipInit failed: %v", err)
+		}
+		m.Join("127.0.0.1:1")
+		m.Shutdown()
+		if !strings.Contains(logs.String(), "membership send failed") || !strings.Contains(logs.String(), "network unreachable") {
+			t.Errorf("Expected the failed sends to be logged, got %q", logs.String())
+		}
+	})
+}
+
+/*
+TestReap tests that dead and departed members are forgotten once the dead retention has
+passed, while live members and members that died recently are kept.
+*/
+func TestReap(t *testing.T) {
+	udp, err := UDPTransportInit("127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to bind transport: %v", err)
+	}
+	m, err := MembershipInit("local", hashring.HashRingInit(), SetTransport(udp), SetDeadRetention(time.Minute))
+	if err != nil {
+		t.Fatalf("MembershipInit failed: %v", err)
+	}
+	defer m.Shutdown()
+
+	m.apply(Member{ID: "alive", Addr: "10.0.0.1:7946", State: StateAlive})
+	m.apply(Member{ID: "crashed", Addr: "10.0.0.2:7946", State: StateDead})
+	m.reap(time.Now().Add(30 * time.Second))
+	if got := len(m.Members()); got != 3 {
+		t.Fatalf("Expected the recently dead member to be kept, got %d members", got)
+	}
+
+	m.reap(time.Now().Add(2 * time.Minute))
+	members := m.Members()
+	if len(members) != 2 || slices.ContainsFunc(members, func(member Member) bool { return member.ID == "crashed" }) {
+		t.Errorf("Expected only local and alive to be left, got %+v", members)
+	}
+}
+
+/*
+TestChunkMembers tests that the full state is split into chunks that each fit in one
+datagram and together hold every member in order.
+*/
+func TestChunkMembers(t *testing.T) {
+	var members []Member
+	for i := 0; i < 3000; i++ {
+		members = append(members, Member{ID: fmt.Sprintf("member-%d.example.internal", i), Addr: "10.0.0.1:7946", Incarnation: uint64(i)})
+	}
+	chunks := chunkMembers(members, maxPacketSize-maxEnvelopeSize)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the state to be split, got %d chunk", len(chunks))
+	}
+	var joined []Member
+	for _, chunk := range chunks {
+		data, _ := json.Marshal(message{Type: msgSyncAck, Updates: chunk, More: true})
+		if len(data) > maxPacketSize {
+			t.Errorf("Chunk of %d members encodes to %d bytes", len(chunk), len(data))
+		}
+		joined = append(joined, chunk...)
+	}
+	if !slices.Equal(joined, members) {
+		t.Error("Expected the chunks to hold every member in order")
+	}
+}
+
+/*
+TestOverrides tests the SWIM precedence rules used to merge updates: higher incarnations
+win, and at equal incarnation suspect beats alive and dead beats both.
+*/
+func TestOverrides(t *testing.T) {
+	current := &Member{ID: "a", State: StateSuspect, Incarnation: 2}
+	tests := []struct {
+		name   string
+		update Member
+		want   bool
+	}{
+		{"older alive", Member{State: StateAlive, Incarnation: 1}, false},
+		{"same alive", Member{State: StateAlive, Incarnation: 2}, false},
+		{"newer alive refutes", Member{State: StateAlive, Incarnation: 3}, true},
+		{"same suspect", Member{State: StateSuspect, Incarnation: 2}, false},
+		{"same dead", Member{State: StateDead, Incarnation: 2}, true},
+	}
+	for _, tt := range tests {
+		if got := overrides(tt.update, current, true); got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.want, got)
+		}
+	}
+	if overrides(Member{State: StateSuspect}, nil, false) {
+		t.Error("Suspicion about an unknown member should be ignored")
+	}
+}
@@ -0,0 +1,143 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package membership
+
+import (
+	"errors"
+	"net"
+	"sync"
+)
+
+// maxPacketSize is the largest UDP payload we read or write
+const maxPacketSize = 65507
+
+// Packet is one datagram received by a Transport
+type Packet struct {
+	From string
+	Data []byte
+}
+
+/*
+Transport moves datagrams between members. UDPTransport is the production implementation,
+tests wrap it to drop or delay packets. Implementations must be safe for concurrent use.
+*/
+type Transport interface {
+	// Addr returns the address other members use to reach this transport
+	Addr() string
+	// WriteTo sends one datagram to addr, delivery is best effort
+	WriteTo(data []byte, addr string) error
+	// Packets returns the channel of received datagrams, closed by Close
+	Packets() <-chan Packet
+	Close() error
+}
+
+/*
+UDPTransport is a Transport over a single UDP socket. Fields:
+  - conn: Socket used for both sending and receiving
+  - packets: Received datagrams handed to the membership loop
+  - mu: Mutex guarding addrs
+  - addrs: Cache of resolved destination addresses
+  - done: Closed when the read loop has exited
+*/
+type UDPTransport struct {
+	conn    *net.UDPConn
+	packets chan Packet
+	mu      sync.Mutex
+	addrs   map[string]*net.UDPAddr
+	done    chan struct{}
+}
+
+/*
+UDPTransportInit binds a UDP socket on bindAddr (for example "127.0.0.1:7946", or
+"127.0.0.1:0" for a random port) and starts reading from it.
+*/
+func UDPTransportInit(bindAddr string) (*UDPTransport, error) {
+	addr, err := net.ResolveUDPAddr("udp", bindAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	t := &UDPTransport{
+		conn:    conn,
+		packets: make(chan Packet, 1024),
+		addrs:   make(map[string]*net.UDPAddr),
+		done:    make(chan struct{}),
+	}
+	go t.readLoop()
+	return t, nil
+}
+
+func (t *UDPTransport) Addr() string {
+	return t.conn.LocalAddr().String()
+}
+
+func (t *UDPTransport) WriteTo(data []byte, addr string) error {
+	t.mu.Lock()
+	udpAddr, ok := t.addrs[addr]
+	t.mu.Unlock()
+	if !ok {
+		var err error
+		if udpAddr, err = net.ResolveUDPAddr("udp", addr); err != nil {
+			return err
+		}
+		t.mu.Lock()
+		t.addrs[addr] = udpAddr
+		t.mu.Unlock()
+	}
+	_, err := t.conn.WriteToUDP(data, udpAddr)
+	return err
+}
+
+func (t *UDPTransport) Packets() <-chan Packet {
+	return t.packets
+}
+
+func (t *UDPTransport) Close() error {
+	err := t.conn.Close()
+	<-t.done
+	return err
+}
+
+// readLoop delivers datagrams until the socket is closed, dropping them when nobody keeps up
+func (t *UDPTransport) readLoop() {
+	defer close(t.done)
+	defer close(t.packets)
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := t.conn.ReadFromUDP(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			continue
+		}
+		packet := Packet{From: from.String(), Data: append([]byte(nil), buf[:n]...)}
+		select {
+		case t.packets <- packet:
+		default:
+		}
+	}
+}
@@ -0,0 +1,579 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package memcache provides a memcached ASCII protocol proxy that shards keys over a pool
+of memcached servers with a consistent hash ring. Clients that cannot shard on their own
+talk to the proxy as if it were a single memcached server. Supported commands are get,
+gets, set, add, delete, incr and touch; a multi-key get is split across the owners of its
+keys and the responses are merged back in request order.
+*/
+package memcache
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrNotAServer   = errors.New("Node is not a memcached Server")
+	ErrBadResponse  = errors.New("Bad response from memcached server")
+	ErrProxyClosed  = errors.New("Proxy is closed")
+	errBadFormat    = errors.New("bad command line format")
+	errBadDataChunk = errors.New("bad data chunk")
+	errLineTooLong  = errors.New("line too long")
+	errTooLarge     = errors.New("object too large for cache")
+)
+
+const (
+	// maxKeyLength is the longest key memcached accepts
+	maxKeyLength = 250
+	// maxLineLength bounds a command or response line, enough for a get of 250 maximum-length keys
+	maxLineLength = 64 * 1024
+	// defaultMaxItemSize is memcached's default item size limit
+	defaultMaxItemSize = 1 << 20
+)
+
+/*
+Server is a ring node backed by a memcached server. Nodes added to the ring used by the
+proxy must implement it, Backend is a ready-made implementation.
+*/
+type Server interface {
+	hashring.CacheNode
+	Addr() string
+}
+
+// Backend is a simple Server identified by ID and listening on Address (host:port)
+type Backend struct {
+	ID      string
+	Address string
+}
+
+func (b *Backend) GetIdentifier() string {
+	return b.ID
+}
+
+func (b *Backend) Addr() string {
+	return b.Address
+}
+
+type proxyConfig struct {
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+	MaxItemSize int
+}
+
+// ProxyConfigFn is a function type that modifies the proxyConfig
+type ProxyConfigFn func(*proxyConfig)
+
+// SetDialTimeout returns a ProxyConfigFn that bounds how long connecting to a server may take
+func SetDialTimeout(timeout time.Duration) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.DialTimeout = timeout
+	}
+}
+
+/*
+SetIOTimeout returns a ProxyConfigFn that bounds every round trip to a server. A server
+that does not answer in time is disconnected and the client gets a SERVER_ERROR.
+*/
+func SetIOTimeout(timeout time.Duration) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.IOTimeout = timeout
+	}
+}
+
+/*
+SetMaxItemSize returns a ProxyConfigFn that sets the largest data block the proxy accepts,
+1MB by default like memcached. Larger set and add commands are answered with
+SERVER_ERROR object too large for cache, and a server returning a larger value is treated
+as a bad response, so keep it at least as large as the servers' own limit.
+*/
+func SetMaxItemSize(size int) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.MaxItemSize = size
+	}
+}
+
+/*
+Proxy accepts memcached text protocol connections and forwards every command to the
+server owning its key on the hash ring. Fields:
+  - ring: Hash ring holding Server nodes
+  - config: Configuration settings such as dial and I/O timeouts
+  - mu: Mutex guarding listeners, conns and closed
+  - listeners: Listeners passed to Serve, closed by Close
+  - conns: Open client connections, closed by Close
+  - closed: Whether Close was called
+*/
+type Proxy struct {
+	ring      *hashring.HashRing
+	config    proxyConfig
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ProxyInit creates a Proxy that shards keys over the Server nodes of ring
+func ProxyInit(ring *hashring.HashRing, opts ...ProxyConfigFn) *Proxy {
+	config := &proxyConfig{
+		DialTimeout: time.Second,
+		IOTimeout:   5 * time.Second,
+		MaxItemSize: defaultMaxItemSize,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Proxy{
+		ring:      ring,
+		config:    *config,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+/*
+Serve accepts client connections on l and serves each one on its own goroutine until
+Close is called or l fails. It returns ErrProxyClosed after Close.
+*/
+func (p *Proxy) Serve(l net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrProxyClosed
+	}
+	p.listeners[l] = struct{}{}
+	p.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			delete(p.listeners, l)
+			p.mu.Unlock()
+			if closed {
+				return ErrProxyClosed
+			}
+			return err
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			conn.Close()
+			return ErrProxyClosed
+		}
+		p.conns[conn] = struct{}{}
+		p.mu.Unlock()
+
+		go p.serveConn(conn)
+	}
+}
+
+// Close stops every Serve call and closes all client connections
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for l := range p.listeners {
+		l.Close()
+	}
+	for conn := range p.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+/*
+serveConn reads commands from one client until it disconnects or sends quit. Every client
+connection keeps its own connections to the servers so responses from concurrent clients
+can never interleave.
+*/
+func (p *Proxy) serveConn(conn net.Conn) {
+	session := &session{
+		proxy:   p,
+		client:  bufio.NewReader(conn),
+		out:     bufio.NewWriter(conn),
+		servers: make(map[string]*serverConn),
+	}
+	defer func() {
+		session.closeServers()
+		conn.Close()
+		p.mu.Lock()
+		delete(p.conns, conn)
+		p.mu.Unlock()
+	}()
+
+	for {
+		line, err := readLine(session.client)
+		if errors.Is(err, errLineTooLong) {
+			// The rest of the line cannot be told apart from the next command, so hang up
+			session.clientError(err)
+			session.out.Flush()
+			return
+		}
+		if err != nil {
+			return
+		}
+		if quit := session.handle(line); quit {
+			return
+		}
+		if err := session.out.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// session holds the state of one client connection
+type session struct {
+	proxy   *Proxy
+	client  *bufio.Reader
+	out     *bufio.Writer
+	servers map[string]*serverConn
+}
+
+// serverConn is a buffered connection to one memcached server
+type serverConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// handle executes one command line and writes its reply, returning true when the client quits
+func (s *session) handle(line string) bool {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		s.reply("ERROR")
+		return false
+	}
+
+	switch fields[0] {
+	case "get", "gets":
+		s.handleGet(fields)
+	case "set", "add":
+		s.handleStorage(line, fields)
+	case "delete":
+		s.handleSimple(line, fields, 2, 3)
+	case "incr", "touch":
+		s.handleSimple(line, fields, 3, 4)
+	case "quit":
+		return true
+	default:
+		s.reply("ERROR")
+	}
+	return false
+}
+
+/*
+handleGet splits the keys of a get or gets command by owner, sends one command per server
+and writes the merged VALUE blocks in the order the client asked for them. A key that
+appears more than once is only fetched once but answered every time it was requested.
+*/
+func (s *session) handleGet(fields []string) {
+	keys := fields[1:]
+	if len(keys) == 0 {
+		s.clientError(errBadFormat)
+		return
+	}
+
+	// Group the distinct keys by owning server, keeping the first-seen order per server
+	byServer := make(map[string][]string)
+	servers := make(map[string]Server)
+	var order []string
+	seen := make(map[string]struct{}, len(keys))
+	for _, key := range keys {
+		if !validKey(key) {
+			s.clientError(errBadFormat)
+			return
+		}
+		if _, dup := seen[key]; dup {
+			continue
+		}
+		seen[key] = struct{}{}
+
+		server, err := s.owner(key)
+		if err != nil {
+			s.serverError(err)
+			return
+		}
+		if _, ok := byServer[server.Addr()]; !ok {
+			order = append(order, server.Addr())
+			servers[server.Addr()] = server
+		}
+		byServer[server.Addr()] = append(byServer[server.Addr()], key)
+	}
+
+	values := make(map[string][]byte, len(seen))
+	for _, addr := range order {
+		command := fields[0] + " " + strings.Join(byServer[addr], " ")
+		if err := s.fetch(servers[addr], command, values); err != nil {
+			s.serverError(err)
+			return
+		}
+	}
+
+	for _, key := range keys {
+		if block, ok := values[key]; ok {
+			s.out.Write(block)
+		}
+	}
+	s.reply("END")
+}
+
+// fetch sends a retrieval command to server and stores every returned VALUE block by key
+func (s *session) fetch(server Server, command string, values map[string][]byte) error {
+	return s.roundTrip(server, func(sc *serverConn) error {
+		if _, err := sc.w.WriteString(command + "\r\n"); err != nil {
+			return err
+		}
+		if err := sc.w.Flush(); err != nil {
+			return err
+		}
+
+		for {
+			line, err := readLine(sc.r)
+			if err != nil {
+				return err
+			}
+			if line == "END" {
+				return nil
+			}
+
+			// VALUE <key> <flags> <bytes> [<cas unique>]
+			parts := strings.Fields(line)
+			if len(parts) < 4 || parts[0] != "VALUE" {
+				return fmt.Errorf("%w: %q", ErrBadResponse, line)
+			}
+			size, err := strconv.ParseUint(parts[3], 10, 32)
+			if err != nil || size > uint64(s.proxy.config.MaxItemSize) {
+				return fmt.Errorf("%w: %q", ErrBadResponse, line)
+			}
+			data, err := readData(sc.r, int(size))
+			if err != nil {
+				return err
+			}
+			values[parts[1]] = append([]byte(line+"\r\n"), data...)
+		}
+	})
+}
+
+/*
+handleStorage forwards a set or add command together with its data block to the owner of
+the key. The data block is always read from the client first so that the connection stays
+in sync even when the command is rejected; a block over the item size limit is discarded
+without being buffered.
+*/
+func (s *session) handleStorage(line string, fields []string) {
+	// <command> <key> <flags> <exptime> <bytes> [noreply]
+	if len(fields) < 5 || len(fields) > 6 {
+		s.clientError(errBadFormat)
+		return
+	}
+	// memcached lengths are 32-bit, which also keeps size+2 from overflowing
+	size, err := strconv.ParseUint(fields[4], 10, 32)
+	if err != nil {
+		s.clientError(errBadFormat)
+		return
+	}
+	if size > uint64(s.proxy.config.MaxItemSize) {
+		if _, err := io.CopyN(io.Discard, s.client, int64(size)+2); err != nil {
+			s.clientError(errBadDataChunk)
+			return
+		}
+		s.serverError(errTooLarge)
+		return
+	}
+	data, err := readData(s.client, int(size))
+	if err != nil {
+		s.clientError(errBadDataChunk)
+		return
+	}
+	if !validKey(fields[1]) {
+		s.clientError(errBadFormat)
+		return
+	}
+
+	s.forward(fields[1], line+"\r\n"+string(data), len(fields) == 6 && fields[5] == "noreply")
+}
+
+/*
+handleSimple forwards a single-line command such as delete, incr or touch to the owner of
+its key. minFields and maxFields bound the number of fields, the extra one being noreply.
+*/
+func (s *session) handleSimple(line string, fields []string, minFields, maxFields int) {
+	if len(fields) < minFields || len(fields) > maxFields || !validKey(fields[1]) {
+		s.clientError(errBadFormat)
+		return
+	}
+	s.forward(fields[1], line+"\r\n", len(fields) == maxFields && fields[maxFields-1] == "noreply")
+}
+
+// forward sends payload to the owner of key and relays its one-line reply unless noreply is set
+func (s *session) forward(key, payload string, noreply bool) {
+	server, err := s.owner(key)
+	if err != nil {
+		if !noreply {
+			s.serverError(err)
+		}
+		return
+	}
+
+	var response string
+	err = s.roundTrip(server, func(sc *serverConn) error {
+		if _, err := sc.w.WriteString(payload); err != nil {
+			return err
+		}
+		if err := sc.w.Flush(); err != nil {
+			return err
+		}
+		if noreply {
+			return nil
+		}
+		response, err = readLine(sc.r)
+		return err
+	})
+	if noreply {
+		return
+	}
+	if err != nil {
+		s.serverError(err)
+		return
+	}
+	s.reply(response)
+}
+
+// owner returns the Server owning key on the ring
+func (s *session) owner(key string) (Server, error) {
+	node, err := s.proxy.ring.GetNode(key)
+	if err != nil {
+		return nil, err
+	}
+	server, ok := node.(Server)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotAServer, node.GetIdentifier())
+	}
+	return server, nil
+}
+
+/*
+roundTrip runs fn on the connection to server, dialing it first if needed. The I/O
+deadline covers the whole exchange, and a connection that failed is dropped so the next
+command dials a fresh one instead of reading a half-consumed response.
+*/
+func (s *session) roundTrip(server Server, fn func(*serverConn) error) error {
+	sc, ok := s.servers[server.Addr()]
+	if !ok {
+		conn, err := net.DialTimeout("tcp", server.Addr(), s.proxy.config.DialTimeout)
+		if err != nil {
+			return err
+		}
+		sc = &serverConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+		s.servers[server.Addr()] = sc
+	}
+
+	if s.proxy.config.IOTimeout > 0 {
+		sc.conn.SetDeadline(time.Now().Add(s.proxy.config.IOTimeout))
+	}
+	if err := fn(sc); err != nil {
+		sc.conn.Close()
+		delete(s.servers, server.Addr())
+		return err
+	}
+	return nil
+}
+
+func (s *session) closeServers() {
+	for _, sc := range s.servers {
+		sc.conn.Close()
+	}
+}
+
+func (s *session) reply(line string) {
+	s.out.WriteString(line + "\r\n")
+}
+
+func (s *session) clientError(err error) {
+	s.reply("CLIENT_ERROR " + err.Error())
+}
+
+func (s *session) serverError(err error) {
+	// Error messages must stay on one line or the client loses track of the protocol
+	s.reply("SERVER_ERROR " + strings.NewReplacer("\r", " ", "\n", " ").Replace(err.Error()))
+}
+
+// validKey reports whether key is a legal memcached key: short and free of control characters
+func validKey(key string) bool {
+	if len(key) == 0 || len(key) > maxKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] <= ' ' || key[i] == 0x7f {
+			return false
+		}
+	}
+	return true
+}
+
+/*
+readLine reads one \r\n terminated line and returns it without the terminator. Lines
+longer than maxLineLength fail with errLineTooLong before they are buffered in full.
+*/
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return "", errLineTooLong
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+	}
+}
+
+// readData reads a data block of size bytes followed by \r\n and returns it with the terminator
+func readData(r *bufio.Reader, size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, errBadDataChunk
+	}
+	return data, nil
+}
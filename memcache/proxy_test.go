@@ -0,0 +1,362 @@
+package memcache
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+fakeMemcached is an in-process memcached server speaking just enough of the text protocol
+for the proxy tests. It counts the commands it receives so tests can check which server a
+key was routed to.
+*/
+type fakeMemcached struct {
+	mu       sync.Mutex
+	items    map[string]fakeItem
+	commands []string
+	listener net.Listener
+}
+
+type fakeItem struct {
+	flags string
+	data  string
+	cas   int
+}
+
+func startFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	fake := &fakeMemcached{items: make(map[string]fakeItem), listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+	return fake
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		line, err := readLine(r)
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		noreply := fields[len(fields)-1] == "noreply"
+
+		f.mu.Lock()
+		f.commands = append(f.commands, line)
+		switch fields[0] {
+		case "get", "gets":
+			for _, key := range fields[1:] {
+				if item, ok := f.items[key]; ok {
+					if fields[0] == "gets" {
+						fmt.Fprintf(w, "VALUE %s %s %d %d\r\n%s\r\n", key, item.flags, len(item.data), item.cas, item.data)
+					} else {
+						fmt.Fprintf(w, "VALUE %s %s %d\r\n%s\r\n", key, item.flags, len(item.data), item.data)
+					}
+				}
+			}
+			w.WriteString("END\r\n")
+		case "set", "add":
+			size, _ := strconv.Atoi(fields[4])
+			data, _ := readData(r, size)
+			_, exists := f.items[fields[1]]
+			reply := "STORED"
+			if fields[0] == "add" && exists {
+				reply = "NOT_STORED"
+			} else {
+				f.items[fields[1]] = fakeItem{flags: fields[2], data: string(data[:size]), cas: len(f.commands)}
+			}
+			if !noreply {
+				w.WriteString(reply + "\r\n")
+			}
+		case "delete":
+			reply := "NOT_FOUND"
+			if _, ok := f.items[fields[1]]; ok {
+				delete(f.items, fields[1])
+				reply = "DELETED"
+			}
+			if !noreply {
+				w.WriteString(reply + "\r\n")
+			}
+		case "incr":
+			item, ok := f.items[fields[1]]
+			if !ok {
+				w.WriteString("NOT_FOUND\r\n")
+				break
+			}
+			current, _ := strconv.Atoi(item.data)
+			delta, _ := strconv.Atoi(fields[2])
+			item.data = strconv.Itoa(current + delta)
+			f.items[fields[1]] = item
+			w.WriteString(item.data + "\r\n")
+		case "touch":
+			if _, ok := f.items[fields[1]]; ok {
+				w.WriteString("TOUCHED\r\n")
+			} else {
+				w.WriteString("NOT_FOUND\r\n")
+			}
+		}
+		f.mu.Unlock()
+		w.Flush()
+	}
+}
+
+func (f *fakeMemcached) has(key string) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	_, ok := f.items[key]
+	return ok
+}
+
+func (f *fakeMemcached) countPrefix(prefix string) int {
+	return len(f.withPrefix(prefix))
+}
+
+// withPrefix returns the received commands starting with prefix, in arrival order
+func (f *fakeMemcached) withPrefix(prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var commands []string
+	for _, command := range f.commands {
+		if strings.HasPrefix(command, prefix) {
+			commands = append(commands, command)
+		}
+	}
+	return commands
+}
+
+/*
+startProxy builds a ring over count fake servers, starts a Proxy in front of them and
+returns a connected client along with the servers keyed by node identifier.
+*/
+func startProxy(t *testing.T, count int) (*bufio.ReadWriter, *hashring.HashRing, map[string]*fakeMemcached) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	fakes := make(map[string]*fakeMemcached)
+	for i := 0; i < count; i++ {
+		fake := startFakeMemcached(t)
+		// The suffix after the index spreads the FNV hashes of the ids around the ring
+		id := fmt.Sprintf("mc-%d.local", i)
+		fakes[id] = fake
+		if err := ring.AddNode(&Backend{ID: id, Address: fake.listener.Addr().String()}); err != nil {
+			t.Fatalf("Failed to add %s: %v", id, err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	p := ProxyInit(ring, SetIOTimeout(time.Second))
+	go p.Serve(listener)
+	t.Cleanup(func() { p.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)), ring, fakes
+}
+
+// send writes raw protocol text to the proxy and reads back the given number of lines
+func send(t *testing.T, client *bufio.ReadWriter, payload string, lines int) []string {
+	t.Helper()
+	client.WriteString(payload)
+	client.Flush()
+	var out []string
+	for i := 0; i < lines; i++ {
+		line, err := readLine(client.Reader)
+		if err != nil {
+			t.Fatalf("Failed to read reply line %d: %v", i, err)
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+/*
+TestProxy tests the memcached proxy end to end against in-process fake servers. It
+verifies that storage commands land on the key's owner, that a multi-key get is split
+across owners and merged in request order, and that incr, touch, delete, noreply and
+protocol errors behave like a single memcached server.
+*/
+func TestProxy(t *testing.T) {
+	t.Run("set routes to the owner and get reads it back", func(t *testing.T) {
+		client, ring, fakes := startProxy(t, 3)
+
+		if reply := send(t, client, "set user:1 5 0 5\r\nhello\r\n", 1); reply[0] != "STORED" {
+			t.Fatalf("Expected STORED, got %v", reply)
+		}
+
+		// Only the owner should have stored the key
+		owner, _ := ring.GetNode("user:1")
+		for id, fake := range fakes {
+			if fake.has("user:1") != (id == owner.GetIdentifier()) {
+				t.Errorf("Server %s has key = %v, owner is %s", id, fake.has("user:1"), owner.GetIdentifier())
+			}
+		}
+
+		reply := send(t, client, "get user:1\r\n", 3)
+		if strings.Join(reply, "|") != "VALUE user:1 5 5|hello|END" {
+			t.Errorf("Unexpected get reply %v", reply)
+		}
+	})
+
+	t.Run("multi-key get is split across owners and merged in order", func(t *testing.T) {
+		client, ring, fakes := startProxy(t, 3)
+
+		// Pick one key per server so the get has to be split three ways
+		byOwner := map[string]string{}
+		for i := 0; len(byOwner) < 3 && i < 100000; i++ {
+			key := fmt.Sprintf("key:%d", i)
+			owner, _ := ring.GetNode(key)
+			if _, ok := byOwner[owner.GetIdentifier()]; !ok {
+				byOwner[owner.GetIdentifier()] = key
+			}
+		}
+		if len(byOwner) < 3 {
+			t.Fatal("Could not find a key for every server")
+		}
+		a, b, c := byOwner["mc-0.local"], byOwner["mc-1.local"], byOwner["mc-2.local"]
+
+		for _, key := range []string{a, b, c} {
+			send(t, client, fmt.Sprintf("set %s 0 0 %d noreply\r\n%s\r\n", key, len(key), key), 0)
+		}
+		// A reply-bearing command guarantees every noreply set was processed before the get
+		send(t, client, "touch "+a+" 0\r\n", 1)
+
+		reply := send(t, client, fmt.Sprintf("get %s missing %s %s %s\r\n", c, a, b, a), 9)
+		var expected []string
+		for _, key := range []string{c, a, b, a} {
+			expected = append(expected, fmt.Sprintf("VALUE %s 0 %d", key, len(key)), key)
+		}
+		expected = append(expected, "END")
+		if strings.Join(reply, "|") != strings.Join(expected, "|") {
+			t.Errorf("Expected %v, got %v", expected, reply)
+		}
+
+		// Each server receives exactly one get carrying only its own keys, each key once
+		want := map[string][]string{}
+		for _, key := range []string{c, "missing", a, b} {
+			owner, _ := ring.GetNode(key)
+			want[owner.GetIdentifier()] = append(want[owner.GetIdentifier()], key)
+		}
+		for id, fake := range fakes {
+			gets := fake.withPrefix("get ")
+			if len(gets) != 1 {
+				t.Errorf("Server %s received %d gets, expected 1", id, len(gets))
+				continue
+			}
+			if got := strings.Fields(gets[0])[1:]; strings.Join(got, " ") != strings.Join(want[id], " ") {
+				t.Errorf("Server %s was asked for %v, expected its own keys %v", id, got, want[id])
+			}
+		}
+	})
+
+	t.Run("gets returns cas values", func(t *testing.T) {
+		client, _, _ := startProxy(t, 2)
+		send(t, client, "set k 0 0 1\r\nv\r\n", 1)
+		reply := send(t, client, "gets k\r\n", 3)
+		if fields := strings.Fields(reply[0]); len(fields) != 5 || fields[0] != "VALUE" {
+			t.Errorf("Expected VALUE line with cas, got %q", reply[0])
+		}
+	})
+
+	t.Run("add incr touch delete", func(t *testing.T) {
+		client, _, _ := startProxy(t, 3)
+
+		steps := []struct{ command, want string }{
+			{"add counter 0 0 1\r\n1\r\n", "STORED"},
+			{"add counter 0 0 1\r\n1\r\n", "NOT_STORED"},
+			{"incr counter 41\r\n", "42"},
+			{"touch counter 60\r\n", "TOUCHED"},
+			{"delete counter\r\n", "DELETED"},
+			{"delete counter\r\n", "NOT_FOUND"},
+			{"incr counter 1\r\n", "NOT_FOUND"},
+		}
+		for _, step := range steps {
+			if reply := send(t, client, step.command, 1); reply[0] != step.want {
+				t.Errorf("%q: expected %s, got %s", strings.TrimSpace(step.command), step.want, reply[0])
+			}
+		}
+	})
+
+	t.Run("protocol errors", func(t *testing.T) {
+		client, _, _ := startProxy(t, 1)
+
+		if reply := send(t, client, "flush_all\r\n", 1); reply[0] != "ERROR" {
+			t.Errorf("Expected ERROR for unknown command, got %s", reply[0])
+		}
+		if reply := send(t, client, "get\r\n", 1); !strings.HasPrefix(reply[0], "CLIENT_ERROR") {
+			t.Errorf("Expected CLIENT_ERROR for get without keys, got %s", reply[0])
+		}
+		if reply := send(t, client, "set k 0 0 2\r\nabc\r\n", 1); !strings.HasPrefix(reply[0], "CLIENT_ERROR") {
+			t.Errorf("Expected CLIENT_ERROR for bad data chunk, got %s", reply[0])
+		}
+	})
+
+	t.Run("oversized items and lines are refused", func(t *testing.T) {
+		client, _, fakes := startProxy(t, 1)
+
+		// A length that would overflow is a format error, not a crash
+		if reply := send(t, client, "set k 0 0 9223372036854775807\r\n", 1); !strings.HasPrefix(reply[0], "CLIENT_ERROR") {
+			t.Errorf("Expected CLIENT_ERROR for an overflowing length, got %s", reply[0])
+		}
+
+		// A block over the limit is discarded and the connection stays usable
+		big := strings.Repeat("x", 1<<20+1)
+		if reply := send(t, client, fmt.Sprintf("set k 0 0 %d\r\n%s\r\n", len(big), big), 1); reply[0] != "SERVER_ERROR object too large for cache" {
+			t.Errorf("Expected SERVER_ERROR object too large for cache, got %s", reply[0])
+		}
+		if reply := send(t, client, "set k 0 0 1\r\nv\r\n", 1); reply[0] != "STORED" {
+			t.Errorf("Expected STORED after the refused item, got %s", reply[0])
+		}
+		for _, fake := range fakes {
+			if got := fake.countPrefix("set "); got != 1 {
+				t.Errorf("Expected only the small set to reach the server, got %d", got)
+			}
+		}
+
+		// An endless line is cut off and the client disconnected
+		reply := send(t, client, "get "+strings.Repeat("k", maxLineLength)+"\r\n", 1)
+		if reply[0] != "CLIENT_ERROR line too long" {
+			t.Errorf("Expected CLIENT_ERROR line too long, got %s", reply[0])
+		}
+		if _, err := readLine(client.Reader); err == nil {
+			t.Error("Expected the proxy to close the connection")
+		}
+	})
+
+	t.Run("unreachable server returns SERVER_ERROR", func(t *testing.T) {
+		client, _, fakes := startProxy(t, 1)
+		for _, fake := range fakes {
+			fake.listener.Close()
+		}
+		if reply := send(t, client, "get k\r\n", 1); !strings.HasPrefix(reply[0], "SERVER_ERROR") {
+			t.Errorf("Expected SERVER_ERROR, got %s", reply[0])
+		}
+	})
+}
@@ -0,0 +1,327 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package proxy provides an HTTP reverse proxy that picks its upstream with a consistent
+hash ring. Requests carrying the same routing key (a path segment, header, query param,
+cookie or the client IP) always reach the same backend while the ring is stable, which
+gives cache-affine routing, and a request fails over to the next node clockwise when the
+owner cannot be reached.
+*/
+package proxy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrNoRoutingKey  = errors.New("Request has no routing key")
+	ErrNotAnUpstream = errors.New("Node is not an Upstream")
+)
+
+/*
+Upstream is a ring node that the proxy can forward requests to. Nodes added to the ring
+used by the proxy must implement it, Backend is a ready-made implementation.
+*/
+type Upstream interface {
+	hashring.CacheNode
+	Target() *url.URL
+}
+
+// Backend is a simple Upstream identified by ID and reachable at URL
+type Backend struct {
+	ID  string
+	URL *url.URL
+}
+
+func (b *Backend) GetIdentifier() string {
+	return b.ID
+}
+
+func (b *Backend) Target() *url.URL {
+	return b.URL
+}
+
+/*
+KeyFunc extracts the routing key from an incoming request. It returns false when the
+request does not carry a key, in which case the proxy answers 400 Bad Request.
+*/
+type KeyFunc func(r *http.Request) (string, bool)
+
+/*
+PathSegment returns a KeyFunc that uses the path segment at the given zero-based index,
+so PathSegment(1) routes /users/42/profile by "42".
+*/
+func PathSegment(index int) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if index < 0 || index >= len(segments) || segments[index] == "" {
+			return "", false
+		}
+		return segments[index], true
+	}
+}
+
+// Header returns a KeyFunc that uses the value of the named request header
+func Header(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return value, value != ""
+	}
+}
+
+// QueryParam returns a KeyFunc that uses the value of the named query parameter
+func QueryParam(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		value := r.URL.Query().Get(name)
+		return value, value != ""
+	}
+}
+
+// Cookie returns a KeyFunc that uses the value of the named cookie
+func Cookie(name string) KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		cookie, err := r.Cookie(name)
+		if err != nil || cookie.Value == "" {
+			return "", false
+		}
+		return cookie.Value, true
+	}
+}
+
+/*
+ClientIP returns a KeyFunc that uses the IP address of the connected client taken from
+RemoteAddr. Forwarding headers such as X-Forwarded-For are ignored on purpose since the
+client controls them; use Header when the proxy sits behind a trusted load balancer.
+*/
+func ClientIP() KeyFunc {
+	return func(r *http.Request) (string, bool) {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			host = r.RemoteAddr
+		}
+		return host, host != ""
+	}
+}
+
+type proxyConfig struct {
+	Transport   http.RoundTripper
+	MaxAttempts int
+	ErrorLog    *log.Logger
+}
+
+// ProxyConfigFn is a function type that modifies the proxyConfig
+type ProxyConfigFn func(*proxyConfig)
+
+// SetTransport returns a ProxyConfigFn that sets the RoundTripper used to reach upstreams
+func SetTransport(transport http.RoundTripper) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.Transport = transport
+	}
+}
+
+/*
+SetMaxAttempts returns a ProxyConfigFn that sets how many distinct nodes a request is
+tried against before giving up. The default of 2 tries the owner and its successor.
+*/
+func SetMaxAttempts(attempts int) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.MaxAttempts = max(attempts, 1)
+	}
+}
+
+// SetErrorLog returns a ProxyConfigFn that sets the logger used by the underlying ReverseProxy
+func SetErrorLog(logger *log.Logger) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.ErrorLog = logger
+	}
+}
+
+/*
+Proxy is an http.Handler that forwards every request to the upstream owning its routing
+key on the hash ring. Fields:
+  - ring: Hash ring holding Upstream nodes
+  - key: Function extracting the routing key from a request
+  - config: Configuration settings such as the transport and the number of attempts
+  - reverse: Underlying httputil.ReverseProxy doing the actual forwarding
+*/
+type Proxy struct {
+	ring    *hashring.HashRing
+	key     KeyFunc
+	config  proxyConfig
+	reverse *httputil.ReverseProxy
+}
+
+/*
+ProxyInit creates a Proxy that routes requests by the key returned from key through ring.
+The ring may be changed with AddNode/RemoveNode while the proxy is serving, every request
+looks up its candidates on the current ring.
+*/
+func ProxyInit(ring *hashring.HashRing, key KeyFunc, opts ...ProxyConfigFn) *Proxy {
+	config := &proxyConfig{
+		Transport:   http.DefaultTransport,
+		MaxAttempts: 2,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	p := &Proxy{
+		ring:   ring,
+		key:    key,
+		config: *config,
+	}
+	p.reverse = &httputil.ReverseProxy{
+		Rewrite:   p.rewrite,
+		Transport: &failoverTransport{next: config.Transport},
+		ErrorLog:  config.ErrorLog,
+	}
+	return p
+}
+
+// routeKey is the context key under which ServeHTTP stores the route of a request
+type routeKey struct{}
+
+// route holds the ordered candidate upstreams of a request and the request as it came in
+type route struct {
+	candidates []Upstream
+	in         *http.Request
+}
+
+// ServeHTTP resolves the candidate upstreams for the request and hands it to the ReverseProxy
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	key, ok := p.key(r)
+	if !ok {
+		http.Error(w, ErrNoRoutingKey.Error(), http.StatusBadRequest)
+		return
+	}
+
+	nodes, err := p.ring.GetNodes(key, p.config.MaxAttempts)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+
+	// Every candidate must be an Upstream, otherwise we cannot know where to send the request
+	candidates := make([]Upstream, 0, len(nodes))
+	for _, node := range nodes {
+		upstream, ok := node.(Upstream)
+		if !ok {
+			http.Error(w, ErrNotAnUpstream.Error()+": "+node.GetIdentifier(), http.StatusBadGateway)
+			return
+		}
+		candidates = append(candidates, upstream)
+	}
+
+	ctx := context.WithValue(r.Context(), routeKey{}, &route{candidates: candidates, in: r})
+	p.reverse.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// rewrite points the outgoing request at the first candidate and sets the X-Forwarded headers
+func (p *Proxy) rewrite(pr *httputil.ProxyRequest) {
+	rt := pr.In.Context().Value(routeKey{}).(*route)
+	pr.SetURL(rt.candidates[0].Target())
+	pr.SetXForwarded()
+	pr.Out.Host = pr.In.Host
+
+	// Wrap the body so a failed dial can send it again to the next candidate
+	if pr.Out.Body != nil && pr.Out.Body != http.NoBody {
+		pr.Out.Body = &replayableBody{ReadCloser: pr.Out.Body}
+	}
+}
+
+/*
+failoverTransport retries a request against the next candidate upstream when the
+connection to the current one cannot be established. Only dial errors are retried, since
+after a connection is made the upstream may already have acted on the request.
+*/
+type failoverTransport struct {
+	next http.RoundTripper
+}
+
+func (t *failoverTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt, ok := req.Context().Value(routeKey{}).(*route)
+	if !ok {
+		return t.next.RoundTrip(req)
+	}
+
+	// The request URL already points at the first candidate through rewrite
+	resp, err := t.next.RoundTrip(req)
+	for i := 1; i < len(rt.candidates) && err != nil && isDialError(err) && canReplay(req); i++ {
+		// Rebuild the URL from the incoming request so the path is joined onto the new target
+		retry := req.Clone(req.Context())
+		retry.URL = new(url.URL)
+		*retry.URL = *rt.in.URL
+		(&httputil.ProxyRequest{In: rt.in, Out: retry}).SetURL(rt.candidates[i].Target())
+		retry.Host = req.Host
+		resp, err = t.next.RoundTrip(retry)
+	}
+	if body, ok := req.Body.(*replayableBody); ok {
+		body.ReadCloser.Close()
+	}
+	return resp, err
+}
+
+// isDialError reports whether err happened while connecting, before anything was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// canReplay reports whether the request body is still intact and can be sent again
+func canReplay(req *http.Request) bool {
+	if req.Body == nil || req.Body == http.NoBody {
+		return true
+	}
+	body, ok := req.Body.(*replayableBody)
+	return ok && !body.read
+}
+
+/*
+replayableBody wraps an outgoing request body so that the transport closing it after a
+failed dial does not close the client's body, and records whether any of it was read.
+The real body is closed by failoverTransport once all attempts are done.
+*/
+type replayableBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *replayableBody) Read(p []byte) (int, error) {
+	b.read = true
+	return b.ReadCloser.Read(p)
+}
+
+func (b *replayableBody) Close() error {
+	return nil
+}
@@ -0,0 +1,166 @@
+package proxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+newBackend starts a test server that answers with its own name, the path it received
+and the request body, so tests can tell which upstream served a request.
+*/
+func newBackend(t *testing.T, name string) (*httptest.Server, *Backend) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write([]byte(name + " " + r.URL.Path + " " + string(body)))
+	}))
+	t.Cleanup(server.Close)
+	target, _ := url.Parse(server.URL)
+	return server, &Backend{ID: name, URL: target}
+}
+
+// get sends a request through the proxy and returns the status code and response body
+func get(t *testing.T, handler http.Handler, req *http.Request) (int, string) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	return recorder.Code, recorder.Body.String()
+}
+
+/*
+TestKeyFuncs tests the built-in KeyFunc implementations to ensure each one extracts the
+routing key from the right part of the request and reports a missing key.
+*/
+func TestKeyFuncs(t *testing.T) {
+	req := httptest.NewRequest("GET", "/users/42/profile?tenant=acme", nil)
+	req.Header.Set("X-Shard-Key", "h1")
+	req.AddCookie(&http.Cookie{Name: "session", Value: "s1"})
+	req.RemoteAddr = "10.0.0.7:5555"
+
+	tests := []struct {
+		name string
+		fn   KeyFunc
+		want string
+		ok   bool
+	}{
+		{"path segment", PathSegment(1), "42", true},
+		{"path segment out of range", PathSegment(5), "", false},
+		{"header", Header("X-Shard-Key"), "h1", true},
+		{"missing header", Header("X-Other"), "", false},
+		{"query param", QueryParam("tenant"), "acme", true},
+		{"cookie", Cookie("session"), "s1", true},
+		{"missing cookie", Cookie("other"), "", false},
+		{"client ip", ClientIP(), "10.0.0.7", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.fn(req)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("Expected (%q, %v), got (%q, %v)", tt.want, tt.ok, got, ok)
+			}
+		})
+	}
+}
+
+/*
+TestProxy tests that requests are forwarded to the upstream owning their routing key,
+that the same key always reaches the same upstream, and that a request fails over to
+the next node when the owner refuses connections.
+*/
+func TestProxy(t *testing.T) {
+	t.Run("routes by key to the ring owner", func(t *testing.T) {
+		ring := hashring.HashRingInit()
+		for _, name := range []string{"a", "b", "c"} {
+			_, backend := newBackend(t, name)
+			if err := ring.AddNode(backend); err != nil {
+				t.Fatalf("Failed to add backend %s: %v", name, err)
+			}
+		}
+		p := ProxyInit(ring, Header("X-Shard-Key"))
+
+		for _, key := range []string{"user:1", "user:2", "user:3", "user:4"} {
+			owner, err := ring.GetNode(key)
+			if err != nil {
+				t.Fatalf("GetNode failed: %v", err)
+			}
+
+			// Every request with this key must land on the same owner
+			for i := 0; i < 3; i++ {
+				req := httptest.NewRequest("GET", "/items/7", nil)
+				req.Header.Set("X-Shard-Key", key)
+				code, body := get(t, p, req)
+				if code != http.StatusOK {
+					t.Fatalf("Expected 200, got %d: %s", code, body)
+				}
+				if want := owner.GetIdentifier() + " /items/7 "; body != want {
+					t.Errorf("Expected %q, got %q", want, body)
+				}
+			}
+		}
+	})
+
+	t.Run("fails over to next node on connection error", func(t *testing.T) {
+		ring := hashring.HashRingInit()
+		deadServer, dead := newBackend(t, "dead")
+		_, alive := newBackend(t, "alive")
+		ring.AddNode(dead)
+		ring.AddNode(alive)
+		deadServer.Close()
+
+		p := ProxyInit(ring, QueryParam("k"))
+
+		// Find a key owned by the dead backend so the first attempt fails to dial
+		var key string
+		for i := 0; key == ""; i++ {
+			candidate := "key" + string(rune('a'+i))
+			if owner, _ := ring.GetNode(candidate); owner.GetIdentifier() == "dead" {
+				key = candidate
+			}
+		}
+
+		req := httptest.NewRequest("POST", "/write?k="+key, strings.NewReader("payload"))
+		code, body := get(t, p, req)
+		if code != http.StatusOK {
+			t.Fatalf("Expected 200 after failover, got %d: %s", code, body)
+		}
+		if body != "alive /write payload" {
+			t.Errorf("Expected failover to alive with body intact, got %q", body)
+		}
+	})
+
+	t.Run("gives up after max attempts", func(t *testing.T) {
+		ring := hashring.HashRingInit()
+		deadServer, dead := newBackend(t, "dead")
+		ring.AddNode(dead)
+		deadServer.Close()
+
+		p := ProxyInit(ring, ClientIP(), SetMaxAttempts(3))
+		code, _ := get(t, p, httptest.NewRequest("GET", "/", nil))
+		if code != http.StatusBadGateway {
+			t.Errorf("Expected 502, got %d", code)
+		}
+	})
+
+	t.Run("missing key and empty ring", func(t *testing.T) {
+		ring := hashring.HashRingInit()
+		p := ProxyInit(ring, Header("X-Shard-Key"))
+
+		// No header means no key to route by
+		if code, _ := get(t, p, httptest.NewRequest("GET", "/", nil)); code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for missing key, got %d", code)
+		}
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Shard-Key", "k")
+		if code, _ := get(t, p, req); code != http.StatusServiceUnavailable {
+			t.Errorf("Expected 503 for empty ring, got %d", code)
+		}
+	})
+}
@@ -0,0 +1,257 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package quorum
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrHintStoreFull     = errors.New("Hint store is full")
+	ErrInvalidHintLimits = errors.New("Hint store limits must be at least 1")
+)
+
+/*
+Hint records a write that was handed to a stand-in node because its owner was down. Owner
+is the identifier of the node the write was meant for; the hint is replayed to it once it
+is back. StandIn is the identifier of the node holding the write meanwhile, which drops
+its copy once the owner has it.
+*/
+type Hint struct {
+	Owner   string `json:"owner"`
+	StandIn string `json:"stand_in,omitempty"`
+	Key     string `json:"key"`
+	Value   Value  `json:"value"`
+}
+
+// size returns the bytes hint counts against the byte budget of a HintStore
+func (hint Hint) size() int64 {
+	return int64(len(hint.Owner) + len(hint.StandIn) + len(hint.Key) + len(hint.Value.Data))
+}
+
+// hintKey identifies a hint, a newer write of the same key for the same owner replaces it
+type hintKey struct {
+	owner string
+	key   string
+}
+
+/*
+HintStore holds the hints of a Coordinator, bounded in number and in bytes and optionally
+persisted to a file so that pending hints survive a restart. The file is rewritten
+atomically on every change, which keeps it consistent at the cost of write amplification;
+the bounds keep that in check. Fields:
+  - mu: Mutex guarding hints, bytes and the file
+  - path: File the hints are persisted to, empty to keep them in memory only
+  - maxHints: Maximum number of hints held
+  - maxBytes: Maximum total size of the hints held, counting keys, identifiers and data
+  - hints: Pending hints
+  - bytes: Total size of the pending hints
+*/
+type HintStore struct {
+	mu       sync.Mutex
+	path     string
+	maxHints int
+	maxBytes int64
+	hints    map[hintKey]Hint
+	bytes    int64
+}
+
+/*
+HintStoreInit creates a HintStore holding at most maxHints hints of at most maxBytes in
+total, and returns ErrInvalidHintLimits when either is below 1. When path is not empty
+hints are persisted there and the hints already in the file are loaded, even beyond the
+bounds.
+*/
+func HintStoreInit(path string, maxHints int, maxBytes int64) (*HintStore, error) {
+	if maxHints < 1 || maxBytes < 1 {
+		return nil, fmt.Errorf("%w: %d hints, %d bytes", ErrInvalidHintLimits, maxHints, maxBytes)
+	}
+	s := &HintStore{
+		path:     path,
+		maxHints: maxHints,
+		maxBytes: maxBytes,
+		hints:    make(map[hintKey]Hint),
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var hints []Hint
+	if err := json.Unmarshal(data, &hints); err != nil {
+		return nil, fmt.Errorf("loading hints from %s: %w", path, err)
+	}
+	for _, hint := range hints {
+		k := hintKey{hint.Owner, hint.Key}
+		s.bytes += hint.size() - s.hints[k].size()
+		s.hints[k] = hint
+	}
+	return s, nil
+}
+
+/*
+Add stores hint. A hint for the same owner and key is replaced when hint has a higher
+version, since only the latest write needs to be replayed. Returns ErrHintStoreFull when a
+new hint would exceed the number of hints, or when the hint would exceed the byte budget.
+*/
+func (s *HintStore) Add(hint Hint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := hintKey{hint.Owner, hint.Key}
+	current, ok := s.hints[k]
+	if ok && current.Value.Version >= hint.Value.Version {
+		return nil
+	}
+	if !ok && len(s.hints) >= s.maxHints {
+		return fmt.Errorf("%w: %d hints", ErrHintStoreFull, len(s.hints))
+	}
+	bytes := s.bytes - current.size() + hint.size()
+	if bytes > s.maxBytes {
+		return fmt.Errorf("%w: %d of %d bytes", ErrHintStoreFull, bytes, s.maxBytes)
+	}
+	s.hints[k] = hint
+	previous := s.bytes
+	s.bytes = bytes
+	if err := s.persist(); err != nil {
+		if ok {
+			s.hints[k] = current
+		} else {
+			delete(s.hints, k)
+		}
+		s.bytes = previous
+		return err
+	}
+	return nil
+}
+
+/*
+Remove deletes hint once it has been replayed. A hint replaced by a newer write in the
+meantime is kept, so that the newer write is replayed as well.
+*/
+func (s *HintStore) Remove(hint Hint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := hintKey{hint.Owner, hint.Key}
+	current, ok := s.hints[k]
+	if !ok || current.Value.Version != hint.Value.Version {
+		return nil
+	}
+	delete(s.hints, k)
+	s.bytes -= current.size()
+	if err := s.persist(); err != nil {
+		s.hints[k] = current
+		s.bytes += current.size()
+		return err
+	}
+	return nil
+}
+
+// Hints returns the pending hints, sorted by owner and key
+func (s *HintStore) Hints() []Hint {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedHints()
+}
+
+// Len returns the number of pending hints
+func (s *HintStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.hints)
+}
+
+// Bytes returns the total size of the pending hints, as counted against the byte budget
+func (s *HintStore) Bytes() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bytes
+}
+
+// sortedHints does the work of Hints, callers must hold s.mu
+func (s *HintStore) sortedHints() []Hint {
+	hints := make([]Hint, 0, len(s.hints))
+	for _, hint := range s.hints {
+		hints = append(hints, hint)
+	}
+	slices.SortFunc(hints, func(a, b Hint) int {
+		if c := strings.Compare(a.Owner, b.Owner); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	return hints
+}
+
+// persist writes the hints to the file, callers must hold s.mu
+func (s *HintStore) persist() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.Marshal(s.sortedHints())
+	if err != nil {
+		return err
+	}
+
+	// Write through a temporary file and a rename so a crash never leaves a torn file
+	tmp := s.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return err
+	}
+	dir, err := os.Open(filepath.Dir(s.path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
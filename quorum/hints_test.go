@@ -0,0 +1,89 @@
+package quorum
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+/*
+TestHintStore tests the hint store. It verifies that a newer hint for the same owner and
+key replaces an older one, that the bound rejects new hints but not replacements, that a
+hint replaced during replay is not removed, that hints survive reopening the file, that
+the byte budget rejects hints too large to fit and that limits below one are rejected.
+*/
+func TestHintStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hints.json")
+	s, err := HintStoreInit(path, 2, 1<<20)
+	if err != nil {
+		t.Fatalf("HintStoreInit failed: %v", err)
+	}
+
+	old := Hint{Owner: "node-1", Key: "a", Value: Value{Data: []byte("old"), Version: 1}}
+	newer := Hint{Owner: "node-1", Key: "a", Value: Value{Data: []byte("new"), Version: 2}}
+	s.Add(old)
+	s.Add(newer)
+	s.Add(old)
+	if hints := s.Hints(); len(hints) != 1 || hints[0].Value.Version != 2 {
+		t.Fatalf("Expected only the newest hint, got %+v", hints)
+	}
+
+	if err := s.Add(Hint{Owner: "node-2", Key: "a", Value: Value{Version: 1}}); err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+	if err := s.Add(Hint{Owner: "node-3", Key: "a", Value: Value{Version: 1}}); !errors.Is(err, ErrHintStoreFull) {
+		t.Errorf("Expected ErrHintStoreFull, got %v", err)
+	}
+	if err := s.Add(Hint{Owner: "node-1", Key: "a", Value: Value{Version: 3}}); err != nil {
+		t.Errorf("Expected a replacement to fit in a full store, got %v", err)
+	}
+
+	// Removing the version that was replayed keeps the newer one
+	s.Remove(newer)
+	if s.Len() != 2 {
+		t.Errorf("Expected the replaced hint to stay, got %d hints", s.Len())
+	}
+
+	reopened, err := HintStoreInit(path, 2, 1<<20)
+	if err != nil {
+		t.Fatalf("HintStoreInit failed: %v", err)
+	}
+	hints := reopened.Hints()
+	if len(hints) != 2 || hints[0].Owner != "node-1" || hints[0].Value.Version != 3 {
+		t.Errorf("Expected the hints to survive a reopen, got %+v", hints)
+	}
+
+	t.Run("byte budget", func(t *testing.T) {
+		s, _ := HintStoreInit("", 10, 20)
+		small := Hint{Owner: "n1", Key: "a", Value: Value{Data: []byte("0123456789"), Version: 1}}
+		if err := s.Add(small); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if s.Bytes() != 13 {
+			t.Errorf("Expected 13 bytes, got %d", s.Bytes())
+		}
+		if err := s.Add(Hint{Owner: "n2", Key: "a", Value: Value{Data: []byte("0123456789"), Version: 1}}); !errors.Is(err, ErrHintStoreFull) {
+			t.Errorf("Expected ErrHintStoreFull, got %v", err)
+		}
+
+		// A smaller replacement frees its share of the budget
+		if err := s.Add(Hint{Owner: "n1", Key: "a", Value: Value{Data: []byte("0"), Version: 2}}); err != nil {
+			t.Fatalf("Add failed: %v", err)
+		}
+		if err := s.Add(Hint{Owner: "n2", Key: "a", Value: Value{Data: []byte("01234"), Version: 1}}); err != nil {
+			t.Errorf("Expected the hint to fit after the replacement, got %v", err)
+		}
+		s.Remove(Hint{Owner: "n1", Key: "a", Value: Value{Version: 2}})
+		if s.Bytes() != 8 {
+			t.Errorf("Expected 8 bytes after the removal, got %d", s.Bytes())
+		}
+	})
+
+	t.Run("limits below one", func(t *testing.T) {
+		for _, limits := range [][2]int{{0, 1}, {-1, 1}, {1, 0}} {
+			if _, err := HintStoreInit("", limits[0], int64(limits[1])); !errors.Is(err, ErrInvalidHintLimits) {
+				t.Errorf("Limits %v: expected ErrInvalidHintLimits, got %v", limits, err)
+			}
+		}
+	})
+}
@@ -0,0 +1,144 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package quorum
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// ErrNodeDown is returned by MemoryTransport for nodes marked down
+var ErrNodeDown = errors.New("Node is down")
+
+/*
+MemoryTransport is an in-memory Transport for tests and examples. Every node gets its own
+map of values, and nodes can be marked down or slowed down to exercise quorum failures.
+A Put only replaces a stored value with a higher version and a Delete keeps a stored value
+with a higher version. Fields:
+  - mu: Mutex guarding all fields below
+  - data: Stored values, keyed by node identifier and then by key
+  - down: Nodes whose calls fail with ErrNodeDown
+  - delay: Latency added to every call of a node
+*/
+type MemoryTransport struct {
+	mu    sync.Mutex
+	data  map[string]map[string]Value
+	down  map[string]bool
+	delay map[string]time.Duration
+}
+
+// MemoryTransportInit creates an empty MemoryTransport
+func MemoryTransportInit() *MemoryTransport {
+	return &MemoryTransport{
+		data:  make(map[string]map[string]Value),
+		down:  make(map[string]bool),
+		delay: make(map[string]time.Duration),
+	}
+}
+
+// SetDown marks the node with the given identifier as down or back up
+func (t *MemoryTransport) SetDown(id string, down bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.down[id] = down
+}
+
+// SetDelay adds latency to every call to the node with the given identifier
+func (t *MemoryTransport) SetDelay(id string, delay time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.delay[id] = delay
+}
+
+// Stored returns what the node with the given identifier holds under key, bypassing down and delay
+func (t *MemoryTransport) Stored(id, key string) (Value, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	value, ok := t.data[id][key]
+	return value, ok
+}
+
+// wait applies the delay of node and reports whether it is reachable
+func (t *MemoryTransport) wait(ctx context.Context, id string) error {
+	t.mu.Lock()
+	delay, down := t.delay[id], t.down[id]
+	t.mu.Unlock()
+
+	if delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	if down {
+		return ErrNodeDown
+	}
+	return nil
+}
+
+func (t *MemoryTransport) Put(ctx context.Context, node hashring.CacheNode, key string, value Value) error {
+	id := node.GetIdentifier()
+	if err := t.wait(ctx, id); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.data[id] == nil {
+		t.data[id] = make(map[string]Value)
+	}
+	if current, ok := t.data[id][key]; !ok || value.Version > current.Version {
+		t.data[id][key] = Value{Data: append([]byte(nil), value.Data...), Version: value.Version}
+	}
+	return nil
+}
+
+func (t *MemoryTransport) Get(ctx context.Context, node hashring.CacheNode, key string) (Value, bool, error) {
+	id := node.GetIdentifier()
+	if err := t.wait(ctx, id); err != nil {
+		return Value{}, false, err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	value, ok := t.data[id][key]
+	return value, ok, nil
+}
+
+func (t *MemoryTransport) Delete(ctx context.Context, node hashring.CacheNode, key string, version uint64) error {
+	id := node.GetIdentifier()
+	if err := t.wait(ctx, id); err != nil {
+		return err
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if current, ok := t.data[id][key]; ok && current.Version <= version {
+		delete(t.data[id], key)
+	}
+	return nil
+}
@@ -0,0 +1,468 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package quorum coordinates Dynamo-style quorum reads and writes on top of a HashRing. The
+replicas of a key are its first N distinct owners clockwise on the ring. A write is sent to
+all of them and succeeds once W have acknowledged it; a read asks all of them and returns
+the value with the highest version among the first R answers. With R + W > N every read
+overlaps the latest successful write. Replicas are reached through a Transport, so the
+package works with any storage protocol.
+*/
+package quorum
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrInvalidQuorum     = errors.New("Quorum sizes must satisfy 1 <= R, W <= N")
+	ErrNotEnoughReplicas = errors.New("Not enough nodes on the ring to reach quorum")
+	ErrQuorumNotReached  = errors.New("Quorum not reached")
+	ErrNotFound          = errors.New("Key not found")
+)
+
+/*
+Value is a versioned value. Versions are chosen by the writer, for example a timestamp or
+a counter read before the write; when replicas disagree the highest version wins.
+*/
+type Value struct {
+	Data    []byte `json:"data"`
+	Version uint64 `json:"version"`
+}
+
+/*
+Transport reaches the storage on one replica. Implementations must be safe for concurrent
+use and should return promptly when ctx is done.
+*/
+type Transport interface {
+	// Put stores value under key on node
+	Put(ctx context.Context, node hashring.CacheNode, key string, value Value) error
+	// Get returns the value stored under key on node, found is false when there is none
+	Get(ctx context.Context, node hashring.CacheNode, key string) (value Value, found bool, err error)
+	// Delete removes key from node unless node holds a version newer than version
+	Delete(ctx context.Context, node hashring.CacheNode, key string, version uint64) error
+}
+
+type coordinatorConfig struct {
+	Replicas       int
+	WriteQuorum    int
+	ReadQuorum     int
+	Timeout        time.Duration
+	ReplicaTimeout time.Duration
+	Hints          *HintStore
+}
+
+// CoordinatorConfigFn is a function type that modifies the coordinatorConfig
+type CoordinatorConfigFn func(*coordinatorConfig)
+
+/*
+SetQuorum returns a CoordinatorConfigFn that sets the number of replicas n, the write
+quorum w and the read quorum r. The default is n=3, w=2, r=2.
+*/
+func SetQuorum(n, w, r int) CoordinatorConfigFn {
+	return func(config *coordinatorConfig) {
+		config.Replicas = n
+		config.WriteQuorum = w
+		config.ReadQuorum = r
+	}
+}
+
+/*
+SetTimeout returns a CoordinatorConfigFn that bounds every Put and Get by timeout on top
+of the deadline of the caller's context. Zero, the default, leaves it to the context.
+*/
+func SetTimeout(timeout time.Duration) CoordinatorConfigFn {
+	return func(config *coordinatorConfig) {
+		config.Timeout = timeout
+	}
+}
+
+/*
+SetReplicaTimeout returns a CoordinatorConfigFn that bounds every replica write of a Put,
+10 seconds by default. Replica writes run on past the return of Put, which only waits for
+W of them, so they are not bounded by the caller's context but by this timeout.
+*/
+func SetReplicaTimeout(timeout time.Duration) CoordinatorConfigFn {
+	return func(config *coordinatorConfig) {
+		config.ReplicaTimeout = timeout
+	}
+}
+
+/*
+SetHintStore returns a CoordinatorConfigFn that enables hinted handoff. Replicas whose
+state on the ring is hashring.StateDown are then skipped, and their share of a write goes
+to the next node clockwise that is up and not a replica already, together with a hint
+naming the intended owner. Reads use the same stand-ins, so they see the handed-off
+writes. ReplayHints delivers the hints once the owners are back. Without a hint store,
+node states are ignored and every replica is contacted.
+*/
+func SetHintStore(hints *HintStore) CoordinatorConfigFn {
+	return func(config *coordinatorConfig) {
+		config.Hints = hints
+	}
+}
+
+/*
+Coordinator issues quorum reads and writes for keys placed by a HashRing. Fields:
+  - config: Quorum sizes, timeout and hint store
+  - ring: Ring the replicas of a key are looked up on
+  - transport: Transport used to reach the replicas
+*/
+type Coordinator struct {
+	config    coordinatorConfig
+	ring      *hashring.HashRing
+	transport Transport
+}
+
+/*
+CoordinatorInit creates a Coordinator for ring that reaches replicas through transport.
+Returns ErrInvalidQuorum when the configured quorum sizes are out of range.
+*/
+func CoordinatorInit(ring *hashring.HashRing, transport Transport, opts ...CoordinatorConfigFn) (*Coordinator, error) {
+	config := &coordinatorConfig{
+		Replicas:       3,
+		WriteQuorum:    2,
+		ReadQuorum:     2,
+		ReplicaTimeout: 10 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.Replicas < 1 || config.WriteQuorum < 1 || config.WriteQuorum > config.Replicas ||
+		config.ReadQuorum < 1 || config.ReadQuorum > config.Replicas {
+		return nil, fmt.Errorf("%w: N=%d W=%d R=%d", ErrInvalidQuorum, config.Replicas, config.WriteQuorum, config.ReadQuorum)
+	}
+	return &Coordinator{config: *config, ring: ring, transport: transport}, nil
+}
+
+// Replicas returns the nodes holding key, its owner first
+func (c *Coordinator) Replicas(key string) ([]hashring.CacheNode, error) {
+	return c.ring.GetNodes(key, c.config.Replicas)
+}
+
+/*
+target is one node a read or write goes to. owner is the replica the node stands in for
+when the replica is down, nil when the node is a replica itself.
+*/
+type target struct {
+	node  hashring.CacheNode
+	owner hashring.CacheNode
+}
+
+/*
+targets returns the nodes a read or write of key goes to and checks that there are enough
+of them to reach a quorum of size quorum. Without a hint store these are the replicas;
+with one, every replica that is down is replaced by the next node clockwise past the
+replicas that is up, as long as there are such nodes.
+*/
+func (c *Coordinator) targets(key string, quorum int) ([]target, error) {
+	var targets []target
+	if c.config.Hints == nil {
+		nodes, err := c.Replicas(key)
+		if err != nil {
+			return nil, err
+		}
+		for _, node := range nodes {
+			targets = append(targets, target{node: node})
+		}
+	} else {
+		nodes, err := c.ring.GetNodes(key, math.MaxInt)
+		if err != nil {
+			return nil, err
+		}
+		replicas := nodes[:min(c.config.Replicas, len(nodes))]
+		spares := nodes[len(replicas):]
+		for _, replica := range replicas {
+			if c.up(replica) {
+				targets = append(targets, target{node: replica})
+				continue
+			}
+			for len(spares) > 0 {
+				spare := spares[0]
+				spares = spares[1:]
+				if c.up(spare) {
+					targets = append(targets, target{node: spare, owner: replica})
+					break
+				}
+			}
+		}
+	}
+
+	if len(targets) < quorum {
+		return nil, fmt.Errorf("%w: %d nodes, quorum is %d", ErrNotEnoughReplicas, len(targets), quorum)
+	}
+	return targets, nil
+}
+
+// up reports whether node is not marked down on the ring
+func (c *Coordinator) up(node hashring.CacheNode) bool {
+	state, err := c.ring.NodeState(node.GetIdentifier())
+	return err == nil && state != hashring.StateDown
+}
+
+// withTimeout applies the configured timeout to ctx
+func (c *Coordinator) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.config.Timeout > 0 {
+		return context.WithTimeout(ctx, c.config.Timeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+/*
+Put writes value under key to every replica and returns once W of them acknowledged the
+write. Writes still in flight when Put returns carry on in the background, detached from
+ctx and bounded by the replica timeout, so every reachable replica gets the value. Returns
+ErrQuorumNotReached, joined with the replica errors, when too many replicas failed or ctx
+ended before W acks.
+*/
+func (c *Coordinator) Put(ctx context.Context, key string, value Value) error {
+	nodes, err := c.targets(key, c.config.WriteQuorum)
+	if err != nil {
+		return err
+	}
+	waitCtx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	// The replica writes keep the caller's values but not its cancellation
+	writeCtx, cancelWrites := context.WithTimeout(context.WithoutCancel(ctx), c.config.ReplicaTimeout)
+	var wg sync.WaitGroup
+	wg.Add(len(nodes))
+	go func() {
+		wg.Wait()
+		cancelWrites()
+	}()
+
+	results := make(chan error, len(nodes))
+	for _, t := range nodes {
+		go func() {
+			defer wg.Done()
+			if err := c.transport.Put(writeCtx, t.node, key, value); err != nil {
+				results <- fmt.Errorf("%s: %w", t.node.GetIdentifier(), err)
+				return
+			}
+			// A handed-off write only counts once its hint is stored
+			if t.owner != nil {
+				hint := Hint{Owner: t.owner.GetIdentifier(), StandIn: t.node.GetIdentifier(), Key: key, Value: value}
+				if err := c.config.Hints.Add(hint); err != nil {
+					results <- fmt.Errorf("hint for %s: %w", t.owner.GetIdentifier(), err)
+					return
+				}
+			}
+			results <- nil
+		}()
+	}
+
+	acks := 0
+	var errs []error
+	for range nodes {
+		select {
+		case err := <-results:
+			if err != nil {
+				errs = append(errs, err)
+			} else if acks++; acks >= c.config.WriteQuorum {
+				return nil
+			}
+			if len(nodes)-len(errs) < c.config.WriteQuorum {
+				return quorumError("write", acks, c.config.WriteQuorum, errs)
+			}
+		case <-waitCtx.Done():
+			return quorumError("write", acks, c.config.WriteQuorum, append(errs, waitCtx.Err()))
+		}
+	}
+	return quorumError("write", acks, c.config.WriteQuorum, errs)
+}
+
+// readResult is the answer of one replica to a read
+type readResult struct {
+	value Value
+	found bool
+	err   error
+}
+
+/*
+Get reads key from every replica and, once R of them answered, returns the value with the
+highest version among the answers. Returns ErrNotFound when none of the R replicas has the
+key and ErrQuorumNotReached, joined with the replica errors, when too many replicas failed
+or ctx ended before R answers.
+*/
+func (c *Coordinator) Get(ctx context.Context, key string) (Value, error) {
+	nodes, err := c.targets(key, c.config.ReadQuorum)
+	if err != nil {
+		return Value{}, err
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	results := make(chan readResult, len(nodes))
+	for _, t := range nodes {
+		go func() {
+			value, found, err := c.transport.Get(ctx, t.node, key)
+			if err != nil {
+				err = fmt.Errorf("%s: %w", t.node.GetIdentifier(), err)
+			}
+			results <- readResult{value: value, found: found, err: err}
+		}()
+	}
+
+	answers := 0
+	var freshest Value
+	found := false
+	var errs []error
+	for range nodes {
+		select {
+		case result := <-results:
+			if result.err != nil {
+				errs = append(errs, result.err)
+				if len(nodes)-len(errs) < c.config.ReadQuorum {
+					return Value{}, quorumError("read", answers, c.config.ReadQuorum, errs)
+				}
+				continue
+			}
+			if result.found && (!found || result.value.Version > freshest.Version) {
+				freshest, found = result.value, true
+			}
+			if answers++; answers >= c.config.ReadQuorum {
+				if !found {
+					return Value{}, fmt.Errorf("%w: %s", ErrNotFound, key)
+				}
+				return freshest, nil
+			}
+		case <-ctx.Done():
+			return Value{}, quorumError("read", answers, c.config.ReadQuorum, append(errs, ctx.Err()))
+		}
+	}
+	return Value{}, quorumError("read", answers, c.config.ReadQuorum, errs)
+}
+
+// quorumError reports a failed quorum together with the errors that caused it
+func quorumError(op string, got, want int, errs []error) error {
+	return fmt.Errorf("%w: %s got %d of %d: %w", ErrQuorumNotReached, op, got, want, errors.Join(errs...))
+}
+
+/*
+ReplayHints delivers the pending hints whose owners are back up and removes them once
+delivered, deleting the handed-off copy from the stand-in unless it has since become a
+replica of the key. Hints for nodes that are no longer a replica of their key, because
+they left the ring or the ring changed around them, are dropped: the key now belongs to
+other replicas. Returns the number of hints delivered and the delivery errors; failed
+hints, including those whose stand-in could not drop its copy, stay pending for the next
+replay.
+*/
+func (c *Coordinator) ReplayHints(ctx context.Context) (int, error) {
+	if c.config.Hints == nil {
+		return 0, nil
+	}
+
+	delivered := 0
+	var errs []error
+	for _, hint := range c.config.Hints.Hints() {
+		replicas, err := c.Replicas(hint.Key)
+		if err != nil {
+			return delivered, err
+		}
+		i := slices.IndexFunc(replicas, func(node hashring.CacheNode) bool {
+			return node.GetIdentifier() == hint.Owner
+		})
+		if i < 0 {
+			if err := c.config.Hints.Remove(hint); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		if !c.up(replicas[i]) {
+			continue
+		}
+
+		if err := c.putWithTimeout(ctx, replicas[i], hint); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hint.Owner, err))
+			continue
+		}
+		if err := c.dropHandedOff(ctx, replicas, hint); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", hint.StandIn, err))
+			continue
+		}
+		if err := c.config.Hints.Remove(hint); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		delivered++
+	}
+	return delivered, errors.Join(errs...)
+}
+
+// putWithTimeout delivers one hint to its owner under the configured timeout
+func (c *Coordinator) putWithTimeout(ctx context.Context, owner hashring.CacheNode, hint Hint) error {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.transport.Put(ctx, owner, hint.Key, hint.Value)
+}
+
+/*
+dropHandedOff deletes the copy of a delivered hint from its stand-in under the configured
+timeout. Nothing is deleted when the stand-in left the ring or is now one of replicas, the
+replicas of the key, since its copy is then a real replica.
+*/
+func (c *Coordinator) dropHandedOff(ctx context.Context, replicas []hashring.CacheNode, hint Hint) error {
+	if hint.StandIn == "" || slices.ContainsFunc(replicas, func(node hashring.CacheNode) bool {
+		return node.GetIdentifier() == hint.StandIn
+	}) {
+		return nil
+	}
+	members, _ := c.ring.Members()
+	i := slices.IndexFunc(members, func(member hashring.Member) bool {
+		return member.Node.GetIdentifier() == hint.StandIn
+	})
+	if i < 0 {
+		return nil
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	return c.transport.Delete(ctx, members[i].Node, hint.Key, hint.Value.Version)
+}
+
+/*
+RunHintReplay calls ReplayHints every interval until ctx is done, which is how a
+long-running coordinator delivers hints after an outage without the caller polling.
+*/
+func (c *Coordinator) RunHintReplay(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.ReplayHints(ctx)
+		}
+	}
+}
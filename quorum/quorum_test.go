@@ -0,0 +1,365 @@
+package quorum
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+testNode is a test implementation of the CacheNode interface. It only carries the
+identifier the ring hashes to place the node.
+*/
+type testNode struct {
+	id string
+}
+
+func (n *testNode) GetIdentifier() string {
+	return n.id
+}
+
+/*
+nodeIDs name the nodes setup puts on the ring, in order. FNV-1a places node-0, node-1 and
+so on next to each other, which would leave one node with nearly the whole ring; every
+prefix of this list gives each node at least a tenth of it. outsideID is never on the ring.
+*/
+var nodeIDs = []string{"alder", "birch", "cypress", "sycamore", "banyan"}
+
+const outsideID = "rowan"
+
+// setup returns a coordinator over count nodes backed by a MemoryTransport
+func setup(t *testing.T, count int, opts ...CoordinatorConfigFn) (*Coordinator, *MemoryTransport) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	for i := 0; i < count; i++ {
+		ring.AddNode(&testNode{id: nodeIDs[i]})
+	}
+	transport := MemoryTransportInit()
+	c, err := CoordinatorInit(ring, transport, opts...)
+	if err != nil {
+		t.Fatalf("CoordinatorInit failed: %v", err)
+	}
+	return c, transport
+}
+
+// replicaIDs returns the identifiers of the replicas of key
+func replicaIDs(t *testing.T, c *Coordinator, key string) []string {
+	t.Helper()
+	nodes, err := c.Replicas(key)
+	if err != nil {
+		t.Fatalf("Replicas failed: %v", err)
+	}
+	ids := make([]string, len(nodes))
+	for i, node := range nodes {
+		ids[i] = node.GetIdentifier()
+	}
+	return ids
+}
+
+/*
+TestCoordinator tests quorum reads and writes over the in-memory transport. It verifies
+that writes and reads survive one replica failure with N=3, W=2, R=2 but not two, that a
+read returns the freshest version among the replicas, that a write does not wait for a
+slow replica once it has a quorum, and that context deadlines and invalid configurations
+are reported.
+*/
+func TestCoordinator(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("write then read", func(t *testing.T) {
+		c, transport := setup(t, 5)
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		value, err := c.Get(ctx, "user:42")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if string(value.Data) != "alice" || value.Version != 1 {
+			t.Errorf("Unexpected value %+v", value)
+		}
+
+		stored := 0
+		for _, id := range replicaIDs(t, c, "user:42") {
+			if _, ok := transport.Stored(id, "user:42"); ok {
+				stored++
+			}
+		}
+		if stored < 2 {
+			t.Errorf("Expected at least W=2 replicas to hold the value, got %d", stored)
+		}
+	})
+
+	t.Run("one replica down", func(t *testing.T) {
+		c, transport := setup(t, 5)
+		transport.SetDown(replicaIDs(t, c, "user:42")[0], true)
+
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if _, err := c.Get(ctx, "user:42"); err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+	})
+
+	t.Run("two replicas down", func(t *testing.T) {
+		c, transport := setup(t, 5)
+		ids := replicaIDs(t, c, "user:42")
+		transport.SetDown(ids[0], true)
+		transport.SetDown(ids[2], true)
+
+		err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+		if !errors.Is(err, ErrQuorumNotReached) || !errors.Is(err, ErrNodeDown) {
+			t.Errorf("Expected ErrQuorumNotReached caused by ErrNodeDown, got %v", err)
+		}
+		if _, err := c.Get(ctx, "user:42"); !errors.Is(err, ErrQuorumNotReached) {
+			t.Errorf("Expected ErrQuorumNotReached, got %v", err)
+		}
+	})
+
+	t.Run("freshest version wins", func(t *testing.T) {
+		c, transport := setup(t, 5, SetQuorum(3, 3, 3))
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("old"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		// Only the last replica sees the newer write
+		ids := replicaIDs(t, c, "user:42")
+		transport.Put(ctx, &testNode{id: ids[2]}, "user:42", Value{Data: []byte("new"), Version: 2})
+
+		value, err := c.Get(ctx, "user:42")
+		if err != nil {
+			t.Fatalf("Get failed: %v", err)
+		}
+		if string(value.Data) != "new" {
+			t.Errorf("Expected the freshest value, got %q", value.Data)
+		}
+	})
+
+	t.Run("missing key", func(t *testing.T) {
+		c, _ := setup(t, 3)
+		if _, err := c.Get(ctx, "nobody"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected ErrNotFound, got %v", err)
+		}
+	})
+
+	t.Run("write does not wait for a slow replica", func(t *testing.T) {
+		c, transport := setup(t, 5)
+		transport.SetDelay(replicaIDs(t, c, "user:42")[1], time.Second)
+
+		start := time.Now()
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected Put to return once W replicas acked, took %v", elapsed)
+		}
+	})
+
+	t.Run("slow replica still gets the write", func(t *testing.T) {
+		c, transport := setup(t, 5)
+		ids := replicaIDs(t, c, "user:42")
+		transport.SetDelay(ids[2], 100*time.Millisecond)
+
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		if _, ok := transport.Stored(ids[2], "user:42"); ok {
+			t.Fatal("Expected Put to return before the slow replica stored the value")
+		}
+
+		// The write keeps going after Put returned until every replica holds it
+		deadline := time.Now().Add(2 * time.Second)
+		for _, id := range ids {
+			for {
+				if _, ok := transport.Stored(id, "user:42"); ok {
+					break
+				}
+				if time.Now().After(deadline) {
+					t.Fatalf("Replica %s never received the write", id)
+				}
+				time.Sleep(10 * time.Millisecond)
+			}
+		}
+	})
+
+	t.Run("deadline", func(t *testing.T) {
+		c, transport := setup(t, 3, SetTimeout(50*time.Millisecond))
+		for _, id := range replicaIDs(t, c, "user:42") {
+			transport.SetDelay(id, time.Second)
+		}
+
+		err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+		if !errors.Is(err, ErrQuorumNotReached) || !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected ErrQuorumNotReached caused by the deadline, got %v", err)
+		}
+
+		callCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+		defer cancel()
+		if _, err := c.Get(callCtx, "user:42"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the caller's deadline to end Get, got %v", err)
+		}
+	})
+
+	t.Run("not enough nodes", func(t *testing.T) {
+		c, _ := setup(t, 1)
+		if err := c.Put(ctx, "user:42", Value{Version: 1}); !errors.Is(err, ErrNotEnoughReplicas) {
+			t.Errorf("Expected ErrNotEnoughReplicas, got %v", err)
+		}
+	})
+
+	t.Run("invalid quorum", func(t *testing.T) {
+		for _, q := range [][3]int{{3, 4, 2}, {3, 2, 0}, {0, 0, 0}} {
+			if _, err := CoordinatorInit(hashring.HashRingInit(), MemoryTransportInit(), SetQuorum(q[0], q[1], q[2])); !errors.Is(err, ErrInvalidQuorum) {
+				t.Errorf("Expected ErrInvalidQuorum for %v, got %v", q, err)
+			}
+		}
+	})
+}
+
+/*
+TestHintedHandoff tests hinted handoff. It verifies that a write whose owner is marked down
+goes to the next healthy node with a hint, that reads find it there, that the hint is
+replayed to the owner once it is back and not before, that the stand-in then drops its
+copy unless it holds a newer write, that hints for owners that left the ring are dropped,
+and that a full hint store fails the handed-off write.
+*/
+func TestHintedHandoff(t *testing.T) {
+	ctx := context.Background()
+
+	// setupHandoff returns a coordinator with hinted handoff whose key owner is down
+	setupHandoff := func(t *testing.T, maxHints int, opts ...CoordinatorConfigFn) (*Coordinator, *MemoryTransport, *HintStore, *hashring.HashRing, []hashring.CacheNode) {
+		t.Helper()
+		hints, err := HintStoreInit(filepath.Join(t.TempDir(), "hints.json"), maxHints, 1<<20)
+		if err != nil {
+			t.Fatalf("HintStoreInit failed: %v", err)
+		}
+		c, transport := setup(t, 5, append([]CoordinatorConfigFn{SetHintStore(hints)}, opts...)...)
+		nodes, _ := c.ring.GetNodes("user:42", 5)
+		c.ring.SetNodeState(nodes[0].GetIdentifier(), hashring.StateDown)
+		transport.SetDown(nodes[0].GetIdentifier(), true)
+		return c, transport, hints, c.ring, nodes
+	}
+
+	t.Run("write is handed off and replayed", func(t *testing.T) {
+		c, transport, hints, ring, nodes := setupHandoff(t, 10, SetQuorum(3, 3, 3))
+		if err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1}); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+
+		// The first node past the replicas holds the write, with a hint for the owner
+		if _, ok := transport.Stored(nodes[3].GetIdentifier(), "user:42"); !ok {
+			t.Error("Expected the stand-in to hold the write")
+		}
+		if got := hints.Hints(); len(got) != 1 || got[0].Owner != nodes[0].GetIdentifier() {
+			t.Fatalf("Expected one hint for the owner, got %+v", got)
+		}
+		if value, err := c.Get(ctx, "user:42"); err != nil || string(value.Data) != "alice" {
+			t.Errorf("Expected to read the handed-off write, got %+v, %v", value, err)
+		}
+
+		// Nothing is replayed while the owner is down
+		if delivered, _ := c.ReplayHints(ctx); delivered != 0 || hints.Len() != 1 {
+			t.Errorf("Expected no replay to a down owner, delivered %d", delivered)
+		}
+
+		ring.SetNodeState(nodes[0].GetIdentifier(), hashring.StateActive)
+		transport.SetDown(nodes[0].GetIdentifier(), false)
+		delivered, err := c.ReplayHints(ctx)
+		if err != nil || delivered != 1 {
+			t.Fatalf("Expected one hint delivered, got %d, %v", delivered, err)
+		}
+		if value, ok := transport.Stored(nodes[0].GetIdentifier(), "user:42"); !ok || string(value.Data) != "alice" {
+			t.Error("Expected the owner to hold the write after replay")
+		}
+		if hints.Len() != 0 {
+			t.Errorf("Expected the hint to be removed, %d left", hints.Len())
+		}
+		if _, ok := transport.Stored(nodes[3].GetIdentifier(), "user:42"); ok {
+			t.Error("Expected the stand-in to drop its copy after replay")
+		}
+	})
+
+	t.Run("stand-in keeps a newer write", func(t *testing.T) {
+		c, transport, hints, ring, nodes := setupHandoff(t, 10, SetQuorum(3, 3, 3))
+		c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+		transport.Put(ctx, nodes[3], "user:42", Value{Data: []byte("bob"), Version: 2})
+
+		ring.SetNodeState(nodes[0].GetIdentifier(), hashring.StateActive)
+		transport.SetDown(nodes[0].GetIdentifier(), false)
+		if delivered, err := c.ReplayHints(ctx); err != nil || delivered != 1 {
+			t.Fatalf("Expected one hint delivered, got %d, %v", delivered, err)
+		}
+		if value, ok := transport.Stored(nodes[3].GetIdentifier(), "user:42"); !ok || value.Version != 2 {
+			t.Errorf("Expected the stand-in to keep the newer write, got %+v, %v", value, ok)
+		}
+		if hints.Len() != 0 {
+			t.Errorf("Expected the hint to be removed, %d left", hints.Len())
+		}
+	})
+
+	t.Run("unreachable stand-in keeps the hint", func(t *testing.T) {
+		c, transport, hints, ring, nodes := setupHandoff(t, 10, SetQuorum(3, 3, 3))
+		c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+
+		ring.SetNodeState(nodes[0].GetIdentifier(), hashring.StateActive)
+		transport.SetDown(nodes[0].GetIdentifier(), false)
+		transport.SetDown(nodes[3].GetIdentifier(), true)
+		if _, err := c.ReplayHints(ctx); !errors.Is(err, ErrNodeDown) {
+			t.Errorf("Expected the delete error, got %v", err)
+		}
+		if hints.Len() != 1 {
+			t.Errorf("Expected the hint to stay pending, got %d", hints.Len())
+		}
+
+		transport.SetDown(nodes[3].GetIdentifier(), false)
+		if delivered, err := c.ReplayHints(ctx); err != nil || delivered != 1 {
+			t.Errorf("Expected the retry to deliver the hint, got %d, %v", delivered, err)
+		}
+		if _, ok := transport.Stored(nodes[3].GetIdentifier(), "user:42"); ok {
+			t.Error("Expected the stand-in to drop its copy on the retry")
+		}
+	})
+
+	t.Run("failed replay keeps the hint", func(t *testing.T) {
+		c, _, hints, ring, nodes := setupHandoff(t, 10)
+		c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+
+		// Marked up on the ring but still unreachable
+		ring.SetNodeState(nodes[0].GetIdentifier(), hashring.StateActive)
+		if _, err := c.ReplayHints(ctx); !errors.Is(err, ErrNodeDown) {
+			t.Errorf("Expected the delivery error, got %v", err)
+		}
+		if hints.Len() != 1 {
+			t.Errorf("Expected the hint to stay pending, got %d", hints.Len())
+		}
+	})
+
+	t.Run("hints for removed owners are dropped", func(t *testing.T) {
+		c, _, hints, ring, nodes := setupHandoff(t, 10)
+		c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+
+		ring.RemoveNode(nodes[0])
+		if delivered, err := c.ReplayHints(ctx); err != nil || delivered != 0 {
+			t.Errorf("Expected nothing delivered, got %d, %v", delivered, err)
+		}
+		if hints.Len() != 0 {
+			t.Errorf("Expected the hint to be dropped, %d left", hints.Len())
+		}
+	})
+
+	t.Run("full hint store fails the handed-off write", func(t *testing.T) {
+		c, _, hints, _, _ := setupHandoff(t, 1, SetQuorum(3, 3, 3))
+		hints.Add(Hint{Owner: outsideID, Key: "other", Value: Value{Version: 1}})
+		err := c.Put(ctx, "user:42", Value{Data: []byte("alice"), Version: 1})
+		if !errors.Is(err, ErrQuorumNotReached) || !errors.Is(err, ErrHintStoreFull) {
+			t.Errorf("Expected ErrQuorumNotReached caused by ErrHintStoreFull, got %v", err)
+		}
+		if hints.Len() != 1 {
+			t.Errorf("Expected only the earlier hint, got %d", hints.Len())
+		}
+	})
+}
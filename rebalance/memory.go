@@ -0,0 +1,110 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package rebalance
+
+import (
+	"context"
+	"sync"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+MemorySource is an in-memory DataSource for tests and examples, holding a map of values
+per node identifier. Fields:
+  - mu: Mutex guarding data
+  - data: Values keyed by node identifier and then by key
+*/
+type MemorySource struct {
+	mu   sync.Mutex
+	data map[string]map[string][]byte
+}
+
+// MemorySourceInit creates an empty MemorySource
+func MemorySourceInit() *MemorySource {
+	return &MemorySource{data: make(map[string]map[string][]byte)}
+}
+
+// Set stores value under key on the node with the given identifier
+func (s *MemorySource) Set(id, key string, value []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data[id] == nil {
+		s.data[id] = make(map[string][]byte)
+	}
+	s.data[id][key] = value
+}
+
+// Len returns the number of keys held by the node with the given identifier
+func (s *MemorySource) Len(id string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.data[id])
+}
+
+func (s *MemorySource) Scan(ctx context.Context, node hashring.CacheNode, fn func(key string, value []byte) error) error {
+	// Copy first so fn can call back into the source
+	s.mu.Lock()
+	held := make(map[string][]byte, len(s.data[node.GetIdentifier()]))
+	for key, value := range s.data[node.GetIdentifier()] {
+		held[key] = value
+	}
+	s.mu.Unlock()
+
+	for key, value := range held {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemorySource) Get(ctx context.Context, node hashring.CacheNode, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, ok := s.data[node.GetIdentifier()][key]
+	return value, ok, nil
+}
+
+func (s *MemorySource) PutIfAbsent(ctx context.Context, node hashring.CacheNode, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := node.GetIdentifier()
+	if s.data[id] == nil {
+		s.data[id] = make(map[string][]byte)
+	}
+	if _, ok := s.data[id][key]; !ok {
+		s.data[id][key] = value
+	}
+	return nil
+}
+
+func (s *MemorySource) Delete(ctx context.Context, node hashring.CacheNode, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.data[node.GetIdentifier()], key)
+	return nil
+}
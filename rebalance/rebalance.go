@@ -0,0 +1,396 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package rebalance moves data when the membership of a HashRing changes, so that a new node
+does not start out missing every key it now owns. A Rebalancer applies the change to the
+ring right away and diffs the ring's ranges before and after it; the keys of every moved
+range are then streamed from the previous owner to the new one. Until the migration is cut
+over, a read that misses on the new owner falls back to the previous owner, so clients see
+the data throughout. Deletes made through the Rebalancer during a migration are remembered,
+so the key is not copied back from the previous owner. Data is reached through a
+DataSource, which makes the package work with any storage.
+*/
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// changeAttempts is how often a change is retried when the ring changes under it
+const changeAttempts = 5
+
+// Global error variables which has all error types to return
+var (
+	ErrNotStreamed  = errors.New("Migration has not finished streaming")
+	ErrCutOver      = errors.New("Migration is already cut over")
+	ErrRingChanging = errors.New("Ring kept changing during the membership change")
+)
+
+/*
+DataSource reaches the data held by the nodes of the ring. Implementations must be safe
+for concurrent use.
+*/
+type DataSource interface {
+	// Scan calls fn for every key held by node, stopping at the first error fn returns
+	Scan(ctx context.Context, node hashring.CacheNode, fn func(key string, value []byte) error) error
+	// Get returns the value of key on node, found is false when node does not hold it
+	Get(ctx context.Context, node hashring.CacheNode, key string) (value []byte, found bool, err error)
+	// PutIfAbsent stores value under key on node unless node already holds the key
+	PutIfAbsent(ctx context.Context, node hashring.CacheNode, key string, value []byte) error
+	// Delete removes key from node
+	Delete(ctx context.Context, node hashring.CacheNode, key string) error
+}
+
+type rebalancerConfig struct {
+	DeleteAfterCutover bool
+}
+
+// RebalancerConfigFn is a function type that modifies the rebalancerConfig
+type RebalancerConfigFn func(*rebalancerConfig)
+
+/*
+SetDeleteAfterCutover returns a RebalancerConfigFn that makes Cutover delete the streamed
+keys from their previous owners, freeing the space they no longer need. It is off by
+default, leaving the stale copies to expire or be evicted.
+*/
+func SetDeleteAfterCutover(enabled bool) RebalancerConfigFn {
+	return func(config *rebalancerConfig) {
+		config.DeleteAfterCutover = enabled
+	}
+}
+
+/*
+Rebalancer applies membership changes to a ring together with the data migrations they
+need. Fields:
+  - mu: Read-write mutex guarding active
+  - config: Cutover settings
+  - ring: Ring the changes are applied to
+  - source: DataSource used to stream and read keys
+  - active: Migrations that are not cut over yet, oldest first
+*/
+type Rebalancer struct {
+	mu     sync.RWMutex
+	config rebalancerConfig
+	ring   *hashring.HashRing
+	source DataSource
+	active []*Migration
+}
+
+// RebalancerInit creates a Rebalancer for ring reaching data through source
+func RebalancerInit(ring *hashring.HashRing, source DataSource, opts ...RebalancerConfigFn) *Rebalancer {
+	config := &rebalancerConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Rebalancer{config: *config, ring: ring, source: source}
+}
+
+/*
+Migration is the data movement caused by one membership change. Fields:
+  - Moves: Arcs of the ring whose owner changed
+  - rebalancer: Rebalancer the migration is registered with
+  - mu: Mutex guarding the fields below
+  - streamed: Set once every moved key was copied
+  - cutOver: Set by Cutover
+  - copied: Keys copied so far with the node they came from
+  - deleted: Keys deleted through Rebalancer.Delete, never copied from the previous owner
+*/
+type Migration struct {
+	Moves []hashring.Move
+
+	rebalancer *Rebalancer
+	mu         sync.Mutex
+	streamed   bool
+	cutOver    bool
+	copied     []copiedKey
+	deleted    map[string]struct{}
+}
+
+// copiedKey is a key streamed off its previous owner
+type copiedKey struct {
+	key  string
+	from hashring.CacheNode
+}
+
+/*
+Join adds node to the ring and returns the migration that moves the keys it takes over
+to it. Reads through Get already fall back to the previous owners; call Stream and then
+Cutover, or Run, to complete the migration.
+*/
+func (r *Rebalancer) Join(node hashring.CacheNode) (*Migration, error) {
+	return r.change(hashring.Change{Op: hashring.MembershipAdd, Node: node})
+}
+
+/*
+Leave removes node from the ring and returns the migration that moves its keys to the
+nodes taking over its ranges. node must keep serving until the migration is cut over.
+*/
+func (r *Rebalancer) Leave(node hashring.CacheNode) (*Migration, error) {
+	return r.change(hashring.Change{Op: hashring.MembershipRemove, Node: node})
+}
+
+/*
+change applies one membership change and registers its migration. The ranges before the
+change are read at a known epoch and the change is applied with CompareAndApply at that
+epoch, so the diff describes exactly this change even when other writers touch the ring.
+*/
+func (r *Rebalancer) change(change hashring.Change) (*Migration, error) {
+	for attempt := 0; attempt < changeAttempts; attempt++ {
+		epoch := r.ring.Epoch()
+		before, err := r.ring.Ranges(1)
+		if err != nil && !errors.Is(err, hashring.ErrNoConnectedNodes) {
+			return nil, err
+		}
+		if r.ring.Epoch() != epoch {
+			continue
+		}
+
+		if _, err := r.ring.CompareAndApply(epoch, []hashring.Change{change}); err != nil {
+			if errors.Is(err, hashring.ErrEpochMismatch) {
+				continue
+			}
+			return nil, err
+		}
+		after, err := r.ring.Ranges(1)
+		if err != nil && !errors.Is(err, hashring.ErrNoConnectedNodes) {
+			return nil, err
+		}
+
+		m := &Migration{Moves: hashring.DiffRanges(before, after), rebalancer: r, deleted: make(map[string]struct{})}
+		r.mu.Lock()
+		r.active = append(r.active, m)
+		r.mu.Unlock()
+		return m, nil
+	}
+	return nil, ErrRingChanging
+}
+
+/*
+Stream copies every key of the moved ranges from its previous owner to its new owner.
+Keys the new owner already holds, because they were written after the change, are not
+overwritten, and keys deleted through Rebalancer.Delete since the change are not copied.
+Stream can be called again after an error to resume; keys already copied are skipped by
+the new owner.
+*/
+func (m *Migration) Stream(ctx context.Context) error {
+	r := m.rebalancer
+	var sources []hashring.CacheNode
+	for _, move := range m.Moves {
+		if !slices.ContainsFunc(sources, func(n hashring.CacheNode) bool { return n.GetIdentifier() == move.From.GetIdentifier() }) {
+			sources = append(sources, move.From)
+		}
+	}
+
+	var copied []copiedKey
+	for _, from := range sources {
+		err := r.source.Scan(ctx, from, func(key string, value []byte) error {
+			hash, err := r.ring.KeyHash(key)
+			if err != nil {
+				return err
+			}
+			for _, move := range m.Moves {
+				if move.From.GetIdentifier() != from.GetIdentifier() || !move.Contains(hash) {
+					continue
+				}
+				ok, err := m.copyUnlessDeleted(ctx, move.To, key, value)
+				if err != nil {
+					return fmt.Errorf("copying %s to %s: %w", key, move.To.GetIdentifier(), err)
+				}
+				if ok {
+					copied = append(copied, copiedKey{key: key, from: from})
+				}
+				break
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("streaming from %s: %w", from.GetIdentifier(), err)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.copied = copied
+	m.streamed = true
+	return nil
+}
+
+/*
+copyUnlessDeleted copies key to node unless it was deleted during the migration, and
+reports whether it did. The check and the copy happen under m.mu, so a concurrent Delete
+either sees the copy and removes it or is seen by the check.
+*/
+func (m *Migration) copyUnlessDeleted(ctx context.Context, node hashring.CacheNode, key string, value []byte) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.deleted[key]; ok {
+		return false, nil
+	}
+	return true, m.rebalancer.source.PutIfAbsent(ctx, node, key, value)
+}
+
+/*
+Cutover completes a streamed migration: reads stop falling back to the previous owners
+and, with SetDeleteAfterCutover, the streamed keys are deleted from them. Returns
+ErrNotStreamed when Stream has not completed.
+*/
+func (m *Migration) Cutover(ctx context.Context) error {
+	m.mu.Lock()
+	if m.cutOver {
+		m.mu.Unlock()
+		return ErrCutOver
+	}
+	if !m.streamed {
+		m.mu.Unlock()
+		return ErrNotStreamed
+	}
+	m.cutOver = true
+	copied := m.copied
+	m.mu.Unlock()
+
+	r := m.rebalancer
+	r.mu.Lock()
+	r.active = slices.DeleteFunc(r.active, func(active *Migration) bool { return active == m })
+	r.mu.Unlock()
+
+	if !r.config.DeleteAfterCutover {
+		return nil
+	}
+	var errs []error
+	for _, c := range copied {
+		if err := r.source.Delete(ctx, c.from, c.key); err != nil {
+			errs = append(errs, fmt.Errorf("deleting %s from %s: %w", c.key, c.from.GetIdentifier(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Run streams the migration and cuts it over
+func (m *Migration) Run(ctx context.Context) error {
+	if err := m.Stream(ctx); err != nil {
+		return err
+	}
+	return m.Cutover(ctx)
+}
+
+/*
+Get reads key from its owner. While a migration moving the key's range to the owner is
+active, a miss falls back to the previous owner, newest migration first, and a value found
+there is copied to the owner so the next read hits. A key deleted through Delete during the
+migration is not found.
+*/
+func (r *Rebalancer) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	owner, err := r.ring.GetNode(key)
+	if err != nil {
+		return nil, false, err
+	}
+	value, found, err := r.source.Get(ctx, owner, key)
+	if err != nil || found {
+		return value, found, err
+	}
+
+	hash, err := r.ring.KeyHash(key)
+	if err != nil {
+		return nil, false, err
+	}
+	r.mu.RLock()
+	active := slices.Clone(r.active)
+	r.mu.RUnlock()
+
+	for i := len(active) - 1; i >= 0; i-- {
+		for _, move := range active[i].Moves {
+			if !move.Contains(hash) || move.To.GetIdentifier() != owner.GetIdentifier() {
+				continue
+			}
+			value, found, err := r.source.Get(ctx, move.From, key)
+			if err != nil {
+				return nil, false, fmt.Errorf("falling back to %s: %w", move.From.GetIdentifier(), err)
+			}
+			if found {
+				if copied, _ := active[i].copyUnlessDeleted(ctx, owner, key, value); !copied {
+					return nil, false, nil
+				}
+				return value, true, nil
+			}
+		}
+	}
+	return nil, false, nil
+}
+
+/*
+Delete removes key from its owner. While migrations moving the key's range to the owner
+are active, the delete is recorded in each of them and the key is removed from the
+previous owners too, so neither Stream nor a falling back Get brings it back. Deletes
+that bypass the Rebalancer are not seen by migrations.
+*/
+func (r *Rebalancer) Delete(ctx context.Context, key string) error {
+	owner, err := r.ring.GetNode(key)
+	if err != nil {
+		return err
+	}
+	hash, err := r.ring.KeyHash(key)
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	active := slices.Clone(r.active)
+	r.mu.RUnlock()
+
+	// Record the delete before removing the key, so a copy racing with it is removed below
+	var previous []hashring.CacheNode
+	for _, m := range active {
+		for _, move := range m.Moves {
+			if !move.Contains(hash) || move.To.GetIdentifier() != owner.GetIdentifier() {
+				continue
+			}
+			m.mu.Lock()
+			m.deleted[key] = struct{}{}
+			m.mu.Unlock()
+			previous = append(previous, move.From)
+		}
+	}
+
+	if err := r.source.Delete(ctx, owner, key); err != nil {
+		return err
+	}
+	var errs []error
+	for _, from := range previous {
+		if err := r.source.Delete(ctx, from, key); err != nil {
+			errs = append(errs, fmt.Errorf("deleting %s from %s: %w", key, from.GetIdentifier(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Active returns the migrations that are not cut over yet, oldest first
+func (r *Rebalancer) Active() []*Migration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return slices.Clone(r.active)
+}
@@ -0,0 +1,244 @@
+package rebalance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+testNode is a test implementation of the CacheNode interface, standing in for the nodes
+that join and leave during a migration.
+*/
+type testNode struct {
+	id string
+}
+
+func (n *testNode) GetIdentifier() string {
+	return n.id
+}
+
+/*
+nodeIDs are the nodes setup starts with and joinID the one the tests add. Each node has a
+single position on the ring, and the names are picked so that every node of every prefix
+of the list, and joinID once it joins, owns a tenth of the ring or more.
+*/
+var nodeIDs = []string{"alder", "birch", "cypress", "sycamore", "banyan"}
+
+const (
+	joinID   = "rowan"
+	keyCount = 1000
+)
+
+// setup returns a ring of count nodes with keyCount keys stored on their owners
+func setup(t *testing.T, count int, opts ...RebalancerConfigFn) (*Rebalancer, *hashring.HashRing, *MemorySource) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	for i := 0; i < count; i++ {
+		ring.AddNode(&testNode{id: nodeIDs[i]})
+	}
+	source := MemorySourceInit()
+	for i := 0; i < keyCount; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		owner, _ := ring.GetNode(key)
+		source.Set(owner.GetIdentifier(), key, []byte(key))
+	}
+	return RebalancerInit(ring, source, opts...), ring, source
+}
+
+// checkReads verifies that every key but the skipped ones reads back through the rebalancer
+func checkReads(t *testing.T, r *Rebalancer, skip ...string) {
+	t.Helper()
+	for i := 0; i < keyCount; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		if slices.Contains(skip, key) {
+			continue
+		}
+		value, found, err := r.Get(context.Background(), key)
+		if err != nil || !found || string(value) != key {
+			t.Fatalf("Expected %s to read back, got %q, %v, %v", key, value, found, err)
+		}
+	}
+}
+
+// owned counts the keys the node with the given identifier owns on ring
+func owned(ring *hashring.HashRing, id string) int {
+	n := 0
+	for i := 0; i < keyCount; i++ {
+		if owner, _ := ring.GetNode(fmt.Sprintf("key-%d", i)); owner.GetIdentifier() == id {
+			n++
+		}
+	}
+	return n
+}
+
+/*
+TestRebalancer tests live rebalancing. It verifies that reads keep working through a join
+and a leave, falling back to the previous owner until the data is streamed, that streaming
+copies exactly the moved keys without clobbering newer writes, that a key deleted during a
+migration stays deleted, and that cutover stops the fallback and deletes the stale copies
+when asked to.
+*/
+func TestRebalancer(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("join", func(t *testing.T) {
+		r, ring, source := setup(t, 4, SetDeleteAfterCutover(true))
+		newNode := &testNode{id: joinID}
+		m, err := r.Join(newNode)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		want := owned(ring, newNode.id)
+		if want == 0 || len(m.Moves) == 0 {
+			t.Fatal("Expected the new node to take over some keys")
+		}
+
+		// A write after the join lands on the new owner and must survive streaming
+		var fresh string
+		for i := 0; i < keyCount; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if owner, _ := ring.GetNode(key); owner.GetIdentifier() == newNode.id {
+				fresh = key
+				source.Set(newNode.id, key, []byte("fresh"))
+				break
+			}
+		}
+
+		// Before streaming, the new node misses and reads fall back
+		if source.Len(newNode.id) != 1 {
+			t.Fatalf("Expected only the fresh write on the new node, got %d keys", source.Len(newNode.id))
+		}
+		if value, _, _ := r.Get(ctx, fresh); string(value) != "fresh" {
+			t.Errorf("Expected the fresh write, got %q", value)
+		}
+
+		if err := m.Stream(ctx); err != nil {
+			t.Fatalf("Stream failed: %v", err)
+		}
+		if got := source.Len(newNode.id); got != want {
+			t.Errorf("Expected the new node to hold its %d keys, got %d", want, got)
+		}
+		if value, _, _ := source.Get(ctx, newNode, fresh); string(value) != "fresh" {
+			t.Errorf("Expected streaming to keep the fresh write, got %q", value)
+		}
+
+		if err := m.Cutover(ctx); err != nil {
+			t.Fatalf("Cutover failed: %v", err)
+		}
+		if len(r.Active()) != 0 {
+			t.Error("Expected no active migrations after cutover")
+		}
+		total := 0
+		for i := 0; i < 4; i++ {
+			total += source.Len(nodeIDs[i])
+		}
+		if total+want != keyCount {
+			t.Errorf("Expected the moved keys to be deleted from their old owners, %d keys left", total)
+		}
+		checkReads(t, r, fresh)
+	})
+
+	t.Run("reads fall back during migration", func(t *testing.T) {
+		r, _, _ := setup(t, 4)
+		if _, err := r.Join(&testNode{id: joinID}); err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+		checkReads(t, r)
+	})
+
+	t.Run("delete during migration", func(t *testing.T) {
+		r, ring, source := setup(t, 4, SetDeleteAfterCutover(true))
+		newNode := &testNode{id: joinID}
+		m, err := r.Join(newNode)
+		if err != nil {
+			t.Fatalf("Join failed: %v", err)
+		}
+
+		// Two moved keys: one deleted before any read, one read (and copied) first
+		var moved []string
+		for i := 0; i < keyCount && len(moved) < 2; i++ {
+			key := fmt.Sprintf("key-%d", i)
+			if owner, _ := ring.GetNode(key); owner.GetIdentifier() == newNode.id {
+				moved = append(moved, key)
+			}
+		}
+		if _, found, _ := r.Get(ctx, moved[1]); !found {
+			t.Fatalf("Expected %s to be found through the fallback", moved[1])
+		}
+		for _, key := range moved {
+			if err := r.Delete(ctx, key); err != nil {
+				t.Fatalf("Delete failed: %v", err)
+			}
+			if _, found, _ := r.Get(ctx, key); found {
+				t.Errorf("Expected %s to stay deleted before streaming", key)
+			}
+		}
+
+		if err := m.Run(ctx); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		for _, key := range moved {
+			if _, found, _ := r.Get(ctx, key); found {
+				t.Errorf("Expected streaming not to bring %s back", key)
+			}
+		}
+		total := source.Len(newNode.id)
+		for i := 0; i < 4; i++ {
+			total += source.Len(nodeIDs[i])
+		}
+		if total != keyCount-2 {
+			t.Errorf("Expected %d keys left in total, got %d", keyCount-2, total)
+		}
+		checkReads(t, r, moved...)
+	})
+
+	t.Run("leave", func(t *testing.T) {
+		r, ring, source := setup(t, 4)
+		leaving := &testNode{id: nodeIDs[1]}
+		held := source.Len(leaving.id)
+		m, err := r.Leave(leaving)
+		if err != nil {
+			t.Fatalf("Leave failed: %v", err)
+		}
+		checkReads(t, r)
+
+		if err := m.Run(ctx); err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		// Without SetDeleteAfterCutover the leaving node keeps its copies
+		if source.Len(leaving.id) != held {
+			t.Errorf("Expected the leaving node to keep its %d keys, got %d", held, source.Len(leaving.id))
+		}
+		checkReads(t, r)
+		if _, err := ring.NodeState(leaving.id); !errors.Is(err, hashring.ErrNodeNotFound) {
+			t.Errorf("Expected the node to be gone from the ring, got %v", err)
+		}
+	})
+
+	t.Run("cutover order", func(t *testing.T) {
+		r, _, _ := setup(t, 2)
+		m, _ := r.Join(&testNode{id: joinID})
+		if err := m.Cutover(ctx); !errors.Is(err, ErrNotStreamed) {
+			t.Errorf("Expected ErrNotStreamed, got %v", err)
+		}
+		m.Run(ctx)
+		if err := m.Cutover(ctx); !errors.Is(err, ErrCutOver) {
+			t.Errorf("Expected ErrCutOver, got %v", err)
+		}
+	})
+
+	t.Run("invalid change", func(t *testing.T) {
+		r, _, _ := setup(t, 2)
+		if _, err := r.Join(&testNode{id: nodeIDs[0]}); !errors.Is(err, hashring.ErrNodeExits) {
+			t.Errorf("Expected ErrNodeExits, got %v", err)
+		}
+		if len(r.Active()) != 0 {
+			t.Error("Expected no migration for a rejected change")
+		}
+	})
+}
@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import hashring "github.com/atharvamhaske/chash/hash-ring"
+
+// keySpec describes which arguments of a command are keys
+type keySpec int
+
+const (
+	keysFirst      keySpec = iota // only the first argument
+	keysAll                       // every argument
+	keysFirstTwo                  // the first two arguments
+	keysEveryOther                // arguments 0, 2, 4, ... (key value pairs)
+	keysAllButLast                // every argument except the trailing timeout
+)
+
+// fanoutKind describes how a multi-key command is split when its keys live on several servers
+type fanoutKind int
+
+const (
+	fanoutNone fanoutKind = iota // keys must share one server, otherwise CROSSSLOT
+	fanoutMGet                   // split keys, merge array replies in request order
+	fanoutSum                    // split keys, add integer replies together
+	fanoutMSet                   // split key value pairs, reply OK when every server did
+)
+
+/*
+commandSpec tells the proxy how to route a command. Fields:
+  - keys: Which arguments are keys
+  - fanout: How to split the command when its keys span servers
+  - minArgs: Minimum number of arguments after the command name
+*/
+type commandSpec struct {
+	keys    keySpec
+	fanout  fanoutKind
+	minArgs int
+}
+
+/*
+commands lists every keyed command the proxy routes. Commands that have no key (KEYS,
+SCAN, FLUSHALL, ...) or encode their key count in an argument (EVAL, ZUNIONSTORE, ...)
+cannot be sharded and are rejected.
+*/
+var commands = map[string]commandSpec{
+	// Fan-out commands
+	"MGET":   {keysAll, fanoutMGet, 1},
+	"DEL":    {keysAll, fanoutSum, 1},
+	"UNLINK": {keysAll, fanoutSum, 1},
+	"EXISTS": {keysAll, fanoutSum, 1},
+	"TOUCH":  {keysAll, fanoutSum, 1},
+	"MSET":   {keysEveryOther, fanoutMSet, 2},
+
+	// Multi-key commands whose keys must co-locate
+	"MSETNX":      {keysEveryOther, fanoutNone, 2},
+	"RENAME":      {keysFirstTwo, fanoutNone, 2},
+	"RENAMENX":    {keysFirstTwo, fanoutNone, 2},
+	"COPY":        {keysFirstTwo, fanoutNone, 2},
+	"RPOPLPUSH":   {keysFirstTwo, fanoutNone, 2},
+	"LMOVE":       {keysFirstTwo, fanoutNone, 4},
+	"SMOVE":       {keysFirstTwo, fanoutNone, 3},
+	"SINTER":      {keysAll, fanoutNone, 1},
+	"SUNION":      {keysAll, fanoutNone, 1},
+	"SDIFF":       {keysAll, fanoutNone, 1},
+	"SINTERSTORE": {keysAll, fanoutNone, 2},
+	"SUNIONSTORE": {keysAll, fanoutNone, 2},
+	"SDIFFSTORE":  {keysAll, fanoutNone, 2},
+	"PFCOUNT":     {keysAll, fanoutNone, 1},
+	"PFMERGE":     {keysAll, fanoutNone, 1},
+	"BLPOP":       {keysAllButLast, fanoutNone, 2},
+	"BRPOP":       {keysAllButLast, fanoutNone, 2},
+
+	// Single-key commands
+	"GET":           {keysFirst, fanoutNone, 1},
+	"SET":           {keysFirst, fanoutNone, 2},
+	"SETNX":         {keysFirst, fanoutNone, 2},
+	"SETEX":         {keysFirst, fanoutNone, 3},
+	"PSETEX":        {keysFirst, fanoutNone, 3},
+	"GETSET":        {keysFirst, fanoutNone, 2},
+	"GETDEL":        {keysFirst, fanoutNone, 1},
+	"GETEX":         {keysFirst, fanoutNone, 1},
+	"APPEND":        {keysFirst, fanoutNone, 2},
+	"STRLEN":        {keysFirst, fanoutNone, 1},
+	"GETRANGE":      {keysFirst, fanoutNone, 3},
+	"SETRANGE":      {keysFirst, fanoutNone, 3},
+	"INCR":          {keysFirst, fanoutNone, 1},
+	"DECR":          {keysFirst, fanoutNone, 1},
+	"INCRBY":        {keysFirst, fanoutNone, 2},
+	"DECRBY":        {keysFirst, fanoutNone, 2},
+	"INCRBYFLOAT":   {keysFirst, fanoutNone, 2},
+	"GETBIT":        {keysFirst, fanoutNone, 2},
+	"SETBIT":        {keysFirst, fanoutNone, 3},
+	"BITCOUNT":      {keysFirst, fanoutNone, 1},
+	"EXPIRE":        {keysFirst, fanoutNone, 2},
+	"PEXPIRE":       {keysFirst, fanoutNone, 2},
+	"EXPIREAT":      {keysFirst, fanoutNone, 2},
+	"PEXPIREAT":     {keysFirst, fanoutNone, 2},
+	"TTL":           {keysFirst, fanoutNone, 1},
+	"PTTL":          {keysFirst, fanoutNone, 1},
+	"PERSIST":       {keysFirst, fanoutNone, 1},
+	"TYPE":          {keysFirst, fanoutNone, 1},
+	"DUMP":          {keysFirst, fanoutNone, 1},
+	"RESTORE":       {keysFirst, fanoutNone, 3},
+	"HGET":          {keysFirst, fanoutNone, 2},
+	"HSET":          {keysFirst, fanoutNone, 3},
+	"HSETNX":        {keysFirst, fanoutNone, 3},
+	"HMSET":         {keysFirst, fanoutNone, 3},
+	"HMGET":         {keysFirst, fanoutNone, 2},
+	"HDEL":          {keysFirst, fanoutNone, 2},
+	"HGETALL":       {keysFirst, fanoutNone, 1},
+	"HKEYS":         {keysFirst, fanoutNone, 1},
+	"HVALS":         {keysFirst, fanoutNone, 1},
+	"HLEN":          {keysFirst, fanoutNone, 1},
+	"HEXISTS":       {keysFirst, fanoutNone, 2},
+	"HINCRBY":       {keysFirst, fanoutNone, 3},
+	"HINCRBYFLOAT":  {keysFirst, fanoutNone, 3},
+	"LPUSH":         {keysFirst, fanoutNone, 2},
+	"RPUSH":         {keysFirst, fanoutNone, 2},
+	"LPOP":          {keysFirst, fanoutNone, 1},
+	"RPOP":          {keysFirst, fanoutNone, 1},
+	"LLEN":          {keysFirst, fanoutNone, 1},
+	"LRANGE":        {keysFirst, fanoutNone, 3},
+	"LINDEX":        {keysFirst, fanoutNone, 2},
+	"LSET":          {keysFirst, fanoutNone, 3},
+	"LREM":          {keysFirst, fanoutNone, 3},
+	"LTRIM":         {keysFirst, fanoutNone, 3},
+	"SADD":          {keysFirst, fanoutNone, 2},
+	"SREM":          {keysFirst, fanoutNone, 2},
+	"SMEMBERS":      {keysFirst, fanoutNone, 1},
+	"SISMEMBER":     {keysFirst, fanoutNone, 2},
+	"SCARD":         {keysFirst, fanoutNone, 1},
+	"SPOP":          {keysFirst, fanoutNone, 1},
+	"SRANDMEMBER":   {keysFirst, fanoutNone, 1},
+	"ZADD":          {keysFirst, fanoutNone, 3},
+	"ZREM":          {keysFirst, fanoutNone, 2},
+	"ZSCORE":        {keysFirst, fanoutNone, 2},
+	"ZINCRBY":       {keysFirst, fanoutNone, 3},
+	"ZCARD":         {keysFirst, fanoutNone, 1},
+	"ZCOUNT":        {keysFirst, fanoutNone, 3},
+	"ZRANK":         {keysFirst, fanoutNone, 2},
+	"ZREVRANK":      {keysFirst, fanoutNone, 2},
+	"ZRANGE":        {keysFirst, fanoutNone, 3},
+	"ZREVRANGE":     {keysFirst, fanoutNone, 3},
+	"ZRANGEBYSCORE": {keysFirst, fanoutNone, 3},
+	"PFADD":         {keysFirst, fanoutNone, 1},
+}
+
+// keysOf returns the keys among args (the arguments after the command name) according to spec
+func keysOf(spec commandSpec, args []string) []string {
+	switch spec.keys {
+	case keysAll:
+		return args
+	case keysFirstTwo:
+		return args[:2]
+	case keysEveryOther:
+		keys := make([]string, 0, len(args)/2)
+		for i := 0; i < len(args); i += 2 {
+			keys = append(keys, args[i])
+		}
+		return keys
+	case keysAllButLast:
+		return args[:len(args)-1]
+	}
+	return args[:1]
+}
+
+/*
+HashTag returns the part of key that decides its placement, following the Redis Cluster
+hashtag rule. It is hashring.HashTag, kept here so callers of this package do not need to
+import the ring to predict where a key goes.
+*/
+func HashTag(key string) string {
+	return hashring.HashTag(key)
+}
@@ -0,0 +1,517 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package redis provides a RESP2/RESP3 proxy that shards keys over standalone Redis servers
+with a consistent hash ring. Single-key commands go to the owner of their key, keys that
+share a {hashtag} always land on the same server, and multi-key commands are either fanned
+out (MGET, MSET, DEL, UNLINK, EXISTS, TOUCH) or rejected with a CROSSSLOT error when their
+keys live on different servers.
+*/
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrNotAServer  = errors.New("Node is not a Redis Server")
+	ErrProxyClosed = errors.New("Proxy is closed")
+)
+
+/*
+Server is a ring node backed by a Redis server. Nodes added to the ring used by the proxy
+must implement it, Backend is a ready-made implementation.
+*/
+type Server interface {
+	hashring.CacheNode
+	Addr() string
+}
+
+// Backend is a simple Server identified by ID and listening on Address (host:port)
+type Backend struct {
+	ID      string
+	Address string
+}
+
+func (b *Backend) GetIdentifier() string {
+	return b.ID
+}
+
+func (b *Backend) Addr() string {
+	return b.Address
+}
+
+type proxyConfig struct {
+	DialTimeout time.Duration
+	IOTimeout   time.Duration
+}
+
+// ProxyConfigFn is a function type that modifies the proxyConfig
+type ProxyConfigFn func(*proxyConfig)
+
+// SetDialTimeout returns a ProxyConfigFn that bounds how long connecting to a server may take
+func SetDialTimeout(timeout time.Duration) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.DialTimeout = timeout
+	}
+}
+
+/*
+SetIOTimeout returns a ProxyConfigFn that bounds every round trip to a server. A server
+that does not answer in time is disconnected and the client gets an error reply. Set it to
+zero when clients use blocking commands such as BLPOP with long timeouts.
+*/
+func SetIOTimeout(timeout time.Duration) ProxyConfigFn {
+	return func(config *proxyConfig) {
+		config.IOTimeout = timeout
+	}
+}
+
+/*
+Proxy accepts RESP connections and forwards every command to the server owning its keys
+on the hash ring. Fields:
+  - ring: Hash ring holding Server nodes
+  - config: Configuration settings such as dial and I/O timeouts
+  - mu: Mutex guarding listeners, conns and closed
+  - listeners: Listeners passed to Serve, closed by Close
+  - conns: Open client connections, closed by Close
+  - closed: Whether Close was called
+*/
+type Proxy struct {
+	ring      *hashring.HashRing
+	config    proxyConfig
+	mu        sync.Mutex
+	listeners map[net.Listener]struct{}
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+// ProxyInit creates a Proxy that shards keys over the Server nodes of ring
+func ProxyInit(ring *hashring.HashRing, opts ...ProxyConfigFn) *Proxy {
+	config := &proxyConfig{
+		DialTimeout: time.Second,
+		IOTimeout:   5 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Proxy{
+		ring:      ring,
+		config:    *config,
+		listeners: make(map[net.Listener]struct{}),
+		conns:     make(map[net.Conn]struct{}),
+	}
+}
+
+/*
+Serve accepts client connections on l and serves each one on its own goroutine until
+Close is called or l fails. It returns ErrProxyClosed after Close.
+*/
+func (p *Proxy) Serve(l net.Listener) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrProxyClosed
+	}
+	p.listeners[l] = struct{}{}
+	p.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			p.mu.Lock()
+			closed := p.closed
+			delete(p.listeners, l)
+			p.mu.Unlock()
+			if closed {
+				return ErrProxyClosed
+			}
+			return err
+		}
+
+		p.mu.Lock()
+		if p.closed {
+			p.mu.Unlock()
+			conn.Close()
+			return ErrProxyClosed
+		}
+		p.conns[conn] = struct{}{}
+		p.mu.Unlock()
+
+		go p.serveConn(conn)
+	}
+}
+
+// Close stops every Serve call and closes all client connections
+func (p *Proxy) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closed = true
+	for l := range p.listeners {
+		l.Close()
+	}
+	for conn := range p.conns {
+		conn.Close()
+	}
+	return nil
+}
+
+/*
+serveConn reads commands from one client until it disconnects or sends QUIT. Every client
+connection keeps its own connections to the servers, so per-connection state such as the
+protocol version chosen with HELLO or the database chosen with SELECT is never shared
+between clients. Replies are flushed once the client has no more pipelined commands.
+*/
+func (p *Proxy) serveConn(conn net.Conn) {
+	session := &session{
+		proxy:   p,
+		client:  bufio.NewReader(conn),
+		out:     bufio.NewWriter(conn),
+		servers: make(map[string]*serverConn),
+		setup:   make(map[string][]string),
+	}
+	defer func() {
+		session.closeServers()
+		conn.Close()
+		p.mu.Lock()
+		delete(p.conns, conn)
+		p.mu.Unlock()
+	}()
+
+	for {
+		args, err := readCommand(session.client)
+		if err != nil {
+			// A malformed request leaves the stream in an unknown state, so report it and hang up
+			if errors.Is(err, ErrProtocol) {
+				session.out.Write(errorReply("ERR " + err.Error()))
+				session.out.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if quit := session.handle(args); quit {
+			session.out.Flush()
+			return
+		}
+		if session.client.Buffered() == 0 {
+			if err := session.out.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// setupCommands are connection-state commands replayed, in this order, on every server connection
+var setupCommands = []string{"AUTH", "HELLO", "SELECT"}
+
+// session holds the state of one client connection
+type session struct {
+	proxy   *Proxy
+	client  *bufio.Reader
+	out     *bufio.Writer
+	servers map[string]*serverConn
+	setup   map[string][]string
+}
+
+// serverConn is a buffered connection to one Redis server
+type serverConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// handle executes one command and writes its reply, returning true when the client quits
+func (s *session) handle(args []string) bool {
+	name := strings.ToUpper(args[0])
+
+	switch name {
+	case "PING":
+		if len(args) > 1 {
+			s.writeBulk(args[1])
+		} else {
+			s.out.WriteString("+PONG\r\n")
+		}
+		return false
+	case "ECHO":
+		if len(args) != 2 {
+			s.out.Write(errorReply("ERR wrong number of arguments for 'echo' command"))
+		} else {
+			s.writeBulk(args[1])
+		}
+		return false
+	case "QUIT":
+		s.out.WriteString("+OK\r\n")
+		return true
+	case "AUTH", "HELLO", "SELECT":
+		s.handleSetup(name, args)
+		return false
+	}
+
+	spec, ok := commands[name]
+	if !ok {
+		s.out.Write(errorReply(fmt.Sprintf("ERR command '%s' is not supported by the sharding proxy", args[0])))
+		return false
+	}
+	if len(args)-1 < spec.minArgs || (spec.keys == keysEveryOther && (len(args)-1)%2 != 0) {
+		s.out.Write(errorReply(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(args[0]))))
+		return false
+	}
+
+	// Group the keys by owning server, remembering the position of every key in the request
+	keys := keysOf(spec, args[1:])
+	groups, err := s.group(keys)
+	if err != nil {
+		s.out.Write(errorReply("ERR " + err.Error()))
+		return false
+	}
+
+	if len(groups) == 1 {
+		reply, err := s.roundTrip(groups[0].server, args)
+		s.writeReply(reply, err)
+		return false
+	}
+	switch spec.fanout {
+	case fanoutMGet:
+		s.fanoutMGet(args[0], groups, len(keys))
+	case fanoutSum:
+		s.fanoutSum(args[0], groups)
+	case fanoutMSet:
+		s.fanoutMSet(args, groups)
+	default:
+		s.out.Write(errorReply("CROSSSLOT Keys in request don't hash to the same node"))
+	}
+	return false
+}
+
+// keyGroup is the set of keys of one request owned by one server
+type keyGroup struct {
+	server    Server
+	keys      []string
+	positions []int
+}
+
+// group splits keys by owner, keeping servers in the order their first key appears
+func (s *session) group(keys []string) ([]*keyGroup, error) {
+	var groups []*keyGroup
+	byAddr := make(map[string]*keyGroup)
+	for i, key := range keys {
+		server, err := s.owner(key)
+		if err != nil {
+			return nil, err
+		}
+		g, ok := byAddr[server.Addr()]
+		if !ok {
+			g = &keyGroup{server: server}
+			byAddr[server.Addr()] = g
+			groups = append(groups, g)
+		}
+		g.keys = append(g.keys, key)
+		g.positions = append(g.positions, i)
+	}
+	return groups, nil
+}
+
+// fanoutMGet sends each server an MGET for its keys and merges the values in request order
+func (s *session) fanoutMGet(command string, groups []*keyGroup, count int) {
+	values := make([][]byte, count)
+	for _, g := range groups {
+		reply, err := s.roundTrip(g.server, append([]string{command}, g.keys...))
+		if err != nil || isError(reply) {
+			s.writeReply(reply, err)
+			return
+		}
+		elements, err := splitArray(reply)
+		if err != nil || len(elements) != len(g.keys) {
+			s.out.Write(errorReply(fmt.Sprintf("ERR unexpected MGET reply from %s", g.server.GetIdentifier())))
+			return
+		}
+		for i, position := range g.positions {
+			values[position] = elements[i]
+		}
+	}
+
+	fmt.Fprintf(s.out, "*%d\r\n", count)
+	for _, value := range values {
+		s.out.Write(value)
+	}
+}
+
+// fanoutSum sends each server the command for its keys and replies with the sum of the counts
+func (s *session) fanoutSum(command string, groups []*keyGroup) {
+	total := int64(0)
+	for _, g := range groups {
+		reply, err := s.roundTrip(g.server, append([]string{command}, g.keys...))
+		if err != nil || isError(reply) {
+			s.writeReply(reply, err)
+			return
+		}
+		n, err := strconv.ParseInt(strings.TrimSpace(string(reply[1:])), 10, 64)
+		if reply[0] != ':' || err != nil {
+			s.out.Write(errorReply(fmt.Sprintf("ERR unexpected %s reply from %s", command, g.server.GetIdentifier())))
+			return
+		}
+		total += n
+	}
+	fmt.Fprintf(s.out, ":%d\r\n", total)
+}
+
+/*
+fanoutMSet sends each server an MSET with its key value pairs. MSET is atomic on a single
+server only, so a failure on one server can leave the others written; the first error is
+relayed to the client.
+*/
+func (s *session) fanoutMSet(args []string, groups []*keyGroup) {
+	for _, g := range groups {
+		command := []string{args[0]}
+		for _, position := range g.positions {
+			command = append(command, args[1+2*position], args[2+2*position])
+		}
+		reply, err := s.roundTrip(g.server, command)
+		if err != nil || isError(reply) {
+			s.writeReply(reply, err)
+			return
+		}
+	}
+	s.out.WriteString("+OK\r\n")
+}
+
+/*
+handleSetup runs a connection-state command (AUTH, HELLO or SELECT). Existing server
+connections are dropped and the command is sent to one server to produce the reply; on
+success it is remembered and replayed on every server connection opened afterwards.
+*/
+func (s *session) handleSetup(name string, args []string) {
+	server, err := s.owner("")
+	if err != nil {
+		s.out.Write(errorReply("ERR " + err.Error()))
+		return
+	}
+
+	s.closeServers()
+	reply, err := s.roundTrip(server, args)
+	if err == nil && !isError(reply) {
+		s.setup[name] = args
+	}
+	s.writeReply(reply, err)
+}
+
+// owner returns the Server owning key on the ring, hashing only its hashtag
+func (s *session) owner(key string) (Server, error) {
+	node, err := s.proxy.ring.GetNode(HashTag(key))
+	if err != nil {
+		return nil, err
+	}
+	server, ok := node.(Server)
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrNotAServer, node.GetIdentifier())
+	}
+	return server, nil
+}
+
+/*
+roundTrip sends one command to server and returns its raw reply, dialing the server and
+replaying the session's setup commands first if needed. RESP3 push messages received while
+waiting are forwarded to the client. A connection that failed is dropped so the next
+command dials a fresh one instead of reading a half-consumed reply.
+*/
+func (s *session) roundTrip(server Server, args []string) ([]byte, error) {
+	sc, ok := s.servers[server.Addr()]
+	if !ok {
+		conn, err := net.DialTimeout("tcp", server.Addr(), s.proxy.config.DialTimeout)
+		if err != nil {
+			return nil, err
+		}
+		sc = &serverConn{conn: conn, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}
+		s.servers[server.Addr()] = sc
+
+		for _, name := range setupCommands {
+			if setup, ok := s.setup[name]; ok {
+				reply, err := s.exchange(server, sc, setup)
+				if err == nil && isError(reply) {
+					err = fmt.Errorf("%s failed on %s: %s", name, server.GetIdentifier(), strings.TrimSpace(string(reply[1:])))
+				}
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return s.exchange(server, sc, args)
+}
+
+// exchange writes args on sc and reads the reply, dropping the connection on any error
+func (s *session) exchange(server Server, sc *serverConn, args []string) ([]byte, error) {
+	if s.proxy.config.IOTimeout > 0 {
+		sc.conn.SetDeadline(time.Now().Add(s.proxy.config.IOTimeout))
+	} else {
+		sc.conn.SetDeadline(time.Time{})
+	}
+
+	err := writeCommand(sc.w, args)
+	for err == nil {
+		var reply []byte
+		reply, err = readValue(sc.r)
+		if err == nil && isPush(reply) {
+			s.out.Write(reply)
+			continue
+		}
+		if err == nil {
+			return reply, nil
+		}
+	}
+	sc.conn.Close()
+	delete(s.servers, server.Addr())
+	return nil, err
+}
+
+func (s *session) closeServers() {
+	for addr, sc := range s.servers {
+		sc.conn.Close()
+		delete(s.servers, addr)
+	}
+}
+
+// writeReply relays a server reply, or an error reply when the round trip failed
+func (s *session) writeReply(reply []byte, err error) {
+	if err != nil {
+		s.out.Write(errorReply("ERR " + err.Error()))
+		return
+	}
+	s.out.Write(reply)
+}
+
+func (s *session) writeBulk(value string) {
+	fmt.Fprintf(s.out, "$%d\r\n%s\r\n", len(value), value)
+}
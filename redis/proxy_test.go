@@ -0,0 +1,356 @@
+package redis
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+/*
+stubRedis is an in-process RESP server implementing the handful of commands the proxy
+tests need. It answers HELLO by switching the connection to RESP3, in which case missing
+values are sent as the RESP3 null "_" instead of "$-1".
+*/
+type stubRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	commands []string
+	listener net.Listener
+}
+
+func startStubRedis(t *testing.T) *stubRedis {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	stub := &stubRedis{data: make(map[string]string), listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go stub.serve(conn)
+		}
+	}()
+	return stub
+}
+
+func (s *stubRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	resp3 := false
+	null := func() string {
+		if resp3 {
+			return "_\r\n"
+		}
+		return "$-1\r\n"
+	}
+
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, strings.Join(args, " "))
+		switch strings.ToUpper(args[0]) {
+		case "HELLO":
+			resp3 = len(args) > 1 && args[1] == "3"
+			if resp3 {
+				w.WriteString("%1\r\n+proto\r\n:3\r\n")
+			} else {
+				w.WriteString("*2\r\n$5\r\nproto\r\n:2\r\n")
+			}
+		case "SELECT":
+			w.WriteString("+OK\r\n")
+		case "GET":
+			if value, ok := s.data[args[1]]; ok {
+				fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				w.WriteString(null())
+			}
+		case "SET":
+			s.data[args[1]] = args[2]
+			w.WriteString("+OK\r\n")
+		case "MSET":
+			for i := 1; i+1 < len(args); i += 2 {
+				s.data[args[i]] = args[i+1]
+			}
+			w.WriteString("+OK\r\n")
+		case "MGET":
+			fmt.Fprintf(w, "*%d\r\n", len(args)-1)
+			for _, key := range args[1:] {
+				if value, ok := s.data[key]; ok {
+					fmt.Fprintf(w, "$%d\r\n%s\r\n", len(value), value)
+				} else {
+					w.WriteString(null())
+				}
+			}
+		case "DEL", "EXISTS":
+			count := 0
+			for _, key := range args[1:] {
+				if _, ok := s.data[key]; ok {
+					count++
+					if strings.ToUpper(args[0]) == "DEL" {
+						delete(s.data, key)
+					}
+				}
+			}
+			fmt.Fprintf(w, ":%d\r\n", count)
+		case "SUNION":
+			w.WriteString("*0\r\n")
+		default:
+			fmt.Fprintf(w, "-ERR unknown command '%s'\r\n", args[0])
+		}
+		s.mu.Unlock()
+		w.Flush()
+	}
+}
+
+func (s *stubRedis) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.data[key]
+	return ok
+}
+
+func (s *stubRedis) received(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	count := 0
+	for _, command := range s.commands {
+		if strings.HasPrefix(command, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+// client is a minimal RESP client used to talk to the proxy under test
+type client struct {
+	t *testing.T
+	r *bufio.Reader
+	w *bufio.Writer
+}
+
+// do sends a command and returns the raw reply
+func (c *client) do(args ...string) string {
+	c.t.Helper()
+	if err := writeCommand(c.w, args); err != nil {
+		c.t.Fatalf("Failed to send %v: %v", args, err)
+	}
+	reply, err := readValue(c.r)
+	if err != nil {
+		c.t.Fatalf("Failed to read reply to %v: %v", args, err)
+	}
+	return string(reply)
+}
+
+/*
+startProxy builds a ring over count stub servers, starts a Proxy in front of them and
+returns a connected client along with the stubs keyed by node identifier.
+*/
+func startProxy(t *testing.T, count int) (*client, *hashring.HashRing, map[string]*stubRedis) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	stubs := make(map[string]*stubRedis)
+	for i := 0; i < count; i++ {
+		stub := startStubRedis(t)
+		// The suffix after the index spreads the FNV hashes of the ids around the ring
+		id := fmt.Sprintf("redis-%d.local", i)
+		stubs[id] = stub
+		if err := ring.AddNode(&Backend{ID: id, Address: stub.listener.Addr().String()}); err != nil {
+			t.Fatalf("Failed to add %s: %v", id, err)
+		}
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	p := ProxyInit(ring, SetIOTimeout(time.Second))
+	go p.Serve(listener)
+	t.Cleanup(func() { p.Close() })
+
+	conn, err := net.Dial("tcp", listener.Addr().String())
+	if err != nil {
+		t.Fatalf("Failed to dial proxy: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &client{t: t, r: bufio.NewReader(conn), w: bufio.NewWriter(conn)}, ring, stubs
+}
+
+// spreadKeys returns count keys owned by at least two different nodes of ring
+func spreadKeys(t *testing.T, ring *hashring.HashRing, count int) []string {
+	t.Helper()
+	var keys []string
+	owners := map[string]bool{}
+	for i := 0; i < 100000 && (len(keys) < count || len(owners) < 2); i++ {
+		key := fmt.Sprintf("key:%d", i)
+		owner, _ := ring.GetNode(key)
+		// Take the first keys as they come, then only keys on a new owner
+		if len(keys) < count-1 || !owners[owner.GetIdentifier()] {
+			if len(keys) == count {
+				keys = keys[:count-1]
+			}
+			keys = append(keys, key)
+			owners[owner.GetIdentifier()] = true
+		}
+	}
+	if len(owners) < 2 {
+		t.Fatal("Could not find keys owned by different nodes")
+	}
+	return keys
+}
+
+/*
+TestHashTag tests that HashTag follows the Redis Cluster hashtag rule, hashing only the
+first non-empty {...} section of a key and the whole key otherwise.
+*/
+func TestHashTag(t *testing.T) {
+	tests := map[string]string{
+		"user:1000":             "user:1000",
+		"{user:1000}.following": "user:1000",
+		"foo{bar}{zap}":         "bar",
+		"foo{}{bar}":            "foo{}{bar}",
+		"foo{{bar}}zap":         "{bar",
+		"foo{bar":               "foo{bar",
+	}
+	for key, want := range tests {
+		if got := HashTag(key); got != want {
+			t.Errorf("HashTag(%q): expected %q, got %q", key, want, got)
+		}
+	}
+}
+
+/*
+TestProxy tests the RESP proxy end to end against in-process stub servers. It verifies
+routing of single-key commands, hashtag co-location, fan-out of MGET, MSET, DEL and
+EXISTS, CROSSSLOT rejection, local commands and RESP3 negotiation through HELLO.
+*/
+func TestProxy(t *testing.T) {
+	t.Run("single-key commands go to the owner", func(t *testing.T) {
+		c, ring, stubs := startProxy(t, 3)
+
+		if reply := c.do("SET", "user:1", "alice"); reply != "+OK\r\n" {
+			t.Fatalf("Expected +OK, got %q", reply)
+		}
+		owner, _ := ring.GetNode("user:1")
+		for id, stub := range stubs {
+			if stub.has("user:1") != (id == owner.GetIdentifier()) {
+				t.Errorf("Server %s has key = %v, owner is %s", id, stub.has("user:1"), owner.GetIdentifier())
+			}
+		}
+		if reply := c.do("get", "user:1"); reply != "$5\r\nalice\r\n" {
+			t.Errorf("Expected alice, got %q", reply)
+		}
+	})
+
+	t.Run("hashtagged keys co-locate", func(t *testing.T) {
+		c, ring, stubs := startProxy(t, 4)
+
+		keys := []string{"{user:7}.profile", "{user:7}.friends", "{user:7}.settings"}
+		for _, key := range keys {
+			c.do("SET", key, "v")
+		}
+		owner, _ := ring.GetNode("user:7")
+		for _, key := range keys {
+			if !stubs[owner.GetIdentifier()].has(key) {
+				t.Errorf("Expected %s on %s", key, owner.GetIdentifier())
+			}
+		}
+
+		// A multi-key command without fan-out works when every key shares the tag
+		if reply := c.do(append([]string{"SUNION"}, keys...)...); reply != "*0\r\n" {
+			t.Errorf("Expected SUNION to be forwarded, got %q", reply)
+		}
+	})
+
+	t.Run("multi-key commands fan out and merge", func(t *testing.T) {
+		c, ring, stubs := startProxy(t, 3)
+		k := spreadKeys(t, ring, 4)
+
+		if reply := c.do("MSET", k[0], "1", k[1], "2", k[2], "3", k[3], "4"); reply != "+OK\r\n" {
+			t.Fatalf("Expected +OK from MSET, got %q", reply)
+		}
+		reply := c.do("MGET", k[3], "missing", k[0], k[2], k[1])
+		expected := "*5\r\n$1\r\n4\r\n$-1\r\n$1\r\n1\r\n$1\r\n3\r\n$1\r\n2\r\n"
+		if reply != expected {
+			t.Errorf("Expected %q, got %q", expected, reply)
+		}
+
+		if reply := c.do("EXISTS", k[0], k[3], k[0], "missing"); reply != ":3\r\n" {
+			t.Errorf("Expected :3 from EXISTS, got %q", reply)
+		}
+		if reply := c.do("DEL", k[0], k[1], k[3], "missing"); reply != ":3\r\n" {
+			t.Errorf("Expected :3 from DEL, got %q", reply)
+		}
+
+		// The keys were spread, so more than one server must have seen an MSET
+		servers := 0
+		for _, stub := range stubs {
+			if stub.received("MSET") > 0 {
+				servers++
+			}
+		}
+		if servers < 2 {
+			t.Errorf("Expected MSET to fan out to several servers, got %d", servers)
+		}
+	})
+
+	t.Run("cross-node multi-key commands are rejected", func(t *testing.T) {
+		c, ring, _ := startProxy(t, 3)
+
+		// Two keys owned by different servers
+		k := spreadKeys(t, ring, 2)
+		if reply := c.do("SUNION", k[0], k[1]); !strings.HasPrefix(reply, "-CROSSSLOT") {
+			t.Errorf("Expected CROSSSLOT, got %q", reply)
+		}
+	})
+
+	t.Run("local and unsupported commands", func(t *testing.T) {
+		c, _, _ := startProxy(t, 1)
+
+		if reply := c.do("PING"); reply != "+PONG\r\n" {
+			t.Errorf("Expected +PONG, got %q", reply)
+		}
+		if reply := c.do("ECHO", "hi"); reply != "$2\r\nhi\r\n" {
+			t.Errorf("Expected hi, got %q", reply)
+		}
+		if reply := c.do("KEYS", "*"); !strings.HasPrefix(reply, "-ERR") {
+			t.Errorf("Expected error for KEYS, got %q", reply)
+		}
+		if reply := c.do("GET"); !strings.Contains(reply, "wrong number of arguments") {
+			t.Errorf("Expected arity error, got %q", reply)
+		}
+	})
+
+	t.Run("HELLO 3 switches every server connection to RESP3", func(t *testing.T) {
+		c, _, _ := startProxy(t, 3)
+
+		if reply := c.do("HELLO", "3"); !strings.HasPrefix(reply, "%1") {
+			t.Fatalf("Expected RESP3 map reply, got %q", reply)
+		}
+
+		// Missing keys now come back as RESP3 nulls from whichever server owns them
+		for _, key := range []string{"x", "y", "z", "w"} {
+			if reply := c.do("GET", key); reply != "_\r\n" {
+				t.Errorf("Expected RESP3 null for %s, got %q", key, reply)
+			}
+		}
+		if reply := c.do("MGET", "x", "y", "z", "w"); reply != "*4\r\n_\r\n_\r\n_\r\n_\r\n" {
+			t.Errorf("Expected RESP3 nulls in MGET, got %q", reply)
+		}
+	})
+}
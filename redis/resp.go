@@ -0,0 +1,246 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package redis
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrProtocol = errors.New("Protocol error")
+)
+
+const (
+	// maxBulkLength is the largest bulk string accepted from a client or server (512MB like Redis)
+	maxBulkLength = 512 << 20
+	// maxLineLength bounds inline commands and RESP headers (64KB like Redis' inline limit)
+	maxLineLength = 64 * 1024
+)
+
+/*
+readCommand reads one client command. Commands normally arrive as a RESP array of bulk
+strings, but like Redis we also accept inline commands (space separated words on one
+line) so that telnet-style clients work.
+*/
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return strings.Fields(line), nil
+	}
+
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 || count > 1024*1024 {
+		return nil, fmt.Errorf("%w: invalid multibulk length", ErrProtocol)
+	}
+	args := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if len(header) == 0 || header[0] != '$' {
+			return nil, fmt.Errorf("%w: expected '$', got %q", ErrProtocol, header)
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 || size > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length", ErrProtocol)
+		}
+		data, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, string(data[:size]))
+	}
+	return args, nil
+}
+
+// writeCommand encodes args as a RESP array of bulk strings
+func writeCommand(w *bufio.Writer, args []string) error {
+	fmt.Fprintf(w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	return w.Flush()
+}
+
+/*
+readValue reads one complete RESP2 or RESP3 value and returns its raw encoding, so the
+proxy can relay server replies byte for byte without understanding them. Aggregates are
+read recursively. Streamed RESP3 aggregates and strings (with a '?' length) are rejected.
+*/
+func readValue(r *bufio.Reader) ([]byte, error) {
+	line, err := readRawLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("%w: bad line %q", ErrProtocol, line)
+	}
+	raw := line
+	length := string(line[1 : len(line)-2])
+
+	switch line[0] {
+	// Simple string, error, integer, null, double, boolean and big number fit on one line
+	case '+', '-', ':', '_', ',', '#', '(':
+		return raw, nil
+
+	// Bulk string, bulk error and verbatim string carry a length-prefixed payload
+	case '$', '!', '=':
+		size, err := strconv.Atoi(length)
+		if err != nil || size < -1 || size > maxBulkLength {
+			return nil, fmt.Errorf("%w: invalid bulk length %q", ErrProtocol, length)
+		}
+		if size == -1 {
+			return raw, nil
+		}
+		data, err := readBulk(r, size)
+		if err != nil {
+			return nil, err
+		}
+		return append(raw, data...), nil
+
+	// Array, set and push hold count elements, map and attribute hold count pairs
+	case '*', '~', '>', '%', '|':
+		count, err := strconv.Atoi(length)
+		if err != nil || count < -1 {
+			return nil, fmt.Errorf("%w: invalid aggregate length %q", ErrProtocol, length)
+		}
+		if line[0] == '%' || line[0] == '|' {
+			count *= 2
+		}
+		for i := 0; i < count; i++ {
+			element, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			raw = append(raw, element...)
+		}
+		// An attribute decorates the value that follows it, so both are returned together
+		if line[0] == '|' {
+			value, err := readValue(r)
+			if err != nil {
+				return nil, err
+			}
+			raw = append(raw, value...)
+		}
+		return raw, nil
+	}
+	return nil, fmt.Errorf("%w: unknown type %q", ErrProtocol, line[0])
+}
+
+/*
+splitArray splits the raw encoding of an array reply into the raw encodings of its
+elements. It is used to merge MGET replies from several servers.
+*/
+func splitArray(raw []byte) ([][]byte, error) {
+	r := bufio.NewReader(bytes.NewReader(raw))
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 || line[0] != '*' {
+		return nil, fmt.Errorf("%w: expected array, got %q", ErrProtocol, line)
+	}
+	count, err := strconv.Atoi(line[1:])
+	if err != nil || count < 0 {
+		return nil, fmt.Errorf("%w: invalid array length %q", ErrProtocol, line)
+	}
+	elements := make([][]byte, 0, count)
+	for i := 0; i < count; i++ {
+		element, err := readValue(r)
+		if err != nil {
+			return nil, err
+		}
+		elements = append(elements, element)
+	}
+	return elements, nil
+}
+
+// isError reports whether a raw reply is a simple or bulk error
+func isError(raw []byte) bool {
+	return len(raw) > 0 && (raw[0] == '-' || raw[0] == '!')
+}
+
+// isPush reports whether a raw reply is an out-of-band RESP3 push
+func isPush(raw []byte) bool {
+	return len(raw) > 0 && raw[0] == '>'
+}
+
+// errorReply encodes msg as a simple error
+func errorReply(msg string) []byte {
+	return []byte("-" + strings.NewReplacer("\r", " ", "\n", " ").Replace(msg) + "\r\n")
+}
+
+// readLine reads one \r\n terminated line and returns it without the terminator
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := readRawLine(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(line), "\n"), "\r"), nil
+}
+
+/*
+readRawLine reads one line including its terminator. Lines longer than maxLineLength fail
+with ErrProtocol before they are buffered in full, so a client cannot grow the buffer
+without bound by never sending a newline.
+*/
+func readRawLine(r *bufio.Reader) ([]byte, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxLineLength {
+			return nil, fmt.Errorf("%w: line too long", ErrProtocol)
+		}
+		line = append(line, chunk...)
+		if err == bufio.ErrBufferFull {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return line, nil
+	}
+}
+
+// readBulk reads a bulk payload of size bytes and its \r\n terminator and returns both
+func readBulk(r *bufio.Reader, size int) ([]byte, error) {
+	data := make([]byte, size+2)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+	if data[size] != '\r' || data[size+1] != '\n' {
+		return nil, fmt.Errorf("%w: bulk string not terminated by CRLF", ErrProtocol)
+	}
+	return data, nil
+}
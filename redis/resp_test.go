@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"strings"
+	"testing"
+)
+
+/*
+TestReadValue tests that readValue returns the exact raw encoding of RESP2 and RESP3
+values, including nested aggregates, maps and attributes, and rejects malformed input.
+*/
+func TestReadValue(t *testing.T) {
+	values := []string{
+		"+OK\r\n",
+		"-ERR boom\r\n",
+		":42\r\n",
+		"$5\r\nhello\r\n",
+		"$-1\r\n",
+		"*-1\r\n",
+		"*2\r\n$1\r\na\r\n*1\r\n:1\r\n",
+		"_\r\n",
+		",3.14\r\n",
+		"#t\r\n",
+		"(12345678901234567890\r\n",
+		"!9\r\nERR again\r\n",
+		"=15\r\ntxt:Some string\r\n",
+		"%2\r\n+a\r\n:1\r\n+b\r\n:2\r\n",
+		"~2\r\n+x\r\n+y\r\n",
+		">2\r\n+pubsub\r\n+message\r\n",
+		"|1\r\n+ttl\r\n:100\r\n$3\r\nval\r\n",
+	}
+	for _, value := range values {
+		// Append a trailing value to make sure readValue stops at the right byte
+		r := bufio.NewReader(strings.NewReader(value + "+NEXT\r\n"))
+		raw, err := readValue(r)
+		if err != nil {
+			t.Errorf("readValue(%q) failed: %v", value, err)
+			continue
+		}
+		if string(raw) != value {
+			t.Errorf("Expected %q, got %q", value, raw)
+		}
+	}
+
+	for _, bad := range []string{"?x\r\n", "$abc\r\n", "+no-crlf\n", "$3\r\nabcde\r\n", "+" + strings.Repeat("x", maxLineLength) + "\r\n"} {
+		if _, err := readValue(bufio.NewReader(strings.NewReader(bad))); !errors.Is(err, ErrProtocol) {
+			t.Errorf("Expected ErrProtocol for %q, got %v", bad, err)
+		}
+	}
+}
+
+/*
+TestReadCommand tests that readCommand parses both multibulk and inline commands, that
+it rejects overlong lines and bulk strings not followed by CRLF, and that splitArray
+splits an array reply into its raw elements.
+*/
+func TestReadCommand(t *testing.T) {
+	r := bufio.NewReader(strings.NewReader("*2\r\n$3\r\nGET\r\n$4\r\nk ey\r\nPING hello\r\n"))
+	args, err := readCommand(r)
+	if err != nil || strings.Join(args, "|") != "GET|k ey" {
+		t.Errorf("Expected GET|k ey, got %v (%v)", args, err)
+	}
+	args, err = readCommand(r)
+	if err != nil || strings.Join(args, "|") != "PING|hello" {
+		t.Errorf("Expected inline PING|hello, got %v (%v)", args, err)
+	}
+
+	for _, bad := range []string{
+		strings.Repeat("x", maxLineLength+1),
+		"*1\r\n$" + strings.Repeat("9", maxLineLength),
+		"*1\r\n$3\r\nGETxx",
+		"*2\r\n$3\r\nGET\n\r$1\r\nk\r\n",
+	} {
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(bad))); !errors.Is(err, ErrProtocol) {
+			t.Errorf("Expected ErrProtocol for %.20q, got %v", bad, err)
+		}
+	}
+
+	elements, err := splitArray([]byte("*3\r\n$1\r\na\r\n_\r\n*1\r\n:1\r\n"))
+	if err != nil {
+		t.Fatalf("splitArray failed: %v", err)
+	}
+	if len(elements) != 3 || string(elements[2]) != "*1\r\n:1\r\n" {
+		t.Errorf("Unexpected elements %q", elements)
+	}
+}
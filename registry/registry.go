@@ -0,0 +1,582 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package registry manages many named HashRing instances, one per tenant or keyspace, from
+one place. Nodes are defined once and attached to as many rings as needed, each ring has
+its own configuration and metrics, membership changes spanning several rings are applied
+all-or-nothing, and the whole registry can be snapshotted and restored.
+*/
+package registry
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"maps"
+	"net/http"
+	"slices"
+	"sync"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Hash function names accepted in RingConfig.Hash
+const (
+	HashFNV1a   = "fnv1a"
+	HashFNV1    = "fnv1"
+	HashSipHash = "siphash"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrRingExists        = errors.New("Ring already exists")
+	ErrRingNotFound      = errors.New("Ring not found")
+	ErrNodeDefined       = errors.New("Node is already defined differently")
+	ErrNodeUndefined     = errors.New("Node is not defined")
+	ErrNodeInUse         = errors.New("Node is still attached to a ring")
+	ErrInvalidRingConfig = errors.New("Invalid ring config")
+)
+
+/*
+Node is a node definition shared between rings. Every ring the node is attached to holds
+the same *Node, so lookups on any ring return the definition kept by the registry. Zone
+makes it a hashring.ZonedNode.
+*/
+type Node struct {
+	ID   string `json:"id"`
+	Addr string `json:"addr"`
+	Zone string `json:"zone,omitempty"`
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+// GetZone makes Node a hashring.ZonedNode, so lookups can prefer a zone
+func (n *Node) GetZone() string {
+	return n.Zone
+}
+
+/*
+RingConfig is the configuration of one ring. It only holds settings that can be written
+to a snapshot; options that cannot, such as a logger, are set for every ring with
+SetRingOptions. Fields:
+  - Hash: Hash function, HashFNV1a (the default), HashFNV1 or HashSipHash
+  - Seed: Secret seed of HashSipHash, required for it and not allowed otherwise
+  - LookupTableBits: Size of the ring's lookup table, sized automatically when nil
+  - RedactKeys: Replace lookup keys in the ring's logs with [REDACTED]
+*/
+type RingConfig struct {
+	Hash            string         `json:"hash,omitempty"`
+	Seed            *hashring.Seed `json:"seed,omitempty"`
+	LookupTableBits *int           `json:"lookup_table_bits,omitempty"`
+	RedactKeys      bool           `json:"redact_keys,omitempty"`
+}
+
+// options turns the config into ring options, or fails with ErrInvalidRingConfig
+func (c RingConfig) options() ([]hashring.HashRingConfigFn, error) {
+	var hashFn func() hash.Hash64
+	switch c.Hash {
+	case "", HashFNV1a:
+		hashFn = fnv.New64a
+	case HashFNV1:
+		hashFn = fnv.New64
+	case HashSipHash:
+		if c.Seed == nil || c.Seed.IsZero() {
+			return nil, fmt.Errorf("%w: %s needs a non-zero seed", ErrInvalidRingConfig, HashSipHash)
+		}
+		hashFn = hashring.SipHash(*c.Seed)
+	default:
+		return nil, fmt.Errorf("%w: unknown hash %q", ErrInvalidRingConfig, c.Hash)
+	}
+	if c.Seed != nil && c.Hash != HashSipHash {
+		return nil, fmt.Errorf("%w: seed is only used by %s", ErrInvalidRingConfig, HashSipHash)
+	}
+
+	opts := []hashring.HashRingConfigFn{hashring.SetHashFunction(hashFn), hashring.RedactLoggedKeys(c.RedactKeys)}
+	if c.LookupTableBits != nil {
+		opts = append(opts, hashring.SetLookupTableBits(*c.LookupTableBits))
+	}
+	return opts, nil
+}
+
+type registryConfig struct {
+	RingOptions []hashring.HashRingConfigFn
+}
+
+// RegistryConfigFn is a function type that modifies the registryConfig
+type RegistryConfigFn func(*registryConfig)
+
+/*
+SetRingOptions returns a RegistryConfigFn with options applied to every ring before its
+RingConfig, such as a logger or a lookup tracer. Metrics hooks set here are replaced by
+the registry's per-ring exporter.
+*/
+func SetRingOptions(opts ...hashring.HashRingConfigFn) RegistryConfigFn {
+	return func(config *registryConfig) {
+		config.RingOptions = append(config.RingOptions, opts...)
+	}
+}
+
+/*
+entry is one named ring of the registry. Fields:
+  - config: Configuration the ring was created with
+  - ring: The ring itself
+  - metrics: Exporter attached to the ring, kept across Restore
+*/
+type entry struct {
+	config  RingConfig
+	ring    *hashring.HashRing
+	metrics *hashring.PrometheusMetrics
+}
+
+/*
+Registry holds named rings and shared node definitions. Lookups through GetNode and every
+change made through the registry are serialized against each other, so a lookup never sees
+an Update applied to one ring but not yet to another. Fields:
+  - mu: Read-write mutex guarding rings, nodes and the membership of every ring
+  - config: Options applied to every ring
+  - nodes: Node definitions keyed by identifier
+  - rings: Rings keyed by name
+*/
+type Registry struct {
+	mu     sync.RWMutex
+	config registryConfig
+	nodes  map[string]*Node
+	rings  map[string]*entry
+}
+
+// RegistryInit creates an empty Registry
+func RegistryInit(opts ...RegistryConfigFn) *Registry {
+	config := &registryConfig{}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return &Registry{
+		config: *config,
+		nodes:  make(map[string]*Node),
+		rings:  make(map[string]*entry),
+	}
+}
+
+// newEntry creates an empty ring for config reporting to metrics, a new exporter when nil
+func (r *Registry) newEntry(config RingConfig, metrics *hashring.PrometheusMetrics) (*entry, error) {
+	opts, err := config.options()
+	if err != nil {
+		return nil, err
+	}
+	if metrics == nil {
+		metrics = hashring.NewPrometheusMetrics()
+	}
+	opts = append(append(slices.Clone(r.config.RingOptions), opts...), hashring.SetMetricsHook(metrics))
+	return &entry{config: config, ring: hashring.HashRingInit(opts...), metrics: metrics}, nil
+}
+
+/*
+DefineNode adds a node definition that rings can attach by identifier. Defining a node
+again with the same fields is a no-op, with different fields it fails with
+ErrNodeDefined: rings hold the definition, so it cannot change under them.
+*/
+func (r *Registry) DefineNode(node Node) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.nodes[node.ID]; ok {
+		if *existing != node {
+			return fmt.Errorf("%w: %s", ErrNodeDefined, node.ID)
+		}
+		return nil
+	}
+	r.nodes[node.ID] = &node
+	return nil
+}
+
+// UndefineNode drops a node definition, which must not be attached to any ring
+func (r *Registry) UndefineNode(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.nodes[id]; !ok {
+		return fmt.Errorf("%w: %s", ErrNodeUndefined, id)
+	}
+	for _, name := range sortedNames(r.rings) {
+		if _, err := r.rings[name].ring.NodeState(id); err == nil {
+			return fmt.Errorf("%w: %s is on ring %s", ErrNodeInUse, id, name)
+		}
+	}
+	delete(r.nodes, id)
+	return nil
+}
+
+// Node returns the definition of a node
+func (r *Registry) Node(id string) (Node, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	node, ok := r.nodes[id]
+	if !ok {
+		return Node{}, fmt.Errorf("%w: %s", ErrNodeUndefined, id)
+	}
+	return *node, nil
+}
+
+// CreateRing creates an empty ring under name
+func (r *Registry) CreateRing(name string, config RingConfig) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rings[name]; ok {
+		return fmt.Errorf("%w: %s", ErrRingExists, name)
+	}
+	e, err := r.newEntry(config, nil)
+	if err != nil {
+		return err
+	}
+	r.rings[name] = e
+	return nil
+}
+
+// DeleteRing removes a ring and its metrics from the registry
+func (r *Registry) DeleteRing(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.rings[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrRingNotFound, name)
+	}
+	delete(r.rings, name)
+	return nil
+}
+
+/*
+Ring returns the named ring for the lookups the registry does not wrap, such as
+GetNodeContext or GetNodesForKeys. Its membership must only be changed through the
+registry, otherwise snapshots and cross-ring updates no longer reflect it.
+*/
+func (r *Registry) Ring(name string) (*hashring.HashRing, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.rings[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRingNotFound, name)
+	}
+	return e.ring, nil
+}
+
+// Rings returns the names of all rings in sorted order
+func (r *Registry) Rings() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedNames(r.rings)
+}
+
+// GetNode looks up key on the named ring
+func (r *Registry) GetNode(ring, key string) (hashring.CacheNode, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.rings[ring]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRingNotFound, ring)
+	}
+	return e.ring.GetNode(key)
+}
+
+// AddNode attaches a defined node to the named ring
+func (r *Registry) AddNode(ring, id string) error {
+	return r.Update(func(tx *Tx) error {
+		return tx.AddNode(ring, id)
+	})
+}
+
+// RemoveNode detaches a node from the named ring
+func (r *Registry) RemoveNode(ring, id string) error {
+	return r.Update(func(tx *Tx) error {
+		return tx.RemoveNode(ring, id)
+	})
+}
+
+// SetNodeState sets the state of a node on the named ring, see hashring.HashRing.SetNodeState
+func (r *Registry) SetNodeState(ring, id string, state hashring.NodeState) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	e, ok := r.rings[ring]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrRingNotFound, ring)
+	}
+	return e.ring.SetNodeState(id, state)
+}
+
+/*
+Tx collects the membership changes of one Update. Its methods only check that the ring
+and node exist; whether a change fits the ring is checked when the Update is applied.
+*/
+type Tx struct {
+	registry *Registry
+	order    []string
+	changes  map[string][]hashring.Change
+}
+
+// AddNode stages attaching the defined node id to ring
+func (tx *Tx) AddNode(ring, id string) error {
+	return tx.stage(ring, id, hashring.MembershipAdd)
+}
+
+// RemoveNode stages detaching the node id from ring
+func (tx *Tx) RemoveNode(ring, id string) error {
+	return tx.stage(ring, id, hashring.MembershipRemove)
+}
+
+func (tx *Tx) stage(ring, id string, op hashring.MembershipOp) error {
+	if _, ok := tx.registry.rings[ring]; !ok {
+		return fmt.Errorf("%w: %s", ErrRingNotFound, ring)
+	}
+	node, ok := tx.registry.nodes[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrNodeUndefined, id)
+	}
+	if _, ok := tx.changes[ring]; !ok {
+		tx.order = append(tx.order, ring)
+	}
+	tx.changes[ring] = append(tx.changes[ring], hashring.Change{Op: op, Node: node})
+	return nil
+}
+
+/*
+Update applies the membership changes staged by fn on every ring they touch, all or
+nothing. The changes of each ring are applied in one CompareAndApply, so each ring moves
+to its next epoch once; if any ring refuses its batch, the rings already changed are
+rolled back and the error is returned. When fn returns an error nothing is applied. The
+registry is locked for the whole Update, so fn must not call other Registry methods.
+
+A rollback restores the members of a ring and their states, a draining node removed by
+the batch comes back draining, but it is itself a change: the ring ends at least two
+epochs past where it started, so callers comparing epochs see that it may have moved.
+*/
+func (r *Registry) Update(fn func(tx *Tx) error) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	tx := &Tx{registry: r, changes: make(map[string][]hashring.Change)}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	// The states of the members before the update, removing a node drops its state
+	states := make(map[string][]hashring.Member, len(tx.order))
+	for _, name := range tx.order {
+		states[name], _ = r.rings[name].ring.Members()
+	}
+
+	for i, name := range tx.order {
+		ring := r.rings[name].ring
+		if _, err := ring.CompareAndApply(ring.Epoch(), tx.changes[name]); err != nil {
+			err = fmt.Errorf("ring %s: %w", name, err)
+			// Undo the rings before this one in reverse
+			for j := i - 1; j >= 0; j-- {
+				if undoErr := rollback(r.rings[tx.order[j]].ring, tx.changes[tx.order[j]], states[tx.order[j]]); undoErr != nil {
+					err = errors.Join(err, fmt.Errorf("rolling back ring %s: %w", tx.order[j], undoErr))
+				}
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// rollback undoes changes on ring and sets the members back to the states they had before
+func rollback(ring *hashring.HashRing, changes []hashring.Change, members []hashring.Member) error {
+	if _, err := ring.CompareAndApply(ring.Epoch(), inverse(changes)); err != nil {
+		return err
+	}
+	for _, member := range members {
+		if member.State == hashring.StateActive {
+			continue
+		}
+		if err := ring.SetNodeState(member.Node.GetIdentifier(), member.State); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inverse returns the changes undoing changes
+func inverse(changes []hashring.Change) []hashring.Change {
+	undo := make([]hashring.Change, 0, len(changes))
+	for i := len(changes) - 1; i >= 0; i-- {
+		op := hashring.MembershipAdd
+		if changes[i].Op == hashring.MembershipAdd {
+			op = hashring.MembershipRemove
+		}
+		undo = append(undo, hashring.Change{Op: op, Node: changes[i].Node})
+	}
+	return undo
+}
+
+// Metrics returns the metrics exporter of the named ring
+func (r *Registry) Metrics(ring string) (*hashring.PrometheusMetrics, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	e, ok := r.rings[ring]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrRingNotFound, ring)
+	}
+	return e.metrics, nil
+}
+
+// WriteMetrics writes the metrics of every ring, each series labeled with ring="name"
+func (r *Registry) WriteMetrics(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	metrics := make(map[string]*hashring.PrometheusMetrics, len(r.rings))
+	for name, e := range r.rings {
+		metrics[name] = e.metrics
+	}
+	r.mu.RUnlock()
+
+	return hashring.WriteLabeledMetrics(w, "ring", metrics)
+}
+
+// ServeHTTP writes the metrics of every ring so the registry can be mounted as a scrape endpoint
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	r.WriteMetrics(w)
+}
+
+/*
+Snapshot is the whole state of a registry, ready to be written as JSON. Ring configs
+include SipHash seeds, so a snapshot must be stored as carefully as the seeds themselves.
+*/
+type Snapshot struct {
+	Nodes []Node         `json:"nodes"`
+	Rings []RingSnapshot `json:"rings"`
+}
+
+/*
+RingSnapshot is one ring of a Snapshot. Fields:
+  - Name: Name of the ring
+  - Config: Configuration of the ring
+  - Members: Identifiers of the nodes on the ring, in sorted order
+  - States: States of the members that are not active
+*/
+type RingSnapshot struct {
+	Name    string                        `json:"name"`
+	Config  RingConfig                    `json:"config"`
+	Members []string                      `json:"members"`
+	States  map[string]hashring.NodeState `json:"states,omitempty"`
+}
+
+// Snapshot returns the node definitions and every ring with its config, members and node states
+func (r *Registry) Snapshot() Snapshot {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	snapshot := Snapshot{Nodes: make([]Node, 0, len(r.nodes)), Rings: make([]RingSnapshot, 0, len(r.rings))}
+	for _, id := range sortedNames(r.nodes) {
+		snapshot.Nodes = append(snapshot.Nodes, *r.nodes[id])
+	}
+	for _, name := range sortedNames(r.rings) {
+		e := r.rings[name]
+		rs := RingSnapshot{Name: name, Config: e.config, Members: []string{}}
+		for _, id := range sortedNames(r.nodes) {
+			state, err := e.ring.NodeState(id)
+			if err != nil {
+				continue
+			}
+			rs.Members = append(rs.Members, id)
+			if state != hashring.StateActive {
+				if rs.States == nil {
+					rs.States = make(map[string]hashring.NodeState)
+				}
+				rs.States[id] = state
+			}
+		}
+		snapshot.Rings = append(snapshot.Rings, rs)
+	}
+	return snapshot
+}
+
+/*
+Restore replaces the whole registry with snapshot. The new rings are built completely
+before anything is swapped in, so a snapshot that is invalid (an unknown hash, a member
+that is not defined, a duplicate name) is rejected and the registry is left as it was.
+Rings whose name survives the restore keep their metrics exporter, so counters do not
+reset; *hashring.HashRing values returned by Ring before the restore are stale afterwards.
+*/
+func (r *Registry) Restore(snapshot Snapshot) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	nodes := make(map[string]*Node, len(snapshot.Nodes))
+	for _, node := range snapshot.Nodes {
+		if _, ok := nodes[node.ID]; ok {
+			return fmt.Errorf("%w: %s is defined twice", ErrNodeDefined, node.ID)
+		}
+		nodes[node.ID] = &node
+	}
+
+	rings := make(map[string]*entry, len(snapshot.Rings))
+	for _, rs := range snapshot.Rings {
+		if _, ok := rings[rs.Name]; ok {
+			return fmt.Errorf("%w: %s", ErrRingExists, rs.Name)
+		}
+		var metrics *hashring.PrometheusMetrics
+		if old, ok := r.rings[rs.Name]; ok {
+			metrics = old.metrics
+		}
+		e, err := r.newEntry(rs.Config, metrics)
+		if err != nil {
+			return fmt.Errorf("ring %s: %w", rs.Name, err)
+		}
+
+		changes := make([]hashring.Change, 0, len(rs.Members))
+		for _, id := range rs.Members {
+			node, ok := nodes[id]
+			if !ok {
+				return fmt.Errorf("ring %s: %w: %s", rs.Name, ErrNodeUndefined, id)
+			}
+			changes = append(changes, hashring.Change{Op: hashring.MembershipAdd, Node: node})
+		}
+		if _, err := e.ring.CompareAndApply(0, changes); err != nil {
+			return fmt.Errorf("ring %s: %w", rs.Name, err)
+		}
+		for _, id := range slices.Sorted(maps.Keys(rs.States)) {
+			if err := e.ring.SetNodeState(id, rs.States[id]); err != nil {
+				return fmt.Errorf("ring %s: %w", rs.Name, err)
+			}
+		}
+		rings[rs.Name] = e
+	}
+
+	r.nodes, r.rings = nodes, rings
+	return nil
+}
+
+// sortedNames returns the keys of m in sorted order
+func sortedNames[V any](m map[string]V) []string {
+	return slices.Sorted(maps.Keys(m))
+}
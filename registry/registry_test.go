@@ -0,0 +1,237 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// registryWithNodes returns a registry with nodes node-0.local to node-(n-1).local defined
+func registryWithNodes(t *testing.T, n int) *Registry {
+	t.Helper()
+	r := RegistryInit()
+	for i := 0; i < n; i++ {
+		if err := r.DefineNode(Node{ID: fmt.Sprintf("node-%d.local", i), Addr: fmt.Sprintf("10.0.0.%d:11211", i)}); err != nil {
+			t.Fatalf("DefineNode failed: %v", err)
+		}
+	}
+	return r
+}
+
+// members returns the identifiers of the nodes on the named ring
+func members(t *testing.T, r *Registry, name string) []string {
+	t.Helper()
+	for _, rs := range r.Snapshot().Rings {
+		if rs.Name == name {
+			return rs.Members
+		}
+	}
+	t.Fatalf("Ring %s not in snapshot", name)
+	return nil
+}
+
+/*
+TestRegistry tests the ring registry. It verifies that rings share node definitions and
+keep their own config, that a cross-ring update is applied to every ring or to none, that
+metrics are kept per ring and written with a ring label, and that a registry restored from
+a JSON snapshot routes every key like the original.
+*/
+func TestRegistry(t *testing.T) {
+	t.Run("rings share node definitions", func(t *testing.T) {
+		r := registryWithNodes(t, 3)
+		seed, _ := hashring.NewSeed()
+		r.CreateRing("tenant-a", RingConfig{})
+		r.CreateRing("tenant-b", RingConfig{Hash: HashSipHash, Seed: &seed})
+		for _, ring := range []string{"tenant-a", "tenant-b"} {
+			for i := 0; i < 3; i++ {
+				if err := r.AddNode(ring, fmt.Sprintf("node-%d.local", i)); err != nil {
+					t.Fatalf("AddNode failed: %v", err)
+				}
+			}
+		}
+
+		a, _ := r.GetNode("tenant-a", "user:42")
+		b, _ := r.GetNode("tenant-b", "user:42")
+		if a.(*Node).Addr == "" || b.(*Node).Addr == "" {
+			t.Fatal("Expected lookups to return the shared definitions")
+		}
+		def, _ := r.Node(a.GetIdentifier())
+		if *a.(*Node) != def {
+			t.Errorf("Expected %+v, got %+v", def, a)
+		}
+
+		if err := r.DefineNode(Node{ID: "node-0.local", Addr: "10.9.9.9:11211"}); !errors.Is(err, ErrNodeDefined) {
+			t.Errorf("Expected ErrNodeDefined, got %v", err)
+		}
+		if err := r.DefineNode(def); err != nil {
+			t.Errorf("Expected redefining identically to succeed, got %v", err)
+		}
+		if err := r.UndefineNode("node-0.local"); !errors.Is(err, ErrNodeInUse) {
+			t.Errorf("Expected ErrNodeInUse, got %v", err)
+		}
+	})
+
+	t.Run("invalid ring configs", func(t *testing.T) {
+		r := RegistryInit()
+		seed, _ := hashring.NewSeed()
+		for name, config := range map[string]RingConfig{
+			"unknown hash":      {Hash: "md5"},
+			"siphash no seed":   {Hash: HashSipHash},
+			"seed without hash": {Seed: &seed},
+		} {
+			if err := r.CreateRing(name, config); !errors.Is(err, ErrInvalidRingConfig) {
+				t.Errorf("%s: expected ErrInvalidRingConfig, got %v", name, err)
+			}
+		}
+		r.CreateRing("users", RingConfig{})
+		if err := r.CreateRing("users", RingConfig{}); !errors.Is(err, ErrRingExists) {
+			t.Errorf("Expected ErrRingExists, got %v", err)
+		}
+		if _, err := r.GetNode("orders", "key"); !errors.Is(err, ErrRingNotFound) {
+			t.Errorf("Expected ErrRingNotFound, got %v", err)
+		}
+	})
+
+	t.Run("cross-ring updates are all or nothing", func(t *testing.T) {
+		r := registryWithNodes(t, 3)
+		r.CreateRing("users", RingConfig{})
+		r.CreateRing("orders", RingConfig{})
+		r.AddNode("orders", "node-1.local")
+
+		// The second ring refuses its batch, so the first must be rolled back
+		err := r.Update(func(tx *Tx) error {
+			tx.AddNode("users", "node-0.local")
+			tx.AddNode("users", "node-1.local")
+			return tx.AddNode("orders", "node-1.local")
+		})
+		if !errors.Is(err, hashring.ErrNodeExits) {
+			t.Fatalf("Expected ErrNodeExits, got %v", err)
+		}
+		if got := members(t, r, "users"); len(got) != 0 {
+			t.Errorf("Expected the failed update to leave users empty, got %v", got)
+		}
+
+		// Rolling back a removal brings the node back in the state it had
+		r.AddNode("users", "node-2.local")
+		r.SetNodeState("users", "node-2.local", hashring.StateDraining)
+		err = r.Update(func(tx *Tx) error {
+			tx.RemoveNode("users", "node-2.local")
+			return tx.AddNode("orders", "node-1.local")
+		})
+		if !errors.Is(err, hashring.ErrNodeExits) {
+			t.Fatalf("Expected ErrNodeExits, got %v", err)
+		}
+		users, _ := r.Ring("users")
+		if state, err := users.NodeState("node-2.local"); err != nil || state != hashring.StateDraining {
+			t.Errorf("Expected node-2.local to be rolled back as draining, got %q, %v", state, err)
+		}
+		r.RemoveNode("users", "node-2.local")
+
+		// A failing fn applies nothing either
+		r.Update(func(tx *Tx) error {
+			tx.AddNode("users", "node-0.local")
+			return tx.AddNode("users", "node-9.local")
+		})
+		if got := members(t, r, "users"); len(got) != 0 {
+			t.Errorf("Expected an aborted update to leave users empty, got %v", got)
+		}
+
+		// Moving a node between rings in one update
+		if err := r.Update(func(tx *Tx) error {
+			tx.RemoveNode("orders", "node-1.local")
+			tx.AddNode("orders", "node-2.local")
+			return tx.AddNode("users", "node-1.local")
+		}); err != nil {
+			t.Fatalf("Update failed: %v", err)
+		}
+		if got := members(t, r, "orders"); len(got) != 1 || got[0] != "node-2.local" {
+			t.Errorf("Expected orders to hold node-2.local, got %v", got)
+		}
+		if got := members(t, r, "users"); len(got) != 1 || got[0] != "node-1.local" {
+			t.Errorf("Expected users to hold node-1.local, got %v", got)
+		}
+	})
+
+	t.Run("metrics per ring", func(t *testing.T) {
+		r := registryWithNodes(t, 2)
+		r.CreateRing("users", RingConfig{})
+		r.CreateRing("orders", RingConfig{})
+		r.AddNode("users", "node-0.local")
+		r.AddNode("orders", "node-1.local")
+		for i := 0; i < 3; i++ {
+			r.GetNode("users", "key")
+		}
+		r.GetNode("orders", "key")
+
+		var out strings.Builder
+		r.WriteMetrics(&out)
+		for _, line := range []string{
+			`chash_lookups_total{ring="users",node="node-0.local"} 3`,
+			`chash_lookups_total{ring="orders",node="node-1.local"} 1`,
+			`chash_nodes{ring="orders"} 1`,
+		} {
+			if !strings.Contains(out.String(), line+"\n") {
+				t.Errorf("Expected output to contain %q, got:\n%s", line, out.String())
+			}
+		}
+		if m, _ := r.Metrics("users"); m == nil {
+			t.Error("Expected the users ring to have metrics")
+		}
+	})
+
+	t.Run("snapshot and restore", func(t *testing.T) {
+		r := registryWithNodes(t, 5)
+		seed, _ := hashring.NewSeed()
+		bits := 4
+		r.CreateRing("users", RingConfig{Hash: HashSipHash, Seed: &seed})
+		r.CreateRing("orders", RingConfig{LookupTableBits: &bits})
+		for i := 0; i < 5; i++ {
+			r.AddNode("users", fmt.Sprintf("node-%d.local", i))
+			if i%2 == 0 {
+				r.AddNode("orders", fmt.Sprintf("node-%d.local", i))
+			}
+		}
+		r.SetNodeState("users", "node-3.local", hashring.StateDraining)
+
+		data, err := json.Marshal(r.Snapshot())
+		if err != nil {
+			t.Fatalf("Marshal failed: %v", err)
+		}
+		var snapshot Snapshot
+		if err := json.Unmarshal(data, &snapshot); err != nil {
+			t.Fatalf("Unmarshal failed: %v", err)
+		}
+
+		restored := RegistryInit()
+		if err := restored.Restore(snapshot); err != nil {
+			t.Fatalf("Restore failed: %v", err)
+		}
+		for _, ring := range []string{"users", "orders"} {
+			for i := 0; i < 200; i++ {
+				key := fmt.Sprintf("%d:key", i)
+				want, _ := r.GetNode(ring, key)
+				got, _ := restored.GetNode(ring, key)
+				if want.GetIdentifier() != got.GetIdentifier() {
+					t.Fatalf("Ring %s key %s: restored registry picked %s, original %s", ring, key, got.GetIdentifier(), want.GetIdentifier())
+				}
+			}
+		}
+		ring, _ := restored.Ring("users")
+		if state, _ := ring.NodeState("node-3.local"); state != hashring.StateDraining {
+			t.Errorf("Expected the draining state to be restored, got %s", state)
+		}
+
+		// An invalid snapshot leaves the registry untouched
+		snapshot.Rings[0].Members = append(snapshot.Rings[0].Members, "node-9.local")
+		if err := restored.Restore(snapshot); !errors.Is(err, ErrNodeUndefined) {
+			t.Errorf("Expected ErrNodeUndefined, got %v", err)
+		}
+		if got := restored.Rings(); len(got) != 2 {
+			t.Errorf("Expected the failed restore to keep 2 rings, got %v", got)
+		}
+	})
+}
@@ -0,0 +1,456 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package simulate replays membership scenarios against a key population to plan capacity
+before touching production: adding and removing nodes, changing weights and failing whole
+zones. Every step reports the fraction of keys that moved, the peak per-node load and the
+imbalance, so that the number of virtual nodes per unit of weight can be picked by running
+the same scenario with several counts. Placement goes through a real hashring.HashRing
+with the weighted point layout of the hierarchy package, so the numbers are those the
+library produces rather than an idealized model.
+*/
+package simulate
+
+import (
+	"bufio"
+	"cmp"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"io"
+	"maps"
+	"math/rand/v2"
+	"slices"
+	"strconv"
+	"strings"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrInvalidScenario = errors.New("Invalid scenario")
+	ErrNoKeys          = errors.New("No keys to simulate")
+)
+
+/*
+NodeSpec is a node of a scenario. Fields:
+  - ID: Identifier of the node, hashed for its points
+  - Zone: Zone of the node, failed and restored as a whole by FailZone and RestoreZone
+  - Weight: Relative capacity of the node, 1 when zero
+*/
+type NodeSpec struct {
+	ID     string `json:"id"`
+	Zone   string `json:"zone,omitempty"`
+	Weight int    `json:"weight,omitempty"`
+}
+
+/*
+Step is one change of a scenario, applied to the ring as a single CompareAndApply. The
+parts of a step apply in the order of the fields. Fields:
+  - Name: Label of the step in the report, "step N" when empty
+  - Add: Nodes to add
+  - AddCount: Number of extra nodes to add with generated identifiers
+  - AddZone: Zone of the generated nodes, also the prefix of their identifiers
+  - Remove: Identifiers of nodes to remove for good
+  - Weights: New weights keyed by node identifier
+  - FailZone: Zone whose nodes all leave the ring until it is restored
+  - RestoreZone: Failed zone whose nodes come back
+*/
+type Step struct {
+	Name        string         `json:"name,omitempty"`
+	Add         []NodeSpec     `json:"add,omitempty"`
+	AddCount    int            `json:"add_count,omitempty"`
+	AddZone     string         `json:"add_zone,omitempty"`
+	Remove      []string       `json:"remove,omitempty"`
+	Weights     map[string]int `json:"weights,omitempty"`
+	FailZone    string         `json:"fail_zone,omitempty"`
+	RestoreZone string         `json:"restore_zone,omitempty"`
+}
+
+// Scenario is the initial set of nodes and the steps replayed after it
+type Scenario struct {
+	Nodes []NodeSpec `json:"nodes"`
+	Steps []Step     `json:"steps"`
+}
+
+// LoadScenario decodes a JSON scenario, rejecting unknown fields so that typos do not go unnoticed
+func LoadScenario(r io.Reader) (Scenario, error) {
+	var s Scenario
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&s); err != nil {
+		return Scenario{}, fmt.Errorf("%w: %v", ErrInvalidScenario, err)
+	}
+	return s, nil
+}
+
+/*
+SyntheticKeys returns n random keys drawn from a generator seeded with seed, so the same
+seed always gives the same population. The keys are random hex strings: sequential keys
+such as user:1, user:2 differ only in their last bytes, which FNV-1a places close together,
+and would measure the key naming scheme rather than the ring. Use ReadKeys to replay real
+keys instead.
+*/
+func SyntheticKeys(n int, seed uint64) []string {
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	keys := make([]string, n)
+	var buf [8]byte
+	for i := range keys {
+		value := rng.Uint64()
+		for j := range buf {
+			buf[j] = byte(value >> (8 * j))
+		}
+		keys[i] = hex.EncodeToString(buf[:])
+	}
+	return keys
+}
+
+// ReadKeys reads a key population with one key per line, skipping blank lines
+func ReadKeys(r io.Reader) ([]string, error) {
+	var keys []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		if key := strings.TrimSpace(scanner.Text()); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+type simConfig struct {
+	VNodes       int
+	HashFunction func() hash.Hash64
+	RingOptions  []hashring.HashRingConfigFn
+}
+
+// SimConfigFn is a function type that modifies the simConfig
+type SimConfigFn func(*simConfig)
+
+/*
+SetVNodes returns a SimConfigFn that sets the number of points per unit of weight, 1 by
+default. A node of weight w gets w*n points on the ring.
+*/
+func SetVNodes(n int) SimConfigFn {
+	return func(config *simConfig) {
+		config.VNodes = n
+	}
+}
+
+// SetHashFunction returns a SimConfigFn that sets the hash function of the ring, fnv.New64a by default
+func SetHashFunction(f func() hash.Hash64) SimConfigFn {
+	return func(config *simConfig) {
+		config.HashFunction = f
+	}
+}
+
+/*
+SetRingOptions returns a SimConfigFn with options applied to the simulated ring, such as
+a lookup table size. A hash function set here is replaced by the one of SetHashFunction.
+*/
+func SetRingOptions(opts ...hashring.HashRingConfigFn) SimConfigFn {
+	return func(config *simConfig) {
+		config.RingOptions = append(config.RingOptions, opts...)
+	}
+}
+
+/*
+StepReport is the outcome of one step. The initial placement of the scenario's nodes is
+reported as a step named "initial" with nothing moved. Fields:
+  - Name: Name of the step
+  - Nodes: Number of nodes on the ring after the step, failed zones left out
+  - Points: Number of points on the ring after the step
+  - Moved: Fraction of keys whose owner changed in the step
+  - PeakLoad: Number of keys on the most loaded node
+  - PeakNode: Identifier of the most loaded node
+  - Imbalance: Highest ratio of a node's keys to its fair share by weight, 1 is perfect
+  - Loads: Number of keys on every node keyed by identifier
+*/
+type StepReport struct {
+	Name      string         `json:"name"`
+	Nodes     int            `json:"nodes"`
+	Points    int            `json:"points"`
+	Moved     float64        `json:"moved"`
+	PeakLoad  int            `json:"peak_load"`
+	PeakNode  string         `json:"peak_node"`
+	Imbalance float64        `json:"imbalance"`
+	Loads     map[string]int `json:"loads"`
+}
+
+// Report is the outcome of a whole scenario run with VNodes points per unit of weight
+type Report struct {
+	VNodes int          `json:"vnodes"`
+	Keys   int          `json:"keys"`
+	Steps  []StepReport `json:"steps"`
+}
+
+/*
+point is one position of a node on the ring, with the identifier i#id like the weighted
+points of the hierarchy package: the index goes first, since FNV-1a places identifiers
+that differ only at the end close together.
+*/
+type point struct {
+	id   string
+	node string
+}
+
+func (p *point) GetIdentifier() string {
+	return p.id
+}
+
+/*
+simulation is the state of a run. Fields:
+  - config: Options of the run
+  - ring: Ring holding the points of every node outside failed zones
+  - nodes: Nodes of the scenario keyed by identifier, including failed ones
+  - placed: Number of points every node currently has on the ring
+  - failed: Zones that are currently failed
+  - generated: Number of nodes generated by AddCount so far
+*/
+type simulation struct {
+	config    simConfig
+	ring      *hashring.HashRing
+	nodes     map[string]NodeSpec
+	placed    map[string]int
+	failed    map[string]bool
+	generated int
+}
+
+/*
+Run replays s against keys and reports every step, starting with the initial placement.
+Returns ErrNoKeys without keys, ErrInvalidScenario for a step that does not apply to the
+nodes at that point, such as removing an unknown node, and hashring.ErrNoConnectedNodes
+when a step leaves no node on the ring.
+*/
+func Run(s Scenario, keys []string, opts ...SimConfigFn) (Report, error) {
+	config := &simConfig{VNodes: 1, HashFunction: fnv.New64a}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if config.VNodes < 1 {
+		return Report{}, fmt.Errorf("%w: %d vnodes", ErrInvalidScenario, config.VNodes)
+	}
+	if len(keys) == 0 {
+		return Report{}, ErrNoKeys
+	}
+
+	ringOpts := append(slices.Clone(config.RingOptions), hashring.SetHashFunction(config.HashFunction))
+	sim := &simulation{
+		config: *config,
+		ring:   hashring.HashRingInit(ringOpts...),
+		nodes:  make(map[string]NodeSpec),
+		placed: make(map[string]int),
+		failed: make(map[string]bool),
+	}
+	report := Report{VNodes: config.VNodes, Keys: len(keys)}
+
+	initial := Step{Name: "initial", Add: s.Nodes}
+	var before []string
+	for i, step := range append([]Step{initial}, s.Steps...) {
+		if step.Name == "" {
+			step.Name = "step " + strconv.Itoa(i)
+		}
+		if err := sim.apply(step); err != nil {
+			return report, fmt.Errorf("%s: %w", step.Name, err)
+		}
+		owners, err := sim.owners(keys)
+		if err != nil {
+			return report, fmt.Errorf("%s: %w", step.Name, err)
+		}
+		report.Steps = append(report.Steps, sim.report(step.Name, before, owners))
+		before = owners
+	}
+	return report, nil
+}
+
+// apply validates a step against the current nodes and moves the ring to its outcome
+func (sim *simulation) apply(step Step) error {
+	for _, spec := range step.Add {
+		if err := sim.addSpec(spec); err != nil {
+			return err
+		}
+	}
+	for range step.AddCount {
+		prefix := cmp.Or(step.AddZone, "node")
+		for {
+			sim.generated++
+			id := prefix + "-" + strconv.Itoa(sim.generated)
+			if _, ok := sim.nodes[id]; !ok {
+				sim.nodes[id] = NodeSpec{ID: id, Zone: step.AddZone, Weight: 1}
+				break
+			}
+		}
+	}
+	for _, id := range step.Remove {
+		if _, ok := sim.nodes[id]; !ok {
+			return fmt.Errorf("%w: cannot remove unknown node %s", ErrInvalidScenario, id)
+		}
+		delete(sim.nodes, id)
+	}
+	for _, id := range slices.Sorted(maps.Keys(step.Weights)) {
+		spec, ok := sim.nodes[id]
+		if !ok {
+			return fmt.Errorf("%w: cannot reweight unknown node %s", ErrInvalidScenario, id)
+		}
+		if step.Weights[id] < 1 {
+			return fmt.Errorf("%w: node %s has weight %d", ErrInvalidScenario, id, step.Weights[id])
+		}
+		spec.Weight = step.Weights[id]
+		sim.nodes[id] = spec
+	}
+	if zone := step.FailZone; zone != "" {
+		if sim.failed[zone] || !sim.hasZone(zone) {
+			return fmt.Errorf("%w: zone %q has no nodes to fail", ErrInvalidScenario, zone)
+		}
+		sim.failed[zone] = true
+	}
+	if zone := step.RestoreZone; zone != "" {
+		if !sim.failed[zone] {
+			return fmt.Errorf("%w: zone %q is not failed", ErrInvalidScenario, zone)
+		}
+		delete(sim.failed, zone)
+	}
+	return sim.sync()
+}
+
+// addSpec adds a node of the scenario, defaulting its weight to 1
+func (sim *simulation) addSpec(spec NodeSpec) error {
+	switch {
+	case spec.ID == "":
+		return fmt.Errorf("%w: node without an id", ErrInvalidScenario)
+	case spec.Weight < 0:
+		return fmt.Errorf("%w: node %s has weight %d", ErrInvalidScenario, spec.ID, spec.Weight)
+	}
+	if _, ok := sim.nodes[spec.ID]; ok {
+		return fmt.Errorf("%w: node %s is added twice", ErrInvalidScenario, spec.ID)
+	}
+	if spec.Weight == 0 {
+		spec.Weight = 1
+	}
+	sim.nodes[spec.ID] = spec
+	return nil
+}
+
+// hasZone reports whether any node of the scenario is in zone
+func (sim *simulation) hasZone(zone string) bool {
+	for _, spec := range sim.nodes {
+		if spec.Zone == zone {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+sync adds and removes points so that every node outside a failed zone has VNodes points
+per unit of weight and every other node has none. Like the hierarchy package, a changed
+weight only adds or removes the points at the end, and the whole step is one
+CompareAndApply.
+*/
+func (sim *simulation) sync() error {
+	ids := slices.Sorted(maps.Keys(sim.placed))
+	for id := range sim.nodes {
+		if _, ok := sim.placed[id]; !ok {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+
+	var changes []hashring.Change
+	for _, id := range ids {
+		want := 0
+		if spec, ok := sim.nodes[id]; ok && !sim.failed[spec.Zone] {
+			want = spec.Weight * sim.config.VNodes
+		}
+		have := sim.placed[id]
+		for i := want; i < have; i++ {
+			changes = append(changes, hashring.Change{Op: hashring.MembershipRemove, Node: newPoint(id, i)})
+		}
+		for i := have; i < want; i++ {
+			changes = append(changes, hashring.Change{Op: hashring.MembershipAdd, Node: newPoint(id, i)})
+		}
+		if want == 0 {
+			delete(sim.placed, id)
+		} else {
+			sim.placed[id] = want
+		}
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+	_, err := sim.ring.CompareAndApply(sim.ring.Epoch(), changes)
+	return err
+}
+
+func newPoint(id string, index int) *point {
+	return &point{id: strconv.Itoa(index) + "#" + id, node: id}
+}
+
+// owners returns the identifier of the node owning every key
+func (sim *simulation) owners(keys []string) ([]string, error) {
+	points := make([]hashring.CacheNode, len(keys))
+	if err := sim.ring.GetNodesForKeysInto(keys, points); err != nil {
+		return nil, err
+	}
+	owners := make([]string, len(keys))
+	for i, p := range points {
+		owners[i] = p.(*point).node
+	}
+	return owners, nil
+}
+
+// report summarizes the owners after a step, comparing them with the owners before it
+func (sim *simulation) report(name string, before, owners []string) StepReport {
+	r := StepReport{Name: name, Nodes: len(sim.placed), Loads: make(map[string]int, len(sim.placed))}
+	totalWeight := 0
+	for id, points := range sim.placed {
+		r.Points += points
+		r.Loads[id] = 0
+		totalWeight += sim.nodes[id].Weight
+	}
+
+	moved := 0
+	for i, owner := range owners {
+		r.Loads[owner]++
+		if before != nil && before[i] != owner {
+			moved++
+		}
+	}
+	r.Moved = float64(moved) / float64(len(owners))
+
+	for _, id := range slices.Sorted(maps.Keys(r.Loads)) {
+		load := r.Loads[id]
+		if load > r.PeakLoad || r.PeakNode == "" {
+			r.PeakLoad, r.PeakNode = load, id
+		}
+		fair := float64(len(owners)) * float64(sim.nodes[id].Weight) / float64(totalWeight)
+		r.Imbalance = max(r.Imbalance, float64(load)/fair)
+	}
+	return r
+}
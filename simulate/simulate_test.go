@@ -0,0 +1,201 @@
+package simulate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// testSeed keeps the placements of the tests stable, SipHash spreads the points evenly
+var testSeed = hashring.Seed{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16}
+
+func testScenario() Scenario {
+	return Scenario{
+		Nodes: []NodeSpec{
+			{ID: "cache-a", Zone: "east"},
+			{ID: "cache-b", Zone: "east"},
+			{ID: "cache-c", Zone: "west"},
+			{ID: "cache-d", Zone: "west"},
+		},
+		Steps: []Step{
+			{Name: "expand", AddCount: 2, AddZone: "central"},
+			{Name: "drop b", Remove: []string{"cache-b"}},
+			{Name: "double c", Weights: map[string]int{"cache-c": 2}},
+			{Name: "west down", FailZone: "west"},
+			{Name: "west back", RestoreZone: "west"},
+		},
+	}
+}
+
+func run(t *testing.T, s Scenario, keys []string, vnodes int) Report {
+	t.Helper()
+	report, err := Run(s, keys, SetVNodes(vnodes), SetHashFunction(hashring.SipHash(testSeed)))
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	return report
+}
+
+/*
+TestRun tests that every step reports the keys it moved and the resulting loads, that
+steps only move the keys they have to, and that more vnodes per node balance the load.
+*/
+func TestRun(t *testing.T) {
+	keys := SyntheticKeys(20000, 1)
+
+	t.Run("reports every step", func(t *testing.T) {
+		report := run(t, testScenario(), keys, 50)
+		if report.VNodes != 50 || report.Keys != len(keys) {
+			t.Errorf("Unexpected report header %d vnodes, %d keys", report.VNodes, report.Keys)
+		}
+
+		names := []string{"initial", "expand", "drop b", "double c", "west down", "west back"}
+		nodes := []int{4, 6, 5, 5, 3, 5}
+		points := []int{200, 300, 250, 300, 150, 300}
+		if len(report.Steps) != len(names) {
+			t.Fatalf("Expected %d steps, got %d", len(names), len(report.Steps))
+		}
+		for i, step := range report.Steps {
+			if step.Name != names[i] || step.Nodes != nodes[i] || step.Points != points[i] {
+				t.Errorf("Step %d: expected %s with %d nodes and %d points, got %s with %d and %d",
+					i, names[i], nodes[i], points[i], step.Name, step.Nodes, step.Points)
+			}
+			total := 0
+			for _, load := range step.Loads {
+				total += load
+			}
+			if total != len(keys) {
+				t.Errorf("Step %s: loads add up to %d, expected %d", step.Name, total, len(keys))
+			}
+			if step.PeakLoad != step.Loads[step.PeakNode] {
+				t.Errorf("Step %s: peak node %s has %d keys, not %d", step.Name, step.PeakNode, step.Loads[step.PeakNode], step.PeakLoad)
+			}
+		}
+		if report.Steps[0].Moved != 0 {
+			t.Errorf("Initial placement should move nothing, got %v", report.Steps[0].Moved)
+		}
+		if _, ok := report.Steps[1].Loads["central-1"]; !ok {
+			t.Errorf("Expected generated node central-1, got %v", report.Steps[1].Loads)
+		}
+	})
+
+	t.Run("steps only move the keys they have to", func(t *testing.T) {
+		report := run(t, testScenario(), keys, 50)
+		initial, expand, drop := report.Steps[0], report.Steps[1], report.Steps[2]
+
+		// Adding nodes only moves keys onto the new nodes
+		gained := expand.Loads["central-1"] + expand.Loads["central-2"]
+		if want := float64(gained) / float64(len(keys)); expand.Moved != want {
+			t.Errorf("Expand moved %v, expected exactly the new nodes' share %v", expand.Moved, want)
+		}
+		for id, load := range initial.Loads {
+			if expand.Loads[id] > load {
+				t.Errorf("Node %s gained keys when others were added: %d -> %d", id, load, expand.Loads[id])
+			}
+		}
+
+		// Removing a node only moves its own keys
+		if want := float64(expand.Loads["cache-b"]) / float64(len(keys)); drop.Moved != want {
+			t.Errorf("Drop moved %v, expected the removed node's share %v", drop.Moved, want)
+		}
+	})
+
+	t.Run("restoring a zone brings its keys back", func(t *testing.T) {
+		report := run(t, testScenario(), keys, 50)
+		before, down, back := report.Steps[3], report.Steps[4], report.Steps[5]
+		if _, ok := down.Loads["cache-c"]; ok {
+			t.Errorf("Failed node cache-c should have no load, got %v", down.Loads)
+		}
+		for id, load := range before.Loads {
+			if back.Loads[id] != load {
+				t.Errorf("Node %s has %d keys after the restore, expected %d", id, back.Loads[id], load)
+			}
+		}
+		if down.Moved != back.Moved {
+			t.Errorf("The restore should move back the %v that left, moved %v", down.Moved, back.Moved)
+		}
+	})
+
+	t.Run("weights scale the load", func(t *testing.T) {
+		report := run(t, testScenario(), keys, 100)
+		drop, double := report.Steps[2], report.Steps[3]
+		if double.Loads["cache-c"] <= drop.Loads["cache-c"] {
+			t.Errorf("Doubling the weight of cache-c should raise its load: %d -> %d", drop.Loads["cache-c"], double.Loads["cache-c"])
+		}
+		// The imbalance is measured against the weighted fair share
+		if double.Imbalance > 1.3 {
+			t.Errorf("Expected a weighted imbalance below 1.3 with 100 vnodes, got %v", double.Imbalance)
+		}
+	})
+
+	t.Run("more vnodes balance the load", func(t *testing.T) {
+		one := run(t, testScenario(), keys, 1)
+		many := run(t, testScenario(), keys, 200)
+		if many.Steps[1].Imbalance >= one.Steps[1].Imbalance {
+			t.Errorf("Expected 200 vnodes to balance better than 1: %v vs %v", many.Steps[1].Imbalance, one.Steps[1].Imbalance)
+		}
+		if many.Steps[1].Imbalance > 1.2 {
+			t.Errorf("Expected an imbalance below 1.2 with 200 vnodes, got %v", many.Steps[1].Imbalance)
+		}
+	})
+
+	t.Run("invalid steps", func(t *testing.T) {
+		nodes := []NodeSpec{{ID: "cache-a", Zone: "east"}}
+		for name, step := range map[string]Step{
+			"unknown node":    {Remove: []string{"cache-x"}},
+			"duplicate node":  {Add: []NodeSpec{{ID: "cache-a"}}},
+			"bad weight":      {Weights: map[string]int{"cache-a": 0}},
+			"empty zone":      {FailZone: "west"},
+			"restore healthy": {RestoreZone: "east"},
+		} {
+			_, err := Run(Scenario{Nodes: nodes, Steps: []Step{step}}, keys)
+			if !errors.Is(err, ErrInvalidScenario) {
+				t.Errorf("%s: expected ErrInvalidScenario, got %v", name, err)
+			}
+		}
+
+		_, err := Run(Scenario{Nodes: nodes, Steps: []Step{{FailZone: "east"}}}, keys)
+		if !errors.Is(err, hashring.ErrNoConnectedNodes) {
+			t.Errorf("Expected ErrNoConnectedNodes when every node is down, got %v", err)
+		}
+		if _, err := Run(Scenario{Nodes: nodes}, nil); !errors.Is(err, ErrNoKeys) {
+			t.Errorf("Expected ErrNoKeys, got %v", err)
+		}
+	})
+}
+
+// TestLoadScenario tests decoding a JSON scenario and reading a key population
+func TestLoadScenario(t *testing.T) {
+	t.Run("decodes a scenario", func(t *testing.T) {
+		s, err := LoadScenario(strings.NewReader(`{
+			"nodes": [{"id": "cache-a", "zone": "east", "weight": 2}],
+			"steps": [{"name": "grow", "add_count": 3, "add_zone": "west"}, {"fail_zone": "east"}]
+		}`))
+		if err != nil {
+			t.Fatalf("LoadScenario failed: %v", err)
+		}
+		if s.Nodes[0].Weight != 2 || s.Steps[0].AddCount != 3 || s.Steps[1].FailZone != "east" {
+			t.Errorf("Unexpected scenario %+v", s)
+		}
+	})
+
+	t.Run("rejects unknown fields", func(t *testing.T) {
+		_, err := LoadScenario(strings.NewReader(`{"nodes": [], "steps": [{"fail": "east"}]}`))
+		if !errors.Is(err, ErrInvalidScenario) {
+			t.Errorf("Expected ErrInvalidScenario, got %v", err)
+		}
+	})
+
+	t.Run("reads keys and generates them deterministically", func(t *testing.T) {
+		keys, err := ReadKeys(strings.NewReader("user:1\n\n  user:2  \n"))
+		if err != nil || len(keys) != 2 || keys[1] != "user:2" {
+			t.Errorf("Unexpected keys %q, %v", keys, err)
+		}
+		a, b := SyntheticKeys(100, 7), SyntheticKeys(100, 7)
+		if a[99] != b[99] || a[0] == a[1] {
+			t.Errorf("Synthetic keys should be stable and distinct, got %q %q", a[:2], b[99])
+		}
+	})
+}
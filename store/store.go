@@ -0,0 +1,540 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+/*
+Package store persists the membership of a HashRing so that a process restarts into the
+ring it had. Every AddNode, RemoveNode and state change made through a Store is appended
+to a checksummed write-ahead log before it is acknowledged, and the log is periodically
+compacted into a snapshot. On startup the snapshot is loaded, the log is replayed on top
+of it and the ring is rebuilt; a record torn by a crash in the middle of an append is
+detected by its checksum and cut off, while corruption anywhere else in the log is
+reported instead of silently dropping the records after it.
+*/
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+const (
+	logFileName      = "wal.log"
+	snapshotFileName = "snapshot.json"
+)
+
+// Global error variables which has all error types to return
+var (
+	ErrClosed          = errors.New("Store is closed")
+	ErrCorruptSnapshot = errors.New("Snapshot is corrupt")
+	ErrCorruptLog      = errors.New("Write-ahead log is corrupt")
+)
+
+// Entry is the persisted view of one node
+type Entry struct {
+	ID    string             `json:"id"`
+	Addr  string             `json:"addr"`
+	State hashring.NodeState `json:"state"`
+}
+
+/*
+Node is the CacheNode a Store adds to the ring by default. Lookups on the ring return
+*Node values, so callers can use Addr to reach the owner of a key.
+*/
+type Node struct {
+	ID   string
+	Addr string
+}
+
+func (n *Node) GetIdentifier() string {
+	return n.ID
+}
+
+// snapshot is the JSON document a compaction writes, covering every change up to LastSeq
+type snapshot struct {
+	LastSeq uint64  `json:"last_seq"`
+	Members []Entry `json:"members"`
+}
+
+type storeConfig struct {
+	NodeFactory      func(id, addr string) hashring.CacheNode
+	CompactThreshold int
+	SyncWrites       bool
+	Logger           *slog.Logger
+}
+
+// StoreConfigFn is a function type that modifies the storeConfig
+type StoreConfigFn func(*storeConfig)
+
+/*
+SetNodeFactory returns a StoreConfigFn that sets how the CacheNode for an id and address
+is built, both for new nodes and for nodes restored on startup. The default builds *Node.
+*/
+func SetNodeFactory(factory func(id, addr string) hashring.CacheNode) StoreConfigFn {
+	return func(config *storeConfig) {
+		config.NodeFactory = factory
+	}
+}
+
+/*
+SetCompactThreshold returns a StoreConfigFn that sets how many records the log may hold
+before it is compacted into a snapshot. Zero disables automatic compaction, Compact can
+still be called by hand. The default is 1000.
+*/
+func SetCompactThreshold(records int) StoreConfigFn {
+	return func(config *storeConfig) {
+		config.CompactThreshold = records
+	}
+}
+
+/*
+SetSyncWrites returns a StoreConfigFn that sets whether every append is flushed to stable
+storage with fsync before it is acknowledged. It is on by default; turning it off trades
+the last few changes on power loss for speed, a process crash still loses nothing.
+*/
+func SetSyncWrites(enabled bool) StoreConfigFn {
+	return func(config *storeConfig) {
+		config.SyncWrites = enabled
+	}
+}
+
+/*
+SetLogger returns a StoreConfigFn that sets where the store reports failed automatic
+compactions, slog.Default() by default and nil to drop them. A failed compaction does not fail the change that
+triggered it, the change is already durable, and compaction is retried on the next change.
+*/
+func SetLogger(logger *slog.Logger) StoreConfigFn {
+	return func(config *storeConfig) {
+		config.Logger = logger
+	}
+}
+
+/*
+Store is a durable membership store backing a HashRing. Fields:
+  - mu: Mutex serializing changes, so the log order is the order they hit the ring
+  - config: Node factory and compaction settings
+  - dir: Directory holding the log and the snapshot
+  - ring: Ring kept in sync with the persisted membership
+  - log: Write-ahead log, open for appending
+  - logSize: Length of the log up to the last complete record
+  - logRecords: Number of records in the log since the last compaction
+  - seq: Sequence number of the last change
+  - members: Persisted view of every node, keyed by identifier
+  - nodes: CacheNode on the ring for every node, keyed by identifier
+*/
+type Store struct {
+	mu         sync.Mutex
+	config     storeConfig
+	dir        string
+	ring       *hashring.HashRing
+	log        *os.File
+	logSize    int64
+	logRecords int
+	seq        uint64
+	members    map[string]Entry
+	nodes      map[string]hashring.CacheNode
+}
+
+/*
+StoreInit opens the store in dir, creating the directory if needed, and rebuilds ring
+from it: the snapshot is loaded, the log replayed on top of it and every persisted node
+added to ring with its persisted state. ring should be empty. A torn record at the end
+of the log is cut off, while a bad record with more data after it fails with
+ErrCorruptLog and leaves the log as it is for inspection. A corrupt snapshot fails with
+ErrCorruptSnapshot since snapshots are replaced atomically and should never be torn.
+*/
+func StoreInit(dir string, ring *hashring.HashRing, opts ...StoreConfigFn) (*Store, error) {
+	config := &storeConfig{
+		NodeFactory: func(id, addr string) hashring.CacheNode {
+			return &Node{ID: id, Addr: addr}
+		},
+		CompactThreshold: 1000,
+		SyncWrites:       true,
+		Logger:           slog.Default(),
+	}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	s := &Store{
+		config:  *config,
+		dir:     dir,
+		ring:    ring,
+		members: make(map[string]Entry),
+		nodes:   make(map[string]hashring.CacheNode),
+	}
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	if err := s.replayLog(); err != nil {
+		return nil, err
+	}
+
+	for _, entry := range s.sortedMembers() {
+		node := s.config.NodeFactory(entry.ID, entry.Addr)
+		if err := ring.AddNode(node); err != nil {
+			s.log.Close()
+			return nil, fmt.Errorf("restoring node %s: %w", entry.ID, err)
+		}
+		if entry.State != hashring.StateActive {
+			if err := ring.SetNodeState(entry.ID, entry.State); err != nil {
+				s.log.Close()
+				return nil, fmt.Errorf("restoring state of node %s: %w", entry.ID, err)
+			}
+		}
+		s.nodes[entry.ID] = node
+	}
+	return s, nil
+}
+
+// loadSnapshot loads the snapshot into members, if there is one
+func (s *Store) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptSnapshot, err)
+	}
+	for _, entry := range snap.Members {
+		s.members[entry.ID] = entry
+	}
+	s.seq = snap.LastSeq
+	return nil
+}
+
+/*
+replayLog opens the log, applies the records the snapshot does not cover yet and cuts off
+a torn record at its end, leaving the log open for appending. A log corrupt anywhere else
+is left untouched and fails with ErrCorruptLog.
+*/
+func (s *Store) replayLog() error {
+	f, err := os.OpenFile(filepath.Join(s.dir, logFileName), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	records, valid, err := readRecords(f, info.Size())
+	if err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	s.log, s.logSize = f, valid
+
+	for _, rec := range records {
+		s.logRecords++
+		// Records up to the snapshot are left over from a compaction that crashed before truncating
+		if rec.Seq <= s.seq {
+			continue
+		}
+		s.applyRecord(rec)
+		s.seq = rec.Seq
+	}
+	return nil
+}
+
+// applyRecord applies one logged change to members
+func (s *Store) applyRecord(rec record) {
+	switch rec.Op {
+	case opAdd:
+		s.members[rec.ID] = Entry{ID: rec.ID, Addr: rec.Addr, State: hashring.StateActive}
+	case opRemove:
+		delete(s.members, rec.ID)
+	case opState:
+		if entry, ok := s.members[rec.ID]; ok {
+			entry.State = rec.State
+			s.members[rec.ID] = entry
+		}
+	}
+}
+
+/*
+AddNode persists the addition of the node with the given identifier and address, then
+adds it to the ring. Returns hashring.ErrNodeExits if the node is already there. The
+change is on disk before the ring shows it; if it cannot be persisted the ring is left
+unchanged, and if the ring rejects it the record is dropped from the log again.
+*/
+func (s *Store) AddNode(id, addr string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrClosed
+	}
+	if _, ok := s.members[id]; ok {
+		return fmt.Errorf("%w: node %s", hashring.ErrNodeExits, id)
+	}
+
+	rec := record{Op: opAdd, ID: id, Addr: addr}
+	node := s.config.NodeFactory(id, addr)
+	if err := s.logAndApply(rec, func() error { return s.ring.AddNode(node) }); err != nil {
+		return err
+	}
+	s.nodes[id] = node
+	return nil
+}
+
+/*
+RemoveNode persists the removal of the node with the given identifier, then removes it
+from the ring. Returns hashring.ErrNodeNotFound if there is no such node.
+*/
+func (s *Store) RemoveNode(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrClosed
+	}
+	if _, ok := s.members[id]; !ok {
+		return fmt.Errorf("%w: %s", hashring.ErrNodeNotFound, id)
+	}
+
+	rec := record{Op: opRemove, ID: id}
+	if err := s.logAndApply(rec, func() error { return s.ring.RemoveNode(s.nodes[id]) }); err != nil {
+		return err
+	}
+	delete(s.nodes, id)
+	return nil
+}
+
+/*
+SetNodeState persists the new state of the node with the given identifier, then sets it
+on the ring. Returns hashring.ErrNodeNotFound if there is no such node and
+hashring.ErrUnknownNodeState for an invalid state.
+*/
+func (s *Store) SetNodeState(id string, state hashring.NodeState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrClosed
+	}
+	entry, ok := s.members[id]
+	if !ok {
+		return fmt.Errorf("%w: %s", hashring.ErrNodeNotFound, id)
+	}
+	if !state.Valid() {
+		return fmt.Errorf("%w: %q", hashring.ErrUnknownNodeState, state)
+	}
+	if entry.State == state {
+		return nil
+	}
+
+	rec := record{Op: opState, ID: id, State: state}
+	return s.logAndApply(rec, func() error { return s.ring.SetNodeState(id, state) })
+}
+
+/*
+logAndApply is the write-ahead step shared by every change: rec is appended to the log,
+and only once it is durable is apply run to change the ring, so readers of the ring never
+see a change that could still be lost. If apply fails the record is dropped from the log
+again. A failed compaction afterwards is logged rather than returned, since the change is
+already durable and live. Callers must hold s.mu.
+*/
+func (s *Store) logAndApply(rec record, apply func() error) error {
+	size := s.logSize
+	if err := s.append(rec); err != nil {
+		return err
+	}
+	if err := apply(); err != nil {
+		s.logSize = size
+		s.rewindLog()
+		s.seq--
+		s.logRecords--
+		return err
+	}
+	s.applyRecord(rec)
+
+	if err := s.maybeCompact(); err != nil && s.config.Logger != nil {
+		s.config.Logger.LogAttrs(context.Background(), slog.LevelWarn, "compaction failed, retrying on the next change",
+			slog.String("dir", s.dir),
+			slog.Int("records", s.logRecords),
+			slog.String("error", err.Error()),
+		)
+	}
+	return nil
+}
+
+// Members returns the persisted view of every node, sorted by identifier
+func (s *Store) Members() []Entry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sortedMembers()
+}
+
+// sortedMembers does the work of Members, callers must hold s.mu
+func (s *Store) sortedMembers() []Entry {
+	entries := make([]Entry, 0, len(s.members))
+	for _, entry := range s.members {
+		entries = append(entries, entry)
+	}
+	slices.SortFunc(entries, func(a, b Entry) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return entries
+}
+
+/*
+append writes rec to the log with the next sequence number. If the write fails half way
+the log is truncated back to the last complete record, so that a later append is not
+hidden behind a torn one.
+*/
+func (s *Store) append(rec record) error {
+	rec.Seq = s.seq + 1
+	data, err := encodeRecord(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := s.log.Write(data); err != nil {
+		s.rewindLog()
+		return err
+	}
+	if s.config.SyncWrites {
+		if err := s.log.Sync(); err != nil {
+			s.rewindLog()
+			return err
+		}
+	}
+	s.seq = rec.Seq
+	s.logSize += int64(len(data))
+	s.logRecords++
+	return nil
+}
+
+// rewindLog drops anything written after the last complete record
+func (s *Store) rewindLog() {
+	s.log.Truncate(s.logSize)
+	s.log.Seek(s.logSize, io.SeekStart)
+}
+
+// maybeCompact compacts the log once it holds more records than the threshold
+func (s *Store) maybeCompact() error {
+	if s.config.CompactThreshold <= 0 || s.logRecords < s.config.CompactThreshold {
+		return nil
+	}
+	return s.compact()
+}
+
+/*
+Compact writes the current membership to a new snapshot and empties the log. The snapshot
+replaces the old one atomically, and records left in the log by a crash before it is
+emptied are skipped on replay because the snapshot already covers their sequence numbers.
+*/
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrClosed
+	}
+	return s.compact()
+}
+
+func (s *Store) compact() error {
+	data, err := json.Marshal(snapshot{LastSeq: s.seq, Members: s.sortedMembers()})
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFileName), data); err != nil {
+		return err
+	}
+
+	if err := s.log.Truncate(0); err != nil {
+		return err
+	}
+	if _, err := s.log.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	s.logSize, s.logRecords = 0, 0
+	if s.config.SyncWrites {
+		return s.log.Sync()
+	}
+	return nil
+}
+
+// Close closes the log. The ring keeps its nodes, but further changes through the store fail.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.log == nil {
+		return ErrClosed
+	}
+	err := s.log.Close()
+	s.log = nil
+	return err
+}
+
+// writeFileAtomic replaces path with data through a synced temporary file and a rename
+func writeFileAtomic(path string, data []byte) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+
+	// Sync the directory so the rename itself survives a crash
+	dir, err := os.Open(filepath.Dir(path))
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
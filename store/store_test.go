@@ -0,0 +1,261 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+// openStore opens the store in dir over a fresh ring
+func openStore(t *testing.T, dir string, opts ...StoreConfigFn) (*Store, *hashring.HashRing) {
+	t.Helper()
+	ring := hashring.HashRingInit()
+	s, err := StoreInit(dir, ring, opts...)
+	if err != nil {
+		t.Fatalf("StoreInit failed: %v", err)
+	}
+	return s, ring
+}
+
+// populate adds node1..node5, removes node2 and drains node4
+func populate(t *testing.T, s *Store) {
+	t.Helper()
+	for _, id := range []string{"node1", "node2", "node3", "node4", "node5"} {
+		if err := s.AddNode(id, id+".local:11211"); err != nil {
+			t.Fatalf("AddNode %s failed: %v", id, err)
+		}
+	}
+	if err := s.RemoveNode("node2"); err != nil {
+		t.Fatalf("RemoveNode failed: %v", err)
+	}
+	if err := s.SetNodeState("node4", hashring.StateDraining); err != nil {
+		t.Fatalf("SetNodeState failed: %v", err)
+	}
+}
+
+// checkRing verifies that ring holds exactly what populate left behind
+func checkRing(t *testing.T, ring *hashring.HashRing, want uint64) {
+	t.Helper()
+	if got := ring.ContentHash(); got != want {
+		t.Errorf("Restored ring has content hash %d, expected %d", got, want)
+	}
+	if state, err := ring.NodeState("node4"); err != nil || state != hashring.StateDraining {
+		t.Errorf("Expected node4 to be restored as draining, got %q, %v", state, err)
+	}
+	if _, err := ring.NodeState("node2"); !errors.Is(err, hashring.ErrNodeNotFound) {
+		t.Errorf("Expected node2 to stay removed, got %v", err)
+	}
+	node, err := ring.GetNode("user:42")
+	if err != nil {
+		t.Fatalf("GetNode failed: %v", err)
+	}
+	if n := node.(*Node); n.Addr != n.ID+".local:11211" {
+		t.Errorf("Expected the address to be restored, got %q", n.Addr)
+	}
+}
+
+/*
+TestStore tests the durable membership store. It verifies that a reopened store rebuilds
+the exact ring from the log, from a snapshot, and from a snapshot plus the log left behind
+by a compaction that crashed half way, that a torn final record is cut off without losing
+the records before it, and that invalid changes are rejected without being logged.
+*/
+func TestStore(t *testing.T) {
+	t.Run("restart replays the log", func(t *testing.T) {
+		dir := t.TempDir()
+		s, ring := openStore(t, dir)
+		populate(t, s)
+		want := ring.ContentHash()
+		s.Close()
+
+		_, restored := openStore(t, dir)
+		checkRing(t, restored, want)
+	})
+
+	t.Run("restart loads the snapshot", func(t *testing.T) {
+		dir := t.TempDir()
+		s, ring := openStore(t, dir, SetCompactThreshold(3))
+		populate(t, s)
+		want := ring.ContentHash()
+		s.Close()
+
+		if _, err := os.Stat(filepath.Join(dir, snapshotFileName)); err != nil {
+			t.Fatalf("Expected a snapshot to be written: %v", err)
+		}
+		_, restored := openStore(t, dir)
+		checkRing(t, restored, want)
+	})
+
+	t.Run("records covered by the snapshot are skipped", func(t *testing.T) {
+		dir := t.TempDir()
+		s, ring := openStore(t, dir, SetCompactThreshold(0))
+		populate(t, s)
+		want := ring.ContentHash()
+
+		// Simulate a crash after the snapshot was written but before the log was emptied
+		logPath := filepath.Join(dir, logFileName)
+		leftover, err := os.ReadFile(logPath)
+		if err != nil {
+			t.Fatalf("Failed to read log: %v", err)
+		}
+		if err := s.Compact(); err != nil {
+			t.Fatalf("Compact failed: %v", err)
+		}
+		s.Close()
+		if err := os.WriteFile(logPath, leftover, 0o644); err != nil {
+			t.Fatalf("Failed to restore log: %v", err)
+		}
+
+		s, restored := openStore(t, dir)
+		checkRing(t, restored, want)
+
+		// New changes continue after the snapshot's sequence numbers
+		if err := s.AddNode("node6", "node6.local:11211"); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		want = restored.ContentHash()
+		s.Close()
+		_, restored = openStore(t, dir)
+		if restored.ContentHash() != want {
+			t.Error("Expected the change after the snapshot to survive a restart")
+		}
+	})
+
+	t.Run("torn final record is cut off", func(t *testing.T) {
+		dir := t.TempDir()
+		s, ring := openStore(t, dir)
+		populate(t, s)
+		want := ring.ContentHash()
+		s.Close()
+
+		// Append half of a record, as a crash in the middle of a write would
+		logPath := filepath.Join(dir, logFileName)
+		info, _ := os.Stat(logPath)
+		data, _ := encodeRecord(record{Seq: 99, Op: opAdd, ID: "node9", Addr: "node9.local:11211"})
+		f, _ := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o644)
+		f.Write(data[:len(data)/2])
+		f.Close()
+
+		s, restored := openStore(t, dir)
+		checkRing(t, restored, want)
+		if info2, _ := os.Stat(logPath); info2.Size() != info.Size() {
+			t.Errorf("Expected the log to be truncated to %d bytes, got %d", info.Size(), info2.Size())
+		}
+
+		// Appends after the cut are readable on the next restart
+		if err := s.AddNode("node9", "node9.local:11211"); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		s.Close()
+		_, restored = openStore(t, dir)
+		if _, err := restored.NodeState("node9"); err != nil {
+			t.Errorf("Expected node9 after restart, got %v", err)
+		}
+	})
+
+	t.Run("corrupt record in the middle fails to open", func(t *testing.T) {
+		dir := t.TempDir()
+		s, _ := openStore(t, dir)
+		populate(t, s)
+		s.Close()
+
+		// Flip a byte inside the first record's payload, the records after it are intact
+		logPath := filepath.Join(dir, logFileName)
+		data, _ := os.ReadFile(logPath)
+		data[recordHeaderSize+2] ^= 0x01
+		os.WriteFile(logPath, data, 0o644)
+
+		if _, err := StoreInit(dir, hashring.HashRingInit()); !errors.Is(err, ErrCorruptLog) {
+			t.Fatalf("Expected ErrCorruptLog, got %v", err)
+		}
+		if after, _ := os.ReadFile(logPath); !bytes.Equal(after, data) {
+			t.Errorf("Expected the corrupt log to be left untouched, it went from %d to %d bytes", len(data), len(after))
+		}
+	})
+
+	t.Run("invalid changes are rejected", func(t *testing.T) {
+		dir := t.TempDir()
+		s, _ := openStore(t, dir)
+		defer s.Close()
+		s.AddNode("node1", "node1.local:11211")
+
+		if err := s.AddNode("node1", "elsewhere:11211"); !errors.Is(err, hashring.ErrNodeExits) {
+			t.Errorf("Expected ErrNodeExits, got %v", err)
+		}
+		if err := s.RemoveNode("node9"); !errors.Is(err, hashring.ErrNodeNotFound) {
+			t.Errorf("Expected ErrNodeNotFound, got %v", err)
+		}
+		if err := s.SetNodeState("node1", "sleeping"); !errors.Is(err, hashring.ErrUnknownNodeState) {
+			t.Errorf("Expected ErrUnknownNodeState, got %v", err)
+		}
+		if s.logRecords != 1 {
+			t.Errorf("Expected only the valid change to be logged, got %d records", s.logRecords)
+		}
+	})
+
+	t.Run("change rejected by the ring is dropped from the log", func(t *testing.T) {
+		dir := t.TempDir()
+		s, ring := openStore(t, dir)
+		// The ring already holds node1, added behind the store's back
+		ring.AddNode(&Node{ID: "node1", Addr: "node1.local:11211"})
+
+		if err := s.AddNode("node1", "node1.local:11211"); !errors.Is(err, hashring.ErrNodeExits) {
+			t.Fatalf("Expected ErrNodeExits, got %v", err)
+		}
+		if err := s.AddNode("node2", "node2.local:11211"); err != nil {
+			t.Fatalf("AddNode failed: %v", err)
+		}
+		s.Close()
+
+		s, _ = openStore(t, dir)
+		if members := s.Members(); len(members) != 1 || members[0].ID != "node2" {
+			t.Errorf("Expected only node2 to be persisted, got %v", members)
+		}
+	})
+
+	t.Run("failed compaction does not fail the change", func(t *testing.T) {
+		dir := t.TempDir()
+		var logs bytes.Buffer
+		s, ring := openStore(t, dir, SetCompactThreshold(1), SetLogger(slog.New(slog.NewTextHandler(&logs, nil))))
+		// A directory where the temporary snapshot goes makes every compaction fail
+		os.Mkdir(filepath.Join(dir, snapshotFileName+".tmp"), 0o755)
+
+		if err := s.AddNode("node1", "node1.local:11211"); err != nil {
+			t.Fatalf("Expected the durable change to succeed, got %v", err)
+		}
+		if _, err := ring.NodeState("node1"); err != nil {
+			t.Errorf("Expected node1 on the ring, got %v", err)
+		}
+		if !strings.Contains(logs.String(), "compaction failed") {
+			t.Errorf("Expected the failed compaction to be logged, got %q", logs.String())
+		}
+		s.Close()
+
+		_, restored := openStore(t, dir)
+		if _, err := restored.NodeState("node1"); err != nil {
+			t.Errorf("Expected node1 after restart, got %v", err)
+		}
+	})
+
+	t.Run("closed store rejects changes", func(t *testing.T) {
+		s, _ := openStore(t, t.TempDir())
+		s.Close()
+		if err := s.AddNode("node1", "node1.local:11211"); err != ErrClosed {
+			t.Errorf("Expected ErrClosed, got %v", err)
+		}
+	})
+
+	t.Run("corrupt snapshot fails to open", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, snapshotFileName), []byte("{not json"), 0o644)
+		if _, err := StoreInit(dir, hashring.HashRingInit()); !errors.Is(err, ErrCorruptSnapshot) {
+			t.Errorf("Expected ErrCorruptSnapshot, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,150 @@
+/*
+Copyright (c) 2026 Atharva Mhaske
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all
+copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+SOFTWARE.
+*/
+
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"slices"
+
+	hashring "github.com/atharvamhaske/chash/hash-ring"
+)
+
+const (
+	// recordHeaderSize is the length and checksum prefix of every log record
+	recordHeaderSize = 8
+	// maxRecordSize bounds the payload length we trust, a larger length means a corrupt header
+	maxRecordSize = 1 << 20
+)
+
+var castagnoli = crc32.MakeTable(crc32.Castagnoli)
+
+// recordOp identifies the kind of change a log record holds
+type recordOp string
+
+const (
+	opAdd    recordOp = "add"
+	opRemove recordOp = "remove"
+	opState  recordOp = "state"
+)
+
+/*
+record is one change in the write-ahead log. Fields:
+  - Seq: Sequence number of the change, strictly increasing over the life of the store
+  - Op: Kind of change
+  - ID: Identifier of the node the change is about
+  - Addr: Address of the node, set for adds
+  - State: New state of the node, set for state changes
+*/
+type record struct {
+	Seq   uint64             `json:"seq"`
+	Op    recordOp           `json:"op"`
+	ID    string             `json:"id"`
+	Addr  string             `json:"addr,omitempty"`
+	State hashring.NodeState `json:"state,omitempty"`
+}
+
+/*
+encodeRecord frames a record for the log: a 4 byte big endian payload length, a 4 byte
+CRC-32C of the payload, and the JSON payload itself.
+*/
+func encodeRecord(rec record) ([]byte, error) {
+	payload, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, recordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, castagnoli))
+	copy(buf[recordHeaderSize:], payload)
+	return buf, nil
+}
+
+/*
+readRecords reads framed records from r, which holds size bytes, until the end of the log.
+A record that is cut short, fails its checksum or does not decode is what a crash in the
+middle of an append leaves behind, but only as the last record: it is accepted as torn if
+it reaches the end of the log or only zero bytes follow it, as some file systems leave
+after a crash. Returns the records read and the offset just past the last good one, where
+the log should be truncated before appending again. A bad record with data after it is
+corruption rather than a torn append and fails with ErrCorruptLog, since dropping it would
+silently drop every good record behind it.
+*/
+func readRecords(r io.Reader, size int64) ([]record, int64, error) {
+	reader := bufio.NewReader(r)
+	var records []record
+	var valid int64
+	header := make([]byte, recordHeaderSize)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+		length := binary.BigEndian.Uint32(header[0:4])
+		end := valid + recordHeaderSize + int64(length)
+		if length > maxRecordSize {
+			// The length is garbage, so only the header is known to be part of the record
+			return badRecord(reader, records, valid, valid+recordHeaderSize, size)
+		}
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(reader, payload); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return records, valid, nil
+			}
+			return nil, 0, err
+		}
+		var rec record
+		if crc32.Checksum(payload, castagnoli) != binary.BigEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &rec) != nil {
+			return badRecord(reader, records, valid, end, size)
+		}
+		records = append(records, rec)
+		valid = end
+	}
+}
+
+/*
+badRecord decides what a bad record starting at offset valid and claiming to end at end
+means: a torn final append when it reaches the end of the log or is followed by nothing
+but zeros, and corruption otherwise. reader is positioned at end, or before it when the
+record claims to reach past the end of the log.
+*/
+func badRecord(reader *bufio.Reader, records []record, valid, end, size int64) ([]record, int64, error) {
+	if end >= size {
+		return records, valid, nil
+	}
+	rest, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, err
+	}
+	if slices.ContainsFunc(rest, func(b byte) bool { return b != 0 }) {
+		return nil, 0, fmt.Errorf("%w: bad record at offset %d is followed by more data", ErrCorruptLog, valid)
+	}
+	return records, valid, nil
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+/*
+TestReadRecords tests the log framing. It verifies that encoded records read back in
+order, that a log cut short or corrupted at its end yields the records before the
+damage and the offset at which to truncate, and that damage with good records after it is
+reported as corruption.
+*/
+func TestReadRecords(t *testing.T) {
+	var log []byte
+	var offsets []int64
+	for i, id := range []string{"node1", "node2", "node3"} {
+		data, err := encodeRecord(record{Seq: uint64(i + 1), Op: opAdd, ID: id, Addr: id + ":11211"})
+		if err != nil {
+			t.Fatalf("encodeRecord failed: %v", err)
+		}
+		log = append(log, data...)
+		offsets = append(offsets, int64(len(log)))
+	}
+
+	t.Run("complete log", func(t *testing.T) {
+		records, valid, err := readRecords(bytes.NewReader(log), int64(len(log)))
+		if err != nil {
+			t.Fatalf("readRecords failed: %v", err)
+		}
+		if len(records) != 3 || valid != int64(len(log)) {
+			t.Fatalf("Expected 3 records up to %d, got %d up to %d", len(log), len(records), valid)
+		}
+		if records[2].ID != "node3" || records[2].Seq != 3 || records[2].Addr != "node3:11211" {
+			t.Errorf("Unexpected record %+v", records[2])
+		}
+	})
+
+	tests := []struct {
+		name   string
+		damage func([]byte) []byte
+	}{
+		{"torn payload", func(b []byte) []byte { return b[:len(b)-3] }},
+		{"torn header", func(b []byte) []byte { return b[:offsets[1]+5] }},
+		{"flipped bit", func(b []byte) []byte { b[len(b)-2] ^= 0x01; return b }},
+		{"garbage length", func(b []byte) []byte { return append(b[:offsets[1]], 0xff, 0xff, 0xff, 0xff, 0, 0, 0, 0) }},
+		{"zero filled tail", func(b []byte) []byte { return append(b[:offsets[1]], make([]byte, 64)...) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			damaged := tt.damage(append([]byte(nil), log...))
+			records, valid, err := readRecords(bytes.NewReader(damaged), int64(len(damaged)))
+			if err != nil {
+				t.Fatalf("readRecords failed: %v", err)
+			}
+			if len(records) != 2 || valid != offsets[1] {
+				t.Errorf("Expected 2 records up to %d, got %d up to %d", offsets[1], len(records), valid)
+			}
+		})
+	}
+
+	middle := []struct {
+		name   string
+		damage func([]byte) []byte
+	}{
+		{"flipped bit", func(b []byte) []byte { b[offsets[0]+recordHeaderSize+2] ^= 0x01; return b }},
+		{"garbage length", func(b []byte) []byte { b[offsets[0]] = 0x7f; return b }},
+	}
+	for _, tt := range middle {
+		t.Run("corrupt middle record with "+tt.name, func(t *testing.T) {
+			damaged := tt.damage(append([]byte(nil), log...))
+			if _, _, err := readRecords(bytes.NewReader(damaged), int64(len(damaged))); !errors.Is(err, ErrCorruptLog) {
+				t.Errorf("Expected ErrCorruptLog, got %v", err)
+			}
+		})
+	}
+}